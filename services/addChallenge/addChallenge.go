@@ -2,10 +2,16 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,64 +21,411 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/google/uuid"
 )
 
+// customChallenge is stored with dynamodbattribute, the dynamodbav tags are the attribute names
+// DynamoDB doesn't allow empty string sets, so empty sets are omitted
 type customChallenge struct {
-	ID              string   `json:"id"`
-	CreatedBy       string   `json:"createdBy"`
-	Name            string   `json:"name"`
-	Description     string   `json:"description"`
-	Public          bool     `json:"public"`
-	EquipmentNeeded []string `json:"equipmentNeeded"`
-	Difficulty      float32  `json:"difficulty"`
-	StartDate       string   `json:"startDate"`
-	EndDate         string   `json:"endDate"`
-	NumWorkoutGoal  int      `json:"numWorkoutGoal"`
-	WorkoutTypes    []string `json:"workoutTypes"`
-}
-
-func bodyValidation(c customChallenge) error {
+	ID              string   `json:"id" dynamodbav:"Id"`
+	CreatedBy       string   `json:"createdBy" dynamodbav:"CreatedBy"`
+	Name            string   `json:"name" dynamodbav:"Name"`
+	Description     string   `json:"description" dynamodbav:"Description"`
+	Public          bool     `json:"public" dynamodbav:"Public"`
+	EquipmentNeeded []string `json:"equipmentNeeded" dynamodbav:"EquipmentNeeded,stringset,omitempty"`
+	Difficulty      float32  `json:"difficulty" dynamodbav:"Difficulty"`
+	StartDate       string   `json:"startDate" dynamodbav:"StartDate"`
+	EndDate         string   `json:"endDate" dynamodbav:"EndDate"`
+	// NumWorkoutGoal is the legacy form of goalType workouts with a goalValue
+	// it's only stored for workout goals, see putItem
+	NumWorkoutGoal  int                 `json:"numWorkoutGoal" dynamodbav:"-"`
+	GoalType        string              `json:"goalType" dynamodbav:"GoalType"`
+	GoalValue       int                 `json:"goalValue" dynamodbav:"GoalValue"`
+	SubGoals        shared.SubGoalsBlob `json:"subGoals" dynamodbav:"SubGoals,omitempty"`
+	WorkoutTypes    []string            `json:"workoutTypes" dynamodbav:"WorkoutTypes,stringset,omitempty"`
+	Tags            []string            `json:"tags" dynamodbav:"Tags,stringset,omitempty"`
+	InvitedUsers    []string            `json:"invitedUsers" dynamodbav:"InvitedUsers,stringset,omitempty"`
+	CreatedDate     string              `json:"createdDate" dynamodbav:"CreatedDate"`
+	UpdatedDate     string              `json:"updatedDate" dynamodbav:"UpdatedDate"`
+	SourceProgramID string              `json:"sourceProgramId,omitempty" dynamodbav:"SourceProgramId,omitempty"`
+	// Recurrence is how often services/recurChallenges re-creates the challenge once it ends
+	Recurrence string `json:"recurrence" dynamodbav:"Recurrence"`
+	// Warning is returned when the challenge is valid but looks unreasonable, it isn't stored
+	Warning string `json:"warning,omitempty" dynamodbav:"-"`
+	// CloneFrom is the id of a challenge to copy, fields in the request body override the copied fields
+	// it can also be set with the challengeId path param, POST /challenges/{challengeId}/clone
+	CloneFrom string `json:"cloneFrom,omitempty" dynamodbav:"-"`
+	// IdempotencyKey is from the Idempotency-Key header and idempotencyHash is a hash of the request body
+	// neither is returned
+	IdempotencyKey  string `json:"-" dynamodbav:"IdempotencyKey,omitempty"`
+	idempotencyHash string
+	// Timezone is the IANA time zone used to determine today's date
+	// defaults to the X-Timezone header, then UTC
+	Timezone string `json:"timezone,omitempty" dynamodbav:"-"`
+}
+
+// Allowed range of a challenge's difficulty
+const (
+	minDifficulty = 0.1
+	maxDifficulty = 10.0
+)
+
+// Limits on a challenge's tags
+const (
+	maxTags      = 10
+	maxTagLength = 30
+)
+
+// idempotencyKeyTTL is how long an Idempotency-Key is honored for
+const idempotencyKeyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength is the max length of the Idempotency-Key header
+const maxIdempotencyKeyLength = 255
+
+// maxInvitedUsers is the max number of users that can be invited to a challenge
+const maxInvitedUsers = 50
+
+// tagPattern matches lowercase slugs, ex) low-impact
+var tagPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// maxWorkoutsPerDay is the number of workouts per day above which a workouts goal is flagged as unreasonable
+const maxWorkoutsPerDay = 3
+
+// maxMinutesPerDay is the number of minutes per day above which a minutes goal is flagged as unreasonable
+const maxMinutesPerDay = 240
+
+// defaultMaxChallengeDays is used when the max_challenge_days env var isn't set
+const defaultMaxChallengeDays = 365
+
+// getMaxChallengeDays returns the max number of days a challenge can span
+func getMaxChallengeDays() int {
+	maxDaysStr, exists := os.LookupEnv("max_challenge_days")
+	if !exists {
+		return defaultMaxChallengeDays
+	}
+	maxDays, err := strconv.Atoi(strings.TrimSpace(maxDaysStr))
+	if err != nil || maxDays < 1 {
+		return defaultMaxChallengeDays
+	}
+
+	return maxDays
+}
+
+// dateFormat is the format dates are stored in
+const dateFormat = shared.DateFormat
+
+// acceptedDateFormats are the formats startDate and endDate can be sent in
+var acceptedDateFormats = []string{dateFormat, time.RFC3339}
+
+// parseDate attempts to parse date with each of the accepted formats
+func parseDate(date string) (time.Time, error) {
+	for _, layout := range acceptedDateFormats {
+		if t, err := time.Parse(layout, date); err == nil {
+			// Only the date portion is used, so drop the time of day
+			return time.Parse(dateFormat, t.Format(dateFormat))
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unable to parse date %s", date)
+}
+
+// today returns the current date in loc as midnight UTC so it can be compared to parsed dates
+func today(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// bodyValidation validates the request body and normalizes the dates to dateFormat
+// every invalid field is returned rather than stopping at the first one
+func bodyValidation(c *customChallenge) []shared.FieldError {
+	errs := []shared.FieldError{}
+	addErr := func(field string, err error) {
+		errs = append(errs, shared.FieldError{Field: field, Message: err.Error()})
+	}
+
 	// Validation on request body
+	c.Name = shared.SanitizeText(c.Name)
+	c.Description = shared.SanitizeText(c.Description)
+	c.EquipmentNeeded = shared.SanitizeEquipment(c.EquipmentNeeded)
 	if c.Name == "" {
-		return errors.New("name is required in request body")
+		addErr("name", errors.New("name is required in request body"))
 	}
-	if c.Difficulty <= 0.0 {
-		return errors.New("difficulty must be a number greater than 0")
+	errs = append(errs, shared.TextLimitErrors(c.Name, c.Description, c.EquipmentNeeded)...)
+	if c.Difficulty < minDifficulty || c.Difficulty > maxDifficulty {
+		addErr("difficulty", fmt.Errorf("difficulty must be a number between %.1f and %.1f", minDifficulty, maxDifficulty))
 	}
-	if c.NumWorkoutGoal < 1 {
-		return errors.New("numWorkoutGoal must be a number greater than 0")
+	// Difficulty is stored with one decimal place
+	c.Difficulty = float32(math.Round(float64(c.Difficulty)*10) / 10)
+	goalErr := goalValidation(c)
+	if goalErr != nil && c.GoalType != shared.GoalTypeWorkouts && c.GoalType != shared.GoalTypeMinutes {
+		addErr("goalType", goalErr)
+	} else if goalErr != nil {
+		addErr("goalValue", goalErr)
+	}
+	if err := datesValidation(c); err != nil {
+		addErr(err.field, err.err)
+	}
+	// WorkoutTypes are stored as lowercase category slugs without duplicates
+	workoutTypes := []string{}
+	seen := map[string]bool{}
+	for _, wt := range c.WorkoutTypes {
+		wt = strings.ToLower(strings.TrimSpace(wt))
+		if wt == "" || seen[wt] {
+			continue
+		}
+		seen[wt] = true
+		workoutTypes = append(workoutTypes, wt)
+	}
+	c.WorkoutTypes = workoutTypes
+	if len(c.WorkoutTypes) < 1 {
+		addErr("workoutTypes", errors.New("workoutTypes must not be empty"))
+	}
+	// SubGoals are checked against the goal and workoutTypes, so they can only be validated when those are valid
+	if goalErr == nil && len(c.WorkoutTypes) > 0 {
+		if err := subGoalsValidation(c); err != nil {
+			addErr("subGoals", err)
+		}
+	}
+	if err := tagsValidation(c); err != nil {
+		addErr("tags", err)
+	}
+	if err := invitedUsersValidation(c); err != nil {
+		addErr("invitedUsers", err)
+	}
+	c.Recurrence = strings.ToLower(strings.TrimSpace(c.Recurrence))
+	if c.Recurrence == "" {
+		c.Recurrence = shared.RecurrenceNone
+	}
+	if c.Recurrence != shared.RecurrenceNone && c.Recurrence != shared.RecurrenceWeekly && c.Recurrence != shared.RecurrenceMonthly {
+		addErr("recurrence", fmt.Errorf("recurrence must be one of: %s, %s, %s", shared.RecurrenceNone, shared.RecurrenceWeekly, shared.RecurrenceMonthly))
+	}
+
+	return errs
+}
+
+// dateError is a validation error for one of the date fields
+type dateError struct {
+	field string
+	err   error
+}
+
+// datesValidation validates the timezone, startDate and endDate and normalizes the dates to dateFormat
+// the dates depend on each other, so only the first invalid one is returned
+func datesValidation(c *customChallenge) *dateError {
+	loc := time.UTC
+	if c.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(c.Timezone)
+		if err != nil {
+			return &dateError{"timezone", fmt.Errorf("timezone %s is not a valid IANA time zone", c.Timezone)}
+		}
 	}
 	if c.StartDate == "" {
-		return errors.New("startDate is required in request body")
+		return &dateError{"startDate", errors.New("startDate is required in request body")}
 	}
-	sDate, err := time.Parse("2006-01-02", c.StartDate)
+	sDate, err := parseDate(c.StartDate)
 	if err != nil {
-		return errors.New("startDate must be in the format of YYYY-MM-DD")
+		return &dateError{"startDate", errors.New("startDate must be in the format of YYYY-MM-DD or RFC3339")}
 	}
-	if sDate.Before(time.Now()) {
-		// StartDate must be after the current date
-		return errors.New("startDate must not be before today")
+	c.StartDate = sDate.Format(dateFormat)
+	if sDate.Before(today(loc)) {
+		// StartDate must be on or after the current date in the user's time zone
+		return &dateError{"startDate", errors.New("startDate must not be before today")}
 	}
 	if c.EndDate == "" {
-		return errors.New("endDate is required in request body")
+		return &dateError{"endDate", errors.New("endDate is required in request body")}
 	}
-	eDate, err := time.Parse("2006-01-02", c.EndDate)
+	eDate, err := parseDate(c.EndDate)
 	if err != nil {
-		return errors.New("endDate must be in the format of YYYY-MM-DD")
+		return &dateError{"endDate", errors.New("endDate must be in the format of YYYY-MM-DD or RFC3339")}
 	}
+	c.EndDate = eDate.Format(dateFormat)
 	if eDate.Before(sDate) {
 		// EndDate must be after the StartDate
-		return errors.New("endDate must not be before startDate")
+		return &dateError{"endDate", errors.New("endDate must not be before startDate")}
 	}
-	if len(c.WorkoutTypes) < 1 {
-		return errors.New("workoutTypes must not be empty")
+	// StartDate and EndDate are both inclusive, so a one day challenge has the same start and end date
+	numDays := int(eDate.Sub(sDate).Hours()/24) + 1
+	if maxDays := getMaxChallengeDays(); numDays > maxDays {
+		return &dateError{"endDate", fmt.Errorf("challenge must not be longer than %d days, startDate to endDate spans %d days", maxDays, numDays)}
+	}
+
+	return nil
+}
+
+// subGoalsValidation validates each sub-goal is for one of the challenge's workout types
+// and the sub-goals fit within the main goal
+// workoutTypes and the goal must already be normalized
+func subGoalsValidation(c *customChallenge) error {
+	if c.SubGoals == nil {
+		c.SubGoals = []shared.SubGoal{}
+	}
+
+	total := 0
+	seen := map[string]bool{}
+	for idx, sg := range c.SubGoals {
+		sg.WorkoutType = strings.ToLower(strings.TrimSpace(sg.WorkoutType))
+		valid := false
+		for _, wt := range c.WorkoutTypes {
+			if sg.WorkoutType == wt {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("subGoal workoutType %s must be one of the challenge's workoutTypes", sg.WorkoutType)
+		}
+		if seen[sg.WorkoutType] {
+			return fmt.Errorf("only one subGoal is allowed for workoutType %s", sg.WorkoutType)
+		}
+		if sg.Count < 1 {
+			return errors.New("subGoal count must be a number greater than 0")
+		}
+		seen[sg.WorkoutType] = true
+		total += sg.Count
+		c.SubGoals[idx] = sg
+	}
+	if total > c.GoalValue {
+		return fmt.Errorf("subGoals add up to %d which is more than the goalValue of %d", total, c.GoalValue)
+	}
+
+	return nil
+}
+
+// tagsValidation validates the tags and normalizes them to lowercase without duplicates
+func tagsValidation(c *customChallenge) error {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, t := range c.Tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > maxTagLength {
+			return fmt.Errorf("tags must not be longer than %d characters", maxTagLength)
+		}
+		if !tagPattern.MatchString(t) {
+			return fmt.Errorf("tag %s is invalid, tags must be slugs of letters, numbers and dashes", t)
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxTags {
+		return fmt.Errorf("challenge must not have more than %d tags", maxTags)
+	}
+	c.Tags = tags
+
+	return nil
+}
+
+// invitedUsersValidation validates the invited users and removes duplicates
+// c.CreatedBy must already be set
+func invitedUsersValidation(c *customChallenge) error {
+	invitedUsers := []string{}
+	seen := map[string]bool{}
+	for _, u := range c.InvitedUsers {
+		u = strings.TrimSpace(u)
+		if u == "" || seen[u] {
+			continue
+		}
+		if u == c.CreatedBy {
+			return errors.New("invitedUsers must not include the challenge creator")
+		}
+		seen[u] = true
+		invitedUsers = append(invitedUsers, u)
+	}
+	if len(invitedUsers) > maxInvitedUsers {
+		return fmt.Errorf("challenge must not have more than %d invitedUsers", maxInvitedUsers)
+	}
+	c.InvitedUsers = invitedUsers
+
+	return nil
+}
+
+// goalValidation validates goalType and goalValue
+// numWorkoutGoal is mapped onto goalType workouts for backward compatibility
+func goalValidation(c *customChallenge) error {
+	c.GoalType = strings.ToLower(strings.TrimSpace(c.GoalType))
+	if c.GoalType == "" {
+		c.GoalType = shared.GoalTypeWorkouts
+	}
+
+	switch c.GoalType {
+	case shared.GoalTypeWorkouts:
+		if c.GoalValue == 0 {
+			c.GoalValue = c.NumWorkoutGoal
+		} else if c.NumWorkoutGoal != 0 && c.NumWorkoutGoal != c.GoalValue {
+			return errors.New("numWorkoutGoal and goalValue must match when both are set")
+		}
+		if c.GoalValue < 1 {
+			return errors.New("goalValue must be a number greater than 0")
+		}
+		c.NumWorkoutGoal = c.GoalValue
+	case shared.GoalTypeMinutes:
+		if c.NumWorkoutGoal != 0 {
+			return fmt.Errorf("numWorkoutGoal can only be used with goalType %s", shared.GoalTypeWorkouts)
+		}
+		if c.GoalValue < 1 {
+			return errors.New("goalValue must be a number greater than 0")
+		}
+	default:
+		return fmt.Errorf("goalType must be %s or %s", shared.GoalTypeWorkouts, shared.GoalTypeMinutes)
+	}
+
+	return nil
+}
+
+// getCategorySlugs gets the slugs workoutTypes are validated against, tests replace it since the slugs are cached
+var getCategorySlugs = shared.GetCategorySlugs
+
+// workoutTypesValidation verifies each workout type is a Peloton category slug
+// if the categories can't be retrieved from Peloton, the workout types are accepted as is
+func workoutTypesValidation(c customChallenge) error {
+	slugs, err := getCategorySlugs()
+	if err != nil {
+		log.Printf("Unable to get categories, skipping workoutTypes validation: %s", err)
+		return nil
+	}
+
+	for _, wt := range c.WorkoutTypes {
+		valid := false
+		for _, slug := range slugs {
+			if wt == slug {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("workoutType %s is invalid, must be one of: %s", wt, strings.Join(slugs, ", "))
+		}
 	}
 
 	return nil
 }
 
-func nameValidation(c customChallenge, tableName string, db *dynamodb.DynamoDB) (int, error) {
+// goalWarning returns a warning if the goal is unreasonable for the length of the challenge
+// dates and goal must already be validated by bodyValidation
+func goalWarning(c customChallenge) string {
+	sDate, _ := time.Parse(dateFormat, c.StartDate)
+	eDate, _ := time.Parse(dateFormat, c.EndDate)
+	numDays := int(eDate.Sub(sDate).Hours()/24) + 1
+
+	if c.GoalType == shared.GoalTypeMinutes && c.GoalValue > numDays*maxMinutesPerDay {
+		return fmt.Sprintf("goalValue of %d minutes is more than %d minutes per day over %d days", c.GoalValue, maxMinutesPerDay, numDays)
+	}
+	if c.GoalType == shared.GoalTypeWorkouts && c.GoalValue > numDays*maxWorkoutsPerDay {
+		return fmt.Sprintf("goalValue of %d workouts is more than %d workouts per day over %d days", c.GoalValue, maxWorkoutsPerDay, numDays)
+	}
+
+	return ""
+}
+
+// nameValidation verifies the challenge name is unique, ignoring case and whitespace
+// challenges created before NameKey existed are still matched on their exact Name
+// soft deleted challenges don't hold their name
+func nameValidation(c customChallenge, tableName string, db dynamodbiface.DynamoDBAPI) (int, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(tableName),
 		ExpressionAttributeNames: map[string]*string{
@@ -82,51 +435,147 @@ func nameValidation(c customChallenge, tableName string, db *dynamodb.DynamoDB)
 	if c.Public {
 		// If c.Public is true, the name must be unique for all public challenges
 		scanInput.ExpressionAttributeNames["#P"] = aws.String("Public")
-		scanInput.FilterExpression = aws.String("#N = :name and #P = :public")
+		scanInput.FilterExpression = aws.String("(NameKey = :nameKey or #N = :name) and #P = :public and " + shared.NotDeletedFilter)
 		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
-			":name":   {S: aws.String(c.Name)},
-			":public": {BOOL: aws.Bool(true)},
+			":nameKey": {S: aws.String(shared.NameKey(c.Name))},
+			":name":    {S: aws.String(c.Name)},
+			":public":  {BOOL: aws.Bool(true)},
 		}
 	} else {
 		// else, the name must be unique for the user's challenges
-		scanInput.FilterExpression = aws.String("#N = :name and CreatedBy = :createdBy")
+		scanInput.FilterExpression = aws.String("(NameKey = :nameKey or #N = :name) and CreatedBy = :createdBy and " + shared.NotDeletedFilter)
 		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":nameKey":   {S: aws.String(shared.NameKey(c.Name))},
 			":name":      {S: aws.String(c.Name)},
 			":createdBy": {S: aws.String(c.CreatedBy)},
 		}
 	}
-	scanOutput, err := db.Scan(scanInput)
+	items, err := shared.ScanAll(db, scanInput)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Unable to get existing challenges: %s", err.Error())
 	}
 
-	// If the Scan call returns any items, then that name can't be used
-	if len(scanOutput.Items) > 0 {
+	// If the scan returns any items, then that name can't be used
+	if len(items) > 0 {
 		return http.StatusBadRequest, fmt.Errorf("A challenge with the name %s already exists", c.Name)
 	}
 
 	return -1, nil
 }
 
-func putItem(c customChallenge, tableName string, db *dynamodb.DynamoDB) error {
-	itemToPut := map[string]*dynamodb.AttributeValue{
-		"Id":              {S: aws.String(c.ID)},
-		"CreatedBy":       {S: aws.String(c.CreatedBy)},
-		"Name":            {S: aws.String(c.Name)},
-		"Description":     {S: aws.String(c.Description)},
-		"Public":          {BOOL: aws.Bool(c.Public)},
-		"EquipmentNeeded": {SS: aws.StringSlice(c.EquipmentNeeded)},
-		"Difficulty":      {N: aws.String(fmt.Sprintf("%.1f", c.Difficulty))},
-		"StartDate":       {S: aws.String(c.StartDate)},
-		"EndDate":         {S: aws.String(c.EndDate)},
-		"NumWorkoutGoal":  {N: aws.String(strconv.Itoa(c.NumWorkoutGoal))},
-		"WorkoutTypes":    {SS: aws.StringSlice(c.WorkoutTypes)},
+// cloneChallenge copies the source challenge into a new challenge then applies the request body over it
+// the source must be visible to the user, dates aren't copied so they must be in the request body
+func cloneChallenge(sourceID, userID, body, tableName string, db dynamodbiface.DynamoDBAPI) (customChallenge, int, error) {
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(sourceID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return customChallenge{}, http.StatusInternalServerError, fmt.Errorf("Unable to get challenge: %s", err)
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return customChallenge{}, http.StatusNotFound, fmt.Errorf("Unable to find challenge %s", sourceID)
+	}
+
+	source, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return customChallenge{}, http.StatusInternalServerError, err
+	}
+	if !source.VisibleTo(userID) {
+		// It's reported as not found so the ids of private challenges can't be probed
+		return customChallenge{}, http.StatusNotFound, fmt.Errorf("Unable to find challenge %s", sourceID)
+	}
+	// Invited users can see a private challenge but only its creator can clone it
+	if !source.Public && source.CreatedBy != userID {
+		return customChallenge{}, http.StatusForbidden, errors.New("Only public challenges or your own challenges can be cloned")
+	}
+
+	// The goal is copied as goalType and goalValue so overriding either one doesn't conflict with numWorkoutGoal
+	c := customChallenge{
+		Name:            fmt.Sprintf("%s (copy)", source.Name),
+		Description:     source.Description,
+		EquipmentNeeded: source.EquipmentNeeded,
+		Difficulty:      source.Difficulty,
+		GoalType:        source.GoalType,
+		GoalValue:       source.GoalValue,
+		SubGoals:        source.SubGoals,
+		WorkoutTypes:    source.WorkoutTypes,
+	}
+	err = json.Unmarshal([]byte(body), &c)
+	if err != nil {
+		return customChallenge{}, http.StatusBadRequest, errors.New("Invalid request body")
+	}
+	c.CloneFrom = sourceID
+
+	return c, -1, nil
+}
+
+// bodyHash returns a hash of the request body, used to detect an Idempotency-Key reused with a different body
+func bodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotentReplay looks for a challenge the user created with the same Idempotency-Key in the last 24 hours
+// the challenge is returned if the request body matches, a 409 is returned if it doesn't
+func idempotentReplay(key, userID, body, tableName string, db dynamodbiface.DynamoDBAPI) (*shared.Challenge, int, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(tableName),
+		FilterExpression: aws.String("IdempotencyKey = :key and CreatedBy = :createdBy and IdempotencyDate >= :since and " + shared.NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":key":       {S: aws.String(key)},
+			":createdBy": {S: aws.String(userID)},
+			":since":     {S: aws.String(time.Now().UTC().Add(-idempotencyKeyTTL).Format(time.RFC3339))},
+		},
+	}
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Unable to get existing challenges: %s", err.Error())
+	}
+	if len(items) == 0 {
+		return nil, -1, nil
+	}
+
+	item := items[0]
+	if item["IdempotencyHash"] == nil || item["IdempotencyHash"].S == nil || *item["IdempotencyHash"].S != bodyHash(body) {
+		return nil, http.StatusConflict, errors.New("Idempotency-Key was already used with a different request body")
+	}
+
+	challenge, err := shared.FormatChallenge(item)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return &challenge, -1, nil
+}
+
+func putItem(c customChallenge, tableName string, db dynamodbiface.DynamoDBAPI) error {
+	itemToPut, err := dynamodbattribute.MarshalMap(c)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal custom challenge: %s", err)
+	}
+	itemToPut["Type"] = &dynamodb.AttributeValue{S: aws.String(shared.ItemTypeChallenge)}
+	itemToPut["NameKey"] = &dynamodb.AttributeValue{S: aws.String(shared.NameKey(c.Name))}
+	// Only public challenges are in the public index
+	if c.Public {
+		itemToPut["Visibility"] = &dynamodb.AttributeValue{S: aws.String(shared.VisibilityPublic)}
+	}
+	// NumWorkoutGoal is still stored for workout goals so older readers keep working
+	if c.GoalType == shared.GoalTypeWorkouts {
+		itemToPut["NumWorkoutGoal"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(c.NumWorkoutGoal))}
+	}
+	if c.IdempotencyKey != "" {
+		itemToPut["IdempotencyHash"] = &dynamodb.AttributeValue{S: aws.String(c.idempotencyHash)}
+		itemToPut["IdempotencyDate"] = &dynamodb.AttributeValue{S: aws.String(time.Now().UTC().Format(time.RFC3339))}
 	}
 	putInput := &dynamodb.PutItemInput{
 		TableName: aws.String(tableName),
 		Item:      itemToPut,
 	}
-	_, err := db.PutItem(putInput)
+	_, err = db.PutItem(putInput)
 	if err != nil {
 		return fmt.Errorf("Unable to save custom challenge: %s", err.Error())
 	}
@@ -136,98 +585,117 @@ func putItem(c customChallenge, tableName string, db *dynamodb.DynamoDB) error {
 
 func addChallenge(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	// Get UserID header
-	userID, ok := request.Headers["UserID"]
+	userID, ok := shared.GetHeader(request.Headers, "UserID")
 	userID = strings.TrimSpace(userID)
 	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
 	}
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 		}, err
 	}
 
+	// Check for query parameters
+	// dryRun - if true, the request is validated but not saved
+	dryRun := false
+	if dryRunStr, ok := request.QueryStringParameters["dryRun"]; ok {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, "dryRun must be true or false"), nil
+		}
+	}
+
 	// Parse request body
 	c := customChallenge{}
 	err = json.Unmarshal([]byte(request.Body), &c)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Invalid request body"
-		}`, http.StatusBadRequest)
+		return shared.ErrorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	db := shared.GetDB(tableRegion)
+
+	// A retry with the same Idempotency-Key returns the challenge created by the first request
+	idempotencyKey, _ := shared.GetHeader(request.Headers, "Idempotency-Key")
+	idempotencyKey = strings.TrimSpace(idempotencyKey)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Idempotency-Key must not be longer than %d characters", maxIdempotencyKeyLength)), nil
+	}
+	if idempotencyKey != "" {
+		existing, returnCode, err := idempotentReplay(idempotencyKey, userID, request.Body, tableName, db)
+		if err != nil {
+			return shared.ErrorResponse(returnCode, err.Error()), nil
+		}
+		if existing != nil {
+			return shared.JSONResponse(http.StatusOK, existing)
+		}
+	}
+
+	// Clone an existing challenge if requested
+	cloneFrom := strings.TrimSpace(c.CloneFrom)
+	if pathID := strings.TrimSpace(request.PathParameters["challengeId"]); pathID != "" {
+		cloneFrom = pathID
+	}
+	if cloneFrom != "" {
+		if err := shared.ValidateID("cloneFrom", cloneFrom); err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		var returnCode int
+		c, returnCode, err = cloneChallenge(cloneFrom, userID, request.Body, tableName, db)
+		if err != nil {
+			return shared.ErrorResponse(returnCode, err.Error()), nil
+		}
 	}
 
 	c.ID = uuid.New().String()
 	c.CreatedBy = userID
+	c.IdempotencyKey = idempotencyKey
+	c.idempotencyHash = bodyHash(request.Body)
+	c.SourceProgramID = strings.TrimSpace(c.SourceProgramID)
+	c.CreatedDate = time.Now().Format(time.RFC3339)
+	c.UpdatedDate = c.CreatedDate
 
-	err = bodyValidation(c)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusBadRequest, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	if c.Timezone == "" {
+		tz, _ := shared.GetHeader(request.Headers, "X-Timezone")
+		c.Timezone = strings.TrimSpace(tz)
 	}
 
-	db := shared.GetDB(tableRegion)
+	if c.EquipmentNeeded == nil {
+		c.EquipmentNeeded = []string{}
+	}
 
-	if returnCode, err := nameValidation(c, tableName, db); err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, returnCode, err.Error())
+	if errs := bodyValidation(&c); len(errs) > 0 {
+		return shared.ValidationErrorResponse(errs), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: returnCode,
-			Body:       errBody,
-		}, nil
+	if err := workoutTypesValidation(c); err != nil {
+		return shared.ValidationErrorResponse([]shared.FieldError{{Field: "workoutTypes", Message: err.Error()}}), nil
 	}
 
-	err = putItem(c, tableName, db)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusInternalServerError, err.Error())
+	c.Warning = goalWarning(c)
 
+	if returnCode, err := nameValidation(c, tableName, db); err != nil {
+		return shared.ErrorResponse(returnCode, err.Error()), nil
+	}
+
+	// Only validate the request if dryRun is set
+	if dryRun {
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
+			StatusCode: http.StatusOK,
+			Body:       `{"valid": true}`,
 		}, nil
 	}
 
-	reply, err := json.Marshal(c)
+	err = putItem(c, tableName, db)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	return shared.CreatedResponse("challenges", c.ID, c)
 }
 
 func main() {
-	lambda.Start(addChallenge)
+	lambda.Start(shared.Chain(addChallenge, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithMaxBodySize, shared.WithJSONBody))
 }
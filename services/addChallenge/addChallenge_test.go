@@ -0,0 +1,1202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB serves items by Id and returns scanItems from every Scan, puts are recorded
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items     map[string]map[string]*dynamodb.AttributeValue
+	scanItems []map[string]*dynamodb.AttributeValue
+	puts      []*dynamodb.PutItemInput
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key["Id"].S]}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{Items: m.scanItems}, nil
+}
+
+func (m *mockDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, input)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func sourceItem(id, createdBy string, public bool, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":           {S: aws.String(id)},
+		"Type":         {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy":    {S: aws.String(createdBy)},
+		"Name":         {S: aws.String("Ride Week")},
+		"Public":       {BOOL: aws.Bool(public)},
+		"StartDate":    {S: aws.String("2024-05-01")},
+		"EndDate":      {S: aws.String("2024-05-07")},
+		"GoalType":     {S: aws.String("workouts")},
+		"GoalValue":    {N: aws.String("5")},
+		"WorkoutTypes": {SS: aws.StringSlice([]string{"cycling"})},
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+const privateID = "6f1c2a8e-3b4d-4e5f-9a0b-1c2d3e4f5a6b"
+
+func cloneDB() *mockDB {
+	return &mockDB{items: map[string]map[string]*dynamodb.AttributeValue{
+		"public":  sourceItem("public", "owner", true, nil),
+		"private": sourceItem("private", "owner", false, nil),
+		// Ids from the path are validated before the source is read
+		privateID: sourceItem(privateID, "owner", false, nil),
+		"invited": sourceItem("invited", "owner", false, map[string]*dynamodb.AttributeValue{
+			"InvitedUsers": {SS: aws.StringSlice([]string{"user1"})},
+		}),
+		"own": sourceItem("own", "user1", false, nil),
+		"deleted": sourceItem("deleted", "user1", true, map[string]*dynamodb.AttributeValue{
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}),
+		"program": sourceItem("program", "user1", true, map[string]*dynamodb.AttributeValue{
+			"Type": {S: aws.String(shared.ItemTypeProgram)},
+		}),
+	}}
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func TestCloneChallenge(t *testing.T) {
+	tests := []struct {
+		name       string
+		sourceID   string
+		body       string
+		wantStatus int
+		wantName   string
+	}{
+		{"someone else's public challenge", "public", `{}`, -1, "Ride Week (copy)"},
+		{"own private challenge", "own", `{}`, -1, "Ride Week (copy)"},
+		{"body overrides the copy", "public", `{"name": "My Ride Week"}`, -1, "My Ride Week"},
+		{"private challenge can't be seen", "private", `{}`, http.StatusNotFound, ""},
+		{"invited to a private challenge", "invited", `{}`, http.StatusForbidden, ""},
+		{"deleted challenge", "deleted", `{}`, http.StatusNotFound, ""},
+		{"program id", "program", `{}`, http.StatusNotFound, ""},
+		{"unknown id", "missing", `{}`, http.StatusNotFound, ""},
+		{"invalid body", "public", `{`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, status, err := cloneChallenge(tt.sourceID, "user1", tt.body, "pelodata", cloneDB())
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (err %v)", status, tt.wantStatus, err)
+			}
+			if tt.wantStatus != -1 {
+				if err == nil {
+					t.Error("error = nil, want an error")
+				}
+				return
+			}
+			if c.Name != tt.wantName || c.CloneFrom != tt.sourceID {
+				t.Errorf("clone name = %q from %q, want %q from %q", c.Name, c.CloneFrom, tt.wantName, tt.sourceID)
+			}
+			if c.GoalType != "workouts" || c.GoalValue != 5 || len(c.WorkoutTypes) != 1 {
+				t.Errorf("goal and workout types weren't copied: %+v", c)
+			}
+		})
+	}
+}
+
+func TestAddChallengeCloneNotVisible(t *testing.T) {
+	defer withMockDB(cloneDB())()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"userid": "user1"},
+		PathParameters: map[string]string{"challengeId": privateID},
+		Body:           `{}`,
+	}
+
+	res, err := addChallenge(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want 404: %s", res.StatusCode, res.Body)
+	}
+}
+
+func TestGetMaxChallengeDays(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *string
+		want  int
+	}{
+		{"unset", nil, defaultMaxChallengeDays},
+		{"set", stringPtr(" 90 "), 90},
+		{"not a number", stringPtr("ninety"), defaultMaxChallengeDays},
+		{"less than 1", stringPtr("0"), defaultMaxChallengeDays},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("max_challenge_days")
+			if tt.value != nil {
+				os.Setenv("max_challenge_days", *tt.value)
+			}
+			defer os.Unsetenv("max_challenge_days")
+
+			if got := getMaxChallengeDays(); got != tt.want {
+				t.Errorf("getMaxChallengeDays() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestChallengeLength(t *testing.T) {
+	day := func(offset int) string {
+		return today(time.UTC).AddDate(0, 0, offset).Format(dateFormat)
+	}
+
+	tests := []struct {
+		name      string
+		maxDays   string
+		endOffset int
+		wantErr   bool
+	}{
+		{"one day", "", 1, false},
+		{"end before start", "", 0, true},
+		{"longest allowed", "", defaultMaxChallengeDays, false},
+		{"one day too long", "", defaultMaxChallengeDays + 1, true},
+		{"longest configured", "30", 30, false},
+		{"one day longer than configured", "30", 31, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxDays != "" {
+				os.Setenv("max_challenge_days", tt.maxDays)
+				defer os.Unsetenv("max_challenge_days")
+			}
+
+			c := customChallenge{StartDate: day(1), EndDate: day(tt.endOffset)}
+			err := datesValidation(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("datesValidation() = %+v, wantErr %t", err, tt.wantErr)
+			}
+			if err != nil && err.field != "endDate" {
+				t.Errorf("error is on %s, want endDate", err.field)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		date    string
+		want    string
+		wantErr bool
+	}{
+		{"2024-05-10", "2024-05-10", false},
+		{"2024-05-10T23:30:00Z", "2024-05-10", false},
+		{"2024-05-10T23:30:00-07:00", "2024-05-10", false},
+		{"05/10/2024", "", true},
+		{"2024-02-30", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			got, err := parseDate(tt.date)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDate() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && got.Format(dateFormat) != tt.want {
+				t.Errorf("parseDate() = %s, want %s", got.Format(dateFormat), tt.want)
+			}
+		})
+	}
+}
+
+func TestDatesValidation(t *testing.T) {
+	day := func(offset int) string {
+		return today(time.UTC).AddDate(0, 0, offset).Format(dateFormat)
+	}
+
+	tests := []struct {
+		name      string
+		c         customChallenge
+		wantField string
+		wantStart string
+		wantEnd   string
+	}{
+		{"date only", customChallenge{StartDate: day(1), EndDate: day(30)}, "", day(1), day(30)},
+		{"rfc3339", customChallenge{StartDate: day(1) + "T10:00:00Z", EndDate: day(2) + "T23:59:59-05:00"}, "", day(1), day(2)},
+		{"missing start", customChallenge{EndDate: day(2)}, "startDate", "", ""},
+		{"invalid start", customChallenge{StartDate: "tomorrow", EndDate: day(2)}, "startDate", "", ""},
+		{"start in the past", customChallenge{StartDate: day(-2), EndDate: day(2)}, "startDate", "", ""},
+		{"missing end", customChallenge{StartDate: day(1)}, "endDate", "", ""},
+		{"invalid end", customChallenge{StartDate: day(1), EndDate: "2024-13-01"}, "endDate", "", ""},
+		{"end before start", customChallenge{StartDate: day(5), EndDate: day(4)}, "endDate", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			err := datesValidation(&c)
+			field := ""
+			if err != nil {
+				field = err.field
+			}
+			if field != tt.wantField {
+				t.Fatalf("datesValidation() = %+v, want an error on %q", err, tt.wantField)
+			}
+			if err == nil && (c.StartDate != tt.wantStart || c.EndDate != tt.wantEnd) {
+				t.Errorf("dates = %s to %s, want %s to %s", c.StartDate, c.EndDate, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+// withCategories points Peloton at a stub serving the category slugs, the returned func restores the env
+func withCategories(slugs ...string) func() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		categories := []string{}
+		for _, s := range slugs {
+			categories = append(categories, fmt.Sprintf(`{"slug": "%s"}`, s))
+		}
+		fmt.Fprintf(w, `{"browse_categories": [%s]}`, strings.Join(categories, ","))
+	}))
+	os.Setenv("peloton_url", server.URL)
+
+	return func() {
+		server.Close()
+		os.Unsetenv("peloton_url")
+	}
+}
+
+func TestAddChallengeDryRun(t *testing.T) {
+	defer withCategories("cycling", "strength")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	end := today(time.UTC).AddDate(0, 0, 7).Format(dateFormat)
+	validBody := fmt.Sprintf(`{"name": "Ride Week", "difficulty": 5, "startDate": "%s", "endDate": "%s", "goalValue": 5, "workoutTypes": ["cycling"]}`, start, end)
+	existing := []map[string]*dynamodb.AttributeValue{sourceItem("existing", "user1", false, nil)}
+
+	tests := []struct {
+		name       string
+		dryRun     string
+		body       string
+		scanItems  []map[string]*dynamodb.AttributeValue
+		wantStatus int
+		wantPuts   int
+	}{
+		{"valid", "true", validBody, nil, http.StatusOK, 0},
+		{"invalid body", "true", `{"name": "Ride Week"}`, nil, http.StatusBadRequest, 0},
+		{"name taken", "true", validBody, existing, http.StatusBadRequest, 0},
+		{"not a dry run", "false", validBody, nil, http.StatusCreated, 1},
+		{"invalid dryRun", "maybe", validBody, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{scanItems: tt.scanItems}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"UserID": "user1"},
+				QueryStringParameters: map[string]string{"dryRun": tt.dryRun},
+				Body:                  tt.body,
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if len(db.puts) != tt.wantPuts {
+				t.Errorf("PutItem called %d times, want %d", len(db.puts), tt.wantPuts)
+			}
+			if tt.wantStatus == http.StatusOK && res.Body != `{"valid": true}` {
+				t.Errorf("body = %s, want {\"valid\": true}", res.Body)
+			}
+		})
+	}
+}
+
+func TestDatesValidationTimezone(t *testing.T) {
+	// The local dates in these zones 25 hours apart always differ, whatever the time of day
+	ahead, _ := time.LoadLocation("Pacific/Kiritimati")
+	behind, _ := time.LoadLocation("Pacific/Pago_Pago")
+	todayIn := func(loc *time.Location) string {
+		return today(loc).Format(dateFormat)
+	}
+
+	tests := []struct {
+		name      string
+		timezone  string
+		startDate string
+		endDate   string
+		wantField string
+	}{
+		{"today in utc", "", todayIn(time.UTC), todayIn(time.UTC), ""},
+		{"today in utc as rfc3339", "", todayIn(time.UTC) + "T00:00:00Z", todayIn(time.UTC), ""},
+		{"yesterday in utc", "", today(time.UTC).AddDate(0, 0, -1).Format(dateFormat), todayIn(time.UTC), "startDate"},
+		{"today ahead of utc", "Pacific/Kiritimati", todayIn(ahead), todayIn(ahead), ""},
+		{"today behind utc", "Pacific/Pago_Pago", todayIn(behind), todayIn(behind), ""},
+		{"already over ahead of utc", "Pacific/Kiritimati", todayIn(behind), todayIn(ahead), "startDate"},
+		{"not yet started behind utc", "Pacific/Pago_Pago", todayIn(ahead), todayIn(ahead), ""},
+		{"invalid timezone", "Mars/Olympus", todayIn(ahead), todayIn(ahead), "timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := customChallenge{Timezone: tt.timezone, StartDate: tt.startDate, EndDate: tt.endDate}
+			err := datesValidation(&c)
+			field := ""
+			if err != nil {
+				field = err.field
+			}
+			if field != tt.wantField {
+				t.Errorf("datesValidation() = %+v, want an error on %q", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestAddChallengeTimezoneHeader(t *testing.T) {
+	defer withCategories("cycling")()
+
+	ahead, _ := time.LoadLocation("Pacific/Kiritimati")
+	behind, _ := time.LoadLocation("Pacific/Pago_Pago")
+	// Today where the user is ahead of UTC is always after today where they're behind it
+	startDate := today(behind).Format(dateFormat)
+	endDate := today(ahead).Format(dateFormat)
+
+	tests := []struct {
+		name         string
+		header       string
+		bodyTimezone string
+		wantStatus   int
+		wantTimezone string
+	}{
+		{"behind utc", "Pacific/Pago_Pago", "", http.StatusCreated, "Pacific/Pago_Pago"},
+		{"ahead of utc", "Pacific/Kiritimati", "", http.StatusBadRequest, ""},
+		{"body overrides the header", "Pacific/Kiritimati", "Pacific/Pago_Pago", http.StatusCreated, "Pacific/Pago_Pago"},
+		{"invalid header", "Not/AZone", "", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1", "x-timezone": tt.header},
+				Body: fmt.Sprintf(`{"name": "Ride Week", "difficulty": 5, "startDate": "%s", "endDate": "%s", "timezone": "%s", "goalValue": 1, "workoutTypes": ["cycling"]}`,
+					startDate, endDate, tt.bodyTimezone),
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantTimezone == "" {
+				return
+			}
+			created := struct {
+				Timezone string `json:"timezone"`
+			}{}
+			if err := json.Unmarshal([]byte(res.Body), &created); err != nil {
+				t.Fatal(err)
+			}
+			if created.Timezone != tt.wantTimezone {
+				t.Errorf("timezone = %s, want %s", created.Timezone, tt.wantTimezone)
+			}
+		})
+	}
+}
+
+func TestEquipmentRoundTrip(t *testing.T) {
+	defer withCategories("cycling")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	tests := []struct {
+		name      string
+		equipment string
+		want      []string
+	}{
+		{"omitted", "", []string{}},
+		{"empty", `"equipmentNeeded": [],`, []string{}},
+		{"blank entries", `"equipmentNeeded": [" ", ""],`, []string{}},
+		{"populated", `"equipmentNeeded": ["Mat", "Weights"],`, []string{"Mat", "Weights"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body: fmt.Sprintf(`{"name": "Ride Week", "difficulty": 5, %s "startDate": "%s", "endDate": "%s", "goalValue": 1, "workoutTypes": ["cycling"]}`,
+					tt.equipment, start, start),
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil || res.StatusCode != http.StatusCreated {
+				t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+			}
+
+			// DynamoDB rejects a string set without members
+			item := db.puts[0].Item
+			if equipment, ok := item["EquipmentNeeded"]; ok && len(equipment.SS) == 0 {
+				t.Fatalf("EquipmentNeeded is stored as %v", equipment)
+			}
+			challenge, err := shared.FormatChallenge(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(challenge.EquipmentNeeded, tt.want) {
+				t.Errorf("EquipmentNeeded = %#v, want %#v", challenge.EquipmentNeeded, tt.want)
+			}
+			body, _ := json.Marshal(challenge)
+			if strings.Contains(string(body), `"equipmentNeeded":null`) {
+				t.Errorf("equipmentNeeded is null in %s", body)
+			}
+		})
+	}
+}
+
+func TestDifficultyValidation(t *testing.T) {
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+
+	tests := []struct {
+		name       string
+		difficulty float32
+		want       float32
+		wantErr    bool
+	}{
+		{"zero", 0, 0, true},
+		{"minimum", 0.1, 0.1, false},
+		{"maximum", 10, 10, false},
+		{"over the maximum", 10.1, 10.1, true},
+		{"rounded to one decimal place", 5.55, 5.6, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := customChallenge{Name: "Ride Week", Difficulty: tt.difficulty, StartDate: start, EndDate: start, GoalValue: 1, WorkoutTypes: []string{"cycling"}}
+			errs := bodyValidation(&c)
+			if (len(errs) > 0) != tt.wantErr {
+				t.Fatalf("bodyValidation() = %+v, wantErr %t", errs, tt.wantErr)
+			}
+			if tt.wantErr {
+				if errs[0].Field != "difficulty" || !strings.Contains(errs[0].Message, "between 0.1 and 10.0") {
+					t.Errorf("error = %+v, want the allowed range for difficulty", errs[0])
+				}
+				return
+			}
+			if c.Difficulty != tt.want {
+				t.Errorf("Difficulty = %v, want %v", c.Difficulty, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoalWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		c        customChallenge
+		wantWarn bool
+	}{
+		{"reasonable workouts", customChallenge{GoalType: shared.GoalTypeWorkouts, GoalValue: 21, StartDate: "2024-05-01", EndDate: "2024-05-07"}, false},
+		{"too many workouts", customChallenge{GoalType: shared.GoalTypeWorkouts, GoalValue: 22, StartDate: "2024-05-01", EndDate: "2024-05-07"}, true},
+		{"too many workouts in a day", customChallenge{GoalType: shared.GoalTypeWorkouts, GoalValue: 4, StartDate: "2024-05-01", EndDate: "2024-05-01"}, true},
+		{"reasonable minutes", customChallenge{GoalType: shared.GoalTypeMinutes, GoalValue: 1680, StartDate: "2024-05-01", EndDate: "2024-05-07"}, false},
+		{"too many minutes", customChallenge{GoalType: shared.GoalTypeMinutes, GoalValue: 1681, StartDate: "2024-05-01", EndDate: "2024-05-07"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := goalWarning(tt.c); (got != "") != tt.wantWarn {
+				t.Errorf("goalWarning() = %q, wantWarn %t", got, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestWorkoutTypesValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		workoutTypes []string
+		slugsErr     error
+		want         []string
+		wantErr      string
+	}{
+		{"valid", []string{"cycling", "strength"}, nil, []string{"cycling", "strength"}, ""},
+		{"mixed case", []string{" Cycling", "STRENGTH "}, nil, []string{"cycling", "strength"}, ""},
+		{"duplicates", []string{"cycling", "Cycling", "strength", "cycling"}, nil, []string{"cycling", "strength"}, ""},
+		{"invalid", []string{"cycling", "cylcing"}, nil, nil, "workoutType cylcing is invalid, must be one of: cycling, strength, yoga"},
+		{"categories unavailable", []string{"cylcing"}, errors.New("Peloton is down"), []string{"cylcing"}, ""},
+	}
+
+	getSlugs := getCategorySlugs
+	defer func() { getCategorySlugs = getSlugs }()
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getCategorySlugs = func() ([]string, error) {
+				if tt.slugsErr != nil {
+					return nil, tt.slugsErr
+				}
+				return []string{"cycling", "strength", "yoga"}, nil
+			}
+
+			c := customChallenge{Name: "Ride Week", Difficulty: 5, StartDate: start, EndDate: start, GoalValue: 1, WorkoutTypes: tt.workoutTypes}
+			if errs := bodyValidation(&c); len(errs) > 0 {
+				t.Fatalf("bodyValidation() = %+v", errs)
+			}
+			err := workoutTypesValidation(c)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("workoutTypesValidation() = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("workoutTypesValidation() = %v", err)
+			}
+			if !reflect.DeepEqual(c.WorkoutTypes, tt.want) {
+				t.Errorf("WorkoutTypes = %v, want %v", c.WorkoutTypes, tt.want)
+			}
+		})
+	}
+}
+
+// nameDB matches scans on NameKey or Name like the FilterExpression built by nameValidation would
+type nameDB struct {
+	mockDB
+	existing []map[string]*dynamodb.AttributeValue
+}
+
+func (m *nameDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	values := input.ExpressionAttributeValues
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.existing {
+		nameKey := i["NameKey"] != nil && *i["NameKey"].S == *values[":nameKey"].S
+		if !nameKey && *i["Name"].S != *values[":name"].S {
+			continue
+		}
+		if values[":createdBy"] != nil && *i["CreatedBy"].S != *values[":createdBy"].S {
+			continue
+		}
+		items = append(items, i)
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func TestNameValidation(t *testing.T) {
+	defer withCategories("cycling")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	existing := []map[string]*dynamodb.AttributeValue{
+		sourceItem("spring", "user1", false, map[string]*dynamodb.AttributeValue{
+			"Name":    {S: aws.String("Spring Sprint")},
+			"NameKey": {S: aws.String("spring sprint")},
+		}),
+		// Created before NameKey was stored
+		sourceItem("legacy", "user1", false, map[string]*dynamodb.AttributeValue{"Name": {S: aws.String("Summer Miles")}}),
+	}
+
+	tests := []struct {
+		name       string
+		challenge  string
+		wantStatus int
+		wantName   string
+	}{
+		{"same name", "Spring Sprint", http.StatusBadRequest, ""},
+		{"different case", "spring sprint", http.StatusBadRequest, ""},
+		{"surrounding whitespace", " Spring Sprint ", http.StatusBadRequest, ""},
+		{"internal whitespace", "SPRING   sprint", http.StatusBadRequest, ""},
+		{"legacy exact name", "Summer Miles", http.StatusBadRequest, ""},
+		{"different name", "Spring Sprint 2", http.StatusCreated, "Spring Sprint 2"},
+		{"trimmed", "  Autumn Sprint ", http.StatusCreated, "Autumn Sprint"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &nameDB{existing: existing}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    fmt.Sprintf(`{"name": %q, "difficulty": 5, "startDate": "%s", "endDate": "%s", "goalValue": 1, "workoutTypes": ["cycling"]}`, tt.challenge, start, start),
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			item := db.puts[0].Item
+			if *item["Name"].S != tt.wantName || *item["NameKey"].S != shared.NameKey(tt.wantName) {
+				t.Errorf("Name = %q and NameKey = %q, want %q", *item["Name"].S, *item["NameKey"].S, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestGoalValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		c         customChallenge
+		wantType  string
+		wantValue int
+		wantNum   int
+		wantErr   bool
+	}{
+		{"legacy numWorkoutGoal", customChallenge{NumWorkoutGoal: 12}, shared.GoalTypeWorkouts, 12, 12, false},
+		{"workouts goalValue", customChallenge{GoalType: " Workouts ", GoalValue: 8}, shared.GoalTypeWorkouts, 8, 8, false},
+		{"matching numWorkoutGoal", customChallenge{GoalValue: 8, NumWorkoutGoal: 8}, shared.GoalTypeWorkouts, 8, 8, false},
+		{"mismatched numWorkoutGoal", customChallenge{GoalValue: 8, NumWorkoutGoal: 9}, shared.GoalTypeWorkouts, 8, 9, true},
+		{"minutes", customChallenge{GoalType: "minutes", GoalValue: 600}, shared.GoalTypeMinutes, 600, 0, false},
+		{"minutes with numWorkoutGoal", customChallenge{GoalType: "minutes", GoalValue: 600, NumWorkoutGoal: 5}, shared.GoalTypeMinutes, 600, 5, true},
+		{"missing goal", customChallenge{}, shared.GoalTypeWorkouts, 0, 0, true},
+		{"negative minutes", customChallenge{GoalType: "minutes", GoalValue: -1}, shared.GoalTypeMinutes, -1, 0, true},
+		{"unknown type", customChallenge{GoalType: "miles", GoalValue: 10}, "miles", 10, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.c
+			err := goalValidation(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goalValidation() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if c.GoalType != tt.wantType || c.GoalValue != tt.wantValue || c.NumWorkoutGoal != tt.wantNum {
+				t.Errorf("goal = %s %d (numWorkoutGoal %d), want %s %d (numWorkoutGoal %d)",
+					c.GoalType, c.GoalValue, c.NumWorkoutGoal, tt.wantType, tt.wantValue, tt.wantNum)
+			}
+		})
+	}
+}
+
+func TestGoalRoundTrip(t *testing.T) {
+	defer withCategories("cycling")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	end := today(time.UTC).AddDate(0, 0, 7).Format(dateFormat)
+
+	tests := []struct {
+		name      string
+		goal      string
+		wantType  string
+		wantValue int
+		wantNum   int
+	}{
+		{"workouts", `"goalType": "workouts", "goalValue": 10`, shared.GoalTypeWorkouts, 10, 10},
+		{"minutes", `"goalType": "minutes", "goalValue": 600`, shared.GoalTypeMinutes, 600, 0},
+		{"legacy numWorkoutGoal", `"numWorkoutGoal": 12`, shared.GoalTypeWorkouts, 12, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    fmt.Sprintf(`{"name": "Ride Week", "difficulty": 5, "startDate": "%s", "endDate": "%s", %s, "workoutTypes": ["cycling"]}`, start, end, tt.goal),
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil || res.StatusCode != http.StatusCreated {
+				t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+			}
+
+			c, err := shared.FormatChallenge(db.puts[0].Item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.GoalType != tt.wantType || c.GoalValue != tt.wantValue || c.NumWorkoutGoal != tt.wantNum {
+				t.Errorf("goal = %s %d (numWorkoutGoal %d), want %s %d (numWorkoutGoal %d)",
+					c.GoalType, c.GoalValue, c.NumWorkoutGoal, tt.wantType, tt.wantValue, tt.wantNum)
+			}
+		})
+	}
+}
+
+func TestTagsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    []string
+		wantErr bool
+	}{
+		{"none", nil, []string{}, false},
+		{"normalized and deduped", []string{" Low-Impact ", "low-impact", "", "hiit"}, []string{"low-impact", "hiit"}, false},
+		{"not a slug", []string{"low impact"}, nil, true},
+		{"too long", []string{"a-very-long-tag-that-goes-past-the-limit"}, nil, true},
+		{"too many", []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := customChallenge{Tags: tt.tags}
+			err := tagsValidation(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tagsValidation() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(c.Tags, tt.want) {
+				t.Errorf("Tags = %v, want %v", c.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsRoundTrip(t *testing.T) {
+	defer withCategories("cycling")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+
+	tests := []struct {
+		name string
+		tags string
+		want []string
+	}{
+		{"omitted", "", []string{}},
+		{"empty", `"tags": [],`, []string{}},
+		{"populated", `"tags": ["Beginner", "pz"],`, []string{"beginner", "pz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    fmt.Sprintf(`{"name": "Ride Week", %s "difficulty": 5, "startDate": "%s", "endDate": "%s", "goalValue": 1, "workoutTypes": ["cycling"]}`, tt.tags, start, start),
+			}
+			res, err := addChallenge(context.Background(), request)
+			if err != nil || res.StatusCode != http.StatusCreated {
+				t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+			}
+
+			// DynamoDB rejects a string set without members
+			item := db.puts[0].Item
+			if tags, ok := item["Tags"]; ok && len(tags.SS) == 0 {
+				t.Fatalf("Tags is stored as %v", tags)
+			}
+			c, err := shared.FormatChallenge(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Tags, tt.want) {
+				t.Errorf("Tags = %#v, want %#v", c.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvitedUsersValidation(t *testing.T) {
+	tooMany := []string{}
+	for i := 0; i <= maxInvitedUsers; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("user%d", i+2))
+	}
+
+	tests := []struct {
+		name         string
+		invitedUsers []string
+		want         []string
+		wantErr      bool
+	}{
+		{"none", nil, []string{}, false},
+		{"duplicates", []string{"user2", " user2 ", "", "user3"}, []string{"user2", "user3"}, false},
+		{"at the limit", tooMany[:maxInvitedUsers], tooMany[:maxInvitedUsers], false},
+		{"over the limit", tooMany, nil, true},
+		{"self invite", []string{"user2", "user1"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := customChallenge{CreatedBy: "user1", InvitedUsers: tt.invitedUsers}
+			err := invitedUsersValidation(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("invitedUsersValidation() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(c.InvitedUsers, tt.want) {
+				t.Errorf("InvitedUsers = %v, want %v", c.InvitedUsers, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddChallengeCreatedDate(t *testing.T) {
+	defer withCategories("cycling")()
+	db := &mockDB{}
+	defer withMockDB(db)()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	request := events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"UserID": "user1"},
+		Body:    fmt.Sprintf(`{"name": "Ride Week", "difficulty": 5, "startDate": "%s", "endDate": "%s", "goalValue": 1, "workoutTypes": ["cycling"], "createdDate": "2000-01-01T00:00:00Z"}`, start, start),
+	}
+	before := time.Now().Add(-time.Second)
+	res, err := addChallenge(context.Background(), request)
+	if err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+	}
+
+	c, err := shared.FormatChallenge(db.puts[0].Item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := time.Parse(time.RFC3339, c.CreatedDate)
+	if err != nil {
+		t.Fatalf("CreatedDate %q isn't RFC3339: %s", c.CreatedDate, err)
+	}
+	if created.Before(before) {
+		t.Errorf("CreatedDate = %s, want the time the challenge was added", c.CreatedDate)
+	}
+}
+
+func TestSubGoalsValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		subGoals []shared.SubGoal
+		want     []shared.SubGoal
+		wantErr  bool
+	}{
+		{"none", nil, []shared.SubGoal{}, false},
+		{"normalized", []shared.SubGoal{{WorkoutType: " Strength ", Count: 4}}, []shared.SubGoal{{WorkoutType: "strength", Count: 4}}, false},
+		{"fills the goal", []shared.SubGoal{{WorkoutType: "cycling", Count: 8}, {WorkoutType: "strength", Count: 4}}, nil, false},
+		{"not a workout type", []shared.SubGoal{{WorkoutType: "yoga", Count: 1}}, nil, true},
+		{"duplicate", []shared.SubGoal{{WorkoutType: "cycling", Count: 1}, {WorkoutType: "Cycling", Count: 1}}, nil, true},
+		{"zero count", []shared.SubGoal{{WorkoutType: "cycling", Count: 0}}, nil, true},
+		{"more than the goal", []shared.SubGoal{{WorkoutType: "cycling", Count: 10}, {WorkoutType: "strength", Count: 3}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := customChallenge{GoalValue: 12, WorkoutTypes: []string{"cycling", "strength"}, SubGoals: tt.subGoals}
+			err := subGoalsValidation(&c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("subGoalsValidation() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.want != nil && !reflect.DeepEqual([]shared.SubGoal(c.SubGoals), tt.want) {
+				t.Errorf("SubGoals = %+v, want %+v", c.SubGoals, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyValidation(t *testing.T) {
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	end := today(time.UTC).AddDate(0, 0, 14).Format(dateFormat)
+
+	t.Run("valid", func(t *testing.T) {
+		c := customChallenge{
+			Name:         "  May   Miles ",
+			Difficulty:   5.55,
+			StartDate:    start,
+			EndDate:      end,
+			GoalValue:    10,
+			WorkoutTypes: []string{"Cycling", "cycling", " running "},
+		}
+		if errs := bodyValidation(&c); len(errs) != 0 {
+			t.Fatalf("bodyValidation() = %+v, want no errors", errs)
+		}
+		if c.Difficulty != 5.6 {
+			t.Errorf("Difficulty = %v, want 5.6", c.Difficulty)
+		}
+		if !reflect.DeepEqual(c.WorkoutTypes, []string{"cycling", "running"}) {
+			t.Errorf("WorkoutTypes = %v", c.WorkoutTypes)
+		}
+		if c.Recurrence != shared.RecurrenceNone {
+			t.Errorf("Recurrence = %s, want %s", c.Recurrence, shared.RecurrenceNone)
+		}
+	})
+
+	t.Run("every invalid field", func(t *testing.T) {
+		c := customChallenge{GoalType: "miles", Recurrence: "daily", StartDate: start, EndDate: end}
+		fields := []string{}
+		for _, fe := range bodyValidation(&c) {
+			fields = append(fields, fe.Field)
+		}
+		want := []string{"name", "difficulty", "goalType", "workoutTypes", "recurrence"}
+		if !reflect.DeepEqual(fields, want) {
+			t.Errorf("invalid fields = %v, want %v", fields, want)
+		}
+	})
+}
+
+func TestAddChallengeValidationErrors(t *testing.T) {
+	db := &mockDB{}
+	defer withMockDB(db)()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"UserID": "user1"},
+		Body:    `{"difficulty": 11, "startDate": "tomorrow", "goalValue": 1}`,
+	}
+	res, err := addChallenge(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("StatusCode = %d, want 400: %s", res.StatusCode, res.Body)
+	}
+
+	body := struct {
+		Errors []shared.FieldError `json:"errors"`
+	}{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+	}
+	fields := map[string]bool{}
+	for _, e := range body.Errors {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"name", "difficulty", "startDate", "workoutTypes"} {
+		if !fields[f] {
+			t.Errorf("no error for %s in %+v", f, body.Errors)
+		}
+	}
+}
+
+// idempotencyDB stores every put and matches scans like the FilterExpression built by idempotentReplay would
+// other scans, such as the name check, match nothing
+type idempotencyDB struct {
+	mockDB
+}
+
+func (m *idempotencyDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	values := input.ExpressionAttributeValues
+	items := []map[string]*dynamodb.AttributeValue{}
+	if values[":key"] == nil {
+		return &dynamodb.ScanOutput{Items: items}, nil
+	}
+	for _, p := range m.puts {
+		i := p.Item
+		if i["IdempotencyKey"] == nil || *i["IdempotencyKey"].S != *values[":key"].S || *i["CreatedBy"].S != *values[":createdBy"].S {
+			continue
+		}
+		if *i["IdempotencyDate"].S >= *values[":since"].S && !shared.IsDeleted(i) {
+			items = append(items, i)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func TestAddChallengeIdempotencyKey(t *testing.T) {
+	defer withCategories("cycling")()
+
+	start := today(time.UTC).AddDate(0, 0, 1).Format(dateFormat)
+	body := func(name string) string {
+		return fmt.Sprintf(`{"name": %q, "difficulty": 5, "startDate": "%s", "endDate": "%s", "goalValue": 1, "workoutTypes": ["cycling"]}`, name, start, start)
+	}
+	type call struct {
+		userID     string
+		body       string
+		wantStatus int
+	}
+
+	tests := []struct {
+		name     string
+		calls    []call
+		expire   bool
+		wantPuts int
+	}{
+		{"first write", []call{{"user1", body("Ride Week"), http.StatusCreated}}, false, 1},
+		{"exact replay", []call{{"user1", body("Ride Week"), http.StatusCreated}, {"user1", body("Ride Week"), http.StatusOK}}, false, 1},
+		{"modified body", []call{{"user1", body("Ride Week"), http.StatusCreated}, {"user1", body("Run Week"), http.StatusConflict}}, false, 1},
+		{"key used by another user", []call{{"user1", body("Ride Week"), http.StatusCreated}, {"user2", body("Ride Week"), http.StatusCreated}}, false, 2},
+		{"expired key", []call{{"user1", body("Ride Week"), http.StatusCreated}, {"user1", body("Ride Week"), http.StatusCreated}}, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &idempotencyDB{}
+			defer withMockDB(db)()
+
+			ids := []string{}
+			for idx, c := range tt.calls {
+				request := events.APIGatewayV2HTTPRequest{
+					Headers: map[string]string{"UserID": c.userID, "idempotency-key": "retry-1"},
+					Body:    c.body,
+				}
+				res, err := addChallenge(context.Background(), request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.StatusCode != c.wantStatus {
+					t.Fatalf("call %d StatusCode = %d, want %d: %s", idx+1, res.StatusCode, c.wantStatus, res.Body)
+				}
+				if tt.expire {
+					for _, p := range db.puts {
+						p.Item["IdempotencyDate"] = &dynamodb.AttributeValue{S: aws.String(time.Now().UTC().Add(-25 * time.Hour).Format(time.RFC3339))}
+					}
+				}
+				if c.wantStatus == http.StatusConflict {
+					continue
+				}
+				created := shared.Challenge{}
+				if err := json.Unmarshal([]byte(res.Body), &created); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, created.ID)
+			}
+
+			if len(db.puts) != tt.wantPuts {
+				t.Errorf("%d challenges written, want %d", len(db.puts), tt.wantPuts)
+			}
+			// A replay returns the challenge created by the first request
+			if tt.wantPuts == 1 && len(ids) == 2 && ids[0] != ids[1] {
+				t.Errorf("replay returned %s, want %s", ids[1], ids[0])
+			}
+		})
+	}
+}
+
+func TestChallengeRoundTrip(t *testing.T) {
+	in := customChallenge{
+		ID:              "c1",
+		CreatedBy:       "user1",
+		Name:            "Ride Week",
+		Description:     "Ride every day",
+		Public:          true,
+		EquipmentNeeded: []string{"bike"},
+		Difficulty:      5.5,
+		StartDate:       "2024-05-01",
+		EndDate:         "2024-05-07",
+		GoalType:        shared.GoalTypeMinutes,
+		GoalValue:       300,
+		SubGoals:        shared.SubGoalsBlob{{WorkoutType: "cycling", Count: 4}, {WorkoutType: "yoga", Count: 2}},
+		WorkoutTypes:    []string{"cycling", "yoga"},
+		Tags:            []string{"summer"},
+		InvitedUsers:    []string{"user2"},
+		CreatedDate:     "2024-04-01",
+		UpdatedDate:     "2024-04-02",
+		SourceProgramID: "p1",
+		Recurrence:      "weekly",
+		Warning:         "long challenge",
+		CloneFrom:       "c0",
+		Timezone:        "America/New_York",
+	}
+
+	db := &mockDB{}
+	if err := putItem(in, "pelodata", db); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.puts) != 1 {
+		t.Fatalf("put %d items, want 1", len(db.puts))
+	}
+	item := db.puts[0].Item
+	if item["SubGoals"] == nil || item["SubGoals"].B == nil {
+		t.Errorf("SubGoals = %v, want a binary attribute", item["SubGoals"])
+	}
+	for _, attr := range []string{"Warning", "CloneFrom", "Timezone", "NumWorkoutGoal", "IdempotencyKey", "IdempotencyHash"} {
+		if _, ok := item[attr]; ok {
+			t.Errorf("%s was stored", attr)
+		}
+	}
+
+	got, err := shared.FormatChallenge(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := shared.Challenge{
+		ID:              in.ID,
+		CreatedBy:       in.CreatedBy,
+		Name:            in.Name,
+		Description:     in.Description,
+		Public:          in.Public,
+		EquipmentNeeded: in.EquipmentNeeded,
+		Difficulty:      in.Difficulty,
+		StartDate:       in.StartDate,
+		EndDate:         in.EndDate,
+		GoalType:        in.GoalType,
+		GoalValue:       in.GoalValue,
+		SubGoals:        []shared.SubGoal(in.SubGoals),
+		WorkoutTypes:    in.WorkoutTypes,
+		Tags:            in.Tags,
+		InvitedUsers:    in.InvitedUsers,
+		AcceptedUsers:   []string{},
+		DeclinedUsers:   []string{},
+		CreatedDate:     in.CreatedDate,
+		UpdatedDate:     in.UpdatedDate,
+		SourceProgramID: in.SourceProgramID,
+		Recurrence:      in.Recurrence,
+	}
+	// Computed fields aren't part of the round trip
+	got.Status, got.DaysRemaining, got.DurationDays = "", nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FormatChallenge() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAddChallengePreflight(t *testing.T) {
+	db := &mockDB{}
+	defer withMockDB(db)()
+
+	request := events.APIGatewayV2HTTPRequest{}
+	request.RequestContext.HTTP.Method = http.MethodOptions
+	res, err := shared.Chain(addChallenge, shared.WithCORS, shared.WithJSONBody)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNoContent || res.Body != "" {
+		t.Errorf("response = %d %q, want 204 with no body", res.StatusCode, res.Body)
+	}
+	if res.Headers["Access-Control-Allow-Origin"] != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", res.Headers["Access-Control-Allow-Origin"])
+	}
+	if len(db.puts) != 0 {
+		t.Errorf("%d items written, want 0", len(db.puts))
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,36 +14,48 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/google/uuid"
 )
 
+// customProgram is stored with dynamodbattribute, the dynamodbav tags are the attribute names
 type customProgram struct {
-	ID              string             `json:"id"`
-	Name            string             `json:"name"`
-	Description     string             `json:"description"`
-	Public          bool               `json:"public"`
-	EquipmentNeeded []string           `json:"equipmentNeeded"`
-	NumWeeks        int                `json:"numWeeks"`
-	Workouts        [][]shared.Workout `json:"workouts"`
-	CreatedBy       string             `json:"createdBy"`
-	CreatedDate     string             `json:"createdDate"`
+	ID          string `json:"id" dynamodbav:"Id"`
+	Name        string `json:"name" dynamodbav:"Name"`
+	Description string `json:"description" dynamodbav:"Description"`
+	Public      bool   `json:"public" dynamodbav:"Public"`
+	// DynamoDB doesn't allow empty string sets
+	EquipmentNeeded []string            `json:"equipmentNeeded" dynamodbav:"EquipmentNeeded,stringset,omitempty"`
+	NumWeeks        int                 `json:"numWeeks" dynamodbav:"NumWeeks"`
+	Workouts        shared.WorkoutsBlob `json:"workouts" dynamodbav:"Workouts"`
+	CreatedBy       string              `json:"createdBy" dynamodbav:"CreatedBy"`
+	CreatedDate     string              `json:"createdDate" dynamodbav:"CreatedDate"`
+	UpdatedDate     string              `json:"updatedDate" dynamodbav:"UpdatedDate"`
+	// ComputedDifficulty is stored so lists don't have to decode the Workouts blob
+	ComputedDifficulty float32 `json:"computedDifficulty" dynamodbav:"ComputedDifficulty"`
 }
 
-func bodyValidation(cp customProgram) error {
+// bodyValidation validates the request body
+// every invalid field is returned rather than stopping at the first one
+func bodyValidation(cp customProgram) []shared.FieldError {
+	errs := []shared.FieldError{}
 	if cp.Name == "" {
-		return errors.New("name is required in request body")
+		errs = append(errs, shared.FieldError{Field: "name", Message: "name is required in request body"})
 	}
+	errs = append(errs, shared.TextLimitErrors(cp.Name, cp.Description, cp.EquipmentNeeded)...)
 	if cp.NumWeeks < 1 {
-		return errors.New("numWeeks must be a number greater than 0")
+		errs = append(errs, shared.FieldError{Field: "numWeeks", Message: "numWeeks must be a number greater than 0"})
 	}
+	// An omitted workouts is nil, so it's rejected here rather than stored as null
 	if len(cp.Workouts) < 1 {
-		return errors.New("workouts must not be empty")
+		errs = append(errs, shared.FieldError{Field: "workouts", Message: "workouts must not be empty"})
 	}
 
-	return nil
+	return errs
 }
 
-func nameValidation(cp customProgram, tableName string, db *dynamodb.DynamoDB) (int, error) {
+func nameValidation(cp customProgram, tableName string, db dynamodbiface.DynamoDBAPI) (int, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(tableName),
 		ExpressionAttributeNames: map[string]*string{
@@ -54,49 +65,43 @@ func nameValidation(cp customProgram, tableName string, db *dynamodb.DynamoDB) (
 	if cp.Public {
 		// If cp.Public is true, the name must be unique for all public programs
 		scanInput.ExpressionAttributeNames["#P"] = aws.String("Public")
-		scanInput.FilterExpression = aws.String("#N = :name and #P = :public")
+		scanInput.FilterExpression = aws.String("#N = :name and #P = :public and " + shared.NotDeletedFilter)
 		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
 			":name":   {S: aws.String(cp.Name)},
 			":public": {BOOL: aws.Bool(true)},
 		}
 	} else {
 		// else, the name must be unique for the user's programs
-		scanInput.FilterExpression = aws.String("#N = :name and CreatedBy = :createdBy")
+		scanInput.FilterExpression = aws.String("#N = :name and CreatedBy = :createdBy and " + shared.NotDeletedFilter)
 		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
 			":name":      {S: aws.String(cp.Name)},
 			":createdBy": {S: aws.String(cp.CreatedBy)},
 		}
 	}
-	scanOutput, err := db.Scan(scanInput)
+	items, err := shared.ScanAll(db, scanInput)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Unable to get existing programs: %s", err.Error())
 	}
 
-	// If the Scan call returns any items, then that name can't be used
-	if len(scanOutput.Items) > 0 {
+	// If the scan returns any items, then that name can't be used
+	if len(items) > 0 {
 		return http.StatusBadRequest, fmt.Errorf("A program with the name %s already exists", cp.Name)
 	}
 
 	return -1, nil
 }
 
-func putItem(cp customProgram, workoutsData []byte, tableName string, db *dynamodb.DynamoDB) error {
-	itemToPut := map[string]*dynamodb.AttributeValue{
-		"Id":              {S: aws.String(cp.ID)},
-		"Name":            {S: aws.String(cp.Name)},
-		"Description":     {S: aws.String(cp.Description)},
-		"Public":          {BOOL: aws.Bool(cp.Public)},
-		"EquipmentNeeded": {SS: aws.StringSlice(cp.EquipmentNeeded)},
-		"NumWeeks":        {N: aws.String(strconv.Itoa(cp.NumWeeks))},
-		"Workouts":        {B: workoutsData},
-		"CreatedBy":       {S: aws.String(cp.CreatedBy)},
-		"CreatedDate":     {S: aws.String(time.Now().Format(time.RFC3339))},
+func putItem(cp customProgram, tableName string, db dynamodbiface.DynamoDBAPI) error {
+	itemToPut, err := dynamodbattribute.MarshalMap(cp)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal custom program: %s", err)
 	}
+	itemToPut["Type"] = &dynamodb.AttributeValue{S: aws.String(shared.ItemTypeProgram)}
 	putInput := &dynamodb.PutItemInput{
 		TableName: aws.String(tableName),
 		Item:      itemToPut,
 	}
-	_, err := db.PutItem(putInput)
+	_, err = db.PutItem(putInput)
 	if err != nil {
 		return fmt.Errorf("Unable to save custom program: %s", err.Error())
 	}
@@ -106,106 +111,88 @@ func putItem(cp customProgram, workoutsData []byte, tableName string, db *dynamo
 
 func addProgram(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	// Get UserID header
-	userID, ok := request.Headers["UserID"]
+	userID, ok := shared.GetHeader(request.Headers, "UserID")
 	userID = strings.TrimSpace(userID)
 	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
 	}
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	tableRegion, tableName, err := shared.GetTableFor(shared.TablePrograms)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 		}, err
 	}
 
+	// Check for query parameters
+	// dryRun - if true, the request is validated but not saved
+	dryRun := false
+	if dryRunStr, ok := request.QueryStringParameters["dryRun"]; ok {
+		dryRun, err = strconv.ParseBool(dryRunStr)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, "dryRun must be true or false"), nil
+		}
+	}
+
 	// Parse request body
 	cp := customProgram{}
 	err = json.Unmarshal([]byte(request.Body), &cp)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Invalid request body"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	cp.ID = uuid.New().String()
 	cp.CreatedBy = userID
-	cp.Name = strings.TrimSpace(cp.Name)
-	cp.Description = strings.TrimSpace(cp.Description)
+	cp.CreatedDate = time.Now().Format(time.RFC3339)
+	cp.UpdatedDate = cp.CreatedDate
+	cp.Name = shared.SanitizeText(cp.Name)
+	cp.Description = shared.SanitizeText(cp.Description)
+	cp.EquipmentNeeded = shared.SanitizeEquipment(cp.EquipmentNeeded)
+	cp.ComputedDifficulty = shared.ComputeDifficulty(cp.Workouts)
+	for _, week := range cp.Workouts {
+		for idx := range week {
+			week[idx] = week[idx].WithoutPopularity()
+		}
+	}
+
+	if errs := bodyValidation(cp); len(errs) > 0 {
+		return shared.ValidationErrorResponse(errs), nil
+	}
+
+	// Workouts are marshaled after validation so an omitted workouts is never stored as null
 	workoutsData, err := json.Marshal(cp.Workouts)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 		}, fmt.Errorf("Unable to marshal classes: %s", err)
 	}
-
-	err = bodyValidation(cp)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusBadRequest, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	// Workouts is stored as one blob, so it must fit in a DynamoDB item
+	if err := shared.BlobSizeError("workouts", workoutsData); err != nil {
+		return shared.ErrorResponse(http.StatusRequestEntityTooLarge, err.Error()), nil
 	}
 
 	db := shared.GetDB(tableRegion)
 
 	if returnCode, err := nameValidation(cp, tableName, db); err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, returnCode, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: returnCode,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(returnCode, err.Error()), nil
 	}
 
-	err = putItem(cp, workoutsData, tableName, db)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusInternalServerError, err.Error())
-
+	// Only validate the request if dryRun is set
+	if dryRun {
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
+			StatusCode: http.StatusOK,
+			Body:       `{"valid": true}`,
 		}, nil
 	}
 
-	reply, err := json.Marshal(cp)
+	err = putItem(cp, tableName, db)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	return shared.CreatedResponse("programs", cp.ID, cp)
 }
 
 func main() {
-	lambda.Start(addProgram)
+	lambda.Start(shared.Chain(addProgram, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithMaxBodySize, shared.WithJSONBody))
 }
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB returns scanItems from every Scan and records puts
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	scanItems []map[string]*dynamodb.AttributeValue
+	puts      []*dynamodb.PutItemInput
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{Items: m.scanItems}, nil
+}
+
+func (m *mockDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, input)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func TestAddProgramWorkouts(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		dryRun     bool
+		wantStatus int
+		wantPuts   int
+	}{
+		{"without workouts", `{"name": "Power Zone Builder", "numWeeks": 4}`, false, http.StatusBadRequest, 0},
+		{"null workouts", `{"name": "Power Zone Builder", "numWeeks": 4, "workouts": null}`, false, http.StatusBadRequest, 0},
+		{"empty workouts", `{"name": "Power Zone Builder", "numWeeks": 4, "workouts": []}`, false, http.StatusBadRequest, 0},
+		{"with workouts", `{"name": "Power Zone Builder", "numWeeks": 4, "workouts": [[{"id": "ride1", "difficulty_estimate": 7}]]}`, false, http.StatusCreated, 1},
+		{"dry run", `{"name": "Power Zone Builder", "numWeeks": 4, "workouts": [[{"id": "ride1"}]]}`, true, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    tt.body,
+			}
+			if tt.dryRun {
+				request.QueryStringParameters = map[string]string{"dryRun": "true"}
+			}
+			res, err := addProgram(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if len(db.puts) != tt.wantPuts {
+				t.Errorf("%d items written, want %d", len(db.puts), tt.wantPuts)
+			}
+			if tt.wantStatus == http.StatusCreated && res.Headers["Location"] != "/programs/"+*db.puts[0].Item["Id"].S {
+				t.Errorf("Location = %q, want the new program", res.Headers["Location"])
+			}
+			// Error bodies must be valid JSON, including the field that failed
+			if tt.wantStatus == http.StatusBadRequest {
+				body := struct {
+					Errors []shared.FieldError `json:"errors"`
+				}{}
+				if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+					t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+				}
+				if len(body.Errors) != 1 || body.Errors[0].Field != "workouts" {
+					t.Errorf("errors = %+v, want one for workouts", body.Errors)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyValidation(t *testing.T) {
+	errs := bodyValidation(customProgram{})
+	fields := map[string]bool{}
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, f := range []string{"name", "numWeeks", "workouts"} {
+		if !fields[f] {
+			t.Errorf("no error for %s in %+v", f, errs)
+		}
+	}
+}
+
+func TestAddProgramAuditDates(t *testing.T) {
+	db := &mockDB{}
+	defer withMockDB(db)()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"UserID": "user1"},
+		Body:    `{"name": "Power Zone Builder", "numWeeks": 1, "workouts": [[{"id": "ride1"}]], "createdDate": "2000-01-01T00:00:00Z"}`,
+	}
+	res, err := addProgram(context.Background(), request)
+	if err != nil || res.StatusCode != http.StatusCreated {
+		t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+	}
+
+	item := db.puts[0].Item
+	created, updated := *item["CreatedDate"].S, *item["UpdatedDate"].S
+	if created == "2000-01-01T00:00:00Z" {
+		t.Error("createdDate was taken from the request body")
+	}
+	if created != updated {
+		t.Errorf("CreatedDate %s and UpdatedDate %s should match on create", created, updated)
+	}
+}
+
+func TestAddProgramDryRun(t *testing.T) {
+	validBody := `{"name": "Power Zone Builder", "numWeeks": 1, "workouts": [[{"id": "ride1"}]]}`
+	existing := []map[string]*dynamodb.AttributeValue{{"Id": {S: aws.String("p1")}, "Name": {S: aws.String("Power Zone Builder")}}}
+
+	tests := []struct {
+		name       string
+		dryRun     string
+		body       string
+		scanItems  []map[string]*dynamodb.AttributeValue
+		wantStatus int
+		wantPuts   int
+	}{
+		{"valid", "true", validBody, nil, http.StatusOK, 0},
+		{"invalid body", "true", `{"numWeeks": 1, "workouts": [[{"id": "ride1"}]]}`, nil, http.StatusBadRequest, 0},
+		{"name taken", "true", validBody, existing, http.StatusBadRequest, 0},
+		{"not a dry run", "false", validBody, nil, http.StatusCreated, 1},
+		{"invalid dryRun", "maybe", validBody, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{scanItems: tt.scanItems}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"UserID": "user1"},
+				QueryStringParameters: map[string]string{"dryRun": tt.dryRun},
+				Body:                  tt.body,
+			}
+			res, err := addProgram(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if len(db.puts) != tt.wantPuts {
+				t.Errorf("PutItem called %d times, want %d", len(db.puts), tt.wantPuts)
+			}
+			if tt.wantStatus == http.StatusOK && res.Body != `{"valid": true}` {
+				t.Errorf("body = %s, want {\"valid\": true}", res.Body)
+			}
+		})
+	}
+}
+
+func TestEquipmentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		equipment string
+		want      []string
+	}{
+		{"omitted", "", []string{}},
+		{"empty", `"equipmentNeeded": [],`, []string{}},
+		{"populated", `"equipmentNeeded": ["Mat", "Weights"],`, []string{"Mat", "Weights"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    `{"name": "Power Zone Builder", ` + tt.equipment + ` "numWeeks": 1, "workouts": [[{"id": "ride1"}]]}`,
+			}
+			res, err := addProgram(context.Background(), request)
+			if err != nil || res.StatusCode != http.StatusCreated {
+				t.Fatalf("StatusCode = %d, error %v: %s", res.StatusCode, err, res.Body)
+			}
+
+			// DynamoDB rejects a string set without members
+			item := db.puts[0].Item
+			if equipment, ok := item["EquipmentNeeded"]; ok && len(equipment.SS) == 0 {
+				t.Fatalf("EquipmentNeeded is stored as %v", equipment)
+			}
+			program, err := shared.FormatProgram(item, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(program.EquipmentNeeded, tt.want) {
+				t.Errorf("EquipmentNeeded = %#v, want %#v", program.EquipmentNeeded, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddProgramTextLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		programName string
+		description string
+		wantFields  []string
+	}{
+		{"at the limits", strings.Repeat("n", shared.MaxNameLength), strings.Repeat("d", shared.MaxDescriptionLength), nil},
+		{"name over", strings.Repeat("n", shared.MaxNameLength+1), "", []string{"name"}},
+		{"description over", "Power Zone Builder", strings.Repeat("d", shared.MaxDescriptionLength+1), []string{"description"}},
+		{"both over", strings.Repeat("n", shared.MaxNameLength+1), strings.Repeat("d", shared.MaxDescriptionLength+1), []string{"name", "description"}},
+		{"only whitespace", " \t ", "", []string{"name"}},
+		{"only control characters", "\x00\x1b", "", []string{"name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			body, _ := json.Marshal(map[string]interface{}{
+				"name":        tt.programName,
+				"description": tt.description,
+				"numWeeks":    1,
+				"workouts":    [][]map[string]string{{{"id": "ride1"}}},
+			})
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    string(body),
+			}
+			res, err := addProgram(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantFields == nil {
+				if res.StatusCode != http.StatusCreated {
+					t.Errorf("StatusCode = %d, want 201: %s", res.StatusCode, res.Body)
+				}
+				return
+			}
+
+			errBody := struct {
+				Errors []shared.FieldError `json:"errors"`
+			}{}
+			if err := json.Unmarshal([]byte(res.Body), &errBody); err != nil || res.StatusCode != http.StatusBadRequest {
+				t.Fatalf("response = %d %s, want a 400 with field errors", res.StatusCode, res.Body)
+			}
+			fields := []string{}
+			for _, e := range errBody.Errors {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields with errors = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestProgramRoundTrip(t *testing.T) {
+	cp := customProgram{
+		ID:                 "p1",
+		Name:               "Power Zone Builder",
+		Description:        "Six weeks of rides",
+		Public:             true,
+		EquipmentNeeded:    []string{"Bike"},
+		NumWeeks:           2,
+		Workouts:           shared.WorkoutsBlob{{{ID: "w1", Difficulty: 6}}, {{ID: "w2", Difficulty: 8}}},
+		CreatedBy:          "user1",
+		CreatedDate:        "2024-05-01T00:00:00Z",
+		UpdatedDate:        "2024-05-01T00:00:00Z",
+		ComputedDifficulty: 7,
+	}
+
+	item, err := dynamodbattribute.MarshalMap(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := shared.FormatProgram(item, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := shared.Program{
+		ID:                 cp.ID,
+		Name:               cp.Name,
+		Description:        cp.Description,
+		Public:             cp.Public,
+		EquipmentNeeded:    cp.EquipmentNeeded,
+		NumWeeks:           cp.NumWeeks,
+		Workouts:           [][]shared.Workout(cp.Workouts),
+		CreatedBy:          cp.CreatedBy,
+		CreatedDate:        cp.CreatedDate,
+		UpdatedDate:        cp.UpdatedDate,
+		ComputedDifficulty: cp.ComputedDifficulty,
+	}
+	if !reflect.DeepEqual(program, want) {
+		t.Errorf("round trip = %+v, want %+v", program, want)
+	}
+}
+
+func TestAddProgramBlobSize(t *testing.T) {
+	workouts := `[[{"id": "ride1", "title": "` + strings.Repeat("a", 200) + `"}]]`
+
+	tests := []struct {
+		name       string
+		maxBytes   string
+		wantStatus int
+		wantPuts   int
+	}{
+		{"under the limit", "1000", http.StatusCreated, 1},
+		{"oversized workouts", "100", http.StatusRequestEntityTooLarge, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+			os.Setenv("max_blob_bytes", tt.maxBytes)
+			defer os.Unsetenv("max_blob_bytes")
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    `{"name": "Power Zone Builder", "numWeeks": 1, "workouts": ` + workouts + `}`,
+			}
+			res, err := addProgram(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if len(db.puts) != tt.wantPuts {
+				t.Errorf("%d items written, want %d", len(db.puts), tt.wantPuts)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge && !strings.Contains(res.Body, tt.maxBytes) {
+				t.Errorf("body %q doesn't explain the limit", res.Body)
+			}
+		})
+	}
+}
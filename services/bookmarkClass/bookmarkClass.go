@@ -7,32 +7,109 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
-// Endpoint:
+// Endpoints:
 //   POST https://api.onepeloton.com/api/favorites/create
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details - only used when validating rides
+
+// Headers:
+//   UserID - Optional. If set, successful bookmarks are recorded in the bookmarks table
+
+// Query Params:
+//   validate - If true, each ride is verified to exist before it is bookmarked. Should be true or false
+//     Defaults to the validate_rides env var, or false if it isn't set
+
+// maxBulkRideIDs is the max number of rides that can be bookmarked in a single request
+const maxBulkRideIDs = 25
+
+// maxConcurrentBookmarks is the max number of Peloton requests made at once for a bulk request
+const maxConcurrentBookmarks = 5
+
+// Bulk bookmark result statuses
+const (
+	resultCreated           = "created"
+	resultAlreadyBookmarked = "already_bookmarked"
+	resultFailed            = "failed"
+)
 
 type bookmarkRequest struct {
-	RideID string `json:"ride_id"`
+	RideID  string   `json:"ride_id"`
+	RideIDs []string `json:"ride_ids,omitempty"`
+}
+
+type bookmarkResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
 }
 
-// getBody attempts to generate the request body and return it
+type bookmarkResponse struct {
+	Status  int    `json:"status"`
+	RideID  string `json:"ride_id"`
+	Message string `json:"message"`
+}
+
+type bulkBookmarkResponse struct {
+	Results map[string]bookmarkResult `json:"results"`
+}
+
+// getRideIDs parses the request body and returns the ride ids to bookmark
+// ride_ids is used for a bulk request, otherwise ride_id is used
 // if an error occurs, the error code and message are returned
-func getBody(url string, request events.APIGatewayV2HTTPRequest) ([]byte, int, error) {
+func getRideIDs(request events.APIGatewayV2HTTPRequest) ([]string, bool, int, error) {
 	bookmarkReq := bookmarkRequest{}
 
 	err := json.Unmarshal([]byte(request.Body), &bookmarkReq)
+	if err != nil {
+		return nil, false, http.StatusBadRequest, errors.New("ride_id or ride_ids is required in request body")
+	}
+
+	if bookmarkReq.RideIDs != nil {
+		rideIDs := []string{}
+		seen := map[string]bool{}
+		for _, id := range bookmarkReq.RideIDs {
+			id = strings.TrimSpace(id)
+			if id == "" || seen[id] {
+				continue
+			}
+			if err := shared.ValidateRideID(id); err != nil {
+				return nil, true, http.StatusBadRequest, err
+			}
+			seen[id] = true
+			rideIDs = append(rideIDs, id)
+		}
+		if len(rideIDs) == 0 {
+			return nil, true, http.StatusBadRequest, errors.New("ride_ids must not be empty")
+		}
+		if len(rideIDs) > maxBulkRideIDs {
+			return nil, true, http.StatusBadRequest, fmt.Errorf("ride_ids must not contain more than %d entries", maxBulkRideIDs)
+		}
+
+		return rideIDs, true, -1, nil
+	}
+
 	bookmarkReq.RideID = strings.TrimSpace(bookmarkReq.RideID)
-	if err != nil || bookmarkReq.RideID == "" {
-		return nil, http.StatusBadRequest, errors.New("ride_id is required in request body")
+	if bookmarkReq.RideID == "" {
+		return nil, false, http.StatusBadRequest, errors.New("ride_id or ride_ids is required in request body")
+	}
+	if err := shared.ValidateRideID(bookmarkReq.RideID); err != nil {
+		return nil, false, http.StatusBadRequest, err
 	}
 
-	bookmarkBytes, err := json.Marshal(bookmarkReq)
+	return []string{bookmarkReq.RideID}, false, -1, nil
+}
+
+// getBody generates the Peloton request body for a single ride
+func getBody(rideID string) ([]byte, int, error) {
+	bookmarkBytes, err := json.Marshal(bookmarkRequest{RideID: rideID})
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("Unable to marshal request: %s", err)
 	}
@@ -40,50 +117,149 @@ func getBody(url string, request events.APIGatewayV2HTTPRequest) ([]byte, int, e
 	return bookmarkBytes, -1, nil
 }
 
-// bookmarkClass bookmarks the class that is passed in
+// shouldValidate returns whether rides should be verified to exist before bookmarking
+func shouldValidate(request events.APIGatewayV2HTTPRequest) (bool, error) {
+	if validateStr, ok := request.QueryStringParameters["validate"]; ok {
+		validate, err := strconv.ParseBool(validateStr)
+		if err != nil {
+			return false, errors.New("validate must be true or false")
+		}
+		return validate, nil
+	}
+
+	validate, _ := strconv.ParseBool(os.Getenv("validate_rides"))
+	return validate, nil
+}
+
+// validateRide verifies the ride exists using the shared ride details cache
+func validateRide(rideID string, headers map[string]string) (int, error) {
+	_, body, _, resCode, err := shared.GetRideDetails(rideID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			return http.StatusNotFound, fmt.Errorf("Ride %s doesn't exist", rideID)
+		}
+		return resCode, errors.New(shared.UpstreamErrorMessage(body, err))
+	}
+
+	return -1, nil
+}
+
+// isAlreadyBookmarked returns true if the Peloton error is because the ride is already bookmarked
+func isAlreadyBookmarked(resCode int, body []byte) bool {
+	if resCode != http.StatusBadRequest && resCode != http.StatusConflict {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(string(body)), "already")
+}
+
+// bookmarkRides bookmarks each ride, making at most maxConcurrentBookmarks requests at once
+// if validate is true, rides that don't exist are marked as failed without being bookmarked
+func bookmarkRides(userID string, rideIDs []string, headers map[string]string, validate bool) map[string]bookmarkResult {
+	results := map[string]bookmarkResult{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentBookmarks)
+
+	for _, id := range rideIDs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(rideID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := bookmarkResult{Status: resultCreated}
+			var err error
+			if validate {
+				_, err = validateRide(rideID, headers)
+			}
+			var reqBody []byte
+			if err == nil {
+				reqBody, _, err = getBody(rideID)
+			}
+			if err == nil {
+				var body []byte
+				var resCode int
+				body, _, resCode, err = shared.PelotonRequest("POST", "/api/favorites/create", headers, bytes.NewBuffer(reqBody))
+				if err != nil && isAlreadyBookmarked(resCode, body) {
+					result.Status = resultAlreadyBookmarked
+					err = nil
+				}
+			}
+			if err != nil {
+				result.Status = resultFailed
+				result.Message = err.Error()
+			} else {
+				shared.SaveBookmark(userID, rideID)
+			}
+
+			mu.Lock()
+			results[rideID] = result
+			mu.Unlock()
+		}(id)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// bookmarkClass bookmarks the class or classes that are passed in
 func bookmarkClass(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	method := "POST"
 	url := "/api/favorites/create"
 	headers := map[string]string{}
 
-	reqBody, resCode, err := getBody(url, request)
+	rideIDs, isBulk, resCode, err := getRideIDs(request)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, resCode, err.Error())
+		return shared.ErrorResponse(resCode, err.Error()), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: resCode,
-			Body:       errBody,
-		}, nil
+	userID := strings.TrimSpace(request.Headers["UserID"])
+
+	validate, err := shouldValidate(request)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
 	}
 
-	// Add peloton cookie heade
+	// Add peloton cookie header
 	if cookie, ok := request.Headers["Cookie"]; ok {
 		headers["Cookie"] = cookie
 	}
 
-	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, bytes.NewBuffer(reqBody))
-	if err != nil {
-		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
-			Body:       err.Error(),
-		}
+	// Bulk requests always return 200 with the result of each ride
+	if isBulk {
+		return shared.JSONResponse(http.StatusOK, bulkBookmarkResponse{Results: bookmarkRides(userID, rideIDs, headers, validate)})
+	}
 
-		if body != nil {
-			res.Body = string(body)
+	rideID := rideIDs[0]
+	if validate {
+		if resCode, err := validateRide(rideID, headers); err != nil {
+			return shared.ErrorResponse(resCode, err.Error()), nil
 		}
+	}
+	reqBody, resCode, err := getBody(rideID)
+	if err != nil {
+		return shared.ErrorResponse(resCode, err.Error()), nil
+	}
 
-		return res, nil
+	message := "Class bookmarked"
+	body, _, resCode, err := shared.PelotonRequest(method, url, headers, bytes.NewBuffer(reqBody))
+	if err != nil {
+		if !isAlreadyBookmarked(resCode, body) {
+			return shared.UpstreamErrorResponse(resCode, body, err), nil
+		}
+		// Bookmarking is idempotent
+		message = "Class already bookmarked"
 	}
+	shared.SaveBookmark(userID, rideID)
 
-	return events.APIGatewayProxyResponse{
-		StatusCode:        resCode,
-		MultiValueHeaders: respHeaders,
-	}, nil
+	return shared.JSONResponse(http.StatusOK, bookmarkResponse{
+		Status:  http.StatusOK,
+		RideID:  rideID,
+		Message: message,
+	})
 }
 
 func main() {
-	lambda.Start(bookmarkClass)
+	lambda.Start(shared.Chain(bookmarkClass, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithJSONBody))
 }
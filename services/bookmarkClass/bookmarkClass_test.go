@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	newRide        = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	bookmarkedRide = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	brokenRide     = "cccccccccccccccccccccccccccccccc"
+	missingRide    = "dddddddddddddddddddddddddddddddd"
+)
+
+// favoritesServer is a stub of Peloton's favorites/create that tracks how many requests are in flight at once
+type favoritesServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	delay       time.Duration
+	// detailsCalls are the rides whose details were requested to validate them
+	detailsCalls []string
+}
+
+func newFavoritesServer(delay time.Duration) *favoritesServer {
+	s := &favoritesServer{delay: delay}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.inFlight++
+		if s.inFlight > s.maxInFlight {
+			s.maxInFlight = s.inFlight
+		}
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}()
+		time.Sleep(s.delay)
+
+		if strings.HasPrefix(r.URL.Path, "/api/ride/") {
+			rideID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/ride/"), "/details")
+			s.mu.Lock()
+			s.detailsCalls = append(s.detailsCalls, rideID)
+			s.mu.Unlock()
+			if rideID == missingRide {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `{"ride": {"id": "%s"}}`, rideID)
+			return
+		}
+
+		req := bookmarkRequest{}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch req.RideID {
+		case bookmarkedRide:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "Ride is already a favorite"}`)
+		case brokenRide:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message": "Something went wrong"}`)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	os.Setenv("peloton_url", s.URL)
+
+	return s
+}
+
+func (s *favoritesServer) close() {
+	s.Close()
+	os.Unsetenv("peloton_url")
+}
+
+func callBookmarkClass(t *testing.T, body string) events.APIGatewayProxyResponse {
+	t.Helper()
+	return callBookmarkClassWithParams(t, body, nil)
+}
+
+func callBookmarkClassWithParams(t *testing.T, body string, params map[string]string) events.APIGatewayProxyResponse {
+	t.Helper()
+	res, err := bookmarkClass(context.Background(), events.APIGatewayV2HTTPRequest{Body: body, QueryStringParameters: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestGetRideIDs(t *testing.T) {
+	tooMany := []string{}
+	for i := 0; i <= maxBulkRideIDs; i++ {
+		tooMany = append(tooMany, fmt.Sprintf(`"%032x"`, i))
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		want       []string
+		wantBulk   bool
+		wantStatus int
+	}{
+		{"single", `{"ride_id": " ` + newRide + ` "}`, []string{newRide}, false, -1},
+		{"bulk deduped", `{"ride_ids": ["` + newRide + `", "", "` + newRide + `", "` + bookmarkedRide + `"]}`, []string{newRide, bookmarkedRide}, true, -1},
+		{"bulk at the limit", `{"ride_ids": [` + strings.Join(tooMany[1:], ",") + `]}`, nil, true, -1},
+		{"bulk over the limit", `{"ride_ids": [` + strings.Join(tooMany, ",") + `]}`, nil, true, http.StatusBadRequest},
+		{"bulk empty", `{"ride_ids": []}`, nil, true, http.StatusBadRequest},
+		{"bulk invalid id", `{"ride_ids": ["not-a-ride"]}`, nil, true, http.StatusBadRequest},
+		{"missing", `{}`, nil, false, http.StatusBadRequest},
+		{"invalid body", `{`, nil, false, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isBulk, status, err := getRideIDs(events.APIGatewayV2HTTPRequest{Body: tt.body})
+			if status != tt.wantStatus || (err != nil) != (tt.wantStatus != -1) {
+				t.Fatalf("getRideIDs() status = %d, error %v, want %d", status, err, tt.wantStatus)
+			}
+			if isBulk != tt.wantBulk {
+				t.Errorf("isBulk = %t, want %t", isBulk, tt.wantBulk)
+			}
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ride ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookmarkClassSingle(t *testing.T) {
+	server := newFavoritesServer(0)
+	defer server.close()
+
+	tests := []struct {
+		name        string
+		rideID      string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"created", newRide, http.StatusOK, "Class bookmarked"},
+		{"already bookmarked", bookmarkedRide, http.StatusOK, "Class already bookmarked"},
+		{"upstream failure", brokenRide, http.StatusBadGateway, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := callBookmarkClass(t, `{"ride_id": "`+tt.rideID+`"}`)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			// Errors use the same JSON envelope as successes
+			if res.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", res.Headers["Content-Type"])
+			}
+			if !json.Valid([]byte(res.Body)) {
+				t.Fatalf("body %q isn't JSON", res.Body)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			body := bookmarkResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.RideID != tt.rideID || body.Message != tt.wantMessage {
+				t.Errorf("body = %+v, want %s for %s", body, tt.wantMessage, tt.rideID)
+			}
+		})
+	}
+}
+
+func TestBookmarkClassRideIDFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+		wantCalls  int
+	}{
+		{"plausible", " 4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c ", http.StatusOK, 1},
+		{"too short", "abc", http.StatusBadRequest, 0},
+		{"not hex", "not-a-ride-id-not-a-ride-id-1234", http.StatusBadRequest, 0},
+		{"path", "../../api/me", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+			os.Setenv("peloton_url", server.URL)
+			defer os.Unsetenv("peloton_url")
+
+			res := callBookmarkClass(t, `{"ride_id": "`+tt.rideID+`"}`)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			// Malformed ids are rejected before Peloton is called
+			if calls != tt.wantCalls {
+				t.Errorf("Peloton called %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantStatus == http.StatusBadRequest && !strings.Contains(res.Body, "ride_id") {
+				t.Errorf("body = %s, want a message about ride_id", res.Body)
+			}
+		})
+	}
+}
+
+func TestBookmarkClassBulkMixedOutcomes(t *testing.T) {
+	server := newFavoritesServer(0)
+	defer server.close()
+
+	res := callBookmarkClass(t, `{"ride_ids": ["`+newRide+`", "`+bookmarkedRide+`", "`+brokenRide+`"]}`)
+	// A partial failure doesn't fail the batch
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want 200: %s", res.StatusCode, res.Body)
+	}
+	body := bulkBookmarkResponse{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{newRide: resultCreated, bookmarkedRide: resultAlreadyBookmarked, brokenRide: resultFailed}
+	got := map[string]string{}
+	for id, result := range body.Results {
+		got[id] = result.Status
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+	if body.Results[brokenRide].Message == "" {
+		t.Error("failed ride has no message")
+	}
+}
+
+func TestBookmarkClassBulkConcurrency(t *testing.T) {
+	server := newFavoritesServer(20 * time.Millisecond)
+	defer server.close()
+
+	ids := []string{}
+	for i := 0; i < maxBulkRideIDs; i++ {
+		ids = append(ids, fmt.Sprintf(`"%032x"`, i))
+	}
+	res := callBookmarkClass(t, `{"ride_ids": [`+strings.Join(ids, ",")+`]}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want 200: %s", res.StatusCode, res.Body)
+	}
+	body := bulkBookmarkResponse{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Results) != maxBulkRideIDs {
+		t.Errorf("%d results, want %d", len(body.Results), maxBulkRideIDs)
+	}
+	if server.maxInFlight > maxConcurrentBookmarks {
+		t.Errorf("%d requests were in flight at once, want at most %d", server.maxInFlight, maxConcurrentBookmarks)
+	}
+	if server.maxInFlight < 2 {
+		t.Errorf("%d requests were in flight at once, want them made concurrently", server.maxInFlight)
+	}
+}
+
+func TestBookmarkClassValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		rideID       string
+		params       map[string]string
+		envFlag      string
+		wantStatus   int
+		wantValidate bool
+	}{
+		{"valid ride", newRide, map[string]string{"validate": "true"}, "", http.StatusOK, true},
+		{"ride doesn't exist", missingRide, map[string]string{"validate": "true"}, "", http.StatusNotFound, true},
+		{"validation disabled", missingRide, nil, "", http.StatusOK, false},
+		{"validation disabled by param", missingRide, map[string]string{"validate": "false"}, "true", http.StatusOK, false},
+		{"validation enabled by env", missingRide, nil, "true", http.StatusNotFound, true},
+		{"invalid validate", newRide, map[string]string{"validate": "maybe"}, "", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFavoritesServer(0)
+			defer server.close()
+			// A ride validated by an earlier run is cached
+			shared.InvalidateRideDetails(tt.rideID, nil)
+			if tt.envFlag != "" {
+				os.Setenv("validate_rides", tt.envFlag)
+				defer os.Unsetenv("validate_rides")
+			}
+
+			res := callBookmarkClassWithParams(t, `{"ride_id": "`+tt.rideID+`"}`, tt.params)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if validated := len(server.detailsCalls) > 0; validated != tt.wantValidate {
+				t.Errorf("validated = %t, want %t", validated, tt.wantValidate)
+			}
+			if tt.wantStatus == http.StatusNotFound && !strings.Contains(res.Body, tt.rideID) {
+				t.Errorf("body %s doesn't name ride %s", res.Body, tt.rideID)
+			}
+		})
+	}
+}
+
+func TestBookmarkClassBulkValidate(t *testing.T) {
+	server := newFavoritesServer(0)
+	defer server.close()
+
+	res := callBookmarkClassWithParams(t, `{"ride_ids": ["`+newRide+`", "`+missingRide+`"]}`, map[string]string{"validate": "true"})
+	body := bulkBookmarkResponse{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Results[newRide].Status != resultCreated || body.Results[missingRide].Status != resultFailed {
+		t.Errorf("results = %+v, want %s created and %s failed", body.Results, newRide, missingRide)
+	}
+}
+
+// bookmarksDB records the rides whose bookmarks are saved, every put fails if err is set
+type bookmarksDB struct {
+	dynamodbiface.DynamoDBAPI
+	mu    sync.Mutex
+	saved []string
+	err   error
+}
+
+func (m *bookmarksDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved = append(m.saved, *input.Item["RideID"].S)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// withBookmarksDB records bookmarks in db, the returned func restores the real client and env
+func withBookmarksDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("bookmarks_table_name", "bookmarks")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("bookmarks_table_name")
+	}
+}
+
+func TestBookmarkClassSync(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		dbErr      error
+		wantStatus int
+		wantSaved  []string
+	}{
+		{"created", `{"ride_id": "` + newRide + `"}`, nil, http.StatusOK, []string{newRide}},
+		{"already bookmarked", `{"ride_id": "` + bookmarkedRide + `"}`, nil, http.StatusOK, []string{bookmarkedRide}},
+		{"upstream failure", `{"ride_id": "` + brokenRide + `"}`, nil, http.StatusBadGateway, nil},
+		{"bulk skips failures", `{"ride_ids": ["` + newRide + `", "` + brokenRide + `"]}`, nil, http.StatusOK, []string{newRide}},
+		{"sync failure", `{"ride_id": "` + newRide + `"}`, errors.New("throttled"), http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFavoritesServer(0)
+			defer server.close()
+			db := &bookmarksDB{err: tt.dbErr}
+			defer withBookmarksDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{Headers: map[string]string{"UserID": "u1"}, Body: tt.body}
+			res, err := bookmarkClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Saving the bookmark is best-effort, so a failure doesn't fail the request
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if !reflect.DeepEqual(db.saved, tt.wantSaved) {
+				t.Errorf("saved bookmarks = %v, want %v", db.saved, tt.wantSaved)
+			}
+		})
+	}
+}
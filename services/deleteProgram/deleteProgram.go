@@ -1,10 +1,12 @@
 package main
 
 import (
+	"net/http"
+
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
 func main() {
-	lambda.Start(shared.DeleteByID)
+	lambda.Start(shared.Chain(shared.DeleteByID, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodDelete), shared.WithRecovery))
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Served at GET /users/me/badges
+
+// Headers:
+//   UserID - the user whose badges are returned
+
+// Query Params:
+//   year - optional, only badges earned in the year are returned, ex) 2024
+
+type badge struct {
+	ChallengeID string `json:"challengeId"`
+	Tier        string `json:"tier"`
+	CompletedAt string `json:"completedAt"`
+	FinalCount  int    `json:"finalCount"`
+	// The challenge's name, difficulty and dates are empty if it was deleted after the user completed it
+	Name       string  `json:"name"`
+	Difficulty float32 `json:"difficulty"`
+	StartDate  string  `json:"startDate"`
+	EndDate    string  `json:"endDate"`
+	GoalType   string  `json:"goalType"`
+	GoalValue  int     `json:"goalValue"`
+}
+
+// inYear returns true if the completion was recorded in the year
+// CompletedAt is RFC3339 so its first 4 characters are the year
+func inYear(c shared.Completion, year string) bool {
+	return strings.HasPrefix(c.CompletedAt, year+"-")
+}
+
+// getBadges returns a badge for each challenge the user has completed, most recently earned first
+func getBadges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	year, filterYear := request.QueryStringParameters["year"]
+	if filterYear {
+		year = strings.TrimSpace(year)
+		if y, err := strconv.Atoi(year); err != nil || len(year) != 4 || y < 1 {
+			return shared.ErrorResponse(http.StatusBadRequest, "year must be a 4 digit year, ex) 2024"), nil
+		}
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	completionsTableName, err := shared.GetCompletionsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	completions, err := shared.GetUserCompletions(db, completionsTableName, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if filterYear {
+		filtered := []shared.Completion{}
+		for _, c := range completions {
+			if inYear(c, year) {
+				filtered = append(filtered, c)
+			}
+		}
+		completions = filtered
+	}
+
+	ids := []string{}
+	for _, c := range completions {
+		ids = append(ids, c.ChallengeID)
+	}
+	challenges, err := shared.BatchGetChallenges(db, tableName, ids)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	// The badge is the user's record, so it's kept even if the challenge was deleted
+	badges := []badge{}
+	for _, c := range completions {
+		b := badge{
+			ChallengeID: c.ChallengeID,
+			Tier:        c.BadgeTier,
+			CompletedAt: c.CompletedAt,
+			FinalCount:  c.FinalCount,
+		}
+		if challenge, ok := challenges[c.ChallengeID]; ok {
+			b.Name = challenge.Name
+			b.Difficulty = challenge.Difficulty
+			b.StartDate = challenge.StartDate
+			b.EndDate = challenge.EndDate
+			b.GoalType = challenge.GoalType
+			b.GoalValue = challenge.GoalValue
+			// Completions recorded before badges existed weren't stamped with a tier
+			if b.Tier == "" {
+				b.Tier = shared.ComputeBadgeTier(challenge)
+			}
+		}
+		if b.Tier == "" {
+			b.Tier = shared.BadgeBronze
+		}
+		badges = append(badges, b)
+	}
+	sort.SliceStable(badges, func(i, j int) bool {
+		return badges[i].CompletedAt > badges[j].CompletedAt
+	})
+
+	return shared.JSONResponse(http.StatusOK, badges)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getBadges, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
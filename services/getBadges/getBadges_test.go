@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB scans completions by UserId and batch gets challenges by Id
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	completions []map[string]*dynamodb.AttributeValue
+	challenges  map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	userID := *input.ExpressionAttributeValues[":userId"].S
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, c := range m.completions {
+		if *c["UserId"].S == userID {
+			items = append(items, c)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (m *mockDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	responses := map[string][]map[string]*dynamodb.AttributeValue{}
+	for table, keys := range input.RequestItems {
+		for _, k := range keys.Keys {
+			if c, ok := m.challenges[*k["Id"].S]; ok {
+				responses[table] = append(responses[table], c)
+			}
+		}
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("completions_table_name", "completions")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("completions_table_name")
+	}
+}
+
+func completion(challengeID, completedAt, tier string) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":          {S: aws.String(shared.ParticipationID(challengeID, "user1"))},
+		"ChallengeId": {S: aws.String(challengeID)},
+		"UserId":      {S: aws.String("user1")},
+		"CompletedAt": {S: aws.String(completedAt)},
+		"FinalCount":  {N: aws.String("40")},
+	}
+	if tier != "" {
+		item["BadgeTier"] = &dynamodb.AttributeValue{S: aws.String(tier)}
+	}
+
+	return item
+}
+
+// challenge is advanced with a 40 workout goal, so ComputeBadgeTier returns platinum
+func challenge(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":         {S: aws.String(id)},
+		"Type":       {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy":  {S: aws.String("user2")},
+		"Name":       {S: aws.String("Challenge " + id)},
+		"Difficulty": {N: aws.String("8")},
+		"GoalType":   {S: aws.String(shared.GoalTypeWorkouts)},
+		"GoalValue":  {N: aws.String("40")},
+	}
+}
+
+func TestGetBadges(t *testing.T) {
+	db := &mockDB{
+		completions: []map[string]*dynamodb.AttributeValue{
+			// Stamped before the formula changed, so the stamped tier is kept
+			completion("stamped", "2024-05-20T12:00:00Z", shared.BadgeSilver),
+			// Recorded before badges existed
+			completion("legacy", "2023-06-01T12:00:00Z", ""),
+			completion("deleted", "2024-01-10T12:00:00Z", ""),
+		},
+		challenges: map[string]map[string]*dynamodb.AttributeValue{
+			"stamped": challenge("stamped"),
+			"legacy":  challenge("legacy"),
+		},
+	}
+	defer withMockDB(db)()
+
+	type entry struct {
+		id, tier, name string
+	}
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		want       []entry
+	}{
+		{
+			"all years",
+			nil,
+			http.StatusOK,
+			[]entry{{"stamped", shared.BadgeSilver, "Challenge stamped"}, {"deleted", shared.BadgeBronze, ""}, {"legacy", shared.BadgePlatinum, "Challenge legacy"}},
+		},
+		{"2024", map[string]string{"year": "2024"}, http.StatusOK, []entry{{"stamped", shared.BadgeSilver, "Challenge stamped"}, {"deleted", shared.BadgeBronze, ""}}},
+		{"2023", map[string]string{"year": " 2023 "}, http.StatusOK, []entry{{"legacy", shared.BadgePlatinum, "Challenge legacy"}}},
+		{"no badges that year", map[string]string{"year": "2020"}, http.StatusOK, []entry{}},
+		{"year isn't a number", map[string]string{"year": "last"}, http.StatusBadRequest, nil},
+		{"year isn't 4 digits", map[string]string{"year": "24"}, http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"UserID": "user1"},
+				QueryStringParameters: tt.params,
+			}
+			res, err := shared.WithUserID(getBadges)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			badges := []badge{}
+			if err := json.Unmarshal([]byte(res.Body), &badges); err != nil {
+				t.Fatal(err)
+			}
+			got := []entry{}
+			for _, b := range badges {
+				got = append(got, entry{b.ChallengeID, b.Tier, b.Name})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("badges = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInYear(t *testing.T) {
+	tests := []struct {
+		completedAt string
+		year        string
+		want        bool
+	}{
+		{"2024-05-10T12:00:00Z", "2024", true},
+		{"2024-12-31T23:59:59Z", "2024", true},
+		{"2023-12-31T23:59:59Z", "2024", false},
+		{"20245-01-01T00:00:00Z", "2024", false},
+		{"", "2024", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.completedAt, func(t *testing.T) {
+			if got := inYear(shared.Completion{CompletedAt: tt.completedAt}, tt.year); got != tt.want {
+				t.Errorf("inYear() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
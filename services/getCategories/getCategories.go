@@ -36,7 +36,7 @@ func getCategories(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, nil, nil)
 	if err != nil {
 		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
+			StatusCode: shared.UpstreamStatus(resCode, err),
 			Body:       err.Error(),
 		}
 
@@ -70,5 +70,5 @@ func getCategories(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 }
 
 func main() {
-	lambda.Start(getCategories)
+	lambda.Start(shared.Chain(getCategories, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
 }
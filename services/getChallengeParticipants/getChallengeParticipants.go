@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge to list the participants of
+
+// Query Params:
+//   limit - max number of participants to return. Defaults to 25, max of 100
+//   cursor - nextCursor from the previous page
+
+// Number of participants returned per page
+const (
+	defaultLimit = 25
+	maxLimit     = 100
+)
+
+// maxConcurrentLookups is the max number of username lookups made at once
+const maxConcurrentLookups = 10
+
+type participant struct {
+	UserID string `json:"userId"`
+	// Username is empty if the lookup failed
+	Username string `json:"username"`
+	JoinedAt string `json:"joinedAt"`
+}
+
+// participantsPage is a page of participants
+// nextCursor is empty when there are no more participants
+type participantsPage struct {
+	Items      []participant `json:"items"`
+	NextCursor string        `json:"nextCursor"`
+}
+
+// encodeCursor converts the Id of the last participation on a page to an opaque cursor
+func encodeCursor(id string) string {
+	cursor, _ := json.Marshal(map[string]string{"Id": id})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// decodeCursor converts a cursor back into the Id of the last participation of the previous page
+// the cursor must be from a page of the same challenge
+func decodeCursor(cursor, challengeID string) (string, error) {
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("cursor is invalid")
+	}
+	key := map[string]string{}
+	err = json.Unmarshal(cursorBytes, &key)
+	if err != nil || !strings.HasPrefix(key["Id"], shared.ParticipationID(challengeID, "")) {
+		return "", errors.New("cursor is invalid")
+	}
+
+	return key["Id"], nil
+}
+
+// canViewParticipants returns the status to respond with if the user can't see the challenge's participants
+// anyone can see the participants of a public challenge, otherwise only the creator and participants can
+func canViewParticipants(db dynamodbiface.DynamoDBAPI, participantsTableName string, challenge shared.Challenge, userID string) (int, error) {
+	if !challenge.VisibleTo(userID) {
+		// Reported as not found so the ids of private challenges can't be probed
+		return http.StatusNotFound, fmt.Errorf("Unable to find challenge %s", challenge.ID)
+	}
+	if challenge.Public || challenge.CreatedBy == userID {
+		return -1, nil
+	}
+
+	_, joined, err := shared.GetParticipation(db, participantsTableName, challenge.ID, userID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !joined {
+		return http.StatusForbidden, errors.New("Must be the owner or a participant of the challenge to see its participants")
+	}
+
+	return -1, nil
+}
+
+// lookupUsernames returns the username of each participant, keyed on user id
+// usernames are best-effort, a user whose lookup fails is left out of the map
+func lookupUsernames(participations []shared.Participation, headers map[string]string) map[string]string {
+	usernames := map[string]string{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentLookups)
+
+	for _, p := range participations {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(userID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			username, _, err := shared.GetUsername(userID, headers)
+			if err != nil {
+				log.Printf("Unable to get username of %s: %s", userID, err)
+				return
+			}
+			mu.Lock()
+			usernames[userID] = username
+			mu.Unlock()
+		}(p.UserID)
+	}
+	wg.Wait()
+
+	return usernames
+}
+
+// getChallengeParticipants returns a page of the users that joined a challenge
+func getChallengeParticipants(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+	headers := map[string]string{}
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	limit := defaultLimit
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > maxLimit {
+			return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("limit must be a number between 1 and %d", maxLimit)), nil
+		}
+		limit = l
+	}
+	startAfter := ""
+	if cursor := strings.TrimSpace(request.QueryStringParameters["cursor"]); cursor != "" {
+		id, err := decodeCursor(cursor, challengeID)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		startAfter = id
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	if returnCode, err := canViewParticipants(db, participantsTableName, challenge, userID); err != nil {
+		return shared.ErrorResponse(returnCode, err.Error()), nil
+	}
+
+	participations, lastID, err := shared.ListParticipants(db, participantsTableName, challengeID, limit, startAfter)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+	usernames := lookupUsernames(participations, headers)
+
+	page := participantsPage{
+		Items: []participant{},
+	}
+	for _, p := range participations {
+		page.Items = append(page.Items, participant{
+			UserID:   p.UserID,
+			Username: usernames[p.UserID],
+			JoinedAt: p.JoinedDate,
+		})
+	}
+	if lastID != "" {
+		page.NextCursor = encodeCursor(lastID)
+	}
+
+	return shared.JSONResponse(http.StatusOK, page)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getChallengeParticipants, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	publicID  = "11111111-1111-4111-8111-111111111111"
+	privateID = "22222222-2222-4222-8222-222222222222"
+	missingID = "33333333-3333-4333-8333-333333333333"
+	// unknownUser's username lookup fails
+	unknownUser = "participant9"
+)
+
+// mockDB serves challenges by Id and scans the participation records in Id order
+// each Scan reads at most Limit records after ExclusiveStartKey, like DynamoDB
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges   map[string]map[string]*dynamodb.AttributeValue
+	participants []map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName != "participants" {
+		return &dynamodb.GetItemOutput{Item: m.challenges[*input.Key["Id"].S]}, nil
+	}
+	for _, p := range m.participants {
+		if *p["Id"].S == *input.Key["Id"].S {
+			return &dynamodb.GetItemOutput{Item: p}, nil
+		}
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	challengeID := *input.ExpressionAttributeValues[":challengeId"].S
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		start = sort.Search(len(m.participants), func(i int) bool {
+			return *m.participants[i]["Id"].S > *input.ExclusiveStartKey["Id"].S
+		})
+	}
+	end := len(m.participants)
+	if input.Limit != nil && start+int(*input.Limit) < end {
+		end = start + int(*input.Limit)
+	}
+
+	output := &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{}}
+	for _, p := range m.participants[start:end] {
+		if *p["ChallengeId"].S == challengeID {
+			output.Items = append(output.Items, p)
+		}
+	}
+	if end < len(m.participants) {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": m.participants[end-1]["Id"]}
+	}
+
+	return output, nil
+}
+
+// withMocks points the handler at db and a Peloton stub that knows every user except unknownUser
+// the returned func restores the real client and env
+func withMocks(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID := strings.TrimPrefix(r.URL.Path, "/api/user/")
+		if userID == unknownUser {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"username": "name-%s"}`, userID)
+	}))
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+	os.Setenv("peloton_url", server.URL)
+
+	return func() {
+		shared.NewDB = newDB
+		server.Close()
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+		os.Unsetenv("peloton_url")
+	}
+}
+
+func challengeItem(id string, public bool) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String("owner")},
+		"Public":    {BOOL: aws.Bool(public)},
+		// Participants of a private challenge were invited to it before they joined
+		"InvitedUsers": {SS: aws.StringSlice([]string{"invited", "participant1", "participant2", "participant3", "participant4", unknownUser})},
+	}
+}
+
+func participationItem(challengeID, userID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":          {S: aws.String(shared.ParticipationID(challengeID, userID))},
+		"ChallengeId": {S: aws.String(challengeID)},
+		"UserId":      {S: aws.String(userID)},
+		"JoinedDate":  {S: aws.String("2024-05-01T00:00:00Z")},
+	}
+}
+
+// participantsDB has 5 participants in each challenge, sorted by Id
+func participantsDB() *mockDB {
+	db := &mockDB{challenges: map[string]map[string]*dynamodb.AttributeValue{
+		publicID:  challengeItem(publicID, true),
+		privateID: challengeItem(privateID, false),
+	}}
+	for _, id := range []string{publicID, privateID} {
+		for i := 1; i <= 4; i++ {
+			db.participants = append(db.participants, participationItem(id, "participant"+strconv.Itoa(i)))
+		}
+		db.participants = append(db.participants, participationItem(id, unknownUser))
+	}
+	sort.Slice(db.participants, func(i, j int) bool {
+		return *db.participants[i]["Id"].S < *db.participants[j]["Id"].S
+	})
+
+	return db
+}
+
+func getParticipants(t *testing.T, userID, challengeID string, params map[string]string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:               map[string]string{"UserID": userID},
+		PathParameters:        map[string]string{"challengeId": challengeID},
+		QueryStringParameters: params,
+	}
+	res, err := shared.WithUserID(getChallengeParticipants)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestGetChallengeParticipantsAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		challengeID string
+		wantStatus  int
+	}{
+		{"stranger on a public challenge", "stranger", publicID, http.StatusOK},
+		{"owner of a private challenge", "owner", privateID, http.StatusOK},
+		{"participant of a private challenge", "participant1", privateID, http.StatusOK},
+		{"invited to a private challenge", "invited", privateID, http.StatusForbidden},
+		{"stranger on a private challenge", "stranger", privateID, http.StatusNotFound},
+		{"unknown challenge", "owner", missingID, http.StatusNotFound},
+		{"invalid id", "owner", "c1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer withMocks(participantsDB())()
+
+			res := getParticipants(t, tt.userID, tt.challengeID, nil)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+		})
+	}
+}
+
+func TestGetChallengeParticipantsPages(t *testing.T) {
+	defer withMocks(participantsDB())()
+
+	got := []participant{}
+	cursor := ""
+	pages := 0
+	for {
+		params := map[string]string{"limit": "2"}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		res := getParticipants(t, "stranger", publicID, params)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("page %d StatusCode = %d: %s", pages+1, res.StatusCode, res.Body)
+		}
+		page := participantsPage{}
+		if err := json.Unmarshal([]byte(res.Body), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) > 2 {
+			t.Errorf("page %d has %d participants, want at most 2", pages+1, len(page.Items))
+		}
+		got = append(got, page.Items...)
+		pages++
+		if page.NextCursor == "" || pages == 10 {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	if pages != 3 {
+		t.Errorf("got %d pages, want 3", pages)
+	}
+	want := []participant{
+		{UserID: "participant1", Username: "name-participant1"},
+		{UserID: "participant2", Username: "name-participant2"},
+		{UserID: "participant3", Username: "name-participant3"},
+		{UserID: "participant4", Username: "name-participant4"},
+		// The username lookup failed, so only the id is returned
+		{UserID: unknownUser},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("participants = %+v, want %+v", got, want)
+	}
+	for idx := range want {
+		want[idx].JoinedAt = "2024-05-01T00:00:00Z"
+		if got[idx] != want[idx] {
+			t.Errorf("participant %d = %+v, want %+v", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestGetChallengeParticipantsInvalidParams(t *testing.T) {
+	defer withMocks(participantsDB())()
+
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{"limit too small", map[string]string{"limit": "0"}},
+		{"limit too large", map[string]string{"limit": strconv.Itoa(maxLimit + 1)}},
+		{"limit not a number", map[string]string{"limit": "ten"}},
+		{"cursor not base64", map[string]string{"cursor": "!"}},
+		{"cursor from another challenge", map[string]string{"cursor": encodeCursor(shared.ParticipationID(privateID, "participant1"))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := getParticipants(t, "stranger", publicID, tt.params)
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, http.StatusBadRequest, res.Body)
+			}
+		})
+	}
+}
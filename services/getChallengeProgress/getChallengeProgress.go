@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge to get the user's progress in
+
+// Headers:
+//   UserID - the user whose progress is returned
+
+// getChallengeProgress returns the user's progress in a challenge they joined
+func getChallengeProgress(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	participation, joined, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if !joined {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Not a participant of challenge %s", challengeID)), nil
+	}
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	return shared.JSONResponse(http.StatusOK, participation.Summary(challenge))
+}
+
+func main() {
+	lambda.Start(shared.Chain(getChallengeProgress, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
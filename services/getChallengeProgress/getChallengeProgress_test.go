@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	workoutsID  = "11111111-1111-4111-8111-111111111111"
+	minutesID   = "22222222-2222-4222-8222-222222222222"
+	deletedID   = "33333333-3333-4333-8333-333333333333"
+	notJoinedID = "44444444-4444-4444-8444-444444444444"
+)
+
+// mockDB serves challenges and participation records by Id
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges   map[string]map[string]*dynamodb.AttributeValue
+	participants map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName == "participants" {
+		return &dynamodb.GetItemOutput{Item: m.participants[*input.Key["Id"].S]}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: m.challenges[*input.Key["Id"].S]}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+	}
+}
+
+func challengeItem(id, goalType, goalValue string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String("u2")},
+		"Public":    {BOOL: aws.Bool(true)},
+		"StartDate": {S: aws.String("2024-05-01")},
+		"EndDate":   {S: aws.String("2024-05-31")},
+		"GoalType":  {S: aws.String(goalType)},
+		"GoalValue": {N: aws.String(goalValue)},
+		"SubGoals":  {B: []byte(`[{"workoutType":"cycling","count":2},{"workoutType":"yoga","count":1}]`)},
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+func participationItem(t *testing.T, challengeID string) map[string]*dynamodb.AttributeValue {
+	logged, err := dynamodbattribute.Marshal([]shared.LoggedWorkout{
+		{WorkoutID: "r1", Discipline: "cycling", Minutes: 30, CompletedAt: "2024-05-10T12:00:00Z"},
+		{WorkoutID: "r2", Discipline: "cycling", Minutes: 45, CompletedAt: "2024-05-11T12:00:00Z"},
+		{WorkoutID: "r3", Discipline: "strength", Minutes: 20, CompletedAt: "2024-05-12T12:00:00Z"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]*dynamodb.AttributeValue{
+		"Id":                  {S: aws.String(shared.ParticipationID(challengeID, "u1"))},
+		"ChallengeId":         {S: aws.String(challengeID)},
+		"UserId":              {S: aws.String("u1")},
+		"CompletedWorkouts":   {N: aws.String("3")},
+		"CompletedMinutes":    {N: aws.String("95")},
+		"CompletedWorkoutIDs": {SS: aws.StringSlice([]string{"r1", "r2", "r3"})},
+		"LoggedWorkouts":      logged,
+	}
+}
+
+func TestGetChallengeProgress(t *testing.T) {
+	db := &mockDB{
+		challenges: map[string]map[string]*dynamodb.AttributeValue{
+			workoutsID:  challengeItem(workoutsID, shared.GoalTypeWorkouts, "12", nil),
+			minutesID:   challengeItem(minutesID, shared.GoalTypeMinutes, "90", nil),
+			notJoinedID: challengeItem(notJoinedID, shared.GoalTypeWorkouts, "12", nil),
+			deletedID: challengeItem(deletedID, shared.GoalTypeWorkouts, "12", map[string]*dynamodb.AttributeValue{
+				"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+			}),
+		},
+		participants: map[string]map[string]*dynamodb.AttributeValue{
+			shared.ParticipationID(workoutsID, "u1"): participationItem(t, workoutsID),
+			shared.ParticipationID(minutesID, "u1"):  participationItem(t, minutesID),
+			shared.ParticipationID(deletedID, "u1"):  participationItem(t, deletedID),
+		},
+	}
+	defer withMockDB(db)()
+
+	tests := []struct {
+		name          string
+		challengeID   string
+		wantStatus    int
+		wantCompleted int
+		wantPercent   float64
+		wantGoalMet   bool
+		wantSubGoals  []int
+	}{
+		{"workouts goal", workoutsID, http.StatusOK, 3, 25, false, []int{2, 0}},
+		{"minutes goal", minutesID, http.StatusOK, 95, 100, true, []int{75, 0}},
+		{"not joined", notJoinedID, http.StatusNotFound, 0, 0, false, nil},
+		{"deleted challenge", deletedID, http.StatusNotFound, 0, 0, false, nil},
+		{"invalid id", "c1", http.StatusBadRequest, 0, 0, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "u1"},
+				PathParameters: map[string]string{"challengeId": tt.challengeID},
+			}
+			res, err := shared.WithUserID(getChallengeProgress)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			summary := shared.ProgressSummary{}
+			if err := json.Unmarshal([]byte(res.Body), &summary); err != nil {
+				t.Fatal(err)
+			}
+			if summary.Completed != tt.wantCompleted || summary.PercentComplete != tt.wantPercent || summary.GoalMet != tt.wantGoalMet {
+				t.Errorf("summary = %d %v%% met %t, want %d %v%% met %t", summary.Completed, summary.PercentComplete, summary.GoalMet, tt.wantCompleted, tt.wantPercent, tt.wantGoalMet)
+			}
+			if summary.CompletedWorkouts != 3 || summary.CompletedMinutes != 95 {
+				t.Errorf("counters = %d workouts %d minutes, want 3 and 95", summary.CompletedWorkouts, summary.CompletedMinutes)
+			}
+			if len(summary.SubGoals) != len(tt.wantSubGoals) {
+				t.Fatalf("SubGoals = %+v, want %d", summary.SubGoals, len(tt.wantSubGoals))
+			}
+			for idx, want := range tt.wantSubGoals {
+				if summary.SubGoals[idx].Completed != want {
+					t.Errorf("sub-goal %s completed = %d, want %d", summary.SubGoals[idx].WorkoutType, summary.SubGoals[idx].Completed, want)
+				}
+			}
+			if summary.SubGoalsMet {
+				t.Error("SubGoalsMet is true without a yoga workout")
+			}
+			if len(summary.LoggedWorkouts) != 3 {
+				t.Errorf("LoggedWorkouts = %+v, want 3", summary.LoggedWorkouts)
+			}
+			// The challenge has ended, so there are no days remaining
+			if summary.DaysRemaining == nil || *summary.DaysRemaining != 0 {
+				t.Errorf("DaysRemaining = %v, want 0", summary.DaysRemaining)
+			}
+		})
+	}
+}
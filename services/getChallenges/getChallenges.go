@@ -2,235 +2,742 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
-type customChallenge struct {
-	ID              string   `json:"id"`
-	CreatedBy       string   `json:"createdBy"`
-	Name            string   `json:"name"`
-	Description     string   `json:"description"`
-	Public          bool     `json:"public"`
-	EquipmentNeeded []string `json:"equipmentNeeded"`
-	Difficulty      float32  `json:"difficulty"`
-	StartDate       string   `json:"startDate"`
-	EndDate         string   `json:"endDate"`
-	NumWorkoutGoal  int      `json:"numWorkoutGoal"`
-	WorkoutTypes    []string `json:"workoutTypes"`
-}
-
-func formatOutput(item map[string]*dynamodb.AttributeValue) (customChallenge, error) {
-	challenge := customChallenge{}
-	var err error
+func getChallengeByID(db dynamodbiface.DynamoDBAPI, tableName, userID, challengeID string) (events.APIGatewayProxyResponse, error) {
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+
+	// Check if item is not found, the id may be for another kind of item sharing the table
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	// Format getItemOutput to shared.Challenge
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
 
-	if item["Id"].S != nil {
-		challenge.ID = *item["Id"].S
+	// If challenge is not public, created by the user or shared with the user then they don't have access
+	// it's reported as not found so the ids of private challenges can't be probed
+	if !challenge.VisibleTo(userID) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
 	}
-	if item["CreatedBy"].S != nil {
-		challenge.CreatedBy = *item["CreatedBy"].S
+	challenge.IsOwner = challenge.CreatedBy == userID
+
+	return shared.JSONResponse(http.StatusOK, challenge)
+}
+
+// listOptions are the query params that filter and sort the list of challenges
+type listOptions struct {
+	// tag - only return challenges with this tag
+	tag string
+	// sort - startDate, endDate, difficulty, name or created. Defaults to startDate
+	// sorting applies within a page unless the list is read from the indexes
+	sortBy string
+	// order - asc or desc. Defaults to desc for created, otherwise asc
+	order string
+	// status - upcoming, active, completed or all. Defaults to all
+	status string
+	// filter - mine, public or all. Defaults to all
+	filter string
+	// q - only return challenges whose name contains q, ignoring case. Must be at least minQueryLength characters
+	q string
+	// searchDescription - if true, q also matches the description
+	searchDescription bool
+	// equipment - comma separated equipment the user has. If set, only challenges that need a subset of it are returned
+	// equipment is lowercase, nil means the param wasn't set
+	equipment map[string]bool
+	// tier - beginner, intermediate or advanced. If set, only challenges with a difficulty in the tier are returned
+	// nil means the param wasn't set
+	tier *shared.DifficultyTier
+	// includeProgress - if true, the caller's progress is included on each challenge
+	includeProgress bool
+	// includeExpired - if true, challenges that ended more than expiredAfterDays ago are included
+	includeExpired bool
+	// limit - max number of challenges to return. Defaults to 25, max of 100
+	limit int
+	// cursor - nextCursor from the previous page
+	startKey map[string]*dynamodb.AttributeValue
+}
+
+// expiredAfterDays is how long after its EndDate a challenge is hidden unless includeExpired is set
+const expiredAfterDays = 30
+
+// isExpired returns true if the challenge ended more than expiredAfterDays before today
+// challenges with a missing or invalid EndDate are never expired
+func isExpired(challenge shared.Challenge, today time.Time) bool {
+	end, err := time.Parse(shared.DateFormat, challenge.EndDate)
+	if err != nil {
+		return false
 	}
-	if item["Name"].S != nil {
-		challenge.Name = *item["Name"].S
+
+	return end.Before(today.AddDate(0, 0, -expiredAfterDays))
+}
+
+// minQueryLength is the shortest q that can be searched for
+const minQueryLength = 2
+
+// maxScannedItems caps the number of items read from the table for one request
+const maxScannedItems = 5000
+
+// Number of challenges returned per page
+const (
+	defaultLimit = 25
+	maxLimit     = 100
+)
+
+// validStatuses are the values of the status query param
+var validStatuses = []string{shared.StatusUpcoming, shared.StatusActive, shared.StatusCompleted, "all"}
+
+// Values of the filter query param
+const (
+	// filterMine is only challenges created by the user
+	filterMine = "mine"
+	// filterPublic is only public challenges created by other users
+	filterPublic = "public"
+	// filterAll is public challenges, the user's challenges and challenges the user is invited to
+	filterAll = "all"
+)
+
+// validFilters are the values of the filter query param
+var validFilters = []string{filterMine, filterPublic, filterAll}
+
+// getListOptions parses the query params of the list of challenges
+func getListOptions(request events.APIGatewayV2HTTPRequest) (listOptions, error) {
+	opts := listOptions{}
+
+	opts.tag, _ = request.QueryStringParameters["tag"]
+	opts.tag = strings.ToLower(strings.TrimSpace(opts.tag))
+
+	opts.sortBy, _ = request.QueryStringParameters["sort"]
+	opts.sortBy = strings.TrimSpace(opts.sortBy)
+	if opts.sortBy == "" {
+		opts.sortBy = "startDate"
+	}
+	if _, ok := challengeLess[opts.sortBy]; !ok {
+		return listOptions{}, fmt.Errorf("sort must be one of: %s", strings.Join(validSorts, ", "))
+	}
+
+	opts.order, _ = request.QueryStringParameters["order"]
+	opts.order = strings.ToLower(strings.TrimSpace(opts.order))
+	if opts.order == "" {
+		// created has always sorted newest first
+		opts.order = "asc"
+		if opts.sortBy == "created" {
+			opts.order = "desc"
+		}
+	}
+	if opts.order != "asc" && opts.order != "desc" {
+		return listOptions{}, errors.New("order must be asc or desc")
+	}
+
+	opts.status, _ = request.QueryStringParameters["status"]
+	opts.status = strings.ToLower(strings.TrimSpace(opts.status))
+	if opts.status == "" {
+		opts.status = "all"
+	}
+	valid := false
+	for _, s := range validStatuses {
+		if opts.status == s {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return listOptions{}, fmt.Errorf("status must be one of: %s", strings.Join(validStatuses, ", "))
+	}
+
+	opts.filter, _ = request.QueryStringParameters["filter"]
+	opts.filter = strings.ToLower(strings.TrimSpace(opts.filter))
+	if opts.filter == "" {
+		opts.filter = filterAll
+	}
+	valid = false
+	for _, f := range validFilters {
+		if opts.filter == f {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return listOptions{}, fmt.Errorf("filter must be one of: %s", strings.Join(validFilters, ", "))
+	}
+
+	opts.q, _ = request.QueryStringParameters["q"]
+	opts.q = strings.TrimSpace(opts.q)
+	if opts.q != "" && len([]rune(opts.q)) < minQueryLength {
+		return listOptions{}, fmt.Errorf("q must be at least %d characters", minQueryLength)
 	}
-	if item["Description"].S != nil {
-		challenge.Description = *item["Description"].S
+	if searchStr, ok := request.QueryStringParameters["searchDescription"]; ok {
+		searchDescription, err := strconv.ParseBool(searchStr)
+		if err != nil {
+			return listOptions{}, errors.New("searchDescription must be true or false")
+		}
+		opts.searchDescription = searchDescription
 	}
-	if item["Public"].BOOL != nil {
-		challenge.Public = *item["Public"].BOOL
+
+	if equipmentStr, ok := request.QueryStringParameters["equipment"]; ok {
+		opts.equipment = map[string]bool{}
+		for _, e := range strings.Split(equipmentStr, ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" {
+				opts.equipment[e] = true
+			}
+		}
 	}
-	if item["EquipmentNeeded"].SS != nil {
-		for _, en := range item["EquipmentNeeded"].SS {
-			challenge.EquipmentNeeded = append(challenge.EquipmentNeeded, *en)
+
+	if tierStr, ok := request.QueryStringParameters["tier"]; ok {
+		tier, ok := shared.GetDifficultyTier(strings.TrimSpace(tierStr))
+		if !ok {
+			return listOptions{}, fmt.Errorf("tier must be one of: %s", strings.Join(shared.DifficultyTierNames(), ", "))
 		}
+		opts.tier = &tier
 	}
-	if item["Difficulty"].N != nil {
-		diff, err := strconv.ParseFloat(*item["Difficulty"].N, 32)
+
+	if includeStr, ok := request.QueryStringParameters["includeProgress"]; ok {
+		includeProgress, err := strconv.ParseBool(includeStr)
 		if err != nil {
-			return customChallenge{}, fmt.Errorf("Unable to convert Difficulty to float: %s", err)
+			return listOptions{}, errors.New("includeProgress must be true or false")
 		}
-		challenge.Difficulty = float32(diff)
+		opts.includeProgress = includeProgress
 	}
-	if item["StartDate"].S != nil {
-		challenge.StartDate = *item["StartDate"].S
+
+	if includeStr, ok := request.QueryStringParameters["includeExpired"]; ok {
+		includeExpired, err := strconv.ParseBool(includeStr)
+		if err != nil {
+			return listOptions{}, errors.New("includeExpired must be true or false")
+		}
+		opts.includeExpired = includeExpired
 	}
-	if item["EndDate"].S != nil {
-		challenge.EndDate = *item["EndDate"].S
+
+	opts.limit = defaultLimit
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return listOptions{}, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+		}
+		opts.limit = limit
 	}
-	if item["NumWorkoutGoal"].N != nil {
-		challenge.NumWorkoutGoal, err = strconv.Atoi(*item["NumWorkoutGoal"].N)
+
+	if cursor := strings.TrimSpace(request.QueryStringParameters["cursor"]); cursor != "" {
+		startKey, err := decodeCursor(cursor)
 		if err != nil {
-			return customChallenge{}, fmt.Errorf("Unable to convert NumWorkoutGoal to int: %s", err)
+			return listOptions{}, err
 		}
+		opts.startKey = startKey
 	}
-	if item["WorkoutTypes"].SS != nil {
-		for _, wt := range item["WorkoutTypes"].SS {
-			challenge.WorkoutTypes = append(challenge.WorkoutTypes, *wt)
+
+	return opts, nil
+}
+
+// challengeLess compares two challenges by a sort key in ascending order
+var challengeLess = map[string]func(a, b shared.Challenge) bool{
+	"startDate":  func(a, b shared.Challenge) bool { return a.StartDate < b.StartDate },
+	"endDate":    func(a, b shared.Challenge) bool { return a.EndDate < b.EndDate },
+	"difficulty": func(a, b shared.Challenge) bool { return a.Difficulty < b.Difficulty },
+	"name":       func(a, b shared.Challenge) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) },
+	"created":    func(a, b shared.Challenge) bool { return a.CreatedDate < b.CreatedDate },
+}
+
+// validSorts are the values of the sort query param
+var validSorts = []string{"startDate", "endDate", "difficulty", "name", "created"}
+
+// sortChallenges sorts the challenges by the sort and order query params
+// the sort is stable so challenges with equal keys keep the scan order
+func sortChallenges(challenges []shared.Challenge, sortBy, order string) {
+	less := challengeLess[sortBy]
+	sort.SliceStable(challenges, func(i, j int) bool {
+		if order == "desc" {
+			return less(challenges[j], challenges[i])
 		}
+		return less(challenges[i], challenges[j])
+	})
+}
+
+// statusFilter returns the FilterExpression for a status
+// dates are stored as YYYY-MM-DD so they can be compared as strings
+func statusFilter(status string) string {
+	switch status {
+	case shared.StatusUpcoming:
+		return "(StartDate > :today or attribute_not_exists(StartDate))"
+	case shared.StatusActive:
+		return "(StartDate <= :today and EndDate >= :today)"
+	case shared.StatusCompleted:
+		return "EndDate < :today"
 	}
 
-	return challenge, nil
+	return ""
 }
 
-func getChallengeByID(db *dynamodb.DynamoDB, tableName, userID, challengeID string) (events.APIGatewayProxyResponse, error) {
-	getItemInput := &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]*dynamodb.AttributeValue{
-			"Id": {S: aws.String(challengeID)},
-		},
+// ownershipFilter returns the FilterExpression for a filter and whether it uses #P and :public
+func ownershipFilter(filter string) (string, bool) {
+	switch filter {
+	case filterMine:
+		return "CreatedBy = :createdBy", false
+	case filterPublic:
+		return "(#P = :public and CreatedBy <> :createdBy)", true
 	}
-	getItemOutput, err := db.GetItem(getItemInput)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to get challenge: %s"
-		}`, http.StatusInternalServerError, err.Error())
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+	// Invitations the user declined are hidden
+	return "(#P = :public or CreatedBy = :createdBy or (contains(InvitedUsers, :createdBy) and not contains(DeclinedUsers, :createdBy)))", true
+}
+
+// matchesQuery returns true if the challenge's name, or description if searchDescription is true, contains q ignoring case
+func matchesQuery(challenge shared.Challenge, q string, searchDescription bool) bool {
+	if q == "" {
+		return true
+	}
+	// Whitespace is normalized the same way as the stored NameKey
+	q = shared.NameKey(q)
+	if strings.Contains(shared.NameKey(challenge.Name), q) {
+		return true
 	}
 
-	// Check if item is not found
-	if len(getItemOutput.Item) == 0 {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to find challenge %s"
-		}`, http.StatusBadRequest, challengeID)
+	return searchDescription && strings.Contains(shared.NameKey(challenge.Description), q)
+}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+// hasEquipment returns true if the user has all of the equipment the challenge needs
+// a nil equipment means the user didn't filter by equipment, challenges that need no equipment always match
+func hasEquipment(challenge shared.Challenge, equipment map[string]bool) bool {
+	if equipment == nil {
+		return true
+	}
+	for _, e := range challenge.EquipmentNeeded {
+		if !equipment[strings.ToLower(strings.TrimSpace(e))] {
+			return false
+		}
 	}
 
-	// If either value is nil, won't be ale to dereference in following if statement
-	if getItemOutput.Item["Public"].BOOL == nil || getItemOutput.Item["CreatedBy"].S == nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, errors.New("Invalid nil pointer on Public or CreatedBy")
+	return true
+}
+
+// challengesPage is a page of challenges
+// nextCursor is empty when there are no more challenges
+type challengesPage struct {
+	Items      []shared.Challenge `json:"items"`
+	NextCursor string             `json:"nextCursor"`
+	// Query echoes the q query param
+	Query string `json:"query,omitempty"`
+}
+
+// encodeCursor converts the Id of the last challenge on a page to an opaque cursor
+func encodeCursor(id string) string {
+	cursor, _ := json.Marshal(map[string]string{"Id": id})
+	return base64.RawURLEncoding.EncodeToString(cursor)
+}
+
+// decodeCursor converts a cursor back into the ExclusiveStartKey of a scan
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("cursor is invalid")
+	}
+	key := map[string]string{}
+	err = json.Unmarshal(cursorBytes, &key)
+	if err != nil || key["Id"] == "" {
+		return nil, errors.New("cursor is invalid")
 	}
 
-	// If program is not public or created by the user then they don't have access
-	if *getItemOutput.Item["Public"].BOOL == false && *getItemOutput.Item["CreatedBy"].S != userID {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unauthorized to view this challenge"
-		}`, http.StatusUnauthorized)
+	return map[string]*dynamodb.AttributeValue{
+		"Id": {S: aws.String(key["Id"])},
+	}, nil
+}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusUnauthorized,
-			Body:       errBody,
-		}, nil
+// challengeFilters returns the FilterExpression conditions and values for the tag, q and status options
+// soft deleted challenges are always excluded
+func challengeFilters(opts listOptions) ([]string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	// Challenges may share a table with programs and recommendations
+	filters := []string{shared.NotDeletedFilter, shared.ItemTypeFilter}
+	names := map[string]*string{
+		"#T": aws.String("Type"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":type": {S: aws.String(shared.ItemTypeChallenge)},
 	}
 
-	// Format getItemOutput to customProgram
-	challenge, err := formatOutput(getItemOutput.Item)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, err
+	if opts.tag != "" {
+		filters = append(filters, "contains(Tags, :tag)")
+		values[":tag"] = &dynamodb.AttributeValue{S: aws.String(opts.tag)}
+	}
+	// The description can't be searched case-insensitively by DynamoDB, so it's only matched in memory
+	// challenges created before NameKey existed are also matched in memory
+	if opts.q != "" && !opts.searchDescription {
+		filters = append(filters, "(contains(NameKey, :q) or attribute_not_exists(NameKey))")
+		values[":q"] = &dynamodb.AttributeValue{S: aws.String(shared.NameKey(opts.q))}
 	}
+	if filter := statusFilter(opts.status); filter != "" {
+		filters = append(filters, filter)
+		values[":today"] = &dynamodb.AttributeValue{S: aws.String(shared.Today().Format(shared.DateFormat))}
+	}
+
+	return filters, names, values
+}
 
-	reply, err := json.Marshal(challenge)
+// formatListItem formats a challenge from a list and returns false if it's removed by the in-memory filters
+func formatListItem(item map[string]*dynamodb.AttributeValue, userID string, opts listOptions) (shared.Challenge, bool, error) {
+	c, err := shared.FormatChallenge(item)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return shared.Challenge{}, false, err
+	}
+	// Dates that can't be compared as strings are filtered again once the status is computed
+	if opts.status != "all" && c.Status != opts.status {
+		return c, false, nil
+	}
+	if !matchesQuery(c, opts.q, opts.searchDescription) {
+		return c, false, nil
 	}
+	// Equipment is a subset check so it can't be part of the FilterExpression
+	if !hasEquipment(c, opts.equipment) {
+		return c, false, nil
+	}
+	// Private challenges the user was invited to are hidden once they decline, on both the scan and index paths
+	if !c.Public && c.CreatedBy != userID && c.InvitationStatus(userID) == shared.InvitationDeclined {
+		return c, false, nil
+	}
+	// Filtered in memory so challenges without a Difficulty are treated as 0, like FormatChallenge does
+	if opts.tier != nil && !opts.tier.Contains(c.Difficulty) {
+		return c, false, nil
+	}
+	// Filtered in memory so challenges with invalid dates stay visible
+	if !opts.includeExpired && isExpired(c, shared.Today()) {
+		return c, false, nil
+	}
+	c.IsOwner = c.CreatedBy == userID
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	return c, true, nil
 }
 
-func getAllChallenges(db *dynamodb.DynamoDB, tableName, userID string) (events.APIGatewayProxyResponse, error) {
+// scanChallenges scans the table for a page of challenges visible to the user
+// sorting only applies within a page
+func scanChallenges(db dynamodbiface.DynamoDBAPI, tableName, userID string, opts listOptions) (challengesPage, int, error) {
+	ownership, usesPublic := ownershipFilter(opts.filter)
+	filters, names, values := challengeFilters(opts)
+	filters = append([]string{ownership}, filters...)
+	values[":createdBy"] = &dynamodb.AttributeValue{S: aws.String(userID)}
+	// DynamoDB rejects unused expression names and values
+	if usesPublic {
+		names["#P"] = aws.String("Public")
+		values[":public"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	}
 	scanInput := &dynamodb.ScanInput{
-		TableName: aws.String(tableName),
-		ExpressionAttributeNames: map[string]*string{
-			"#P": aws.String("Public"),
-		},
-		FilterExpression: aws.String("#P = :public or CreatedBy = :createdBy"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":public":    {BOOL: aws.Bool(true)},
-			":createdBy": {S: aws.String(userID)},
-		},
+		TableName:                 aws.String(tableName),
+		FilterExpression:          aws.String(strings.Join(filters, " and ")),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		Limit:                     aws.Int64(int64(opts.limit)),
+		ExclusiveStartKey:         opts.startKey,
 	}
-	scanOutput, err := db.Scan(scanInput)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to get existing challenges: %s"
-		}`, http.StatusInternalServerError, err.Error())
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+	// The filter is applied after Limit items are read, so keep scanning until the page is full or the table is exhausted
+	var scanned int64
+	page := challengesPage{
+		Items: []shared.Challenge{},
+		Query: opts.q,
 	}
+	for {
+		scanOutput, err := db.Scan(scanInput)
+		if err != nil {
+			return challengesPage{}, http.StatusInternalServerError, fmt.Errorf("Unable to get existing challenges: %s", err)
+		}
 
-	// Check if no results are returned
-	if len(scanOutput.Items) == 0 {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusOK,
-			Body:       "[]",
-		}, nil
+		for idx, i := range scanOutput.Items {
+			c, keep, err := formatListItem(i, userID, opts)
+			if err != nil {
+				return challengesPage{}, http.StatusInternalServerError, err
+			}
+			if !keep {
+				continue
+			}
+			page.Items = append(page.Items, c)
+
+			// The next page starts after the last challenge returned, which may be in the middle of the scanned items
+			if len(page.Items) == opts.limit {
+				if idx < len(scanOutput.Items)-1 || len(scanOutput.LastEvaluatedKey) > 0 {
+					page.NextCursor = encodeCursor(c.ID)
+				}
+				break
+			}
+		}
+
+		if len(page.Items) == opts.limit || len(scanOutput.LastEvaluatedKey) == 0 {
+			break
+		}
+		// Filters that rarely match could read the whole table for one page, so the page is cut short
+		// and the client continues from where the scan stopped
+		scanned += aws.Int64Value(scanOutput.ScannedCount)
+		if scanned >= maxScannedItems {
+			log.Printf("Scanned %d challenges without filling a page of %d, returning %d", scanned, opts.limit, len(page.Items))
+			page.NextCursor = encodeCursor(aws.StringValue(scanOutput.LastEvaluatedKey["Id"].S))
+			break
+		}
+		scanInput.ExclusiveStartKey = scanOutput.LastEvaluatedKey
 	}
+	sortChallenges(page.Items, opts.sortBy, opts.order)
 
-	// Format scanOutput to []customProgram
-	challenges := []customChallenge{}
-	for _, i := range scanOutput.Items {
-		c, err := formatOutput(i)
+	return page, -1, nil
+}
+
+// queryAll runs a query, following LastEvaluatedKey until every item is read or maxScannedItems are read
+func queryAll(db dynamodbiface.DynamoDBAPI, queryInput *dynamodb.QueryInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	var scanned int64
+	for {
+		queryOutput, err := db.Query(queryInput)
 		if err != nil {
-			return events.APIGatewayProxyResponse{
-				StatusCode: http.StatusInternalServerError,
-			}, err
+			return nil, fmt.Errorf("Unable to get existing challenges: %s", err)
+		}
+		items = append(items, queryOutput.Items...)
+
+		if len(queryOutput.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanned += aws.Int64Value(queryOutput.ScannedCount)
+		if scanned >= maxScannedItems {
+			log.Printf("Read %d challenges from %s, the remaining challenges aren't listed", scanned, aws.StringValue(queryInput.IndexName))
+			break
+		}
+		queryInput.ExclusiveStartKey = queryOutput.LastEvaluatedKey
+	}
+
+	return items, nil
+}
+
+// queryChallenges queries the CreatedBy and public indexes for a page of challenges visible to the user
+// the results are merged, sorted across every page and then cut into a page starting after the cursor
+// challenges the user is only invited to aren't in either index, so they're only listed by scanChallenges
+func queryChallenges(db dynamodbiface.DynamoDBAPI, tableName, createdByIndex, publicIndex, userID string, opts listOptions) (challengesPage, int, error) {
+	filters, names, values := challengeFilters(opts)
+	queries := []*dynamodb.QueryInput{}
+
+	if opts.filter != filterPublic {
+		mineValues := map[string]*dynamodb.AttributeValue{
+			":createdBy": {S: aws.String(userID)},
+		}
+		for k, v := range values {
+			mineValues[k] = v
+		}
+		queries = append(queries, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(createdByIndex),
+			KeyConditionExpression:    aws.String("CreatedBy = :createdBy"),
+			FilterExpression:          aws.String(strings.Join(filters, " and ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: mineValues,
+		})
+	}
+	if opts.filter != filterMine {
+		publicFilters := filters
+		publicValues := map[string]*dynamodb.AttributeValue{
+			":visibility": {S: aws.String(shared.VisibilityPublic)},
+		}
+		for k, v := range values {
+			publicValues[k] = v
+		}
+		if opts.filter == filterPublic {
+			publicFilters = append([]string{"CreatedBy <> :createdBy"}, filters...)
+			publicValues[":createdBy"] = &dynamodb.AttributeValue{S: aws.String(userID)}
+		}
+		queries = append(queries, &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(publicIndex),
+			KeyConditionExpression:    aws.String("Visibility = :visibility"),
+			FilterExpression:          aws.String(strings.Join(publicFilters, " and ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: publicValues,
+		})
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, queryInput := range queries {
+		queryItems, err := queryAll(db, queryInput)
+		if err != nil {
+			return challengesPage{}, http.StatusInternalServerError, err
+		}
+		items = append(items, queryItems...)
+	}
+	// Private challenges the user is invited to aren't in either index, so they're scanned for
+	// to return the same challenges as scanChallenges
+	if opts.filter == filterAll {
+		invitedValues := map[string]*dynamodb.AttributeValue{
+			":createdBy": {S: aws.String(userID)},
 		}
+		for k, v := range values {
+			invitedValues[k] = v
+		}
+		invitedFilters := append([]string{"contains(InvitedUsers, :createdBy) and not contains(DeclinedUsers, :createdBy)"}, filters...)
+		invitedItems, err := shared.ScanAll(db, &dynamodb.ScanInput{
+			TableName:                 aws.String(tableName),
+			FilterExpression:          aws.String(strings.Join(invitedFilters, " and ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: invitedValues,
+		})
+		if err != nil {
+			return challengesPage{}, http.StatusInternalServerError, fmt.Errorf("Unable to get existing challenges: %s", err)
+		}
+		items = append(items, invitedItems...)
+	}
+
+	// A public challenge the user created or is invited to is returned more than once
+	challenges := []shared.Challenge{}
+	seen := map[string]bool{}
+	for _, i := range items {
+		c, keep, err := formatListItem(i, userID, opts)
+		if err != nil {
+			return challengesPage{}, http.StatusInternalServerError, err
+		}
+		if !keep || seen[c.ID] {
+			continue
+		}
+		seen[c.ID] = true
 		challenges = append(challenges, c)
 	}
+	sortChallenges(challenges, opts.sortBy, opts.order)
+
+	start := 0
+	if opts.startKey != nil {
+		cursorID := aws.StringValue(opts.startKey["Id"].S)
+		start = -1
+		for idx, c := range challenges {
+			if c.ID == cursorID {
+				start = idx + 1
+				break
+			}
+		}
+		if start < 0 {
+			return challengesPage{}, http.StatusBadRequest, errors.New("cursor is invalid")
+		}
+	}
+
+	page := challengesPage{
+		Items: []shared.Challenge{},
+		Query: opts.q,
+	}
+	end := start + opts.limit
+	if end > len(challenges) {
+		end = len(challenges)
+	}
+	page.Items = append(page.Items, challenges[start:end]...)
+	if end < len(challenges) {
+		page.NextCursor = encodeCursor(challenges[end-1].ID)
+	}
+
+	return page, -1, nil
+}
+
+// challengeIndexes returns the names of the CreatedBy and public GSIs from the
+// created_by_index_name and public_index_name env vars, false is returned unless both are set
+func challengeIndexes() (string, string, bool) {
+	createdByIndex := strings.TrimSpace(os.Getenv("created_by_index_name"))
+	publicIndex := strings.TrimSpace(os.Getenv("public_index_name"))
+
+	return createdByIndex, publicIndex, createdByIndex != "" && publicIndex != ""
+}
 
-	reply, err := json.Marshal(challenges)
+// lastModified returns the latest UpdatedDate, or CreatedDate if it was never updated, of the challenges
+func lastModified(challenges []shared.Challenge) time.Time {
+	latest := time.Time{}
+	for _, c := range challenges {
+		modified := c.UpdatedDate
+		if modified == "" {
+			modified = c.CreatedDate
+		}
+		t, err := time.Parse(time.RFC3339, modified)
+		if err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+
+	return latest
+}
+
+// attachProgress sets the user's progress on each challenge with one batch read of their participation
+// challenges the user hasn't joined have joined set to false
+func attachProgress(db dynamodbiface.DynamoDBAPI, challenges []shared.Challenge, userID string) error {
+	participantsTableName, err := shared.GetParticipantsTableName()
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return err
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	ids := []string{}
+	for _, c := range challenges {
+		ids = append(ids, c.ID)
+	}
+	participations, err := shared.BatchGetParticipations(db, participantsTableName, ids, userID)
+	if err != nil {
+		return err
+	}
+
+	for idx, c := range challenges {
+		progress := shared.ParticipantProgress{}
+		if p, ok := participations[c.ID]; ok {
+			progress = p.Progress(c)
+		}
+		challenges[idx].Progress = &progress
+	}
+
+	return nil
 }
 
-func getChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
-	// Get UserID header
-	userID, ok := request.Headers["UserID"]
-	userID = strings.TrimSpace(userID)
-	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
+// getAllChallenges returns a page of challenges
+// clients can send If-None-Match or If-Modified-Since to get a 304 when the page hasn't changed
+func getAllChallenges(request events.APIGatewayV2HTTPRequest, db dynamodbiface.DynamoDBAPI, tableName, userID string, opts listOptions) (events.APIGatewayProxyResponse, error) {
+	var page challengesPage
+	var returnCode int
+	var err error
+	if createdByIndex, publicIndex, ok := challengeIndexes(); ok {
+		page, returnCode, err = queryChallenges(db, tableName, createdByIndex, publicIndex, userID, opts)
+	} else {
+		page, returnCode, err = scanChallenges(db, tableName, userID, opts)
+	}
+	if err != nil {
+		return shared.ErrorResponse(returnCode, err.Error()), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	if opts.includeProgress {
+		if err := attachProgress(db, page.Items, userID); err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, err
+		}
 	}
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	return shared.ConditionalResponse(request, page, lastModified(page.Items))
+}
+
+func getChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -240,15 +747,24 @@ func getChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 	challengeID, _ := request.PathParameters["challengeId"]
 	challengeID = strings.TrimSpace(challengeID)
 
+	// Check for query parameters
+	opts, err := getListOptions(request)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
 	db := shared.GetDB(tableRegion)
 
 	if len(challengeID) > 0 {
+		if err := shared.ValidateID("challengeId", challengeID); err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
 		return getChallengeByID(db, tableName, userID, challengeID)
 	}
 
-	return getAllChallenges(db, tableName, userID)
+	return getAllChallenges(request, db, tableName, userID, opts)
 }
 
 func main() {
-	lambda.Start(getChallenges)
+	lambda.Start(shared.Chain(getChallenges, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithMetrics("getChallenges"), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
 }
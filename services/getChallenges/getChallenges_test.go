@@ -0,0 +1,1328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB matches items like the indexes and the FilterExpressions built by getChallenges would
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items   []map[string]*dynamodb.AttributeValue
+	queries []*dynamodb.QueryInput
+	scans   []*dynamodb.ScanInput
+	// participations are the participants table's items by Id
+	participations map[string]map[string]*dynamodb.AttributeValue
+	batchGets      []*dynamodb.BatchGetItemInput
+}
+
+func hasString(av *dynamodb.AttributeValue, s string) bool {
+	return av != nil && strings.Contains(strings.Join(aws.StringValueSlice(av.SS), ","), s)
+}
+
+// matches applies the conditions of filter that the tests rely on
+func matches(item map[string]*dynamodb.AttributeValue, filter string, values map[string]*dynamodb.AttributeValue) bool {
+	if strings.Contains(filter, shared.NotDeletedFilter) && shared.IsDeleted(item) {
+		return false
+	}
+	if strings.Contains(filter, shared.ItemTypeFilter) && !shared.IsItemType(item, aws.StringValue(values[":type"].S)) {
+		return false
+	}
+	if strings.Contains(filter, "contains(Tags, :tag)") && !hasString(item["Tags"], aws.StringValue(values[":tag"].S)) {
+		return false
+	}
+	if strings.Contains(filter, "contains(NameKey, :q)") && item["NameKey"] != nil && !strings.Contains(*item["NameKey"].S, *values[":q"].S) {
+		return false
+	}
+	if values[":today"] != nil && !matchesStatus(item, filter, *values[":today"].S) {
+		return false
+	}
+	userID := ""
+	if values[":createdBy"] != nil {
+		userID = aws.StringValue(values[":createdBy"].S)
+	}
+	invited := hasString(item["InvitedUsers"], userID) && !hasString(item["DeclinedUsers"], userID)
+	switch {
+	case strings.HasPrefix(filter, "(#P = :public or"):
+		return aws.BoolValue(item["Public"].BOOL) || aws.StringValue(item["CreatedBy"].S) == userID || invited
+	case strings.HasPrefix(filter, "(#P = :public and CreatedBy <> :createdBy)"):
+		return aws.BoolValue(item["Public"].BOOL) && aws.StringValue(item["CreatedBy"].S) != userID
+	case strings.HasPrefix(filter, "CreatedBy = :createdBy"):
+		return aws.StringValue(item["CreatedBy"].S) == userID
+	case strings.HasPrefix(filter, "contains(InvitedUsers"):
+		return invited
+	}
+
+	return true
+}
+
+// matchesStatus applies the date comparisons of statusFilter like DynamoDB would, on the date strings
+func matchesStatus(item map[string]*dynamodb.AttributeValue, filter, today string) bool {
+	start, end := item["StartDate"], item["EndDate"]
+	switch {
+	case strings.Contains(filter, statusFilter(shared.StatusUpcoming)):
+		return start == nil || *start.S > today
+	case strings.Contains(filter, statusFilter(shared.StatusActive)):
+		return start != nil && end != nil && *start.S <= today && *end.S >= today
+	case strings.Contains(filter, statusFilter(shared.StatusCompleted)):
+		return end != nil && *end.S < today
+	}
+
+	return true
+}
+
+func (m *mockDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	m.queries = append(m.queries, input)
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		if *input.IndexName == "CreatedByIndex" && aws.StringValue(i["CreatedBy"].S) != aws.StringValue(input.ExpressionAttributeValues[":createdBy"].S) {
+			continue
+		}
+		if *input.IndexName == "PublicIndex" && !aws.BoolValue(i["Public"].BOOL) {
+			continue
+		}
+		if matches(i, aws.StringValue(input.FilterExpression), input.ExpressionAttributeValues) {
+			items = append(items, i)
+		}
+	}
+
+	return &dynamodb.QueryOutput{Items: items}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.scans = append(m.scans, input)
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		if matches(i, aws.StringValue(input.FilterExpression), input.ExpressionAttributeValues) {
+			items = append(items, i)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (m *mockDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	m.batchGets = append(m.batchGets, input)
+	responses := map[string][]map[string]*dynamodb.AttributeValue{}
+	for table, keys := range input.RequestItems {
+		for _, k := range keys.Keys {
+			if p, ok := m.participations[*k["Id"].S]; ok {
+				responses[table] = append(responses[table], p)
+			}
+		}
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses}, nil
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	for _, i := range m.items {
+		if *i["Id"].S == *input.Key["Id"].S {
+			return &dynamodb.GetItemOutput{Item: i}, nil
+		}
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func challengeItem(id, itemType, createdBy string, public bool, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(itemType)},
+		"CreatedBy": {S: aws.String(createdBy)},
+		"Name":      {S: aws.String("Challenge " + id)},
+		"Public":    {BOOL: aws.Bool(public)},
+		"StartDate": {S: aws.String("2099-05-01")},
+		"EndDate":   {S: aws.String("2099-05-07")},
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+func listDB() *mockDB {
+	invited := map[string]*dynamodb.AttributeValue{"InvitedUsers": {SS: aws.StringSlice([]string{"user1"})}}
+
+	return &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		challengeItem("public", shared.ItemTypeChallenge, "user2", true, nil),
+		challengeItem("mine", shared.ItemTypeChallenge, "user1", false, nil),
+		challengeItem("invited", shared.ItemTypeChallenge, "user2", false, invited),
+		challengeItem("publicInvited", shared.ItemTypeChallenge, "user2", true, invited),
+		challengeItem("private", shared.ItemTypeChallenge, "user2", false, nil),
+		challengeItem("declined", shared.ItemTypeChallenge, "user2", false, map[string]*dynamodb.AttributeValue{
+			"InvitedUsers":  {SS: aws.StringSlice([]string{"user1"})},
+			"DeclinedUsers": {SS: aws.StringSlice([]string{"user1"})},
+		}),
+		challengeItem("program", shared.ItemTypeProgram, "user1", true, nil),
+		challengeItem("deleted", shared.ItemTypeChallenge, "user1", true, map[string]*dynamodb.AttributeValue{
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}),
+	}}
+}
+
+func pageIDs(page challengesPage) []string {
+	ids := []string{}
+	for _, c := range page.Items {
+		ids = append(ids, c.ID)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestListPathsMatch(t *testing.T) {
+	want := "invited,mine,public,publicInvited"
+
+	opts, err := getListOptions(events.APIGatewayV2HTTPRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.limit = maxLimit
+
+	tests := []struct {
+		name string
+		list func(db *mockDB) (challengesPage, int, error)
+	}{
+		{"scan", func(db *mockDB) (challengesPage, int, error) {
+			return scanChallenges(db, "pelodata", "user1", opts)
+		}},
+		{"indexes", func(db *mockDB) (challengesPage, int, error) {
+			return queryChallenges(db, "pelodata", "CreatedByIndex", "PublicIndex", "user1", opts)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, _, err := tt.list(listDB())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(pageIDs(page), ","); got != want {
+				t.Errorf("challenges = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestQueryChallengesInputs(t *testing.T) {
+	tests := []struct {
+		filter    string
+		wantKeys  []string
+		wantScans int
+	}{
+		{filterAll, []string{"CreatedBy = :createdBy", "Visibility = :visibility"}, 1},
+		{filterMine, []string{"CreatedBy = :createdBy"}, 0},
+		{filterPublic, []string{"Visibility = :visibility"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"filter": tt.filter}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := listDB()
+			if _, _, err := queryChallenges(db, "pelodata", "CreatedByIndex", "PublicIndex", "user1", opts); err != nil {
+				t.Fatal(err)
+			}
+
+			keys := []string{}
+			for _, q := range db.queries {
+				keys = append(keys, aws.StringValue(q.KeyConditionExpression))
+				if !strings.Contains(aws.StringValue(q.FilterExpression), shared.ItemTypeFilter) || aws.StringValue(q.ExpressionAttributeNames["#T"]) != "Type" {
+					t.Errorf("query on %s doesn't filter on Type: %s", aws.StringValue(q.IndexName), aws.StringValue(q.FilterExpression))
+				}
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.wantKeys, ",") {
+				t.Errorf("key conditions = %v, want %v", keys, tt.wantKeys)
+			}
+			if len(db.scans) != tt.wantScans {
+				t.Errorf("%d scans, want %d", len(db.scans), tt.wantScans)
+			}
+		})
+	}
+}
+
+func TestOwnershipFilter(t *testing.T) {
+	tests := []struct {
+		filter     string
+		status     string
+		wantFilter string
+		wantPublic bool
+		want       []string
+	}{
+		{filterAll, "", "(#P = :public or CreatedBy = :createdBy or", true, []string{"invited", "mine", "public", "publicInvited"}},
+		{filterMine, "", "CreatedBy = :createdBy and", false, []string{"mine"}},
+		{filterPublic, "", "(#P = :public and CreatedBy <> :createdBy) and", true, []string{"public", "publicInvited"}},
+		// Every challenge in listDB is upcoming
+		{filterMine, shared.StatusUpcoming, "CreatedBy = :createdBy and", false, []string{"mine"}},
+		{filterPublic, shared.StatusCompleted, "(#P = :public and CreatedBy <> :createdBy) and", true, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter+" "+tt.status, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"filter": tt.filter, "status": tt.status}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := listDB()
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			input := db.scans[0]
+			filter := aws.StringValue(input.FilterExpression)
+			if !strings.HasPrefix(filter, tt.wantFilter) {
+				t.Errorf("FilterExpression = %s, want it to start with %s", filter, tt.wantFilter)
+			}
+			if tt.status != "" && !strings.Contains(filter, statusFilter(tt.status)) {
+				t.Errorf("FilterExpression = %s, want the %s status filter", filter, tt.status)
+			}
+			// DynamoDB rejects unused expression names and values
+			_, hasPublic := input.ExpressionAttributeValues[":public"]
+			if _, hasName := input.ExpressionAttributeNames["#P"]; hasPublic != tt.wantPublic || hasName != tt.wantPublic {
+				t.Errorf("#P and :public bound = %t, want %t", hasPublic, tt.wantPublic)
+			}
+			if got := pageIDs(page); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChallengeByID(t *testing.T) {
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"public challenge", "public", http.StatusOK},
+		{"invited to a private challenge", "invited", http.StatusOK},
+		{"private challenge", "private", http.StatusNotFound},
+		{"program id", "program", http.StatusNotFound},
+		{"deleted challenge", "deleted", http.StatusNotFound},
+		{"unknown id", "missing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := getChallengeByID(listDB(), "pelodata", "user1", tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if res.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", res.Headers["Content-Type"])
+			}
+		})
+	}
+}
+
+func TestQueryChallengesMerge(t *testing.T) {
+	dated := func(id, createdBy string, public bool, startDate string) map[string]*dynamodb.AttributeValue {
+		return challengeItem(id, shared.ItemTypeChallenge, createdBy, public, map[string]*dynamodb.AttributeValue{
+			"StartDate": {S: aws.String(startDate)},
+			"EndDate":   {S: aws.String("2099-12-31")},
+		})
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		// minePublic is in both the CreatedBy and public indexes
+		dated("minePublic", "user1", true, "2099-05-03"),
+		dated("mine", "user1", false, "2099-05-01"),
+		dated("public", "user2", true, "2099-05-04"),
+		dated("public2", "user2", true, "2099-05-02"),
+	}}
+
+	tests := []struct {
+		name      string
+		params    map[string]string
+		wantPages []string
+	}{
+		{"start date", map[string]string{}, []string{"mine,public2,minePublic,public"}},
+		{"start date descending", map[string]string{"order": "desc"}, []string{"public,minePublic,public2,mine"}},
+		{"name", map[string]string{"sort": "name"}, []string{"mine,minePublic,public,public2"}},
+		{"pages", map[string]string{"limit": "3"}, []string{"mine,public2,minePublic", "public"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := []string{}
+			for {
+				opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+				if err != nil {
+					t.Fatal(err)
+				}
+				page, _, err := queryChallenges(db, "pelodata", "CreatedByIndex", "PublicIndex", "user1", opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, c := range page.Items {
+					ids = append(ids, c.ID)
+				}
+				pages = append(pages, strings.Join(ids, ","))
+				if page.NextCursor == "" || len(pages) > len(tt.wantPages) {
+					break
+				}
+				tt.params["cursor"] = page.NextCursor
+			}
+
+			// The merged list is sorted as a whole, so pages don't repeat or reorder challenges
+			if strings.Join(pages, " | ") != strings.Join(tt.wantPages, " | ") {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestChallengeIndexes(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdBy string
+		public    string
+		want      bool
+	}{
+		{"both indexes", "CreatedByIndex", "PublicIndex", true},
+		{"only CreatedBy", "CreatedByIndex", "", false},
+		{"only public", "", "PublicIndex", false},
+		{"blank", " ", " ", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("created_by_index_name", tt.createdBy)
+			os.Setenv("public_index_name", tt.public)
+			defer os.Unsetenv("created_by_index_name")
+			defer os.Unsetenv("public_index_name")
+
+			// Without both indexes the list falls back to a scan
+			if _, _, got := challengeIndexes(); got != tt.want {
+				t.Errorf("challengeIndexes() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOwner(t *testing.T) {
+	for _, id := range []string{"mine", "public", "invited"} {
+		t.Run(id, func(t *testing.T) {
+			res, err := getChallengeByID(listDB(), "pelodata", "user1", id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			challenge := shared.Challenge{}
+			if err := json.Unmarshal([]byte(res.Body), &challenge); err != nil {
+				t.Fatal(err)
+			}
+			if challenge.IsOwner != (id == "mine") {
+				t.Errorf("isOwner = %t, want %t", challenge.IsOwner, id == "mine")
+			}
+		})
+	}
+
+	t.Run("list", func(t *testing.T) {
+		opts, err := getListOptions(events.APIGatewayV2HTTPRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, _, err := scanChallenges(listDB(), "pelodata", "user1", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		owned := []string{}
+		for _, c := range page.Items {
+			if c.IsOwner {
+				owned = append(owned, c.ID)
+			}
+		}
+		if strings.Join(owned, ",") != "mine" {
+			t.Errorf("owned challenges = %v, want [mine]", owned)
+		}
+	})
+}
+
+func TestGetChallengesInvalidOptions(t *testing.T) {
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	defer os.Unsetenv("table_region")
+	defer os.Unsetenv("table_name")
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:               map[string]string{"UserID": "user1"},
+		QueryStringParameters: map[string]string{"filter": "everyone"},
+	}
+	res, err := shared.WithUserID(getChallenges)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusBadRequest || res.Headers["Content-Type"] != "application/json" {
+		t.Errorf("response = %d with Content-Type %q, want a JSON 400", res.StatusCode, res.Headers["Content-Type"])
+	}
+}
+
+func TestTagFilter(t *testing.T) {
+	tagged := func(id string, public bool, tags ...string) map[string]*dynamodb.AttributeValue {
+		attrs := map[string]*dynamodb.AttributeValue{}
+		if len(tags) > 0 {
+			attrs["Tags"] = &dynamodb.AttributeValue{SS: aws.StringSlice(tags)}
+		}
+		return challengeItem(id, shared.ItemTypeChallenge, "user2", public, attrs)
+	}
+	items := []map[string]*dynamodb.AttributeValue{
+		tagged("beginner", true, "beginner", "low-impact"),
+		tagged("pz", true, "pz"),
+		tagged("untagged", true),
+		tagged("privateBeginner", false, "beginner"),
+	}
+
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{"no tag", "", "beginner,pz,untagged"},
+		{"tag", "beginner", "beginner"},
+		{"normalized tag", " Low-Impact ", "beginner"},
+		{"unused tag", "hiit", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"tag": tt.tag}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.limit = maxLimit
+
+			for _, list := range []func(db *mockDB) (challengesPage, int, error){
+				func(db *mockDB) (challengesPage, int, error) {
+					return scanChallenges(db, "pelodata", "user1", opts)
+				},
+				func(db *mockDB) (challengesPage, int, error) {
+					return queryChallenges(db, "pelodata", "CreatedByIndex", "PublicIndex", "user1", opts)
+				},
+			} {
+				page, _, err := list(&mockDB{items: items})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.Join(pageIDs(page), ","); got != tt.want {
+					t.Errorf("challenges = %s, want %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNameSearch(t *testing.T) {
+	named := func(id, name, description string, legacy bool) map[string]*dynamodb.AttributeValue {
+		attrs := map[string]*dynamodb.AttributeValue{
+			"Name":        {S: aws.String(name)},
+			"Description": {S: aws.String(description)},
+		}
+		// Challenges created before NameKey was stored don't have one
+		if !legacy {
+			attrs["NameKey"] = &dynamodb.AttributeValue{S: aws.String(shared.NameKey(name))}
+		}
+		return challengeItem(id, shared.ItemTypeChallenge, "user1", true, attrs)
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		named("climb", "Summer  CLIMB", "Hills every day", false),
+		named("legacy", "Climb Legacy", "From before NameKey", true),
+		named("miles", "Summer Miles", "Climb the leaderboard", false),
+	}}
+
+	tests := []struct {
+		name        string
+		params      map[string]string
+		want        []string
+		wantNameKey bool
+		wantErr     bool
+	}{
+		{"name", map[string]string{"q": " climb "}, []string{"climb", "legacy"}, true, false},
+		{"whitespace in name", map[string]string{"q": "summer climb"}, []string{"climb"}, true, false},
+		{"description", map[string]string{"q": "climb", "searchDescription": "true"}, []string{"climb", "legacy", "miles"}, false, false},
+		{"no matches", map[string]string{"q": "yoga"}, []string{}, true, false},
+		{"too short", map[string]string{"q": "c"}, nil, false, true},
+		{"invalid searchDescription", map[string]string{"q": "climb", "searchDescription": "maybe"}, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getListOptions() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			db.scans = nil
+
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIDs(page); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.want)
+			}
+			// The query is echoed so clients can show what was searched for
+			if page.Query != strings.TrimSpace(tt.params["q"]) {
+				t.Errorf("query = %q, want %q", page.Query, strings.TrimSpace(tt.params["q"]))
+			}
+			// Descriptions are only matched in memory
+			filter := aws.StringValue(db.scans[0].FilterExpression)
+			if strings.Contains(filter, "contains(NameKey, :q)") != tt.wantNameKey {
+				t.Errorf("FilterExpression = %s, want NameKey filter %t", filter, tt.wantNameKey)
+			}
+		})
+	}
+}
+
+func TestInvitedAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		wantStatus int
+		wantListed bool
+	}{
+		{"owner", "user2", http.StatusOK, true},
+		{"invitee", "user1", http.StatusOK, true},
+		{"stranger", "user3", http.StatusNotFound, false},
+	}
+
+	opts, err := getListOptions(events.APIGatewayV2HTTPRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.limit = maxLimit
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := getChallengeByID(listDB(), "pelodata", tt.userID, "invited")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+
+			page, _, err := scanChallenges(listDB(), "pelodata", tt.userID, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed := false
+			for _, id := range pageIDs(page) {
+				listed = listed || id == "invited"
+			}
+			if listed != tt.wantListed {
+				t.Errorf("invited challenge listed = %t, want %t", listed, tt.wantListed)
+			}
+		})
+	}
+}
+
+func TestSortByCreated(t *testing.T) {
+	created := func(id, date string) map[string]*dynamodb.AttributeValue {
+		attrs := map[string]*dynamodb.AttributeValue{}
+		if date != "" {
+			attrs["CreatedDate"] = &dynamodb.AttributeValue{S: aws.String(date)}
+		}
+		return challengeItem(id, shared.ItemTypeChallenge, "user1", true, attrs)
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		created("may", "2024-05-01T00:00:00Z"),
+		created("legacy", ""),
+		created("june", "2024-06-01T00:00:00Z"),
+	}}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   string
+	}{
+		{"newest first by default", map[string]string{"sort": "created"}, "june,may,legacy"},
+		{"oldest first", map[string]string{"sort": "created", "order": "asc"}, "legacy,may,june"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.limit = maxLimit
+
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range page.Items {
+				got = append(got, c.ID)
+				// Challenges created before CreatedDate was stored read back without one
+				if c.ID == "legacy" && c.CreatedDate != "" {
+					t.Errorf("legacy CreatedDate = %q, want empty", c.CreatedDate)
+				}
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("challenges = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortChallenges(t *testing.T) {
+	challenges := []shared.Challenge{
+		{ID: "b", Name: "beta", StartDate: "2024-05-02", EndDate: "2024-05-30", Difficulty: 5, CreatedDate: "2024-04-02T00:00:00Z"},
+		{ID: "a", Name: "Alpha", StartDate: "2024-05-03", EndDate: "2024-05-10", Difficulty: 2, CreatedDate: "2024-04-03T00:00:00Z"},
+		{ID: "c", Name: "Gamma", StartDate: "2024-05-01", EndDate: "2024-05-20", Difficulty: 5, CreatedDate: "2024-04-01T00:00:00Z"},
+	}
+
+	tests := []struct {
+		sortBy string
+		order  string
+		want   string
+	}{
+		{"startDate", "asc", "c,b,a"},
+		{"startDate", "desc", "a,b,c"},
+		{"endDate", "asc", "a,c,b"},
+		{"endDate", "desc", "b,c,a"},
+		// b and c tie on difficulty and keep their order
+		{"difficulty", "asc", "a,b,c"},
+		{"difficulty", "desc", "b,c,a"},
+		{"name", "asc", "a,b,c"},
+		{"name", "desc", "c,b,a"},
+		{"created", "asc", "c,b,a"},
+		{"created", "desc", "a,b,c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy+" "+tt.order, func(t *testing.T) {
+			sorted := append([]shared.Challenge{}, challenges...)
+			sortChallenges(sorted, tt.sortBy, tt.order)
+
+			got := []string{}
+			for _, c := range sorted {
+				got = append(got, c.ID)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("challenges = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		params    map[string]string
+		wantSort  string
+		wantOrder string
+		wantErr   string
+	}{
+		{"defaults", nil, "startDate", "asc", ""},
+		{"created defaults to newest first", map[string]string{"sort": "created"}, "created", "desc", ""},
+		{"order is case insensitive", map[string]string{"sort": "name", "order": " DESC "}, "name", "desc", ""},
+		{"invalid sort", map[string]string{"sort": "popularity"}, "", "", "sort must be one of: startDate, endDate, difficulty, name, created"},
+		{"invalid order", map[string]string{"order": "up"}, "", "", "order must be asc or desc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.sortBy != tt.wantSort || opts.order != tt.wantOrder {
+				t.Errorf("sort = %s %s, want %s %s", opts.sortBy, opts.order, tt.wantSort, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestStatusFilter(t *testing.T) {
+	day := func(n int) *dynamodb.AttributeValue {
+		return &dynamodb.AttributeValue{S: aws.String(shared.Today().AddDate(0, 0, n).Format(shared.DateFormat))}
+	}
+	dated := func(id string, start, end *dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+		item := challengeItem(id, shared.ItemTypeChallenge, "user1", true, nil)
+		delete(item, "StartDate")
+		delete(item, "EndDate")
+		if start != nil {
+			item["StartDate"] = start
+		}
+		if end != nil {
+			item["EndDate"] = end
+		}
+		return item
+	}
+	items := []map[string]*dynamodb.AttributeValue{
+		dated("startsTomorrow", day(1), day(7)),
+		dated("startsToday", day(0), day(7)),
+		dated("endsToday", day(-7), day(0)),
+		dated("endedYesterday", day(-7), day(-1)),
+		dated("noDates", nil, nil),
+	}
+
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"", "endedYesterday,endsToday,noDates,startsToday,startsTomorrow"},
+		{"all", "endedYesterday,endsToday,noDates,startsToday,startsTomorrow"},
+		{shared.StatusUpcoming, "noDates,startsTomorrow"},
+		{shared.StatusActive, "endsToday,startsToday"},
+		{shared.StatusCompleted, "endedYesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"status": tt.status}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.limit = maxLimit
+
+			page, _, err := scanChallenges(&mockDB{items: items}, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(pageIDs(page), ","); got != tt.want {
+				t.Errorf("challenges = %s, want %s", got, tt.want)
+			}
+			for _, c := range page.Items {
+				if want := shared.ChallengeStatus(c.StartDate, c.EndDate, shared.Today()); c.Status != want {
+					t.Errorf("%s status = %q, want %q", c.ID, c.Status, want)
+				}
+			}
+		})
+	}
+
+	if _, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"status": "finished"}}); err == nil {
+		t.Error("invalid status wasn't rejected")
+	}
+}
+
+// pagedDB scans its items in order like DynamoDB, reading Limit items from the ExclusiveStartKey
+// before applying the FilterExpression
+type pagedDB struct {
+	mockDB
+	scanCalls int
+}
+
+func (m *pagedDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.scanCalls++
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		for idx, i := range m.items {
+			if *i["Id"].S == *input.ExclusiveStartKey["Id"].S {
+				start = idx + 1
+			}
+		}
+	}
+	end := start + int(aws.Int64Value(input.Limit))
+	if end > len(m.items) {
+		end = len(m.items)
+	}
+
+	output := &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{}, ScannedCount: aws.Int64(int64(end - start))}
+	for _, i := range m.items[start:end] {
+		if matches(i, aws.StringValue(input.FilterExpression), input.ExpressionAttributeValues) {
+			output.Items = append(output.Items, i)
+		}
+	}
+	if end < len(m.items) {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": m.items[end-1]["Id"]}
+	}
+
+	return output, nil
+}
+
+func TestScanChallengesPages(t *testing.T) {
+	// listDB's items in scan order are public, mine, invited, publicInvited, private, declined, program and deleted
+	// so the visible challenges are spread across the scanned pages
+	// a full page is followed by a cursor while there are unscanned items, even when none of them match
+	tests := []struct {
+		limit     string
+		wantPages []string
+	}{
+		{"1", []string{"public", "mine", "invited", "publicInvited", ""}},
+		{"2", []string{"public,mine", "invited,publicInvited", ""}},
+		{"3", []string{"public,mine,invited", "publicInvited"}},
+		{"4", []string{"public,mine,invited,publicInvited", ""}},
+		{"", []string{"public,mine,invited,publicInvited"}},
+	}
+
+	for _, tt := range tests {
+		t.Run("limit "+tt.limit, func(t *testing.T) {
+			db := &pagedDB{mockDB: *listDB()}
+			params := map[string]string{}
+			if tt.limit != "" {
+				params["limit"] = tt.limit
+			}
+
+			pages := []string{}
+			for {
+				opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: params})
+				if err != nil {
+					t.Fatal(err)
+				}
+				page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, c := range page.Items {
+					ids = append(ids, c.ID)
+				}
+				pages = append(pages, strings.Join(ids, ","))
+				if page.NextCursor == "" || len(pages) > len(tt.wantPages) {
+					break
+				}
+				params["cursor"] = page.NextCursor
+			}
+
+			if strings.Join(pages, " | ") != strings.Join(tt.wantPages, " | ") {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"not base64!", encodeCursor("c1")[:5], "bnVsbA"} {
+		if _, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"cursor": cursor}}); err == nil {
+			t.Errorf("cursor %q wasn't rejected", cursor)
+		}
+	}
+}
+
+func TestEquipmentFilter(t *testing.T) {
+	needs := func(id string, equipment ...string) map[string]*dynamodb.AttributeValue {
+		attrs := map[string]*dynamodb.AttributeValue{}
+		if len(equipment) > 0 {
+			attrs["EquipmentNeeded"] = &dynamodb.AttributeValue{SS: aws.StringSlice(equipment)}
+		}
+		return challengeItem(id, shared.ItemTypeChallenge, "user1", true, attrs)
+	}
+
+	tests := []struct {
+		name      string
+		params    map[string]string
+		wantPages []string
+	}{
+		{"not filtered", map[string]string{}, []string{"bike,bikeWeights", "none,mat"}},
+		{"subset", map[string]string{"equipment": "Bike,mat"}, []string{"bike,none", "mat"}},
+		{"superset", map[string]string{"equipment": "bike,weights,mat,rower"}, []string{"bike,bikeWeights", "none,mat"}},
+		// The page fills before mat is filtered out, so the cursor leads to an empty page
+		{"ignores case and whitespace", map[string]string{"equipment": " BIKE , "}, []string{"bike,none", ""}},
+		{"no equipment", map[string]string{"equipment": ""}, []string{"none"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Pages of 2 check challenges are filtered before the page is cut
+			db := &pagedDB{mockDB: mockDB{items: []map[string]*dynamodb.AttributeValue{
+				needs("bike", "Bike"),
+				needs("bikeWeights", "Bike", "Weights"),
+				needs("none"),
+				needs("mat", "Mat"),
+			}}}
+			tt.params["limit"] = "2"
+
+			pages := []string{}
+			for {
+				opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+				if err != nil {
+					t.Fatal(err)
+				}
+				page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []string{}
+				for _, c := range page.Items {
+					ids = append(ids, c.ID)
+				}
+				pages = append(pages, strings.Join(ids, ","))
+				if page.NextCursor == "" || len(pages) > len(tt.wantPages) {
+					break
+				}
+				tt.params["cursor"] = page.NextCursor
+			}
+
+			if strings.Join(pages, " | ") != strings.Join(tt.wantPages, " | ") {
+				t.Errorf("pages = %v, want %v", pages, tt.wantPages)
+			}
+		})
+	}
+}
+
+// threePageDB returns its items over three scan pages whatever the Limit, like a scan cut short at 1MB
+type threePageDB struct {
+	mockDB
+	scannedPerPage int64
+	startKeys      []string
+}
+
+func (m *threePageDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	page := 0
+	if input.ExclusiveStartKey != nil {
+		page, _ = strconv.Atoi(*input.ExclusiveStartKey["Id"].S)
+	}
+	m.startKeys = append(m.startKeys, strconv.Itoa(page))
+
+	output := &dynamodb.ScanOutput{Items: []map[string]*dynamodb.AttributeValue{}, ScannedCount: aws.Int64(m.scannedPerPage)}
+	for idx, i := range m.items {
+		if idx%3 == page && matches(i, aws.StringValue(input.FilterExpression), input.ExpressionAttributeValues) {
+			output.Items = append(output.Items, i)
+		}
+	}
+	if page < 2 {
+		output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": {S: aws.String(strconv.Itoa(page + 1))}}
+	}
+
+	return output, nil
+}
+
+func TestScanChallengesFollowsPages(t *testing.T) {
+	tests := []struct {
+		name           string
+		scannedPerPage int64
+		wantIDs        []string
+		wantStartKeys  []string
+		wantCursor     string
+	}{
+		{"every page is read", 10, []string{"c0", "c1", "c2", "c3", "c4", "c5"}, []string{"0", "1", "2"}, ""},
+		{"stops at the cap", maxScannedItems / 2, []string{"c0", "c1", "c3", "c4"}, []string{"0", "1"}, encodeCursor("2")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &threePageDB{scannedPerPage: tt.scannedPerPage}
+			for i := 0; i < 6; i++ {
+				db.items = append(db.items, challengeItem(fmt.Sprintf("c%d", i), shared.ItemTypeChallenge, "user1", true, nil))
+			}
+
+			// A request without a cursor still sees challenges past the first scan page
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIDs(page); strings.Join(got, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.wantIDs)
+			}
+			if strings.Join(db.startKeys, ",") != strings.Join(tt.wantStartKeys, ",") {
+				t.Errorf("scanned pages %v, want %v", db.startKeys, tt.wantStartKeys)
+			}
+			if page.NextCursor != tt.wantCursor {
+				t.Errorf("NextCursor = %q, want %q", page.NextCursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	db := listDB()
+	for _, i := range db.items {
+		i["CreatedDate"] = &dynamodb.AttributeValue{S: aws.String("2024-05-01T00:00:00Z")}
+	}
+	db.items[0]["UpdatedDate"] = &dynamodb.AttributeValue{S: aws.String("2024-05-10T12:00:00Z")}
+
+	opts, err := getListOptions(events.APIGatewayV2HTTPRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(headers map[string]string) events.APIGatewayProxyResponse {
+		res, err := getAllChallenges(events.APIGatewayV2HTTPRequest{Headers: headers}, db, "pelodata", "user1", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	first := get(nil)
+	if first.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d: %s", first.StatusCode, first.Body)
+	}
+	etag := first.Headers["ETag"]
+	if etag == "" {
+		t.Fatal("ETag isn't set")
+	}
+	if got, want := first.Headers["Last-Modified"], "Fri, 10 May 2024 12:00:00 GMT"; got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"stale etag", map[string]string{"If-None-Match": `"stale"`}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": "Fri, 10 May 2024 12:00:00 GMT"}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": "Fri, 10 May 2024 11:00:00 GMT"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := get(tt.headers)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified && res.Body != "" {
+				t.Errorf("304 has body %q", res.Body)
+			}
+		})
+	}
+
+	// Adding a challenge busts the client's cache
+	db.items = append(db.items, challengeItem("new", shared.ItemTypeChallenge, "user2", true, map[string]*dynamodb.AttributeValue{
+		"CreatedDate": {S: aws.String("2024-05-11T08:00:00Z")},
+	}))
+	res := get(map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode after adding a challenge = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if res.Headers["ETag"] == etag {
+		t.Error("ETag didn't change after adding a challenge")
+	}
+	if got, want := res.Headers["Last-Modified"], "Sat, 11 May 2024 08:00:00 GMT"; got != want {
+		t.Errorf("Last-Modified = %q, want %q", got, want)
+	}
+}
+
+func TestIncludeProgress(t *testing.T) {
+	os.Setenv("participants_table_name", "participants")
+	defer os.Unsetenv("participants_table_name")
+
+	participation := func(challengeID string, workouts, minutes int) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Id":                {S: aws.String(shared.ParticipationID(challengeID, "user1"))},
+			"ChallengeId":       {S: aws.String(challengeID)},
+			"UserId":            {S: aws.String("user1")},
+			"CompletedWorkouts": {N: aws.String(strconv.Itoa(workouts))},
+			"CompletedMinutes":  {N: aws.String(strconv.Itoa(minutes))},
+		}
+	}
+	db := listDB()
+	db.items[0]["GoalType"] = &dynamodb.AttributeValue{S: aws.String(shared.GoalTypeWorkouts)}
+	db.items[0]["GoalValue"] = &dynamodb.AttributeValue{N: aws.String("12")}
+	db.items[1]["GoalType"] = &dynamodb.AttributeValue{S: aws.String(shared.GoalTypeMinutes)}
+	db.items[1]["GoalValue"] = &dynamodb.AttributeValue{N: aws.String("60")}
+	db.participations = map[string]map[string]*dynamodb.AttributeValue{
+		shared.ParticipationID("public", "user1"): participation("public", 3, 90),
+		shared.ParticipationID("mine", "user1"):   participation("mine", 2, 90),
+	}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   map[string]shared.ParticipantProgress
+	}{
+		{"not requested", map[string]string{}, nil},
+		{
+			"requested",
+			map[string]string{"includeProgress": "true"},
+			map[string]shared.ParticipantProgress{
+				"public":        {Joined: true, CompletedWorkouts: 3, PercentComplete: 25},
+				"mine":          {Joined: true, CompletedWorkouts: 2, PercentComplete: 100},
+				"invited":       {},
+				"publicInvited": {},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.batchGets = nil
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := getAllChallenges(events.APIGatewayV2HTTPRequest{}, db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page := challengesPage{}
+			if err := json.Unmarshal([]byte(res.Body), &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) != 4 {
+				t.Fatalf("got %d challenges, want 4: %s", len(page.Items), res.Body)
+			}
+
+			// Participation is read in one batch rather than per challenge
+			wantBatchGets := 0
+			if tt.want != nil {
+				wantBatchGets = 1
+			}
+			if len(db.batchGets) != wantBatchGets {
+				t.Errorf("BatchGetItem was called %d times, want %d", len(db.batchGets), wantBatchGets)
+			}
+			for _, c := range page.Items {
+				if tt.want == nil {
+					if c.Progress != nil {
+						t.Errorf("%s has progress %+v", c.ID, *c.Progress)
+					}
+					continue
+				}
+				if c.Progress == nil {
+					t.Errorf("%s has no progress", c.ID)
+				} else if *c.Progress != tt.want[c.ID] {
+					t.Errorf("%s progress = %+v, want %+v", c.ID, *c.Progress, tt.want[c.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestIsExpired(t *testing.T) {
+	today := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		endDate string
+		want    bool
+	}{
+		{"active", "2024-07-07", false},
+		{"recently ended", "2024-06-20", false},
+		{"ended expiredAfterDays ago", "2024-05-31", false},
+		{"long ended", "2024-05-30", true},
+		{"missing end date", "", false},
+		{"invalid end date", "06/20/2024", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isExpired(shared.Challenge{EndDate: tt.endDate}, today); got != tt.want {
+				t.Errorf("isExpired() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludeExpired(t *testing.T) {
+	today := shared.Today()
+	endedOn := func(daysAgo int) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"StartDate": {S: aws.String(today.AddDate(0, 0, -daysAgo-7).Format(shared.DateFormat))},
+			"EndDate":   {S: aws.String(today.AddDate(0, 0, -daysAgo).Format(shared.DateFormat))},
+		}
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		challengeItem("upcoming", shared.ItemTypeChallenge, "user2", true, nil),
+		challengeItem("recentlyEnded", shared.ItemTypeChallenge, "user2", true, endedOn(3)),
+		challengeItem("longEnded", shared.ItemTypeChallenge, "user2", true, endedOn(90)),
+		challengeItem("invalidDate", shared.ItemTypeChallenge, "user2", true, map[string]*dynamodb.AttributeValue{
+			"EndDate": {S: aws.String("not a date")},
+		}),
+	}}
+
+	tests := []struct {
+		name   string
+		params map[string]string
+		want   []string
+	}{
+		{"default", map[string]string{}, []string{"invalidDate", "recentlyEnded", "upcoming"}},
+		{"includeExpired=false", map[string]string{"includeExpired": "false"}, []string{"invalidDate", "recentlyEnded", "upcoming"}},
+		{"includeExpired=true", map[string]string{"includeExpired": "true"}, []string{"invalidDate", "longEnded", "recentlyEnded", "upcoming"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIDs(page); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"includeExpired": "maybe"}}); err == nil {
+		t.Error("invalid includeExpired wasn't rejected")
+	}
+}
+
+func TestTierFilter(t *testing.T) {
+	difficulty := func(d string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"Difficulty": {N: aws.String(d)}}
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		challengeItem("unknown", shared.ItemTypeChallenge, "user2", true, nil),
+		challengeItem("3", shared.ItemTypeChallenge, "user2", true, difficulty("3")),
+		challengeItem("3.01", shared.ItemTypeChallenge, "user2", true, difficulty("3.01")),
+		challengeItem("6", shared.ItemTypeChallenge, "user2", true, difficulty("6")),
+		challengeItem("6.01", shared.ItemTypeChallenge, "user2", true, difficulty("6.01")),
+		challengeItem("10", shared.ItemTypeChallenge, "user2", true, difficulty("10")),
+	}}
+
+	tests := []struct {
+		tier    string
+		want    []string
+		wantErr bool
+	}{
+		{"", []string{"10", "3", "3.01", "6", "6.01", "unknown"}, false},
+		{"beginner", []string{"3", "unknown"}, false},
+		{"Intermediate", []string{"3.01", "6"}, false},
+		{"advanced", []string{"10", "6.01"}, false},
+		{"expert", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			params := map[string]string{}
+			if tt.tier != "" {
+				params["tier"] = tt.tier
+			}
+			opts, err := getListOptions(events.APIGatewayV2HTTPRequest{QueryStringParameters: params})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getListOptions() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			page, _, err := scanChallenges(db, "pelodata", "user1", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIDs(page); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
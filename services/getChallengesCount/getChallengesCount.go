@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	lambda.Start(shared.Chain(shared.CountByOwnership(shared.TableChallenges), shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithUserID))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// getChallengesForProgram returns the challenges visible to the user that were built from a program
+// the program must exist and be public or created by the user
+func getChallengesForProgram(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	programID, _ := request.PathParameters["programId"]
+	programID = strings.TrimSpace(programID)
+	if programID == "" {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "Path parameter programId is required: /getChallengesForProgram/{programId}"
+		}`, http.StatusBadRequest)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+	if err := shared.ValidateID("programId", programID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	programsRegion, programsTableName, err := shared.GetTableFor(shared.TablePrograms)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(programsTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(programID)},
+		},
+	}
+	getItemOutput, err := shared.GetDB(programsRegion).GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get program: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeProgram) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
+	}
+	program, err := shared.FormatProgram(getItemOutput.Item, false)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	// Reported as not found so the ids of private programs can't be probed
+	if !program.Public && program.CreatedBy != userID {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#P": aws.String("Public"),
+		},
+		// Invitations the user declined are hidden, as they are by getChallenges
+		FilterExpression: aws.String("SourceProgramId = :programId and (#P = :public or CreatedBy = :createdBy or (contains(InvitedUsers, :createdBy) and not contains(DeclinedUsers, :createdBy))) and " + shared.NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":programId": {S: aws.String(programID)},
+			":public":    {BOOL: aws.Bool(true)},
+			":createdBy": {S: aws.String(userID)},
+		},
+	}
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "Unable to get challenges for program: %s"
+		}`, http.StatusInternalServerError, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Format items to []shared.Challenge
+	challenges := []shared.Challenge{}
+	for _, i := range items {
+		c, err := shared.FormatChallenge(i)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, err
+		}
+		c.IsOwner = c.CreatedBy == userID
+		challenges = append(challenges, c)
+	}
+
+	reply, err := json.Marshal(challenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getChallengesForProgram, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
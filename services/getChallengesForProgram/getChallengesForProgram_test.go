@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	publicProgram  = "11111111-1111-4111-8111-111111111111"
+	privateProgram = "22222222-2222-4222-8222-222222222222"
+	deletedProgram = "33333333-3333-4333-8333-333333333333"
+	otherProgram   = "44444444-4444-4444-8444-444444444444"
+	missingProgram = "55555555-5555-4555-8555-555555555555"
+)
+
+// mockDB serves items by Id and matches scans like the FilterExpression built by getChallengesForProgram would
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items []map[string]*dynamodb.AttributeValue
+	scans []*dynamodb.ScanInput
+}
+
+func hasString(av *dynamodb.AttributeValue, s string) bool {
+	return av != nil && strings.Contains(strings.Join(aws.StringValueSlice(av.SS), ","), s)
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	for _, i := range m.items {
+		if *i["Id"].S == *input.Key["Id"].S {
+			return &dynamodb.GetItemOutput{Item: i}, nil
+		}
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.scans = append(m.scans, input)
+	filter := aws.StringValue(input.FilterExpression)
+	programID := aws.StringValue(input.ExpressionAttributeValues[":programId"].S)
+	userID := aws.StringValue(input.ExpressionAttributeValues[":createdBy"].S)
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		if strings.Contains(filter, "SourceProgramId = :programId") && (i["SourceProgramId"] == nil || *i["SourceProgramId"].S != programID) {
+			continue
+		}
+		if strings.Contains(filter, shared.NotDeletedFilter) && shared.IsDeleted(i) {
+			continue
+		}
+		invited := hasString(i["InvitedUsers"], userID) && !hasString(i["DeclinedUsers"], userID)
+		if strings.Contains(filter, "(#P = :public or") && !aws.BoolValue(i["Public"].BOOL) && *i["CreatedBy"].S != userID && !invited {
+			continue
+		}
+		items = append(items, i)
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func item(id, itemType, createdBy string, public bool, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	i := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(itemType)},
+		"CreatedBy": {S: aws.String(createdBy)},
+		"Name":      {S: aws.String("Item " + id)},
+		"Public":    {BOOL: aws.Bool(public)},
+	}
+	for k, v := range attrs {
+		i[k] = v
+	}
+
+	return i
+}
+
+func challenge(id, createdBy string, public bool, programID string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	c := item(id, shared.ItemTypeChallenge, createdBy, public, attrs)
+	c["SourceProgramId"] = &dynamodb.AttributeValue{S: aws.String(programID)}
+	c["StartDate"] = &dynamodb.AttributeValue{S: aws.String("2099-05-01")}
+	c["EndDate"] = &dynamodb.AttributeValue{S: aws.String("2099-05-07")}
+
+	return c
+}
+
+func programDB() *mockDB {
+	user1 := aws.StringSlice([]string{"user1"})
+
+	return &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		item(publicProgram, shared.ItemTypeProgram, "user2", true, nil),
+		item(privateProgram, shared.ItemTypeProgram, "user2", false, nil),
+		item(deletedProgram, shared.ItemTypeProgram, "user2", true, map[string]*dynamodb.AttributeValue{
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}),
+		item(otherProgram, shared.ItemTypeChallenge, "user2", true, nil),
+		challenge("public", "user2", true, publicProgram, nil),
+		challenge("mine", "user1", false, publicProgram, nil),
+		challenge("invited", "user2", false, publicProgram, map[string]*dynamodb.AttributeValue{"InvitedUsers": {SS: user1}}),
+		challenge("declined", "user2", false, publicProgram, map[string]*dynamodb.AttributeValue{"InvitedUsers": {SS: user1}, "DeclinedUsers": {SS: user1}}),
+		challenge("private", "user2", false, publicProgram, nil),
+		challenge("deleted", "user1", true, publicProgram, map[string]*dynamodb.AttributeValue{
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}),
+		challenge("otherProgram", "user1", true, otherProgram, nil),
+	}}
+}
+
+func TestGetChallengesForProgram(t *testing.T) {
+	tests := []struct {
+		name       string
+		programID  string
+		wantStatus int
+		want       []string
+		wantOwned  []string
+	}{
+		{"public program", publicProgram, http.StatusOK, []string{"invited", "mine", "public"}, []string{"mine"}},
+		{"private program of another user", privateProgram, http.StatusNotFound, nil, nil},
+		{"deleted program", deletedProgram, http.StatusNotFound, nil, nil},
+		{"challenge id", otherProgram, http.StatusNotFound, nil, nil},
+		{"unknown program", missingProgram, http.StatusNotFound, nil, nil},
+		{"invalid id", "program1", http.StatusBadRequest, nil, nil},
+		{"missing id", " ", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := programDB()
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "user1"},
+				PathParameters: map[string]string{"programId": tt.programID},
+			}
+			res, err := shared.WithUserID(getChallengesForProgram)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(db.scans) != 0 {
+					t.Errorf("challenges were scanned %d times, want 0", len(db.scans))
+				}
+				return
+			}
+
+			challenges := []shared.Challenge{}
+			if err := json.Unmarshal([]byte(res.Body), &challenges); err != nil {
+				t.Fatal(err)
+			}
+			got, owned := []string{}, []string{}
+			for _, c := range challenges {
+				got = append(got, c.ID)
+				if c.IsOwner {
+					owned = append(owned, c.ID)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("challenges = %v, want %v", got, tt.want)
+			}
+			if strings.Join(owned, ",") != strings.Join(tt.wantOwned, ",") {
+				t.Errorf("owned challenges = %v, want %v", owned, tt.wantOwned)
+			}
+		})
+	}
+}
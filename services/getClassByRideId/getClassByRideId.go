@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+
+// Path Params:
+//  rideId - Peloton ride id
+
+// getClassByRideId returns a single class in the shape recommendClass expects for its workout
+// so a client with only a ride id can recommend the class
+func getClassByRideId(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{}
+
+	rideID, _ := request.PathParameters["rideId"]
+	rideID = strings.TrimSpace(rideID)
+	if rideID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter rideId is required: /getClassByRideId/{rideId}"), nil
+	}
+	if err := shared.ValidateRideID(rideID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	details, body, _, resCode, err := shared.GetRideDetails(rideID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find ride %s", rideID)), nil
+		}
+		return shared.UpstreamErrorResponse(resCode, body, err), nil
+	}
+
+	return shared.JSONResponse(http.StatusOK, details.Ride.ToWorkout())
+}
+
+func main() {
+	lambda.Start(shared.Chain(getClassByRideId, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	rideID      = "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c"
+	missingRide = "0000000000000000000000000000000f"
+	brokenRide  = "0000000000000000000000000000000e"
+)
+
+// rideServer stubs Peloton's ride details endpoint
+func rideServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/ride/"), "/details") {
+		case rideID:
+			fmt.Fprintf(w, `{"ride": {
+				"id": "%s",
+				"title": "20 min Pop Ride",
+				"difficulty_estimate": 7.5,
+				"duration": 1200,
+				"fitness_discipline": "cycling",
+				"length": 1260,
+				"instructor": {"id": "i1", "name": "Cody Rigsby"}
+			}}`, rideID)
+		case missingRide:
+			http.NotFound(w, r)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message": "Something went wrong"}`)
+		}
+	}))
+}
+
+func TestGetClassByRideID(t *testing.T) {
+	server := rideServer()
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+	}{
+		{"ride", rideID, http.StatusOK},
+		{"unknown ride", missingRide, http.StatusNotFound},
+		{"Peloton error", brokenRide, http.StatusBadGateway},
+		{"invalid ride id", "ride1", http.StatusBadRequest},
+		{"missing ride id", " ", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{
+				PathParameters: map[string]string{"rideId": tt.rideID},
+			}
+			res, err := getClassByRideId(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			got := shared.Workout{}
+			if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := shared.Workout{
+				ID:                rideID,
+				Title:             "20 min Pop Ride",
+				Difficulty:        7.5,
+				Duration:          1200,
+				InstructorID:      "i1",
+				InstructorName:    "Cody Rigsby",
+				FitnessDiscipline: "cycling",
+			}
+			if got != want {
+				t.Errorf("workout = %+v, want %+v", got, want)
+			}
+			// The response is exactly the Workout recommendClass expects, the ride's extra fields aren't included
+			if strings.Contains(res.Body, "length") || strings.Contains(res.Body, `"instructor"`) {
+				t.Errorf("body %s has fields that aren't in shared.Workout", res.Body)
+			}
+		})
+	}
+}
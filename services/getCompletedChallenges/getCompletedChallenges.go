@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Headers:
+//   UserID - the user whose completed challenges are returned
+
+type completedChallenge struct {
+	shared.Completion
+	// Challenge is nil if the challenge was deleted after the user completed it
+	Challenge *shared.Challenge `json:"challenge"`
+}
+
+// getCompletedChallenges returns the challenges the user has completed, most recently completed first
+func getCompletedChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	completionsTableName, err := shared.GetCompletionsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	completions, err := shared.GetUserCompletions(db, completionsTableName, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	ids := []string{}
+	for _, c := range completions {
+		ids = append(ids, c.ChallengeID)
+	}
+	challenges, err := shared.BatchGetChallenges(db, tableName, ids)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	// The completion is the user's record, so it's kept even if the challenge was deleted
+	completed := []completedChallenge{}
+	for _, c := range completions {
+		cc := completedChallenge{Completion: c}
+		if challenge, ok := challenges[c.ChallengeID]; ok {
+			challenge.IsOwner = challenge.CreatedBy == userID
+			cc.Challenge = &challenge
+		}
+		completed = append(completed, cc)
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return completed[i].CompletedAt > completed[j].CompletedAt
+	})
+
+	return shared.JSONResponse(http.StatusOK, completed)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getCompletedChallenges, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
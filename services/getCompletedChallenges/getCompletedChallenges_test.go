@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB scans completions by UserId and batch gets challenges by Id
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	completions []map[string]*dynamodb.AttributeValue
+	challenges  map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	userID := *input.ExpressionAttributeValues[":userId"].S
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, c := range m.completions {
+		if *c["UserId"].S == userID {
+			items = append(items, c)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (m *mockDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	responses := map[string][]map[string]*dynamodb.AttributeValue{}
+	for table, keys := range input.RequestItems {
+		for _, k := range keys.Keys {
+			if c, ok := m.challenges[*k["Id"].S]; ok {
+				responses[table] = append(responses[table], c)
+			}
+		}
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("completions_table_name", "completions")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("completions_table_name")
+	}
+}
+
+func completion(challengeID, userID, completedAt string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":          {S: aws.String(shared.ParticipationID(challengeID, userID))},
+		"ChallengeId": {S: aws.String(challengeID)},
+		"UserId":      {S: aws.String(userID)},
+		"CompletedAt": {S: aws.String(completedAt)},
+		"FinalCount":  {N: aws.String("10")},
+	}
+}
+
+func challenge(id, createdBy string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	c := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String(createdBy)},
+		"Name":      {S: aws.String("Challenge " + id)},
+	}
+	for k, v := range attrs {
+		c[k] = v
+	}
+
+	return c
+}
+
+func TestGetCompletedChallenges(t *testing.T) {
+	db := &mockDB{
+		completions: []map[string]*dynamodb.AttributeValue{
+			completion("older", "user1", "2024-04-30T12:00:00Z"),
+			completion("owned", "user1", "2024-05-20T12:00:00Z"),
+			completion("deleted", "user1", "2024-05-10T12:00:00Z"),
+			completion("owned", "user2", "2024-05-21T12:00:00Z"),
+		},
+		challenges: map[string]map[string]*dynamodb.AttributeValue{
+			"older": challenge("older", "user2", nil),
+			"owned": challenge("owned", "user1", nil),
+			"deleted": challenge("deleted", "user2", map[string]*dynamodb.AttributeValue{
+				"DeletedAt": {S: aws.String("2024-05-15T00:00:00Z")},
+			}),
+		},
+	}
+	defer withMockDB(db)()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"UserID": "user1"},
+	}
+	res, err := shared.WithUserID(getCompletedChallenges)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d: %s", res.StatusCode, res.Body)
+	}
+
+	got := []completedChallenge{}
+	if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Most recently completed first, the deleted challenge's completion is kept without the challenge
+	tests := []struct {
+		id            string
+		wantChallenge bool
+		wantIsOwner   bool
+	}{
+		{"owned", true, true},
+		{"deleted", false, false},
+		{"older", true, false},
+	}
+	if len(got) != len(tests) {
+		t.Fatalf("got %d completions, want %d: %s", len(got), len(tests), res.Body)
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			c := got[i]
+			if c.ChallengeID != tt.id {
+				t.Fatalf("completion %d = %s, want %s", i, c.ChallengeID, tt.id)
+			}
+			if c.UserID != "user1" || c.FinalCount != 10 {
+				t.Errorf("completion = %+v", c.Completion)
+			}
+			if (c.Challenge != nil) != tt.wantChallenge {
+				t.Fatalf("Challenge = %+v, want present %t", c.Challenge, tt.wantChallenge)
+			}
+			if c.Challenge != nil && c.Challenge.IsOwner != tt.wantIsOwner {
+				t.Errorf("IsOwner = %t, want %t", c.Challenge.IsOwner, tt.wantIsOwner)
+			}
+		})
+	}
+}
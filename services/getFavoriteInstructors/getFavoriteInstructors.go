@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/user/{userID}/workouts
+
+// Query Params:
+//   limit - max number of instructors to return. Defaults to 5
+//   min_count - minimum number of classes taken with an instructor. Defaults to 1
+
+const defaultLimit = 5
+
+type favoriteInstructor struct {
+	InstructorID   string `json:"instructor_id"`
+	InstructorName string `json:"instructor_name"`
+	Count          int    `json:"count"`
+}
+
+func getQueryParams(request events.APIGatewayV2HTTPRequest) (int, int, error) {
+	limit := defaultLimit
+	minCount := 1
+	var err error
+
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return 0, 0, errors.New("limit must be a number greater than 0")
+		}
+	}
+	if minCountStr, ok := request.QueryStringParameters["min_count"]; ok {
+		minCount, err = strconv.Atoi(minCountStr)
+		if err != nil || minCount < 1 {
+			return 0, 0, errors.New("min_count must be a number greater than 0")
+		}
+	}
+
+	return limit, minCount, nil
+}
+
+// tallyInstructors counts the classes taken with each instructor, sorted by count descending
+// ties are sorted by instructor name
+func tallyInstructors(history []shared.HistoryWorkout, limit, minCount int) []favoriteInstructor {
+	counts := map[string]*favoriteInstructor{}
+	for _, w := range history {
+		id := w.Ride.InstructorID
+		if id == "" {
+			continue
+		}
+		if _, ok := counts[id]; !ok {
+			counts[id] = &favoriteInstructor{InstructorID: id}
+		}
+		counts[id].Count++
+		if counts[id].InstructorName == "" {
+			counts[id].InstructorName = w.Ride.InstructorName
+		}
+	}
+
+	instructors := []favoriteInstructor{}
+	for _, i := range counts {
+		if i.Count >= minCount {
+			instructors = append(instructors, *i)
+		}
+	}
+	sort.Slice(instructors, func(i, j int) bool {
+		if instructors[i].Count != instructors[j].Count {
+			return instructors[i].Count > instructors[j].Count
+		}
+		return instructors[i].InstructorName < instructors[j].InstructorName
+	})
+	if len(instructors) > limit {
+		instructors = instructors[:limit]
+	}
+
+	return instructors
+}
+
+// getFavoriteInstructors returns the instructors the user has taken the most classes with
+func getFavoriteInstructors(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{}
+
+	// Get UserID header
+	userID, ok := request.Headers["UserID"]
+	userID = strings.TrimSpace(userID)
+	if !ok || userID == "" {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "UserID header is required"
+		}`, http.StatusBadRequest)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	limit, minCount, err := getQueryParams(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	history, body, resCode, err := shared.GetWorkoutHistory(userID, headers)
+	if err != nil {
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	reply, err := json.Marshal(tallyInstructors(history, limit, minCount))
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getFavoriteInstructors, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
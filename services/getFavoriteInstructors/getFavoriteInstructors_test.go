@@ -0,0 +1,73 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestGetQueryParams(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       map[string]string
+		wantLimit    int
+		wantMinCount int
+		wantErr      bool
+	}{
+		{"defaults", map[string]string{}, defaultLimit, 1, false},
+		{"set", map[string]string{"limit": "10", "min_count": "3"}, 10, 3, false},
+		{"zero limit", map[string]string{"limit": "0"}, 0, 0, true},
+		{"bad min_count", map[string]string{"min_count": "a few"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, minCount, err := getQueryParams(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getQueryParams() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if limit != tt.wantLimit || minCount != tt.wantMinCount {
+				t.Errorf("getQueryParams() = %d, %d, want %d, %d", limit, minCount, tt.wantLimit, tt.wantMinCount)
+			}
+		})
+	}
+}
+
+func TestTallyInstructors(t *testing.T) {
+	taken := func(instructorID, name string) shared.HistoryWorkout {
+		w := shared.HistoryWorkout{}
+		w.Ride.InstructorID = instructorID
+		w.Ride.InstructorName = name
+		return w
+	}
+	history := []shared.HistoryWorkout{
+		taken("i1", "Robin"), taken("i2", "Cody"), taken("i1", "Robin"),
+		taken("i3", "Ally"), taken("i2", ""), taken("i4", "Ben"), taken("", "Unknown"),
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		minCount int
+		want     []favoriteInstructor
+	}{
+		{
+			"ties sorted by name",
+			5, 1,
+			[]favoriteInstructor{{"i2", "Cody", 2}, {"i1", "Robin", 2}, {"i3", "Ally", 1}, {"i4", "Ben", 1}},
+		},
+		{"limit", 1, 1, []favoriteInstructor{{"i2", "Cody", 2}}},
+		{"min count", 5, 2, []favoriteInstructor{{"i2", "Cody", 2}, {"i1", "Robin", 2}}},
+		{"none over min count", 5, 3, []favoriteInstructor{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tallyInstructors(history, tt.limit, tt.minCount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tallyInstructors() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
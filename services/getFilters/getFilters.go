@@ -88,7 +88,7 @@ func getFilters(ctx context.Context, request events.APIGatewayV2HTTPRequest) (ev
 	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, nil, nil)
 	if err != nil {
 		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
+			StatusCode: shared.UpstreamStatus(resCode, err),
 			Body:       err.Error(),
 		}
 
@@ -122,5 +122,5 @@ func getFilters(ctx context.Context, request events.APIGatewayV2HTTPRequest) (ev
 }
 
 func main() {
-	lambda.Start(getFilters)
+	lambda.Start(shared.Chain(getFilters, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
 }
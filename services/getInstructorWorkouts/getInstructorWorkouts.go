@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/v2/ride/archived?instructor_id={instructorID}
+
+// Path Params:
+//  instructorId - Peloton instructor id
+
+// Query Params:
+//   limit - number of results to return
+//   page - Used for pagination, page starts at 0
+
+type instructor struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type getWorkoutsResponse struct {
+	Data        []shared.Workout `json:"data"`
+	Instructors []instructor     `json:"instructors"`
+}
+
+func getPathParams(url string, request events.APIGatewayV2HTTPRequest) (string, error) {
+	instructorID, ok := request.PathParameters["instructorId"]
+	instructorID = strings.TrimSpace(instructorID)
+	if !ok || instructorID == "" {
+		return "", errors.New("Path parameter instructorId is required: /getInstructorWorkouts/{instructorId}")
+	}
+
+	url = fmt.Sprintf("%sinstructor_id=%s&", url, instructorID)
+
+	return url, nil
+}
+
+func getQueryParams(url string, request events.APIGatewayV2HTTPRequest) (string, error) {
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			return "", errors.New("limit must be a number greater than 0")
+		}
+		url = fmt.Sprintf("%slimit=%d&", url, limit)
+	}
+	if pageStr, ok := request.QueryStringParameters["page"]; ok {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 0 {
+			return "", errors.New("page must be a number 0 or greater")
+		}
+		url = fmt.Sprintf("%spage=%d&", url, page)
+	}
+
+	return strings.TrimRight(url, "&"), nil
+}
+
+// getInstructorWorkouts returns the most recent classes from a single instructor
+func getInstructorWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	method := "GET"
+	url := "/api/v2/ride/archived?sort_by=original_air_time&desc=true&"
+	headers := map[string]string{}
+	var err error
+
+	url, err = getPathParams(url, request)
+	if err == nil {
+		url, err = getQueryParams(url, request)
+	}
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, nil)
+	if err != nil {
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	getWorkoutsRes := &getWorkoutsResponse{}
+	err = json.Unmarshal(body, getWorkoutsRes)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to unmarshal response: %s", err)
+	}
+
+	if getWorkoutsRes.Data == nil {
+		getWorkoutsRes.Data = []shared.Workout{}
+	}
+
+	// Set instructor name for each workout
+	for idx, d := range getWorkoutsRes.Data {
+		for _, i := range getWorkoutsRes.Instructors {
+			if d.InstructorID == i.ID {
+				getWorkoutsRes.Data[idx].InstructorName = i.Name
+				break
+			}
+		}
+	}
+
+	reply, err := json.Marshal(getWorkoutsRes.Data)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getInstructorWorkouts, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const archivedRidesFixture = `{
+	"data": [
+		{"id": "r1", "title": "30 min Pop Ride", "instructor_id": "i1", "fitness_discipline": "cycling", "duration": 1800},
+		{"id": "r2", "title": "20 min Climb Ride", "instructor_id": "i1", "fitness_discipline": "cycling", "duration": 1200}
+	],
+	"instructors": [
+		{"id": "i2", "name": "Robin Arzón"},
+		{"id": "i1", "name": "Cody Rigsby"}
+	],
+	"page": 0,
+	"page_count": 1
+}`
+
+func TestUnmarshalInstructorWorkouts(t *testing.T) {
+	res := getWorkoutsResponse{}
+	if err := json.Unmarshal([]byte(archivedRidesFixture), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Data) != 2 || res.Data[0].InstructorID != "i1" || res.Data[1].Duration != 1200 {
+		t.Errorf("Data = %+v", res.Data)
+	}
+	if len(res.Instructors) != 2 || res.Instructors[1] != (instructor{ID: "i1", Name: "Cody Rigsby"}) {
+		t.Errorf("Instructors = %+v", res.Instructors)
+	}
+}
+
+func TestGetInstructorWorkouts(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(archivedRidesFixture))
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		query      map[string]string
+		wantStatus int
+		wantQuery  string
+	}{
+		{"instructor", map[string]string{"instructorId": "i1"}, nil, http.StatusOK,
+			"sort_by=original_air_time&desc=true&instructor_id=i1"},
+		{"paged", map[string]string{"instructorId": "i1"}, map[string]string{"limit": "5", "page": "2"}, http.StatusOK,
+			"sort_by=original_air_time&desc=true&instructor_id=i1&limit=5&page=2"},
+		{"missing instructor", nil, nil, http.StatusBadRequest, ""},
+		{"blank instructor", map[string]string{"instructorId": " "}, nil, http.StatusBadRequest, ""},
+		{"invalid limit", map[string]string{"instructorId": "i1"}, map[string]string{"limit": "0"}, http.StatusBadRequest, ""},
+		{"invalid page", map[string]string{"instructorId": "i1"}, map[string]string{"page": "-1"}, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query = ""
+			request := events.APIGatewayV2HTTPRequest{PathParameters: tt.params, QueryStringParameters: tt.query}
+			res, err := getInstructorWorkouts(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			workouts := []shared.Workout{}
+			if err := json.Unmarshal([]byte(res.Body), &workouts); err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, w := range workouts {
+				names = append(names, w.InstructorName)
+			}
+			if want := []string{"Cody Rigsby", "Cody Rigsby"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("instructor names = %v, want %v", names, want)
+			}
+		})
+	}
+}
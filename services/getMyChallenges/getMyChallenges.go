@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Headers:
+//   UserID - the user whose joined challenges are returned
+
+type progress struct {
+	// Completed is in the unit of the challenge's GoalType
+	Completed int `json:"completed"`
+	Goal      int `json:"goal"`
+}
+
+type myChallenge struct {
+	shared.Challenge
+	JoinedDate string   `json:"joinedDate"`
+	Progress   progress `json:"progress"`
+}
+
+// challengeProgress returns the user's progress towards the challenge's goal
+func challengeProgress(c shared.Challenge, p shared.Participation) progress {
+	return progress{
+		Completed: p.Completed(c),
+		Goal:      c.GoalValue,
+	}
+}
+
+// getMyChallenges returns the challenges the user has joined with their progress in each, ordered by start date
+func getMyChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	participations, err := shared.GetUserParticipations(db, participantsTableName, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	ids := []string{}
+	for _, p := range participations {
+		ids = append(ids, p.ChallengeID)
+	}
+	challenges, err := shared.BatchGetChallenges(db, tableName, ids)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	myChallenges := []myChallenge{}
+	for _, p := range participations {
+		c, ok := challenges[p.ChallengeID]
+		if !ok {
+			// The challenge was deleted after the user joined it
+			continue
+		}
+		c.IsOwner = c.CreatedBy == userID
+		myChallenges = append(myChallenges, myChallenge{
+			Challenge:  c,
+			JoinedDate: p.JoinedDate,
+			Progress:   challengeProgress(c, p),
+		})
+	}
+	sort.SliceStable(myChallenges, func(i, j int) bool {
+		return myChallenges[i].StartDate < myChallenges[j].StartDate
+	})
+
+	return shared.JSONResponse(http.StatusOK, myChallenges)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getMyChallenges, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithUserID))
+}
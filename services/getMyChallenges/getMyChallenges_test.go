@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB scans participations by UserId and batch gets challenges by Id
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	participations []map[string]*dynamodb.AttributeValue
+	challenges     map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	userID := *input.ExpressionAttributeValues[":userId"].S
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, p := range m.participations {
+		if *p["UserId"].S == userID {
+			items = append(items, p)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (m *mockDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	responses := map[string][]map[string]*dynamodb.AttributeValue{}
+	for table, keys := range input.RequestItems {
+		for _, k := range keys.Keys {
+			if c, ok := m.challenges[*k["Id"].S]; ok {
+				responses[table] = append(responses[table], c)
+			}
+		}
+	}
+
+	return &dynamodb.BatchGetItemOutput{Responses: responses}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+	}
+}
+
+func participation(challengeID, userID string, workouts, minutes int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":                {S: aws.String(shared.ParticipationID(challengeID, userID))},
+		"ChallengeId":       {S: aws.String(challengeID)},
+		"UserId":            {S: aws.String(userID)},
+		"JoinedDate":        {S: aws.String("2024-05-01")},
+		"CompletedWorkouts": {N: aws.String(strconv.Itoa(workouts))},
+		"CompletedMinutes":  {N: aws.String(strconv.Itoa(minutes))},
+	}
+}
+
+func challenge(id, goalType, startDate, endDate string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	c := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String("user2")},
+		"Name":      {S: aws.String("Challenge " + id)},
+		"StartDate": {S: aws.String(startDate)},
+		"EndDate":   {S: aws.String(endDate)},
+		"GoalType":  {S: aws.String(goalType)},
+		"GoalValue": {N: aws.String("10")},
+	}
+	for k, v := range attrs {
+		c[k] = v
+	}
+
+	return c
+}
+
+func TestGetMyChallenges(t *testing.T) {
+	today := time.Now().UTC()
+	yesterday := today.AddDate(0, 0, -1).Format(shared.DateFormat)
+	nextWeek := today.AddDate(0, 0, 7).Format(shared.DateFormat)
+
+	db := &mockDB{
+		participations: []map[string]*dynamodb.AttributeValue{
+			participation("active", "user1", 3, 90),
+			participation("ended", "user1", 7, 200),
+			participation("deleted", "user1", 1, 20),
+			participation("removed", "user1", 1, 20),
+			participation("active", "user2", 5, 100),
+		},
+		challenges: map[string]map[string]*dynamodb.AttributeValue{
+			"active": challenge("active", shared.GoalTypeMinutes, yesterday, nextWeek, nil),
+			"ended":  challenge("ended", shared.GoalTypeWorkouts, "2020-01-01", "2020-01-31", nil),
+			"deleted": challenge("deleted", shared.GoalTypeWorkouts, yesterday, nextWeek, map[string]*dynamodb.AttributeValue{
+				"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+			}),
+		},
+	}
+	defer withMockDB(db)()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers: map[string]string{"UserID": "user1"},
+	}
+	res, err := shared.WithUserID(getMyChallenges)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d: %s", res.StatusCode, res.Body)
+	}
+
+	got := []myChallenge{}
+	if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ordered by start date, deleted and removed challenges are skipped
+	tests := []struct {
+		id         string
+		wantStatus string
+		want       progress
+	}{
+		{"ended", shared.StatusCompleted, progress{Completed: 7, Goal: 10}},
+		{"active", shared.StatusActive, progress{Completed: 90, Goal: 10}},
+	}
+	if len(got) != len(tests) {
+		t.Fatalf("got %d challenges, want %d: %s", len(got), len(tests), res.Body)
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			c := got[i]
+			if c.ID != tt.id {
+				t.Fatalf("challenge %d = %s, want %s", i, c.ID, tt.id)
+			}
+			if c.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", c.Status, tt.wantStatus)
+			}
+			if c.Progress != tt.want {
+				t.Errorf("Progress = %+v, want %+v", c.Progress, tt.want)
+			}
+			if c.JoinedDate != "2024-05-01" {
+				t.Errorf("JoinedDate = %q, want 2024-05-01", c.JoinedDate)
+			}
+		})
+	}
+}
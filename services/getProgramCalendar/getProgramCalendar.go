@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Path Params:
+//   programId - ID of the program to schedule
+
+// Query Params:
+//   startDate - YYYY-MM-DD date the first day of the program is done on
+
+// scheduledWorkout is a program's workout with the date it's done on
+// Week and Day start at 1, the workout on day d of week w is done (w-1)*7 + (d-1) days after the start date
+type scheduledWorkout struct {
+	Week    int            `json:"week"`
+	Day     int            `json:"day"`
+	Date    string         `json:"date"`
+	Workout shared.Workout `json:"workout"`
+}
+
+type programCalendar struct {
+	ProgramID string `json:"programId"`
+	StartDate string `json:"startDate"`
+	// EndDate is the date of the last workout, it's empty if the program has no workouts
+	EndDate  string             `json:"endDate"`
+	Workouts []scheduledWorkout `json:"workouts"`
+}
+
+// buildCalendar assigns each of the program's workouts a date counted from start
+func buildCalendar(program shared.Program, start time.Time) programCalendar {
+	calendar := programCalendar{
+		ProgramID: program.ID,
+		StartDate: start.Format(shared.DateFormat),
+		Workouts:  []scheduledWorkout{},
+	}
+	for weekIdx, week := range program.Workouts {
+		for dayIdx, w := range week {
+			date := start.AddDate(0, 0, weekIdx*7+dayIdx).Format(shared.DateFormat)
+			calendar.Workouts = append(calendar.Workouts, scheduledWorkout{
+				Week:    weekIdx + 1,
+				Day:     dayIdx + 1,
+				Date:    date,
+				Workout: w,
+			})
+			calendar.EndDate = date
+		}
+	}
+
+	return calendar
+}
+
+// getProgramCalendar returns the workouts of a program with the date each is done on when started on startDate
+func getProgramCalendar(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	programID, _ := request.PathParameters["programId"]
+	programID = strings.TrimSpace(programID)
+	if programID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter programId is required"), nil
+	}
+	if err := shared.ValidateID("programId", programID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	startDate, _ := request.QueryStringParameters["startDate"]
+	startDate = strings.TrimSpace(startDate)
+	if startDate == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Query parameter startDate is required"), nil
+	}
+	start, err := time.Parse(shared.DateFormat, startDate)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, "startDate must be a date in the format YYYY-MM-DD"), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TablePrograms)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(programID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get program: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeProgram) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
+	}
+
+	program, err := shared.FormatProgram(getItemOutput.Item, true)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	// Reported as not found so the ids of private programs can't be probed
+	if !program.Public && program.CreatedBy != userID {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
+	}
+
+	return shared.JSONResponse(http.StatusOK, buildCalendar(program, start))
+}
+
+func main() {
+	lambda.Start(shared.Chain(getProgramCalendar, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestBuildCalendar(t *testing.T) {
+	start := time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC)
+
+	type day struct {
+		week, day int
+		date, id  string
+	}
+	tests := []struct {
+		name     string
+		workouts [][]shared.Workout
+		wantEnd  string
+		want     []day
+	}{
+		{"no workouts", [][]shared.Workout{}, "", []day{}},
+		{
+			"two weeks",
+			[][]shared.Workout{{{ID: "w1"}, {ID: "w2"}}, {{ID: "w3"}}},
+			"2024-05-13",
+			[]day{{1, 1, "2024-05-06", "w1"}, {1, 2, "2024-05-07", "w2"}, {2, 1, "2024-05-13", "w3"}},
+		},
+		{
+			"empty week",
+			[][]shared.Workout{{{ID: "w1"}}, {}, {{ID: "w2"}}},
+			"2024-05-20",
+			[]day{{1, 1, "2024-05-06", "w1"}, {3, 1, "2024-05-20", "w2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calendar := buildCalendar(shared.Program{ID: "p1", Workouts: tt.workouts}, start)
+			if calendar.ProgramID != "p1" || calendar.StartDate != "2024-05-06" || calendar.EndDate != tt.wantEnd {
+				t.Errorf("calendar = %s from %s to %s, want p1 from 2024-05-06 to %s", calendar.ProgramID, calendar.StartDate, calendar.EndDate, tt.wantEnd)
+			}
+
+			got := []day{}
+			for _, w := range calendar.Workouts {
+				got = append(got, day{w.Week, w.Day, w.Date, w.Workout.ID})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("workouts = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetProgramCalendarInvalidParams(t *testing.T) {
+	const programID = "11111111-1111-4111-8111-111111111111"
+
+	tests := []struct {
+		name      string
+		programID string
+		startDate string
+	}{
+		{"missing start date", programID, ""},
+		{"start date in another format", programID, "05/06/2024"},
+		{"start date isn't a date", programID, "2024-02-30"},
+		{"invalid program id", "p1", "2024-05-06"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"UserID": "u1"},
+				PathParameters:        map[string]string{"programId": tt.programID},
+				QueryStringParameters: map[string]string{"startDate": tt.startDate},
+			}
+			res, err := shared.WithUserID(getProgramCalendar)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, http.StatusBadRequest, res.Body)
+			}
+		})
+	}
+}
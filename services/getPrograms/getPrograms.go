@@ -3,10 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
@@ -14,62 +12,102 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
-type customProgram struct {
-	ID              string             `json:"id"`
-	Name            string             `json:"name"`
-	Description     string             `json:"description"`
-	Public          bool               `json:"public"`
-	EquipmentNeeded []string           `json:"equipmentNeeded"`
-	NumWeeks        int                `json:"numWeeks"`
-	Workouts        [][]shared.Workout `json:"workouts"`
-	CreatedBy       string             `json:"createdBy"`
-	CreatedDate     string             `json:"createdDate"`
-}
+// validFields are the json field names that can be requested with the fields query param
+var validFields = []string{"id", "name", "description", "public", "equipmentNeeded", "numWeeks", "workouts", "createdBy", "createdDate", "updatedDate", "computedDifficulty", "isOwner"}
 
-func formatOutput(item map[string]*dynamodb.AttributeValue) (customProgram, error) {
-	program := customProgram{}
-	var err error
+// getFields parses the comma separated fields query param
+// an empty slice means all fields should be returned
+func getFields(request events.APIGatewayV2HTTPRequest) ([]string, error) {
+	fieldsStr, _ := request.QueryStringParameters["fields"]
+	fieldsStr = strings.TrimSpace(fieldsStr)
+	if fieldsStr == "" {
+		return []string{}, nil
+	}
 
-	if item["Id"].S != nil {
-		program.ID = *item["Id"].S
+	fields := []string{}
+	for _, f := range strings.Split(fieldsStr, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		valid := false
+		for _, vf := range validFields {
+			if f == vf {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("Invalid field %s, fields must be one of: %s", f, strings.Join(validFields, ", "))
+		}
+
+		fields = append(fields, f)
 	}
-	if item["Name"].S != nil {
-		program.Name = *item["Name"].S
+
+	return fields, nil
+}
+
+// includesField returns true if field was requested or if no fields were requested
+func includesField(fields []string, field string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
+
+// projectFields restricts the program to the requested fields
+func projectFields(program shared.Program, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return program, nil
 	}
-	if item["Description"].S != nil {
-		program.Description = *item["Description"].S
+
+	programBytes, err := json.Marshal(program)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to marshal program: %s", err)
 	}
-	if item["Public"].BOOL != nil {
-		program.Public = *item["Public"].BOOL
+	allFields := map[string]json.RawMessage{}
+	err = json.Unmarshal(programBytes, &allFields)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal program: %s", err)
 	}
-	if item["CreatedBy"].S != nil {
-		program.CreatedBy = *item["CreatedBy"].S
+
+	projected := map[string]json.RawMessage{}
+	for _, f := range fields {
+		projected[f] = allFields[f]
 	}
-	if item["CreatedDate"].S != nil {
-		program.CreatedDate = *item["CreatedDate"].S
+
+	return projected, nil
+}
+
+// matchesQuery returns true if the program's name, description or equipment contains q, ignoring case
+// q must already be lowercase
+func matchesQuery(program shared.Program, q string) bool {
+	if q == "" {
+		return true
 	}
-	if item["EquipmentNeeded"].SS != nil {
-		for _, en := range item["EquipmentNeeded"].SS {
-			program.EquipmentNeeded = append(program.EquipmentNeeded, *en)
-		}
+	if strings.Contains(strings.ToLower(program.Name), q) || strings.Contains(strings.ToLower(program.Description), q) {
+		return true
 	}
-	if item["NumWeeks"].N != nil {
-		program.NumWeeks, err = strconv.Atoi(*item["NumWeeks"].N)
-		if err != nil {
-			return customProgram{}, fmt.Errorf("Unable to convert NumWeeks to int: %s", err)
+	for _, e := range program.EquipmentNeeded {
+		if strings.Contains(strings.ToLower(e), q) {
+			return true
 		}
 	}
-	err = json.Unmarshal(item["Workouts"].B, &program.Workouts)
-	if err != nil {
-		return customProgram{}, fmt.Errorf("Unable to unmarshal response: %s", err)
-	}
 
-	return program, nil
+	return false
 }
 
-func getProgramByID(db *dynamodb.DynamoDB, tableName, userID, programID string) (events.APIGatewayProxyResponse, error) {
+func getProgramByID(db dynamodbiface.DynamoDBAPI, tableName, userID, programID string, fields []string) (events.APIGatewayProxyResponse, error) {
 	getItemInput := &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -78,146 +116,88 @@ func getProgramByID(db *dynamodb.DynamoDB, tableName, userID, programID string)
 	}
 	getItemOutput, err := db.GetItem(getItemInput)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to get program: %s"
-		}`, http.StatusInternalServerError, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get program: %s", err)), nil
 	}
 
 	// Check if item is not found
-	if len(getItemOutput.Item) == 0 {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to find program %s"
-		}`, http.StatusBadRequest, programID)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
 	}
-
-	// If either value is nil, won't be ale to dereference in following if statement
-	if getItemOutput.Item["Public"].BOOL == nil || getItemOutput.Item["CreatedBy"].S == nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, errors.New("Invalid nil pointer on Public or CreatedBy")
+	if !shared.IsItemType(getItemOutput.Item, shared.ItemTypeProgram) {
+		return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("%s is not a program", programID)), nil
 	}
 
-	// If program is not public or created by the user then they don't have access
-	if *getItemOutput.Item["Public"].BOOL == false && *getItemOutput.Item["CreatedBy"].S != userID {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unauthorized to view this program"
-		}`, http.StatusUnauthorized)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusUnauthorized,
-			Body:       errBody,
-		}, nil
+	// Format getItemOutput to shared.Program
+	program, err := shared.FormatProgram(getItemOutput.Item, includesField(fields, "workouts"))
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 	}
 
-	// Format getItemOutput to customProgram
-	program, err := formatOutput(getItemOutput.Item)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, err
+	// If program is not public or created by the user then they don't have access
+	if !program.Public && program.CreatedBy != userID {
+		// It's reported as not found so the ids of private programs can't be probed
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
 	}
 
-	reply, err := json.Marshal(program)
+	program.IsOwner = program.CreatedBy == userID
+	projected, err := projectFields(program, fields)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	return shared.JSONResponse(http.StatusOK, projected)
 }
 
-func getAllPrograms(db *dynamodb.DynamoDB, tableName, userID string) (events.APIGatewayProxyResponse, error) {
+func getAllPrograms(db dynamodbiface.DynamoDBAPI, tableName, userID, q string, fields []string) (events.APIGatewayProxyResponse, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(tableName),
 		ExpressionAttributeNames: map[string]*string{
 			"#P": aws.String("Public"),
+			"#T": aws.String("Type"),
 		},
-		FilterExpression: aws.String("#P = :public or CreatedBy = :createdBy"),
+		FilterExpression: aws.String("(#P = :public or CreatedBy = :createdBy) and " + shared.ItemTypeFilter + " and " + shared.NotDeletedFilter),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":public":    {BOOL: aws.Bool(true)},
 			":createdBy": {S: aws.String(userID)},
+			":type":      {S: aws.String(shared.ItemTypeProgram)},
 		},
 	}
-	scanOutput, err := db.Scan(scanInput)
-	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to get existing programs: %s"
-		}`, http.StatusInternalServerError, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+	// The Workouts blob is most of each item, so it isn't read unless it's returned
+	if !includesField(fields, "workouts") {
+		scanInput.ExpressionAttributeNames["#N"] = aws.String("Name")
+		scanInput.ProjectionExpression = aws.String(shared.ProgramSummaryProjection)
 	}
-
-	// Check if no results are returned
-	if len(scanOutput.Items) == 0 {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusOK,
-			Body:       "[]",
-		}, nil
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get existing programs: %s", err)), nil
 	}
 
-	// Format scanOutput to []customProgram
-	programs := []customProgram{}
-	for _, i := range scanOutput.Items {
-		p, err := formatOutput(i)
+	// Format items to []shared.Program
+	programs := []interface{}{}
+	for _, i := range items {
+		p, err := shared.FormatProgram(i, includesField(fields, "workouts"))
 		if err != nil {
-			return events.APIGatewayProxyResponse{
-				StatusCode: http.StatusInternalServerError,
-			}, err
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 		}
-		programs = append(programs, p)
-	}
-
-	reply, err := json.Marshal(programs)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		if !matchesQuery(p, q) {
+			continue
+		}
+		p.IsOwner = p.CreatedBy == userID
+		projected, err := projectFields(p, fields)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		programs = append(programs, projected)
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	return shared.JSONResponse(http.StatusOK, programs)
 }
 
 func getPrograms(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
-	// Get UserID header
-	userID, ok := request.Headers["UserID"]
-	userID = strings.TrimSpace(userID)
-	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
-	}
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	tableRegion, tableName, err := shared.GetTableFor(shared.TablePrograms)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -227,15 +207,28 @@ func getPrograms(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 	programID, _ := request.PathParameters["programId"]
 	programID = strings.TrimSpace(programID)
 
+	// Check for query parameters
+	// q - only return programs whose name, description or equipment contains q, ignoring case
+	q, _ := request.QueryStringParameters["q"]
+	q = strings.ToLower(strings.TrimSpace(q))
+	// fields - comma separated list of fields to return. Defaults to all fields
+	fields, err := getFields(request)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
 	db := shared.GetDB(tableRegion)
 
 	if len(programID) > 0 {
-		return getProgramByID(db, tableName, userID, programID)
+		if err := shared.ValidateID("programId", programID); err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
+		return getProgramByID(db, tableName, userID, programID, fields)
 	}
 
-	return getAllPrograms(db, tableName, userID)
+	return getAllPrograms(db, tableName, userID, q, fields)
 }
 
 func main() {
-	lambda.Start(getPrograms)
+	lambda.Start(shared.Chain(getPrograms, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithMetrics("getPrograms"), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
 }
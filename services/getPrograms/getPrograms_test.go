@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB serves the programs in items from Scan and GetItem
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items []map[string]*dynamodb.AttributeValue
+	scans []*dynamodb.ScanInput
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.scans = append(m.scans, input)
+	return &dynamodb.ScanOutput{Items: m.items}, nil
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	for _, i := range m.items {
+		if *i["Id"].S == *input.Key["Id"].S {
+			return &dynamodb.GetItemOutput{Item: i}, nil
+		}
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func programItem(id, name, description, createdBy string, public bool) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":          {S: aws.String(id)},
+		"Type":        {S: aws.String(shared.ItemTypeProgram)},
+		"Name":        {S: aws.String(name)},
+		"Description": {S: aws.String(description)},
+		"CreatedBy":   {S: aws.String(createdBy)},
+		"Public":      {BOOL: aws.Bool(public)},
+		"Workouts":    {B: []byte(`[[{"id":"w1","difficulty_estimate":5}]]`)},
+	}
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+// callGetPrograms calls the handler as userID with the query params
+func callGetPrograms(t *testing.T, userID, programID string, params map[string]string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:               map[string]string{"UserID": userID},
+		PathParameters:        map[string]string{"programId": programID},
+		QueryStringParameters: params,
+	}
+	res, err := shared.WithUserID(getPrograms)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestGetFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		want    []string
+		wantErr bool
+	}{
+		{"missing", "", []string{}, false},
+		{"blank", "  ", []string{}, false},
+		{"single", "name", []string{"name"}, false},
+		{"trimmed and empty skipped", " id , name,,isOwner ", []string{"id", "name", "isOwner"}, false},
+		{"invalid", "id,password", nil, true},
+		{"wrong case", "Name", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{QueryStringParameters: map[string]string{"fields": tt.fields}}
+			got, err := getFields(request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getFields() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludesField(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []string
+		field  string
+		want   bool
+	}{
+		{"no fields requested", []string{}, "workouts", true},
+		{"requested", []string{"id", "workouts"}, "workouts", true},
+		{"not requested", []string{"id", "name"}, "workouts", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := includesField(tt.fields, tt.field); got != tt.want {
+				t.Errorf("includesField() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectFields(t *testing.T) {
+	program := shared.Program{ID: "p1", Name: "Power Zone Builder", NumWeeks: 6, IsOwner: true}
+
+	tests := []struct {
+		name     string
+		fields   []string
+		wantKeys []string
+	}{
+		{"all fields", []string{}, []string{"computedDifficulty", "createdBy", "createdDate", "description", "equipmentNeeded",
+			"id", "isOwner", "name", "numWeeks", "public", "updatedDate", "workouts"}},
+		{"subset", []string{"id", "name"}, []string{"id", "name"}},
+		{"computed field", []string{"isOwner"}, []string{"isOwner"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projected, err := projectFields(program, tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := json.Marshal(projected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]interface{}{}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			keys := []string{}
+			for k := range got {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("projected keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if _, ok := got["name"]; ok && got["name"] != program.Name {
+				t.Errorf("name = %v, want %s", got["name"], program.Name)
+			}
+		})
+	}
+}
+
+func TestMatchesQuery(t *testing.T) {
+	program := shared.Program{Name: "Power Zone Builder", Description: "Six weeks of endurance rides", EquipmentNeeded: []string{"Heart Rate Monitor"}}
+
+	tests := []struct {
+		name string
+		q    string
+		want bool
+	}{
+		{"no query", "", true},
+		{"name match", "power zone", true},
+		{"description match", "endurance", true},
+		{"equipment match", "heart rate", true},
+		{"no match", "yoga", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesQuery(program, tt.q); got != tt.want {
+				t.Errorf("matchesQuery(%q) = %t, want %t", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPrograms(t *testing.T) {
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		programItem("11111111-1111-1111-1111-111111111111", "Power Zone Builder", "Six weeks of rides", "u2", true),
+		programItem("22222222-2222-2222-2222-222222222222", "Strength Basics", "Build endurance with weights", "u1", false),
+		programItem("33333333-3333-3333-3333-333333333333", "Yoga Flow", "Stretch it out", "u2", true),
+	}}
+	defer withMockDB(db)()
+
+	tests := []struct {
+		name    string
+		q       string
+		wantIDs []string
+	}{
+		{"no query", "", []string{"11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222", "33333333-3333-3333-3333-333333333333"}},
+		{"name match ignores case", "POWER zone", []string{"11111111-1111-1111-1111-111111111111"}},
+		{"description match", "endurance", []string{"22222222-2222-2222-2222-222222222222"}},
+		{"no match", "barre", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := callGetPrograms(t, "u1", "", map[string]string{"q": tt.q})
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body %s", res.StatusCode, res.Body)
+			}
+			programs := []shared.Program{}
+			if err := json.Unmarshal([]byte(res.Body), &programs); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := []string{}
+			for _, p := range programs {
+				ids = append(ids, p.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("programs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetProgramByIDResponses(t *testing.T) {
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		programItem("11111111-1111-1111-1111-111111111111", "Power Zone Builder", "Six weeks of rides", "u2", true),
+		programItem("22222222-2222-2222-2222-222222222222", "Private Plan", "Mine only", "u2", false),
+		programItem("33333333-3333-3333-3333-333333333333", "May Miles", "A challenge", "u2", true),
+	}}
+	db.items[2]["Type"] = &dynamodb.AttributeValue{S: aws.String(shared.ItemTypeChallenge)}
+	defer withMockDB(db)()
+
+	tests := []struct {
+		name       string
+		programID  string
+		wantStatus int
+	}{
+		{"public program", "11111111-1111-1111-1111-111111111111", http.StatusOK},
+		{"someone else's private program", "22222222-2222-2222-2222-222222222222", http.StatusNotFound},
+		{"challenge id", "33333333-3333-3333-3333-333333333333", http.StatusBadRequest},
+		{"unknown program", "44444444-4444-4444-4444-444444444444", http.StatusNotFound},
+		{"invalid id", "not an id", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := callGetPrograms(t, "u1", tt.programID, nil)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if res.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", res.Headers["Content-Type"])
+			}
+			if !json.Valid([]byte(res.Body)) {
+				t.Errorf("body %q isn't JSON", res.Body)
+			}
+		})
+	}
+}
+
+func TestIsOwner(t *testing.T) {
+	mine, public := "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		programItem(mine, "Private Plan", "Mine only", "u1", false),
+		programItem(public, "Power Zone Builder", "Six weeks of rides", "u2", true),
+	}}
+	defer withMockDB(db)()
+
+	tests := []struct {
+		name      string
+		programID string
+		want      map[string]bool
+	}{
+		{"list", "", map[string]bool{mine: true, public: false}},
+		{"my program", mine, map[string]bool{mine: true}},
+		{"someone else's program", public, map[string]bool{public: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := callGetPrograms(t, "u1", tt.programID, nil)
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body %s", res.StatusCode, res.Body)
+			}
+
+			programs := []shared.Program{}
+			if tt.programID == "" {
+				if err := json.Unmarshal([]byte(res.Body), &programs); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				program := shared.Program{}
+				if err := json.Unmarshal([]byte(res.Body), &program); err != nil {
+					t.Fatal(err)
+				}
+				programs = append(programs, program)
+			}
+			got := map[string]bool{}
+			for _, p := range programs {
+				got[p.ID] = p.IsOwner
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("isOwner = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListProjection(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         string
+		wantProjection bool
+	}{
+		{"all fields", "", false},
+		{"workouts requested", "id,workouts", false},
+		{"summary fields", "id,name,computedDifficulty", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			defer withMockDB(db)()
+
+			res := callGetPrograms(t, "u1", "", map[string]string{"fields": tt.fields})
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, body %s", res.StatusCode, res.Body)
+			}
+			scan := db.scans[0]
+			if got := scan.ProjectionExpression != nil; got != tt.wantProjection {
+				t.Fatalf("projected = %t, want %t", got, tt.wantProjection)
+			}
+			if tt.wantProjection && strings.Contains(*scan.ProjectionExpression, "Workouts") {
+				t.Errorf("ProjectionExpression %q reads the Workouts blob", *scan.ProjectionExpression)
+			}
+			// Every name bound must be used, DynamoDB rejects unused names
+			for name := range scan.ExpressionAttributeNames {
+				if !strings.Contains(*scan.FilterExpression, name) && !strings.Contains(aws.StringValue(scan.ProjectionExpression), name) {
+					t.Errorf("%s isn't used", name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetProgramsPanic(t *testing.T) {
+	// Every call on a nil client panics
+	defer withMockDB(struct{ dynamodbiface.DynamoDBAPI }{})()
+
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"UserID": "u1"},
+		PathParameters: map[string]string{"programId": "11111111-1111-1111-1111-111111111111"},
+	}
+	request.RequestContext.RequestID = "gateway-id"
+	handler := shared.Chain(getPrograms, shared.WithRequestID, shared.WithRecovery, shared.WithUserID)
+
+	res, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("error = %s, want nil", err)
+	}
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("StatusCode = %d, want 500", res.StatusCode)
+	}
+	body := struct {
+		Status    int    `json:"status"`
+		Message   string `json:"message"`
+		RequestID string `json:"requestId"`
+	}{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+	}
+	if body.Status != http.StatusInternalServerError || body.Message != "internal error" || body.RequestID != "gateway-id" {
+		t.Errorf("body = %+v", body)
+	}
+	if res.Headers["X-Request-Id"] != "gateway-id" {
+		t.Errorf("X-Request-Id = %q, want gateway-id", res.Headers["X-Request-Id"])
+	}
+}
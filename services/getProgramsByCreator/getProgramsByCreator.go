@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Path Params:
+//   creatorId - Peloton user id of the user that created the programs
+
+// queryCreatorPrograms queries the CreatedBy GSI for the creator's programs
+// private programs are only returned if the caller is the creator
+func queryCreatorPrograms(db dynamodbiface.DynamoDBAPI, tableName, creatorID string, includePrivate bool) ([]shared.Program, error) {
+	// The index also has the creator's challenges and recommendations if they share the table
+	filter := shared.ItemTypeFilter + " and " + shared.NotDeletedFilter
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(shared.GetCreatedByIndexName()),
+		KeyConditionExpression: aws.String("CreatedBy = :createdBy"),
+		FilterExpression:       aws.String(filter),
+		ExpressionAttributeNames: map[string]*string{
+			"#T": aws.String("Type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":createdBy": {S: aws.String(creatorID)},
+			":type":      {S: aws.String(shared.ItemTypeProgram)},
+		},
+	}
+	if !includePrivate {
+		queryInput.FilterExpression = aws.String("#P = :public and " + filter)
+		queryInput.ExpressionAttributeNames["#P"] = aws.String("Public")
+		queryInput.ExpressionAttributeValues[":public"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	}
+
+	programs := []shared.Program{}
+	for {
+		queryOutput, err := db.Query(queryInput)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get programs: %s", err)
+		}
+		for _, i := range queryOutput.Items {
+			p, err := shared.FormatProgram(i, true)
+			if err != nil {
+				return nil, err
+			}
+			p.IsOwner = includePrivate
+			programs = append(programs, p)
+		}
+
+		if len(queryOutput.LastEvaluatedKey) == 0 {
+			break
+		}
+		queryInput.ExclusiveStartKey = queryOutput.LastEvaluatedKey
+	}
+
+	return programs, nil
+}
+
+// getProgramsByCreator returns the public programs created by a user, or all of them if the caller is the creator
+func getProgramsByCreator(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	creatorID, _ := request.PathParameters["creatorId"]
+	creatorID = strings.TrimSpace(creatorID)
+	if creatorID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter creatorId is required"), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TablePrograms)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	programs, err := queryCreatorPrograms(db, tableName, creatorID, creatorID == userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	return shared.JSONResponse(http.StatusOK, programs)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getProgramsByCreator, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithUserID))
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB serves items from Query a page at a time, matching the key and filter like the CreatedBy index would
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items   []map[string]*dynamodb.AttributeValue
+	queries []*dynamodb.QueryInput
+}
+
+func (m *mockDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	m.queries = append(m.queries, input)
+	creatorID := aws.StringValue(input.ExpressionAttributeValues[":createdBy"].S)
+	filter := aws.StringValue(input.FilterExpression)
+
+	// Each page reads a single item so the handler has to follow LastEvaluatedKey
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		for i, item := range m.items {
+			if *item["Id"].S == *input.ExclusiveStartKey["Id"].S {
+				start = i + 1
+			}
+		}
+	}
+	output := &dynamodb.QueryOutput{Items: []map[string]*dynamodb.AttributeValue{}}
+	for i := start; i < len(m.items); i++ {
+		item := m.items[i]
+		if *item["CreatedBy"].S != creatorID {
+			continue
+		}
+		if i < len(m.items)-1 {
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": item["Id"]}
+		}
+		// The filter is applied after the page is read, so a page can be empty
+		hidden := strings.Contains(filter, "#P = :public") && !aws.BoolValue(item["Public"].BOOL)
+		deleted := strings.Contains(filter, shared.NotDeletedFilter) && shared.IsDeleted(item)
+		otherType := strings.Contains(filter, shared.ItemTypeFilter) && !shared.IsItemType(item, aws.StringValue(input.ExpressionAttributeValues[":type"].S))
+		if !hidden && !deleted && !otherType {
+			output.Items = append(output.Items, item)
+		}
+		break
+	}
+
+	return output, nil
+}
+
+func programItem(id, createdBy string, public bool, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeProgram)},
+		"Name":      {S: aws.String("Program " + id)},
+		"CreatedBy": {S: aws.String(createdBy)},
+		"Public":    {BOOL: aws.Bool(public)},
+		"Workouts":  {B: []byte(`[[{"id":"w1"}]]`)},
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func TestGetProgramsByCreator(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		creatorID  string
+		wantStatus int
+		want       []string
+		wantOwner  bool
+	}{
+		{"someone else's programs", "user2", "user1", http.StatusOK, []string{"public", "public2"}, false},
+		{"my programs", "user1", "user1", http.StatusOK, []string{"public", "private", "public2"}, true},
+		{"creator without programs", "user1", "user3", http.StatusOK, []string{}, false},
+		{"missing creator", "user1", " ", http.StatusBadRequest, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+				programItem("public", "user1", true, nil),
+				programItem("private", "user1", false, nil),
+				programItem("other", "user2", true, nil),
+				programItem("deleted", "user1", true, map[string]*dynamodb.AttributeValue{
+					"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+				}),
+				programItem("challenge", "user1", true, map[string]*dynamodb.AttributeValue{
+					"Type": {S: aws.String(shared.ItemTypeChallenge)},
+				}),
+				programItem("public2", "user1", true, nil),
+			}}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": tt.userID},
+				PathParameters: map[string]string{"creatorId": tt.creatorID},
+			}
+			res, err := shared.WithUserID(getProgramsByCreator)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			// The index is queried instead of scanning the table
+			if len(db.queries) == 0 || aws.StringValue(db.queries[0].IndexName) != shared.GetCreatedByIndexName() {
+				t.Errorf("the CreatedBy index wasn't queried")
+			}
+			programs := []shared.Program{}
+			if err := json.Unmarshal([]byte(res.Body), &programs); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, p := range programs {
+				got = append(got, p.ID)
+				if p.IsOwner != tt.wantOwner {
+					t.Errorf("%s isOwner = %t, want %t", p.ID, p.IsOwner, tt.wantOwner)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("programs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
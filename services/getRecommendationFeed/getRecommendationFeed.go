@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Query Params:
+//   page - Used for pagination, page starts at 0
+//   limit - number of results per page. Defaults to 20, max of 100
+
+// Env Vars:
+//   programs_table_name - Optional. Table to load recommended programs from, defaults to table_name
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+// feedEntry is a workout or program recommended to the user
+type feedEntry struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	CreatedBy   string          `json:"createdBy"`
+	CreatedDate string          `json:"createdDate"`
+	Workout     *shared.Workout `json:"workout,omitempty"`
+	ProgramID   string          `json:"programId,omitempty"`
+	Program     *shared.Program `json:"program,omitempty"`
+}
+
+type feedResponse struct {
+	Items []feedEntry `json:"items"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+	Total int         `json:"total"`
+}
+
+// getPaging parses the page and limit query params
+func getPaging(request events.APIGatewayV2HTTPRequest) (int, int, error) {
+	page, limit := 0, defaultLimit
+	var err error
+
+	if pageStr, ok := request.QueryStringParameters["page"]; ok {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 0 {
+			return 0, 0, errors.New("page must be a number greater than or equal to 0")
+		}
+	}
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+		}
+	}
+
+	return page, limit, nil
+}
+
+// formatEntry converts a recommendation item to a feedEntry
+func formatEntry(item map[string]*dynamodb.AttributeValue) (feedEntry, error) {
+	entry := feedEntry{
+		Type: shared.RecommendationWorkout,
+	}
+
+	if item["Id"] != nil && item["Id"].S != nil {
+		entry.ID = *item["Id"].S
+	}
+	if item["CreatedBy"] != nil && item["CreatedBy"].S != nil {
+		entry.CreatedBy = *item["CreatedBy"].S
+	}
+	// Recommendations made before CreatedDate was stored sort last
+	if item["CreatedDate"] != nil && item["CreatedDate"].S != nil {
+		entry.CreatedDate = *item["CreatedDate"].S
+	}
+	if item["ProgramId"] != nil && item["ProgramId"].S != nil {
+		entry.Type = shared.RecommendationProgram
+		entry.ProgramID = *item["ProgramId"].S
+		return entry, nil
+	}
+	if workoutData := shared.JSONAttributeBytes(item["Workout"]); workoutData != nil {
+		workout := shared.Workout{}
+		err := json.Unmarshal(workoutData, &workout)
+		if err != nil {
+			return feedEntry{}, fmt.Errorf("Unable to unmarshal workout: %s", err)
+		}
+		entry.Workout = &workout
+	}
+
+	return entry, nil
+}
+
+// hydratePrograms loads the program of each program recommendation
+// programs that no longer exist are left as just their programId
+func hydratePrograms(db dynamodbiface.DynamoDBAPI, tableName string, entries []feedEntry) {
+	// programs_table_name is kept for deployments configured before programs_table
+	programsTableName := os.Getenv("programs_table_name")
+	if programsTableName == "" {
+		programsTableName = tableName
+		if _, name, err := shared.GetTableFor(shared.TablePrograms); err == nil {
+			programsTableName = name
+		}
+	}
+
+	programs := map[string]*shared.Program{}
+	for idx, e := range entries {
+		if e.Type != shared.RecommendationProgram {
+			continue
+		}
+
+		if _, ok := programs[e.ProgramID]; !ok {
+			programs[e.ProgramID] = nil
+			getItemOutput, err := db.GetItem(&dynamodb.GetItemInput{
+				TableName: aws.String(programsTableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"Id": {S: aws.String(e.ProgramID)},
+				},
+			})
+			if err != nil {
+				log.Printf("Unable to get program %s: %s", e.ProgramID, err)
+			} else if len(getItemOutput.Item) > 0 {
+				program, err := shared.FormatProgram(getItemOutput.Item, false)
+				if err != nil {
+					log.Printf("Unable to format program %s: %s", e.ProgramID, err)
+				} else {
+					programs[e.ProgramID] = &program
+				}
+			}
+		}
+		entries[idx].Program = programs[e.ProgramID]
+	}
+}
+
+// getRecommendationFeed returns the workouts and programs recommended to the user, newest first
+func getRecommendationFeed(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	page, limit, err := getPaging(request)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableRecommendations)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(tableName),
+		FilterExpression: aws.String("RecommendedFor = :userID and " + shared.NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userID": {S: aws.String(userID)},
+		},
+	}
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get existing recommendations: %s", err)), nil
+	}
+
+	entries := []feedEntry{}
+	for _, i := range items {
+		e, err := formatEntry(i)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, err
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].CreatedDate > entries[j].CreatedDate
+	})
+
+	total := len(entries)
+	start := page * limit
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	entries = entries[start:end]
+
+	// Only the programs on the page are loaded
+	hydratePrograms(db, tableName, entries)
+
+	return shared.JSONResponse(http.StatusOK, feedResponse{
+		Items: entries,
+		Page:  page,
+		Limit: limit,
+		Total: total,
+	})
+}
+
+func main() {
+	lambda.Start(shared.Chain(getRecommendationFeed, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
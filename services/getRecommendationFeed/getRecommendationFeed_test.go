@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB returns the recommendations for the user from every Scan and serves programs by Id
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	recommendations []map[string]*dynamodb.AttributeValue
+	programs        map[string]map[string]*dynamodb.AttributeValue
+	gets            int
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.recommendations {
+		if *i["RecommendedFor"].S == *input.ExpressionAttributeValues[":userID"].S && !shared.IsDeleted(i) {
+			items = append(items, i)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	m.gets++
+	return &dynamodb.GetItemOutput{Item: m.programs[*input.Key["Id"].S]}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func recommendation(id, createdDate string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"Id":             {S: aws.String(id)},
+		"CreatedBy":      {S: aws.String("u2")},
+		"RecommendedFor": {S: aws.String("u1")},
+	}
+	if createdDate != "" {
+		item["CreatedDate"] = &dynamodb.AttributeValue{S: aws.String(createdDate)}
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+func feedDB() *mockDB {
+	workout := func(rideID string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"Workout": {S: aws.String(`{"id": "` + rideID + `"}`)}}
+	}
+	program := func(programID string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"ProgramId": {S: aws.String(programID)}}
+	}
+
+	return &mockDB{
+		recommendations: []map[string]*dynamodb.AttributeValue{
+			recommendation("oldWorkout", "2024-05-01T00:00:00Z", workout("r1")),
+			recommendation("newProgram", "2024-05-04T00:00:00Z", program("p1")),
+			recommendation("legacyWorkout", "", workout("r2")),
+			recommendation("newWorkout", "2024-05-03T00:00:00Z", workout("r3")),
+			recommendation("missingProgram", "2024-05-02T00:00:00Z", program("p2")),
+			recommendation("otherUser", "2024-05-05T00:00:00Z", map[string]*dynamodb.AttributeValue{
+				"RecommendedFor": {S: aws.String("u3")},
+			}),
+		},
+		programs: map[string]map[string]*dynamodb.AttributeValue{
+			"p1": {"Id": {S: aws.String("p1")}, "Name": {S: aws.String("Power Zone Builder")}},
+		},
+	}
+}
+
+func TestGetRecommendationFeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		want       string
+		wantGets   int
+	}{
+		{"newest first", nil, http.StatusOK, "newProgram:program,newWorkout:workout,missingProgram:program,oldWorkout:workout,legacyWorkout:workout", 2},
+		{"page", map[string]string{"page": "1", "limit": "2"}, http.StatusOK, "missingProgram:program,oldWorkout:workout", 1},
+		{"past the last page", map[string]string{"page": "3", "limit": "2"}, http.StatusOK, "", 0},
+		{"invalid page", map[string]string{"page": "-1"}, http.StatusBadRequest, "", 0},
+		{"invalid limit", map[string]string{"limit": "101"}, http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := feedDB()
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"UserID": "u1"},
+				QueryStringParameters: tt.params,
+			}
+			res, err := shared.WithUserID(getRecommendationFeed)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			feed := feedResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &feed); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, e := range feed.Items {
+				got = append(got, e.ID+":"+e.Type)
+				if e.Type == shared.RecommendationWorkout && e.Workout == nil {
+					t.Errorf("%s has no workout", e.ID)
+				}
+				// Programs that no longer exist are left as just their id
+				if e.Type == shared.RecommendationProgram && (e.Program != nil) != (e.ProgramID == "p1") {
+					t.Errorf("%s program = %+v", e.ID, e.Program)
+				}
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("feed = %v, want %s", got, tt.want)
+			}
+			if feed.Total != 5 {
+				t.Errorf("Total = %d, want 5", feed.Total)
+			}
+			if db.gets != tt.wantGets {
+				t.Errorf("%d programs loaded, want only the %d on the page", db.gets, tt.wantGets)
+			}
+		})
+	}
+}
@@ -13,29 +13,21 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
+// recommendation is read with dynamodbattribute, the dynamodbav tags are the attribute names
 type recommendation struct {
-	ID             string         `json:"id"`
-	CreatedBy      string         `json:"createdBy"`
-	RecommendedFor string         `json:"recommendedFor"`
-	Workout        shared.Workout `json:"workout"`
+	ID             string             `json:"id" dynamodbav:"Id"`
+	CreatedBy      string             `json:"createdBy" dynamodbav:"CreatedBy"`
+	RecommendedFor string             `json:"recommendedFor" dynamodbav:"RecommendedFor"`
+	Workout        shared.WorkoutBlob `json:"workout" dynamodbav:"Workout"`
 }
 
 func formatOutput(item map[string]*dynamodb.AttributeValue) (recommendation, error) {
 	rec := recommendation{}
-	var err error
-
-	if item["Id"].S != nil {
-		rec.ID = *item["Id"].S
-	}
-	if item["CreatedBy"].S != nil {
-		rec.CreatedBy = *item["CreatedBy"].S
-	}
-	if item["RecommendedFor"].S != nil {
-		rec.RecommendedFor = *item["RecommendedFor"].S
-	}
-	err = json.Unmarshal(item["Workout"].B, &rec.Workout)
+	err := dynamodbattribute.UnmarshalMap(item, &rec)
 	if err != nil {
 		return recommendation{}, fmt.Errorf("Unable to unmarshal response: %s", err)
 	}
@@ -43,7 +35,24 @@ func formatOutput(item map[string]*dynamodb.AttributeValue) (recommendation, err
 	return rec, nil
 }
 
-func getRecommendationByID(db *dynamodb.DynamoDB, tableName, userID, recommendationID string) (events.APIGatewayProxyResponse, error) {
+// filterRecommendations removes recommendations whose workout doesn't match the instructor or discipline
+// an empty instructorID or discipline matches all recommendations
+func filterRecommendations(recs []recommendation, instructorID, discipline string) []recommendation {
+	filtered := []recommendation{}
+	for _, r := range recs {
+		if instructorID != "" && r.Workout.InstructorID != instructorID {
+			continue
+		}
+		if discipline != "" && !strings.EqualFold(r.Workout.FitnessDiscipline, discipline) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	return filtered
+}
+
+func getRecommendationByID(db dynamodbiface.DynamoDBAPI, tableName, userID, recommendationID string) (events.APIGatewayProxyResponse, error) {
 	getItemInput := &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
@@ -64,16 +73,11 @@ func getRecommendationByID(db *dynamodb.DynamoDB, tableName, userID, recommendat
 	}
 
 	// Check if item is not found
-	if len(getItemOutput.Item) == 0 {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to find recommendation %s"
-		}`, http.StatusBadRequest, recommendationID)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find recommendation %s", recommendationID)), nil
+	}
+	if !shared.IsItemType(getItemOutput.Item, shared.ItemTypeRecommendation) {
+		return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("%s is not a recommendation", recommendationID)), nil
 	}
 
 	// If either value is nil, won't be ale to dereference in following if statement
@@ -85,15 +89,8 @@ func getRecommendationByID(db *dynamodb.DynamoDB, tableName, userID, recommendat
 
 	// createdBy or recommendedFor must be the current user
 	if *getItemOutput.Item["CreatedBy"].S != userID && *getItemOutput.Item["RecommendedFor"].S != userID {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unauthorized to view this recommendation"
-		}`, http.StatusUnauthorized)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusUnauthorized,
-			Body:       errBody,
-		}, nil
+		// It's reported as not found so the ids of private recommendations can't be probed
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find recommendation %s", recommendationID)), nil
 	}
 
 	// Format getItemOutput to recommendation
@@ -117,40 +114,29 @@ func getRecommendationByID(db *dynamodb.DynamoDB, tableName, userID, recommendat
 	}, nil
 }
 
-func getAllRecommendations(db *dynamodb.DynamoDB, tableName, userID, recType string) (events.APIGatewayProxyResponse, error) {
+func getAllRecommendations(db dynamodbiface.DynamoDBAPI, tableName, userID, recType, instructorID, discipline string) (events.APIGatewayProxyResponse, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName: aws.String(tableName),
 	}
+	// Recommendations written before the Type attribute existed are the only untyped items with RecommendedFor
+	typeFilter := "(#T = :rec or (attribute_not_exists(#T) and attribute_exists(RecommendedFor))) and " + shared.NotDeletedFilter
 	switch recType {
-	case "forme":
-		scanInput.FilterExpression = aws.String("RecommendedFor = :userID")
-		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
-			":userID": {S: aws.String(userID)},
-		}
-	case "byme":
-		scanInput.FilterExpression = aws.String("CreatedBy = :userID")
-		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
-			":userID": {S: aws.String(userID)},
-		}
-	case "all":
-		scanInput.FilterExpression = aws.String("RecommendedFor = :userID or CreatedBy = :userID")
-		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
-			":userID": {S: aws.String(userID)},
-		}
-	default:
-		// Invalid value for type query parameter
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "type must be forMe, byMe, or all"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	case shared.RecTypeForMe:
+		scanInput.FilterExpression = aws.String(typeFilter + " and RecommendedFor = :userID")
+	case shared.RecTypeByMe:
+		scanInput.FilterExpression = aws.String(typeFilter + " and CreatedBy = :userID")
+	case shared.RecTypeAll:
+		scanInput.FilterExpression = aws.String(typeFilter + " and (RecommendedFor = :userID or CreatedBy = :userID)")
+	}
+	scanInput.ExpressionAttributeNames = map[string]*string{
+		"#T": aws.String("Type"),
+	}
+	scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+		":userID": {S: aws.String(userID)},
+		":rec":    {S: aws.String(shared.ItemTypeRecommendation)},
 	}
 
-	scanOutput, err := db.Scan(scanInput)
+	items, err := shared.ScanAll(db, scanInput)
 	if err != nil {
 		errBody := fmt.Sprintf(`{
 			"status": %d,
@@ -164,16 +150,16 @@ func getAllRecommendations(db *dynamodb.DynamoDB, tableName, userID, recType str
 	}
 
 	// Check if no results are returned
-	if len(scanOutput.Items) == 0 {
+	if len(items) == 0 {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusOK,
 			Body:       "[]",
 		}, nil
 	}
 
-	// Format scanOutput to []recommendation
+	// Format items to []recommendation
 	recs := []recommendation{}
-	for _, i := range scanOutput.Items {
+	for _, i := range items {
 		r, err := formatOutput(i)
 		if err != nil {
 			return events.APIGatewayProxyResponse{
@@ -182,6 +168,7 @@ func getAllRecommendations(db *dynamodb.DynamoDB, tableName, userID, recType str
 		}
 		recs = append(recs, r)
 	}
+	recs = filterRecommendations(recs, instructorID, discipline)
 
 	reply, err := json.Marshal(recs)
 	if err != nil {
@@ -212,7 +199,7 @@ func getRecommendations(ctx context.Context, request events.APIGatewayV2HTTPRequ
 		}, nil
 	}
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableRecommendations)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -225,21 +212,40 @@ func getRecommendations(ctx context.Context, request events.APIGatewayV2HTTPRequ
 
 	// Check for query parameters
 	// type - must be either forMe, byMe, or all. Determines the type of recommendations returned
-	recType, _ := request.QueryStringParameters["type"]
-	recType = strings.ToLower(strings.TrimSpace(recType))
-	if recType == "" {
-		recType = "forme"
+	recType, err := shared.ParseRecType(request.QueryStringParameters["type"])
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
 	}
+	// instructorId - only return recommendations for workouts by this instructor
+	instructorID, _ := request.QueryStringParameters["instructorId"]
+	instructorID = strings.TrimSpace(instructorID)
+	// discipline or workoutType - only return recommendations for workouts of this discipline. Ex) cycling, yoga
+	discipline, ok := request.QueryStringParameters["discipline"]
+	if !ok {
+		discipline, _ = request.QueryStringParameters["workoutType"]
+	}
+	discipline = strings.TrimSpace(discipline)
 
 	db := shared.GetDB(tableRegion)
 
 	if len(recommendationID) > 0 {
+		if err := shared.ValidateID("recommendationId", recommendationID); err != nil {
+			return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+		}
 		return getRecommendationByID(db, tableName, userID, recommendationID)
 	}
 
-	return getAllRecommendations(db, tableName, userID, recType)
+	return getAllRecommendations(db, tableName, userID, recType, instructorID, discipline)
 }
 
 func main() {
-	lambda.Start(getRecommendations)
+	lambda.Start(shared.Chain(getRecommendations, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func TestFilterRecommendations(t *testing.T) {
+	recs := []recommendation{
+		{ID: "1", Workout: shared.WorkoutBlob{InstructorID: "i1", FitnessDiscipline: "cycling"}},
+		{ID: "2", Workout: shared.WorkoutBlob{InstructorID: "i2", FitnessDiscipline: "cycling"}},
+		{ID: "3", Workout: shared.WorkoutBlob{InstructorID: "i1", FitnessDiscipline: "strength"}},
+	}
+
+	tests := []struct {
+		name         string
+		instructorID string
+		discipline   string
+		want         []string
+	}{
+		{"no filters", "", "", []string{"1", "2", "3"}},
+		{"instructor", "i1", "", []string{"1", "3"}},
+		{"discipline ignores case", "", "Cycling", []string{"1", "2"}},
+		{"both", "i1", "strength", []string{"3"}},
+		{"no matches", "i3", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range filterRecommendations(recs, tt.instructorID, tt.discipline) {
+				got = append(got, r.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterRecommendations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOutputWorkout(t *testing.T) {
+	workout := `{"id":"w1","title":"20 min Ride","instructor_id":"i1"}`
+
+	tests := []struct {
+		name    string
+		workout *dynamodb.AttributeValue
+		want    shared.WorkoutBlob
+		wantErr bool
+	}{
+		{"binary", &dynamodb.AttributeValue{B: []byte(workout)}, shared.WorkoutBlob{ID: "w1", Title: "20 min Ride", InstructorID: "i1"}, false},
+		{"legacy string", &dynamodb.AttributeValue{S: aws.String(workout)}, shared.WorkoutBlob{ID: "w1", Title: "20 min Ride", InstructorID: "i1"}, false},
+		{"missing", nil, shared.WorkoutBlob{}, false},
+		{"invalid JSON", &dynamodb.AttributeValue{B: []byte("not json")}, shared.WorkoutBlob{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := map[string]*dynamodb.AttributeValue{
+				"Id":             {S: aws.String("r1")},
+				"RecommendedFor": {S: aws.String("user1")},
+			}
+			if tt.workout != nil {
+				item["Workout"] = tt.workout
+			}
+
+			rec, err := formatOutput(item)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("formatOutput() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(rec.Workout, tt.want) {
+				t.Errorf("Workout = %+v, want %+v", rec.Workout, tt.want)
+			}
+		})
+	}
+}
+
+// mockDB matches items like the Type clause of the FilterExpression built by getAllRecommendations would
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items []map[string]*dynamodb.AttributeValue
+	scans []*dynamodb.ScanInput
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	for _, i := range m.items {
+		if *i["Id"].S == *input.Key["Id"].S {
+			return &dynamodb.GetItemOutput{Item: i}, nil
+		}
+	}
+
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.scans = append(m.scans, input)
+	filter := aws.StringValue(input.FilterExpression)
+	recType := aws.StringValue(input.ExpressionAttributeValues[":rec"].S)
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		if strings.Contains(filter, "#T = :rec or (attribute_not_exists(#T) and attribute_exists(RecommendedFor))") {
+			typed := i["Type"] != nil && *i["Type"].S == recType
+			legacy := i["Type"] == nil && i["RecommendedFor"] != nil
+			if !typed && !legacy {
+				continue
+			}
+		}
+		items = append(items, i)
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func TestGetAllRecommendationsType(t *testing.T) {
+	item := func(id, itemType string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+		i := map[string]*dynamodb.AttributeValue{
+			"Id":        {S: aws.String(id)},
+			"CreatedBy": {S: aws.String("user1")},
+		}
+		if itemType != "" {
+			i["Type"] = &dynamodb.AttributeValue{S: aws.String(itemType)}
+		}
+		for k, v := range attrs {
+			i[k] = v
+		}
+		return i
+	}
+	recommendedFor := map[string]*dynamodb.AttributeValue{"RecommendedFor": {S: aws.String("user2")}}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		item("rec", shared.ItemTypeRecommendation, recommendedFor),
+		item("legacyRec", "", recommendedFor),
+		item("program", shared.ItemTypeProgram, nil),
+		item("challenge", shared.ItemTypeChallenge, nil),
+		item("legacyProgram", "", nil),
+	}}
+
+	res, err := getAllRecommendations(db, "pelodata", "user1", shared.RecTypeAll, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d: %s", res.StatusCode, res.Body)
+	}
+
+	// DynamoDB rejects unused expression names and values
+	scan := db.scans[0]
+	if aws.StringValue(scan.ExpressionAttributeNames["#T"]) != "Type" || aws.StringValue(scan.ExpressionAttributeValues[":rec"].S) != shared.ItemTypeRecommendation {
+		t.Errorf("#T and :rec aren't bound for %s", aws.StringValue(scan.FilterExpression))
+	}
+	recs := []recommendation{}
+	if err := json.Unmarshal([]byte(res.Body), &recs); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, r := range recs {
+		got = append(got, r.ID)
+	}
+	sort.Strings(got)
+	if want := []string{"legacyRec", "rec"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("recommendations = %v, want %v", got, want)
+	}
+}
+
+func TestGetRecommendationByID(t *testing.T) {
+	item := func(id, itemType, createdBy, recommendedFor string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+		i := map[string]*dynamodb.AttributeValue{
+			"Id":             {S: aws.String(id)},
+			"Type":           {S: aws.String(itemType)},
+			"CreatedBy":      {S: aws.String(createdBy)},
+			"RecommendedFor": {S: aws.String(recommendedFor)},
+		}
+		for k, v := range attrs {
+			i[k] = v
+		}
+		return i
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		item("byMe", shared.ItemTypeRecommendation, "user1", "user2", nil),
+		item("forMe", shared.ItemTypeRecommendation, "user2", "user1", nil),
+		item("private", shared.ItemTypeRecommendation, "user2", "user3", nil),
+		item("deleted", shared.ItemTypeRecommendation, "user1", "user2", map[string]*dynamodb.AttributeValue{
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}),
+		item("program", shared.ItemTypeProgram, "user1", "user2", nil),
+	}}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"created by the user", "byMe", http.StatusOK},
+		{"recommended for the user", "forMe", http.StatusOK},
+		{"another user's recommendation", "private", http.StatusNotFound},
+		{"deleted recommendation", "deleted", http.StatusNotFound},
+		{"unknown id", "missing", http.StatusNotFound},
+		{"program id", "program", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := getRecommendationByID(db, "pelodata", "user1", tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+
+// Path Params:
+//  rideId - Peloton ride id
+
+type song struct {
+	Title              string `json:"title"`
+	Artist             string `json:"artist"`
+	Album              string `json:"album"`
+	StartOffsetSeconds int    `json:"start_offset_seconds"`
+}
+
+type getRidePlaylistResponse struct {
+	Songs      []song   `json:"songs"`
+	TopArtists []string `json:"top_artists"`
+}
+
+func getPathParams(request events.APIGatewayV2HTTPRequest) (string, error) {
+	rideID, ok := request.PathParameters["rideId"]
+	rideID = strings.TrimSpace(rideID)
+	if !ok || rideID == "" {
+		return "", errors.New("Path parameter rideId is required: /getRidePlaylist/{rideId}")
+	}
+
+	return rideID, nil
+}
+
+// formatOutput converts the Peloton playlist to the response format
+// rides without a playlist return empty lists
+func formatOutput(playlist *shared.Playlist) getRidePlaylistResponse {
+	res := getRidePlaylistResponse{
+		Songs:      []song{},
+		TopArtists: []string{},
+	}
+	if playlist == nil {
+		return res
+	}
+
+	for _, s := range playlist.Songs {
+		artistNames := []string{}
+		for _, a := range s.Artists {
+			artistNames = append(artistNames, a.Name)
+		}
+
+		res.Songs = append(res.Songs, song{
+			Title:              s.Title,
+			Artist:             strings.Join(artistNames, ", "),
+			Album:              s.Album.Name,
+			StartOffsetSeconds: s.StartTimeOffset,
+		})
+	}
+	for _, a := range playlist.TopArtists {
+		res.TopArtists = append(res.TopArtists, a.Name)
+	}
+
+	return res
+}
+
+// getRidePlaylist returns the songs played during a class
+func getRidePlaylist(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{}
+
+	rideID, err := getPathParams(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	details, body, respHeaders, resCode, err := shared.GetRideDetails(rideID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			errBody := fmt.Sprintf(`{
+				"status": %d,
+				"message": "Unable to find ride %s"
+			}`, http.StatusNotFound, rideID)
+
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       errBody,
+			}, nil
+		}
+
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	reply, err := json.Marshal(formatOutput(details.Playlist))
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getRidePlaylist, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const playlistFixture = `{
+	"ride": {"id": "ride-music", "title": "30 min 90s Ride", "fitness_discipline": "cycling"},
+	"playlist": {
+		"songs": [
+			{"title": "Waterfalls", "artists": [{"artist_id": "a1", "artist_name": "TLC"}], "album": {"name": "CrazySexyCool"}, "start_time_offset": 60},
+			{"title": "Ice Ice Baby", "artists": [{"artist_id": "a2", "artist_name": "Vanilla Ice"}, {"artist_id": "a3", "artist_name": "Queen"}], "album": {"name": "To the Extreme"}, "start_time_offset": 302}
+		],
+		"top_artists": [{"artist_id": "a1", "artist_name": "TLC"}, {"artist_id": "a2", "artist_name": "Vanilla Ice"}]
+	}
+}`
+
+const meditationFixture = `{
+	"ride": {"id": "ride-quiet", "title": "10 min Sleep Meditation", "fitness_discipline": "meditation"},
+	"playlist": null
+}`
+
+func TestGetRidePlaylist(t *testing.T) {
+	var cookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie = r.Header.Get("Cookie")
+		switch r.URL.Path {
+		case "/api/ride/ride-music/details":
+			w.Write([]byte(playlistFixture))
+		case "/api/ride/ride-quiet/details":
+			w.Write([]byte(meditationFixture))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+		want       getRidePlaylistResponse
+	}{
+		{"with playlist", "ride-music", http.StatusOK, getRidePlaylistResponse{
+			Songs: []song{
+				{Title: "Waterfalls", Artist: "TLC", Album: "CrazySexyCool", StartOffsetSeconds: 60},
+				{Title: "Ice Ice Baby", Artist: "Vanilla Ice, Queen", Album: "To the Extreme", StartOffsetSeconds: 302},
+			},
+			TopArtists: []string{"TLC", "Vanilla Ice"},
+		}},
+		{"without playlist", "ride-quiet", http.StatusOK, getRidePlaylistResponse{Songs: []song{}, TopArtists: []string{}}},
+		{"unknown ride", "ride-missing", http.StatusNotFound, getRidePlaylistResponse{}},
+		{"missing ride id", "", http.StatusBadRequest, getRidePlaylistResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie = ""
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"Cookie": "peloton_session_id=abc"},
+				PathParameters: map[string]string{"rideId": tt.rideID},
+			}
+			// Details cached by an earlier run wouldn't be requested with the cookie
+			shared.InvalidateRideDetails(tt.rideID, request.Headers)
+			res, err := getRidePlaylist(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if cookie != "peloton_session_id=abc" {
+				t.Errorf("Cookie = %q, want it forwarded", cookie)
+			}
+			// Rides without music must be empty arrays, not null
+			if strings.Contains(res.Body, "null") {
+				t.Errorf("body %s contains null", res.Body)
+			}
+
+			got := getRidePlaylistResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("playlist = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+
+// Path Params:
+//  rideId - Peloton ride id
+
+type segment struct {
+	Name            string  `json:"name"`
+	Length          int     `json:"length"`
+	StartOffset     int     `json:"start_offset"`
+	IntensityInMets float64 `json:"intensity_in_mets"`
+	IconSlug        string  `json:"icon_slug"`
+	MetricsType     string  `json:"metrics_type"`
+}
+
+type metric struct {
+	Name  string  `json:"name"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+type targetMetric struct {
+	StartOffset int      `json:"start_offset"`
+	EndOffset   int      `json:"end_offset"`
+	SegmentType string   `json:"segment_type"`
+	Metrics     []metric `json:"metrics"`
+}
+
+type getRideSegmentsResponse struct {
+	Segments      []segment      `json:"segments"`
+	TargetMetrics []targetMetric `json:"target_metrics"`
+}
+
+func getPathParams(request events.APIGatewayV2HTTPRequest) (string, error) {
+	rideID, ok := request.PathParameters["rideId"]
+	rideID = strings.TrimSpace(rideID)
+	if !ok || rideID == "" {
+		return "", errors.New("Path parameter rideId is required: /getRideSegments/{rideId}")
+	}
+
+	return rideID, nil
+}
+
+// formatOutput converts the ride details to the response format
+// classes without segments or target metrics return empty lists
+func formatOutput(details *shared.RideDetails) getRideSegmentsResponse {
+	res := getRideSegmentsResponse{
+		Segments:      []segment{},
+		TargetMetrics: []targetMetric{},
+	}
+
+	if details.Segments != nil {
+		for _, s := range details.Segments.SegmentList {
+			res.Segments = append(res.Segments, segment{
+				Name:            s.Name,
+				Length:          s.Length,
+				StartOffset:     s.StartTimeOffset,
+				IntensityInMets: s.IntensityInMets,
+				IconSlug:        s.IconSlug,
+				MetricsType:     s.MetricsType,
+			})
+		}
+	}
+	if details.TargetMetricsData != nil {
+		for _, tm := range details.TargetMetricsData.TargetMetrics {
+			t := targetMetric{
+				StartOffset: tm.Offsets.Start,
+				EndOffset:   tm.Offsets.End,
+				SegmentType: tm.SegmentType,
+				Metrics:     []metric{},
+			}
+			for _, m := range tm.Metrics {
+				t.Metrics = append(t.Metrics, metric{
+					Name:  m.Name,
+					Lower: m.Lower,
+					Upper: m.Upper,
+				})
+			}
+			res.TargetMetrics = append(res.TargetMetrics, t)
+		}
+	}
+
+	return res
+}
+
+// getRideSegments returns the segment structure and target metrics of a class
+func getRideSegments(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{}
+
+	rideID, err := getPathParams(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	details, body, respHeaders, resCode, err := shared.GetRideDetails(rideID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			errBody := fmt.Sprintf(`{
+				"status": %d,
+				"message": "Unable to find ride %s"
+			}`, http.StatusNotFound, rideID)
+
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       errBody,
+			}, nil
+		}
+
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	reply, err := json.Marshal(formatOutput(details))
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getRideSegments, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
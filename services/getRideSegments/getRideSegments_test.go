@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const powerZoneFixture = `{
+	"ride": {"id": "ride-pz", "title": "45 min Power Zone Ride", "fitness_discipline": "cycling"},
+	"segments": {"segment_list": [
+		{"id": "s1", "name": "Warm Up", "length": 300, "start_time_offset": 0, "intensity_in_mets": 4.5, "icon_slug": "warmup", "metrics_type": "cycling"},
+		{"id": "s2", "name": "Cycling", "length": 2100, "start_time_offset": 300, "intensity_in_mets": 8.5, "icon_slug": "cycling", "metrics_type": "cycling"}
+	]},
+	"target_metrics_data": {"target_metrics": [
+		{"offsets": {"start": 300, "end": 600}, "segment_type": "cycling", "metrics": [{"name": "power_zone", "lower": 3, "upper": 3}]},
+		{"offsets": {"start": 600, "end": 900}, "segment_type": "cycling", "metrics": [{"name": "power_zone", "lower": 4, "upper": 5}]}
+	]}
+}`
+
+const yogaFixture = `{
+	"ride": {"id": "ride-yoga", "title": "20 min Yoga Flow", "fitness_discipline": "yoga"}
+}`
+
+func TestGetRideSegments(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/api/ride/ride-pz/details":
+			w.Write([]byte(powerZoneFixture))
+		case "/api/ride/ride-yoga/details":
+			w.Write([]byte(yogaFixture))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+		want       getRideSegmentsResponse
+	}{
+		{"power zone", "ride-pz", http.StatusOK, getRideSegmentsResponse{
+			Segments: []segment{
+				{Name: "Warm Up", Length: 300, StartOffset: 0, IntensityInMets: 4.5, IconSlug: "warmup", MetricsType: "cycling"},
+				{Name: "Cycling", Length: 2100, StartOffset: 300, IntensityInMets: 8.5, IconSlug: "cycling", MetricsType: "cycling"},
+			},
+			TargetMetrics: []targetMetric{
+				{StartOffset: 300, EndOffset: 600, SegmentType: "cycling", Metrics: []metric{{Name: "power_zone", Lower: 3, Upper: 3}}},
+				{StartOffset: 600, EndOffset: 900, SegmentType: "cycling", Metrics: []metric{{Name: "power_zone", Lower: 4, Upper: 5}}},
+			},
+		}},
+		{"yoga", "ride-yoga", http.StatusOK, getRideSegmentsResponse{Segments: []segment{}, TargetMetrics: []targetMetric{}}},
+		{"unknown ride", "ride-missing", http.StatusNotFound, getRideSegmentsResponse{}},
+		{"missing ride id", "", http.StatusBadRequest, getRideSegmentsResponse{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{PathParameters: map[string]string{"rideId": tt.rideID}}
+			res, err := getRideSegments(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			got := getRideSegmentsResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segments = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The ride details are cached, so asking for the same class again doesn't call Peloton
+	before := calls
+	request := events.APIGatewayV2HTTPRequest{PathParameters: map[string]string{"rideId": "ride-pz"}}
+	if res, err := getRideSegments(context.Background(), request); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("getRideSegments() = %d, %v", res.StatusCode, err)
+	}
+	if calls != before {
+		t.Errorf("Peloton was called %d more times for a cached ride, want 0", calls-before)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Query Params:
+//   limit - max number of challenges to return. Defaults to 20, max of 100
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type upcomingChallenge struct {
+	challenge shared.Challenge
+	startDate time.Time
+}
+
+// getUpcomingChallenges returns public challenges that start after today, soonest first
+func getUpcomingChallenges(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	limit := defaultLimit
+	if limitStr, ok := request.QueryStringParameters["limit"]; ok {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("limit must be a number between 1 and %d", maxLimit)), nil
+		}
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#P": aws.String("Public"),
+		},
+		FilterExpression: aws.String("#P = :public and " + shared.NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":public": {BOOL: aws.Bool(true)},
+		},
+	}
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get existing challenges: %s", err)), nil
+	}
+
+	today := shared.Today()
+	upcoming := []upcomingChallenge{}
+	for _, i := range items {
+		c, err := shared.FormatChallenge(i)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, err
+		}
+
+		startDate, err := time.Parse(shared.DateFormat, c.StartDate)
+		if err != nil {
+			log.Printf("Skipping challenge %s with invalid StartDate %s", c.ID, c.StartDate)
+			continue
+		}
+		if startDate.After(today) {
+			upcoming = append(upcoming, upcomingChallenge{challenge: c, startDate: startDate})
+		}
+	}
+
+	sort.SliceStable(upcoming, func(i, j int) bool {
+		return upcoming[i].startDate.Before(upcoming[j].startDate)
+	})
+	if len(upcoming) > limit {
+		upcoming = upcoming[:limit]
+	}
+
+	challenges := []shared.Challenge{}
+	for _, u := range upcoming {
+		challenges = append(challenges, u.challenge)
+	}
+
+	return shared.JSONResponse(http.StatusOK, challenges)
+}
+
+func main() {
+	lambda.Start(shared.Chain(getUpcomingChallenges, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery, shared.WithLogging))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB returns the public items that aren't deleted from every Scan, like getUpcomingChallenges' FilterExpression
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items []map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		if aws.BoolValue(i["Public"].BOOL) && !shared.IsDeleted(i) {
+			items = append(items, i)
+		}
+	}
+
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+func challengeItem(id string, public bool, startDate string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String("user1")},
+		"Public":    {BOOL: aws.Bool(public)},
+		"StartDate": {S: aws.String(startDate)},
+	}
+}
+
+func TestGetUpcomingChallenges(t *testing.T) {
+	day := func(n int) string {
+		return shared.Today().AddDate(0, 0, n).Format(shared.DateFormat)
+	}
+	db := &mockDB{items: []map[string]*dynamodb.AttributeValue{
+		challengeItem("nextMonth", true, day(30)),
+		challengeItem("past", true, day(-7)),
+		challengeItem("tomorrow", true, day(1)),
+		challengeItem("today", true, day(0)),
+		challengeItem("malformed", true, "next week"),
+		challengeItem("private", false, day(2)),
+		challengeItem("nextWeek", true, day(7)),
+	}}
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantStatus int
+		want       string
+	}{
+		{"soonest first", nil, http.StatusOK, "tomorrow,nextWeek,nextMonth"},
+		{"limit", map[string]string{"limit": "2"}, http.StatusOK, "tomorrow,nextWeek"},
+		{"limit over the max", map[string]string{"limit": "101"}, http.StatusBadRequest, ""},
+		{"invalid limit", map[string]string{"limit": "all"}, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer withMockDB(db)()
+
+			res, err := getUpcomingChallenges(context.Background(), events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			challenges := []shared.Challenge{}
+			if err := json.Unmarshal([]byte(res.Body), &challenges); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, c := range challenges {
+				got = append(got, c.ID)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("challenges = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -65,7 +65,7 @@ func getUser(ctx context.Context, request events.APIGatewayV2HTTPRequest) (event
 	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, nil, nil)
 	if err != nil {
 		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
+			StatusCode: shared.UpstreamStatus(resCode, err),
 			Body:       err.Error(),
 		}
 
@@ -99,5 +99,5 @@ func getUser(ctx context.Context, request events.APIGatewayV2HTTPRequest) (event
 }
 
 func main() {
-	lambda.Start(getUser)
+	lambda.Start(shared.Chain(getUser, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
 }
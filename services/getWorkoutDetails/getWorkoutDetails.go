@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+
+// Path Params:
+//  rideId - Peloton ride id
+
+type getWorkoutDetailsResponse struct {
+	Ride          shared.Ride           `json:"ride"`
+	Instructor    shared.RideInstructor `json:"instructor"`
+	Averages      shared.RideAverages   `json:"averages"`
+	TotalWorkouts int                   `json:"total_workouts"`
+}
+
+func getPathParams(request events.APIGatewayV2HTTPRequest) (string, error) {
+	rideID, ok := request.PathParameters["rideId"]
+	rideID = strings.TrimSpace(rideID)
+	if !ok || rideID == "" {
+		return "", errors.New("Path parameter rideId is required: /getWorkoutDetails/{rideId}")
+	}
+
+	return rideID, nil
+}
+
+// getWorkoutDetails returns the full details of a single class
+func getWorkoutDetails(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{}
+
+	rideID, err := getPathParams(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	details, body, respHeaders, resCode, err := shared.GetRideDetails(rideID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			errBody := fmt.Sprintf(`{
+				"status": %d,
+				"message": "Unable to find ride %s"
+			}`, http.StatusNotFound, rideID)
+
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNotFound,
+				Body:       errBody,
+			}, nil
+		}
+
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	getWorkoutDetailsRes := &getWorkoutDetailsResponse{
+		Ride:          details.Ride,
+		Instructor:    details.Ride.Instructor,
+		Averages:      details.Averages,
+		TotalWorkouts: details.Ride.TotalWorkouts,
+	}
+
+	reply, err := json.Marshal(getWorkoutDetailsRes)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(getWorkoutDetails, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithRecovery))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const rideDetailsFixture = `{
+	"ride": {
+		"id": "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c",
+		"title": "45 min Power Zone Endurance Ride",
+		"difficulty_estimate": 6.42,
+		"duration": 2700,
+		"fitness_discipline": "cycling",
+		"total_workouts": 18234,
+		"length": 2760,
+		"has_closed_captions": true,
+		"captions": ["en-US"],
+		"instructor": {"id": "i1", "name": "Matt Wilpers"}
+	},
+	"averages": {"average_avg_power": 151.2, "average_calories": 480}
+}`
+
+func TestGetWorkoutDetails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ride/4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c/details" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(rideDetailsFixture))
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+	}{
+		{"class", "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c", http.StatusOK},
+		{"unknown class", "00000000000000000000000000000000", http.StatusNotFound},
+		{"missing ride id", " ", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayV2HTTPRequest{PathParameters: map[string]string{"rideId": tt.rideID}}
+			res, err := getWorkoutDetails(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !json.Valid([]byte(res.Body)) {
+					t.Errorf("body %q isn't JSON", res.Body)
+				}
+				return
+			}
+
+			body := getWorkoutDetailsResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Ride.Title != "45 min Power Zone Endurance Ride" || body.Ride.Length != 2760 || !body.Ride.HasClosedCaptions {
+				t.Errorf("ride = %+v", body.Ride)
+			}
+			if body.Instructor.Name != "Matt Wilpers" || body.TotalWorkouts != 18234 || body.Averages.AverageAvgPower != 151.2 {
+				t.Errorf("unexpected details %+v", body)
+			}
+		})
+	}
+}
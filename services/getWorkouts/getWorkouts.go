@@ -5,13 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 // Endpoint:
@@ -32,11 +39,46 @@ import (
 //   instructor_id - ID of instructor.
 //   super_genre_id - ID of music genre
 
+// Additional Query Params (applied after the Peloton response is returned):
+//   aired_after - Only return workouts aired on or after this date. Format YYYY-MM-DD
+//   aired_before - Only return workouts aired on or before this date. Format YYYY-MM-DD
+
 type instructor struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 }
 
+// instructorCacheTTL is how long instructor names are cached between warm invocations
+const instructorCacheTTL = 10 * time.Minute
+
+var (
+	instructorNames          = map[string]string{}
+	instructorNamesExpiresAt time.Time
+	instructorNamesMu        sync.Mutex
+)
+
+// getInstructorNames returns a map of instructor ID to name
+// the instructors from the response are merged into the names cached from previous invocations
+func getInstructorNames(instructors []instructor) map[string]string {
+	instructorNamesMu.Lock()
+	defer instructorNamesMu.Unlock()
+
+	if time.Now().After(instructorNamesExpiresAt) {
+		instructorNames = map[string]string{}
+		instructorNamesExpiresAt = time.Now().Add(instructorCacheTTL)
+	}
+	for _, i := range instructors {
+		instructorNames[i.ID] = i.Name
+	}
+
+	names := make(map[string]string, len(instructorNames))
+	for id, name := range instructorNames {
+		names[id] = name
+	}
+
+	return names
+}
+
 type getWorkoutsResponse struct {
 	Data           []shared.Workout `json:"data"`
 	Page           int              `json:"page"`
@@ -44,6 +86,50 @@ type getWorkoutsResponse struct {
 	WorkoutsInPage int              `json:"count"`
 	NumPages       int              `json:"page_count"`
 	Instructors    []instructor     `json:"instructors"`
+	FilteredCount  *int             `json:"filtered_count,omitempty"`
+}
+
+// getAirDateRange returns the aired_after and aired_before query params as epoch seconds
+// a value of 0 means the param wasn't provided
+func getAirDateRange(request events.APIGatewayV2HTTPRequest) (int64, int64, error) {
+	var airedAfter, airedBefore int64
+
+	if airedAfterStr, ok := request.QueryStringParameters["aired_after"]; ok {
+		afterDate, err := time.Parse("2006-01-02", strings.TrimSpace(airedAfterStr))
+		if err != nil {
+			return 0, 0, errors.New("aired_after must be in the format of YYYY-MM-DD")
+		}
+		airedAfter = afterDate.Unix()
+	}
+	if airedBeforeStr, ok := request.QueryStringParameters["aired_before"]; ok {
+		beforeDate, err := time.Parse("2006-01-02", strings.TrimSpace(airedBeforeStr))
+		if err != nil {
+			return 0, 0, errors.New("aired_before must be in the format of YYYY-MM-DD")
+		}
+		// aired_before is inclusive so include the whole day
+		airedBefore = beforeDate.AddDate(0, 0, 1).Unix() - 1
+	}
+	if airedAfter != 0 && airedBefore != 0 && airedAfter > airedBefore {
+		return 0, 0, errors.New("aired_after must not be after aired_before")
+	}
+
+	return airedAfter, airedBefore, nil
+}
+
+// filterByAirDate removes workouts that weren't aired within the range
+func filterByAirDate(workouts []shared.Workout, airedAfter, airedBefore int64) []shared.Workout {
+	filtered := []shared.Workout{}
+	for _, w := range workouts {
+		if airedAfter != 0 && w.OriginalAirTime < airedAfter {
+			continue
+		}
+		if airedBefore != 0 && w.OriginalAirTime > airedBefore {
+			continue
+		}
+		filtered = append(filtered, w)
+	}
+
+	return filtered
 }
 
 func getQueryParams(url string, request events.APIGatewayV2HTTPRequest) (string, error) {
@@ -111,6 +197,80 @@ func getQueryParams(url string, request events.APIGatewayV2HTTPRequest) (string,
 	return strings.TrimRight(url, "&"), nil
 }
 
+// cacheTTL is how long a cached response is served before calling Peloton again
+const cacheTTL = 3 * time.Minute
+
+// userSpecificParams are query params whose results depend on the user's cookie
+var userSpecificParams = []string{"is_favorite_ride", "has_workout"}
+
+// getCacheDB returns the cache table name and a DynamoDB instance
+// if the workout_cache_table_name env var isn't set, caching is disabled and nil is returned
+func getCacheDB(request events.APIGatewayV2HTTPRequest) (string, dynamodbiface.DynamoDBAPI) {
+	tableName, exists := os.LookupEnv("workout_cache_table_name")
+	if !exists || tableName == "" {
+		return "", nil
+	}
+	for _, p := range userSpecificParams {
+		if _, ok := request.QueryStringParameters[p]; ok {
+			return "", nil
+		}
+	}
+	region, exists := os.LookupEnv("table_region")
+	if !exists {
+		return "", nil
+	}
+
+	return tableName, shared.GetDB(region)
+}
+
+// getCachedResponse returns the cached response for cacheKey if it exists and hasn't expired
+func getCachedResponse(db dynamodbiface.DynamoDBAPI, tableName, cacheKey string) (string, bool) {
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(cacheKey)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		log.Printf("Unable to get cached workouts: %s", err)
+		return "", false
+	}
+
+	response, ok := getItemOutput.Item["Response"]
+	if !ok || response.S == nil {
+		return "", false
+	}
+	// DynamoDB TTL deletes are not immediate so check the expiration as well
+	expiresAt, ok := getItemOutput.Item["ExpiresAt"]
+	if !ok || expiresAt.N == nil {
+		return "", false
+	}
+	expiresAtEpoch, err := strconv.ParseInt(*expiresAt.N, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAtEpoch {
+		return "", false
+	}
+
+	return *response.S, true
+}
+
+// putCachedResponse caches response for cacheKey, failures are logged but not returned
+func putCachedResponse(db dynamodbiface.DynamoDBAPI, tableName, cacheKey, response string) {
+	itemToPut := map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(cacheKey)},
+		"Response":  {S: aws.String(response)},
+		"ExpiresAt": {N: aws.String(strconv.FormatInt(time.Now().Add(cacheTTL).Unix(), 10))},
+	}
+	putInput := &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item:      itemToPut,
+	}
+	_, err := db.PutItem(putInput)
+	if err != nil {
+		log.Printf("Unable to cache workouts: %s", err)
+	}
+}
+
 func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	method := "GET"
 	url := "/api/v2/ride/archived?"
@@ -130,6 +290,34 @@ func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 		}, nil
 	}
 
+	airedAfter, airedBefore, err := getAirDateRange(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, http.StatusBadRequest, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Serve common queries from the cache when possible
+	cacheTableName, cacheDB := getCacheDB(request)
+	cacheKey := fmt.Sprintf("%s|%d|%d", url, airedAfter, airedBefore)
+	if cacheDB != nil {
+		if cached, ok := getCachedResponse(cacheDB, cacheTableName, cacheKey); ok {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				MultiValueHeaders: map[string][]string{
+					"X-Cache": {"hit"},
+				},
+				Body: cached,
+			}, nil
+		}
+	}
+
 	// Add peloton cookie header
 	if cookie, ok := request.Headers["Cookie"]; ok {
 		headers["Cookie"] = cookie
@@ -138,7 +326,7 @@ func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, nil)
 	if err != nil {
 		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
+			StatusCode: shared.UpstreamStatus(resCode, err),
 			Body:       err.Error(),
 		}
 
@@ -158,13 +346,16 @@ func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 	}
 
 	// Set instructor name for each workout
+	instructorNames := getInstructorNames(getWorkoutsRes.Instructors)
 	for idx, d := range getWorkoutsRes.Data {
-		for _, i := range getWorkoutsRes.Instructors {
-			if d.InstructorID == i.ID {
-				getWorkoutsRes.Data[idx].InstructorName = i.Name
-				break
-			}
-		}
+		getWorkoutsRes.Data[idx].InstructorName = instructorNames[d.InstructorID]
+	}
+
+	// Filter by original air date if requested
+	if airedAfter != 0 || airedBefore != 0 {
+		getWorkoutsRes.Data = filterByAirDate(getWorkoutsRes.Data, airedAfter, airedBefore)
+		filteredCount := len(getWorkoutsRes.Data)
+		getWorkoutsRes.FilteredCount = &filteredCount
 	}
 
 	reply, err := json.Marshal(getWorkoutsRes)
@@ -174,6 +365,11 @@ func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 		}, fmt.Errorf("Unable to marshal response: %s", err)
 	}
 
+	if cacheDB != nil {
+		putCachedResponse(cacheDB, cacheTableName, cacheKey, string(reply))
+		respHeaders.Set("X-Cache", "miss")
+	}
+
 	return events.APIGatewayProxyResponse{
 		StatusCode:        http.StatusOK,
 		MultiValueHeaders: respHeaders,
@@ -182,5 +378,5 @@ func getWorkouts(ctx context.Context, request events.APIGatewayV2HTTPRequest) (e
 }
 
 func main() {
-	lambda.Start(getWorkouts)
+	lambda.Start(shared.Chain(getWorkouts, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodGet), shared.WithMetrics("getWorkouts"), shared.WithRecovery))
 }
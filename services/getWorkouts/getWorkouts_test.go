@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func TestGetAirDateRange(t *testing.T) {
+	may10 := time.Date(2024, time.May, 10, 0, 0, 0, 0, time.UTC).Unix()
+	may20 := time.Date(2024, time.May, 21, 0, 0, 0, 0, time.UTC).Unix() - 1
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantAfter  int64
+		wantBefore int64
+		wantErr    bool
+	}{
+		{"none", map[string]string{}, 0, 0, false},
+		{"after", map[string]string{"aired_after": "2024-05-10"}, may10, 0, false},
+		{"before is inclusive", map[string]string{"aired_before": " 2024-05-20 "}, 0, may20, false},
+		{"both", map[string]string{"aired_after": "2024-05-10", "aired_before": "2024-05-20"}, may10, may20, false},
+		{"same day", map[string]string{"aired_after": "2024-05-10", "aired_before": "2024-05-10"}, may10, may10 + 86399, false},
+		{"bad after", map[string]string{"aired_after": "05/10/2024"}, 0, 0, true},
+		{"bad before", map[string]string{"aired_before": "yesterday"}, 0, 0, true},
+		{"after is after before", map[string]string{"aired_after": "2024-05-21", "aired_before": "2024-05-20"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, before, err := getAirDateRange(events.APIGatewayV2HTTPRequest{QueryStringParameters: tt.params})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getAirDateRange() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if after != tt.wantAfter || before != tt.wantBefore {
+				t.Errorf("getAirDateRange() = %d, %d, want %d, %d", after, before, tt.wantAfter, tt.wantBefore)
+			}
+		})
+	}
+}
+
+func TestFilterByAirDate(t *testing.T) {
+	workouts := []shared.Workout{
+		{ID: "early", OriginalAirTime: 100},
+		{ID: "middle", OriginalAirTime: 200},
+		{ID: "late", OriginalAirTime: 300},
+	}
+
+	tests := []struct {
+		name        string
+		airedAfter  int64
+		airedBefore int64
+		want        []string
+	}{
+		{"no range", 0, 0, []string{"early", "middle", "late"}},
+		{"after is inclusive", 200, 0, []string{"middle", "late"}},
+		{"before is inclusive", 0, 200, []string{"early", "middle"}},
+		{"both", 150, 250, []string{"middle"}},
+		{"nothing in range", 201, 299, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, w := range filterByAirDate(workouts, tt.airedAfter, tt.airedBefore) {
+				got = append(got, w.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterByAirDate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// resetInstructorNames empties the instructor cache so tests don't depend on each other
+func resetInstructorNames() {
+	instructorNamesMu.Lock()
+	defer instructorNamesMu.Unlock()
+	instructorNames = map[string]string{}
+	instructorNamesExpiresAt = time.Time{}
+}
+
+func TestInstructorNamesCacheHit(t *testing.T) {
+	resetInstructorNames()
+	defer resetInstructorNames()
+
+	// The first page lists the instructors, the second only has the class
+	pages := []string{
+		`{"data": [{"id": "r1", "instructor_id": "i1"}], "instructors": [{"id": "i1", "name": "Robin"}]}`,
+		`{"data": [{"id": "r2", "instructor_id": "i1"}], "instructors": []}`,
+	}
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[calls]))
+		calls++
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	for idx, wantID := range []string{"r1", "r2"} {
+		res, err := getWorkouts(context.Background(), events.APIGatewayV2HTTPRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`"id":"%s"`, wantID)
+		if res.StatusCode != http.StatusOK || !strings.Contains(res.Body, want) || !strings.Contains(res.Body, `"instructor_name":"Robin"`) {
+			t.Errorf("call %d = %d %s, want %s taught by Robin", idx+1, res.StatusCode, res.Body, wantID)
+		}
+	}
+}
+
+func TestGetInstructorNamesExpires(t *testing.T) {
+	resetInstructorNames()
+	defer resetInstructorNames()
+
+	getInstructorNames([]instructor{{ID: "i1", Name: "Robin"}})
+	if names := getInstructorNames(nil); names["i1"] != "Robin" {
+		t.Fatalf("cached names = %v, want i1 from the previous call", names)
+	}
+
+	instructorNamesMu.Lock()
+	instructorNamesExpiresAt = time.Now().Add(-time.Second)
+	instructorNamesMu.Unlock()
+	if names := getInstructorNames([]instructor{{ID: "i2", Name: "Cody"}}); len(names) != 1 || names["i2"] != "Cody" {
+		t.Errorf("names after expiry = %v, want only i2", names)
+	}
+}
+
+// benchmarkPage returns a page of workouts each taught by one of numInstructors instructors
+func benchmarkPage(numWorkouts, numInstructors int) ([]shared.Workout, []instructor) {
+	instructors := make([]instructor, numInstructors)
+	for i := range instructors {
+		instructors[i] = instructor{ID: fmt.Sprintf("i%d", i), Name: fmt.Sprintf("Instructor %d", i)}
+	}
+	workouts := make([]shared.Workout, numWorkouts)
+	for i := range workouts {
+		workouts[i] = shared.Workout{ID: fmt.Sprintf("r%d", i), InstructorID: instructors[i%numInstructors].ID}
+	}
+
+	return workouts, instructors
+}
+
+// BenchmarkInstructorNames compares looking up each workout's instructor in the array, as getWorkouts used to,
+// with the map from getInstructorNames
+func BenchmarkInstructorNames(b *testing.B) {
+	for _, size := range []int{20, 100, 500} {
+		workouts, instructors := benchmarkPage(size, size/2)
+
+		b.Run(fmt.Sprintf("nested loop %d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				for idx, w := range workouts {
+					for _, i := range instructors {
+						if i.ID == w.InstructorID {
+							workouts[idx].InstructorName = i.Name
+							break
+						}
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("map %d", size), func(b *testing.B) {
+			resetInstructorNames()
+			for n := 0; n < b.N; n++ {
+				names := getInstructorNames(instructors)
+				for idx, w := range workouts {
+					workouts[idx].InstructorName = names[w.InstructorID]
+				}
+			}
+		})
+	}
+}
+
+// cacheDB is an in memory workout cache table
+type cacheDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+	gets  int
+	puts  int
+}
+
+func (m *cacheDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	m.gets++
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key["Id"].S]}, nil
+}
+
+func (m *cacheDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.puts++
+	m.items[*input.Item["Id"].S] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestWorkoutsCache(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"data": [{"id": "r1", "instructor_id": "i1"}], "instructors": [{"id": "i1", "name": "Robin"}]}`))
+	}))
+	defer server.Close()
+
+	newDB := shared.NewDB
+	defer func() { shared.NewDB = newDB }()
+	env := map[string]string{"peloton_url": server.URL, "table_region": "us-east-1", "workout_cache_table_name": "workout-cache"}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		params     map[string]string
+		wantCache  []string
+		wantCalls  int
+		wantPuts   int
+		expireNext bool
+	}{
+		{"common query", map[string]string{"duration": "1200"}, []string{"miss", "hit"}, 1, 1, false},
+		{"expired", map[string]string{"duration": "1200"}, []string{"miss", "miss"}, 2, 2, true},
+		{"favorites", map[string]string{"is_favorite_ride": "true"}, []string{"", ""}, 2, 0, false},
+		{"taken", map[string]string{"has_workout": "false"}, []string{"", ""}, 2, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetInstructorNames()
+			calls = 0
+			db := &cacheDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+			shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+				return db
+			}
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"Cookie": "peloton_session_id=abc"},
+				QueryStringParameters: tt.params,
+			}
+			for idx, want := range tt.wantCache {
+				res, err := getWorkouts(context.Background(), request)
+				if err != nil || res.StatusCode != http.StatusOK {
+					t.Fatalf("call %d = %d %s, %v", idx+1, res.StatusCode, res.Body, err)
+				}
+				got := ""
+				if values := res.MultiValueHeaders["X-Cache"]; len(values) > 0 {
+					got = values[0]
+				}
+				if got != want {
+					t.Errorf("call %d X-Cache = %q, want %q", idx+1, got, want)
+				}
+				if tt.expireNext {
+					for _, item := range db.items {
+						item["ExpiresAt"] = &dynamodb.AttributeValue{N: aws.String("1")}
+					}
+				}
+			}
+			if calls != tt.wantCalls || db.puts != tt.wantPuts {
+				t.Errorf("Peloton called %d times and cached %d times, want %d and %d", calls, db.puts, tt.wantCalls, tt.wantPuts)
+			}
+			// User specific queries don't read the cache either
+			if tt.wantPuts == 0 && db.gets != 0 {
+				t.Errorf("cache was read %d times, want 0", db.gets)
+			}
+		})
+	}
+}
+
+func TestWorkoutsPopularity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"id": "r1", "total_workouts": 18234, "favorite_count": 512}, {"id": "r2"}], "instructors": []}`))
+	}))
+	defer server.Close()
+
+	newDB := shared.NewDB
+	defer func() { shared.NewDB = newDB }()
+	env := map[string]string{"peloton_url": server.URL, "table_region": "us-east-1", "workout_cache_table_name": "workout-cache"}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	// Popularity is returned whether the page came from Peloton or the cache
+	tests := []struct {
+		name   string
+		params map[string]string
+		calls  int
+	}{
+		{"uncached", map[string]string{"is_favorite_ride": "true"}, 1},
+		{"cached", map[string]string{"duration": "1200"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetInstructorNames()
+			db := &cacheDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+			shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+				return db
+			}
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:               map[string]string{"Cookie": "peloton_session_id=abc"},
+				QueryStringParameters: tt.params,
+			}
+			for call := 1; call <= tt.calls; call++ {
+				res, err := getWorkouts(context.Background(), request)
+				if err != nil || res.StatusCode != http.StatusOK {
+					t.Fatalf("call %d = %d %s, %v", call, res.StatusCode, res.Body, err)
+				}
+				body := getWorkoutsResponse{}
+				if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+					t.Fatal(err)
+				}
+
+				got := [][2]int{}
+				for _, w := range body.Data {
+					got = append(got, [2]int{w.TotalWorkouts, w.FavoriteCount})
+				}
+				if want := [][2]int{{18234, 512}, {0, 0}}; !reflect.DeepEqual(got, want) {
+					t.Errorf("call %d popularity = %v, want %v", call, got, want)
+				}
+			}
+		})
+	}
+}
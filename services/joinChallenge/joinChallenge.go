@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge to join
+
+// joinChallenge adds the user as a participant of a challenge
+// joining is idempotent, the first join returns 201 and repeat joins return 200 with the existing participation
+func joinChallenge(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	// Private challenges are reported as not found so their ids can't be probed
+	if !challenge.VisibleTo(userID) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+	if challenge.Status == shared.StatusCompleted {
+		return shared.ErrorResponse(http.StatusBadRequest, "Unable to join a challenge that has ended"), nil
+	}
+
+	participation, created, err := shared.JoinChallenge(db, participantsTableName, tableName, challengeID, userID)
+	if err == shared.ErrChallengeNotFound {
+		// The challenge was deleted after it was read
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if !created {
+		return shared.JSONResponse(http.StatusOK, participation)
+	}
+
+	return shared.CreatedResponse("challenges", fmt.Sprintf("%s/participants/%s", challengeID, userID), participation)
+}
+
+func main() {
+	lambda.Start(shared.Chain(joinChallenge, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
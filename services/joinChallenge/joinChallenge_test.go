@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	challengeID      = "11111111-1111-4111-8111-111111111111"
+	endedChallengeID = "22222222-2222-4222-8222-222222222222"
+	missingID        = "33333333-3333-4333-8333-333333333333"
+	privateID        = "44444444-4444-4444-8444-444444444444"
+	invitedID        = "55555555-5555-4555-8555-555555555555"
+	ownedID          = "66666666-6666-4666-8666-666666666666"
+	deletedID        = "77777777-7777-4777-8777-777777777777"
+)
+
+// mockDB keeps the challenges and participation records in memory
+// TransactWriteItems applies JoinChallenge's conditions and cancels the transaction like DynamoDB when one fails
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges   map[string]map[string]*dynamodb.AttributeValue
+	participants map[string]map[string]*dynamodb.AttributeValue
+	counts       map[string]int
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName == "participants" {
+		return &dynamodb.GetItemOutput{Item: m.participants[*input.Key["Id"].S]}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: m.challenges[*input.Key["Id"].S]}, nil
+}
+
+func (m *mockDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	put, update := input.TransactItems[0].Put, input.TransactItems[1].Update
+	reasons := []*dynamodb.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}}
+	cancelled := false
+	if _, ok := m.participants[*put.Item["Id"].S]; ok {
+		reasons[0].Code = aws.String("ConditionalCheckFailed")
+		cancelled = true
+	}
+	if _, ok := m.challenges[*update.Key["Id"].S]; !ok {
+		reasons[1].Code = aws.String("ConditionalCheckFailed")
+		cancelled = true
+	}
+	if cancelled {
+		return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	m.participants[*put.Item["Id"].S] = put.Item
+	m.counts[*update.Key["Id"].S]++
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+	}
+}
+
+func challengeItem(id, endDate string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String("u2")},
+		"Public":    {BOOL: aws.Bool(true)},
+		"StartDate": {S: aws.String("2024-05-01")},
+		"EndDate":   {S: aws.String(endDate)},
+	}
+}
+
+// privateItem is a private challenge that hasn't ended, attrs are added to it
+func privateItem(id string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := challengeItem(id, "2099-05-31")
+	item["Public"] = &dynamodb.AttributeValue{BOOL: aws.Bool(false)}
+	for k, v := range attrs {
+		item[k] = v
+	}
+
+	return item
+}
+
+func join(t *testing.T, id string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"UserID": "u1"},
+		PathParameters: map[string]string{"challengeId": id},
+	}
+	res, err := shared.WithUserID(joinChallenge)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestJoinChallenge(t *testing.T) {
+	u1 := aws.StringSlice([]string{"u1"})
+	tests := []struct {
+		name        string
+		challengeID string
+		wantStatus  []int
+		wantRecords int
+	}{
+		{"join", challengeID, []int{http.StatusCreated}, 1},
+		{"join twice", challengeID, []int{http.StatusCreated, http.StatusOK}, 1},
+		{"ended challenge", endedChallengeID, []int{http.StatusBadRequest}, 0},
+		{"unknown challenge", missingID, []int{http.StatusNotFound}, 0},
+		{"private challenge", privateID, []int{http.StatusNotFound}, 0},
+		{"invited to a private challenge", invitedID, []int{http.StatusCreated}, 1},
+		{"own private challenge", ownedID, []int{http.StatusCreated}, 1},
+		{"deleted challenge", deletedID, []int{http.StatusNotFound}, 0},
+		{"invalid id", "c1", []int{http.StatusBadRequest}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{
+				challenges: map[string]map[string]*dynamodb.AttributeValue{
+					challengeID:      challengeItem(challengeID, "2099-05-31"),
+					endedChallengeID: challengeItem(endedChallengeID, "2024-05-31"),
+					privateID:        privateItem(privateID, nil),
+					invitedID:        privateItem(invitedID, map[string]*dynamodb.AttributeValue{"InvitedUsers": {SS: u1}}),
+					ownedID:          privateItem(ownedID, map[string]*dynamodb.AttributeValue{"CreatedBy": {S: aws.String("u1")}}),
+					deletedID:        privateItem(deletedID, map[string]*dynamodb.AttributeValue{"Public": {BOOL: aws.Bool(true)}, "DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")}}),
+				},
+				participants: map[string]map[string]*dynamodb.AttributeValue{},
+				counts:       map[string]int{},
+			}
+			defer withMockDB(db)()
+
+			for idx, wantStatus := range tt.wantStatus {
+				res := join(t, tt.challengeID)
+				if res.StatusCode != wantStatus {
+					t.Fatalf("join %d StatusCode = %d, want %d: %s", idx+1, res.StatusCode, wantStatus, res.Body)
+				}
+				if wantStatus != http.StatusCreated && wantStatus != http.StatusOK {
+					continue
+				}
+				p := shared.Participation{}
+				if err := json.Unmarshal([]byte(res.Body), &p); err != nil {
+					t.Fatal(err)
+				}
+				if p.ChallengeID != tt.challengeID || p.UserID != "u1" {
+					t.Errorf("join %d participation = %+v, want u1 in %s", idx+1, p, tt.challengeID)
+				}
+			}
+
+			if len(db.participants) != tt.wantRecords || db.counts[tt.challengeID] != tt.wantRecords {
+				t.Errorf("%d records and ParticipantCount %d, want %d", len(db.participants), db.counts[tt.challengeID], tt.wantRecords)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge to leave
+
+type leaveResponse struct {
+	Status      int    `json:"status"`
+	ChallengeID string `json:"challengeId"`
+	Message     string `json:"message"`
+}
+
+// leaveChallenge removes the user from a challenge they joined
+// the user's progress is stored on their participation record, so it is deleted with it
+// leaving is idempotent, leaving a challenge the user never joined returns 200
+func leaveChallenge(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	// A deleted challenge can still be left so its participation records are cleaned up
+	if createdBy := getItemOutput.Item["CreatedBy"]; createdBy != nil && aws.StringValue(createdBy.S) == userID {
+		return shared.ErrorResponse(http.StatusBadRequest, "The owner of a challenge can't leave it, delete the challenge instead"), nil
+	}
+
+	left, err := shared.LeaveChallenge(db, participantsTableName, tableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	message := "Left the challenge"
+	if !left {
+		message = "Not a participant of the challenge"
+	}
+
+	return shared.JSONResponse(http.StatusOK, leaveResponse{
+		Status:      http.StatusOK,
+		ChallengeID: challengeID,
+		Message:     message,
+	})
+}
+
+func main() {
+	lambda.Start(shared.Chain(leaveChallenge, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	challengeID = "11111111-1111-4111-8111-111111111111"
+	ownedID     = "22222222-2222-4222-8222-222222222222"
+	deletedID   = "33333333-3333-4333-8333-333333333333"
+)
+
+// mockDB keeps the challenges, their ParticipantCount and the participation records in memory
+// TransactWriteItems applies LeaveChallenge's conditions and cancels the transaction like DynamoDB when one fails
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges   map[string]map[string]*dynamodb.AttributeValue
+	participants map[string]bool
+	counts       map[string]int
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.challenges[*input.Key["Id"].S]}, nil
+}
+
+func (m *mockDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	del, update := input.TransactItems[0].Delete, input.TransactItems[1].Update
+	reasons := []*dynamodb.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}}
+	cancelled := false
+	if !m.participants[*del.Key["Id"].S] {
+		reasons[0].Code = aws.String("ConditionalCheckFailed")
+		cancelled = true
+	}
+	if _, ok := m.challenges[*update.Key["Id"].S]; !ok || m.counts[*update.Key["Id"].S] <= 0 {
+		reasons[1].Code = aws.String("ConditionalCheckFailed")
+		cancelled = true
+	}
+	if cancelled {
+		return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	delete(m.participants, *del.Key["Id"].S)
+	m.counts[*update.Key["Id"].S]--
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (m *mockDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	if !m.participants[*input.Key["Id"].S] {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	delete(m.participants, *input.Key["Id"].S)
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+	}
+}
+
+func challengeItem(id, createdBy string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String(id)},
+		"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy": {S: aws.String(createdBy)},
+		"Public":    {BOOL: aws.Bool(true)},
+	}
+}
+
+func TestLeaveChallenge(t *testing.T) {
+	tests := []struct {
+		name        string
+		challengeID string
+		joined      bool
+		count       int
+		wantStatus  int
+		wantMessage string
+		wantCount   int
+	}{
+		{"leave", challengeID, true, 2, http.StatusOK, "Left the challenge", 1},
+		{"never joined", challengeID, false, 2, http.StatusOK, "Not a participant of the challenge", 2},
+		{"count is already 0", challengeID, true, 0, http.StatusOK, "Left the challenge", 0},
+		{"deleted challenge", deletedID, true, 0, http.StatusOK, "Left the challenge", 0},
+		{"owner", ownedID, true, 1, http.StatusBadRequest, "", 1},
+		{"invalid id", "c1", false, 0, http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{
+				challenges: map[string]map[string]*dynamodb.AttributeValue{
+					challengeID: challengeItem(challengeID, "u2"),
+					ownedID:     challengeItem(ownedID, "u1"),
+				},
+				participants: map[string]bool{},
+				counts:       map[string]int{tt.challengeID: tt.count},
+			}
+			if tt.joined {
+				db.participants[shared.ParticipationID(tt.challengeID, "u1")] = true
+			}
+			defer withMockDB(db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "u1"},
+				PathParameters: map[string]string{"challengeId": tt.challengeID},
+			}
+			res, err := shared.WithUserID(leaveChallenge)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if db.counts[tt.challengeID] != tt.wantCount {
+				t.Errorf("ParticipantCount = %d, want %d", db.counts[tt.challengeID], tt.wantCount)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body := leaveResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", body.Message, tt.wantMessage)
+			}
+			if len(db.participants) != 0 {
+				t.Errorf("participation records left: %v", db.participants)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge the workout counts towards
+
+// Body:
+//   rideId - ID of the Peloton class that was taken, a class is only counted once per challenge
+//   discipline - fitness discipline of the class, ex) cycling
+//   duration - length of the class in seconds, counted towards minutes goals
+//   completedAt - RFC3339 time the class was finished
+
+type logRequest struct {
+	RideID      string `json:"rideId"`
+	Discipline  string `json:"discipline"`
+	Duration    int    `json:"duration"`
+	CompletedAt string `json:"completedAt"`
+}
+
+// bodyValidation validates the request body and returns the time the class was completed
+func bodyValidation(lr *logRequest) (time.Time, error) {
+	lr.RideID = strings.TrimSpace(lr.RideID)
+	lr.Discipline = strings.TrimSpace(lr.Discipline)
+	if lr.RideID == "" {
+		return time.Time{}, errors.New("rideId is required in request body")
+	}
+	if lr.Discipline == "" {
+		return time.Time{}, errors.New("discipline is required in request body")
+	}
+	if lr.Duration < 0 {
+		return time.Time{}, errors.New("duration must not be negative")
+	}
+	completedAt, err := time.Parse(time.RFC3339, strings.TrimSpace(lr.CompletedAt))
+	if err != nil {
+		return time.Time{}, errors.New("completedAt must be an RFC3339 time")
+	}
+	if completedAt.After(time.Now()) {
+		return time.Time{}, errors.New("completedAt must not be in the future")
+	}
+
+	return completedAt, nil
+}
+
+// logChallengeWorkout counts a class the user took towards their progress in a challenge they joined
+// the class is counted by ride id, so logging it again or syncing it from the user's history doesn't double count
+func logChallengeWorkout(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	lr := logRequest{}
+	err := json.Unmarshal([]byte(request.Body), &lr)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+	completedAt, err := bodyValidation(&lr)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	completionsTableName, err := shared.GetCompletionsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	_, joined, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if !joined {
+		return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge to log workouts for it"), nil
+	}
+
+	// The class must be taken in the same window and be one of the same disciplines syncChallengeProgress counts
+	if err := challenge.CountsWorkout(lr.Discipline, completedAt); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	err = shared.RecordCompletedWorkout(db, participantsTableName, challengeID, userID, shared.LoggedWorkout{
+		WorkoutID:   lr.RideID,
+		Discipline:  lr.Discipline,
+		Minutes:     lr.Duration / 60,
+		CompletedAt: completedAt.UTC().Format(time.RFC3339),
+	})
+	if err == shared.ErrNotParticipating {
+		return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge to log workouts for it"), nil
+	}
+	if err == shared.ErrWorkoutAlreadyCounted {
+		return shared.ErrorResponse(http.StatusConflict, err.Error()), nil
+	}
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	participation, _, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	completed, err := shared.CompleteIfGoalMet(db, participantsTableName, completionsTableName, challenge, participation)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if completed {
+		participation, _, err = shared.GetParticipation(db, participantsTableName, challengeID, userID)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+	}
+
+	return shared.JSONResponse(http.StatusOK, participation)
+}
+
+func main() {
+	lambda.Start(shared.Chain(logChallengeWorkout, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithJSONBody, shared.WithUserID))
+}
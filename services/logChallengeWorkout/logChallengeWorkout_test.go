@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const challengeID = "11111111-1111-4111-8111-111111111111"
+
+// mockDB serves the challenge and keeps the caller's participation counters in memory
+// UpdateItem applies RecordCompletedWorkout's condition that a class is only counted once
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenge  map[string]*dynamodb.AttributeValue
+	joined     bool
+	workoutIDs []string
+	minutes    int
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName == "participants" {
+		if !m.joined {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		item := map[string]*dynamodb.AttributeValue{
+			"ChallengeId":       {S: aws.String(challengeID)},
+			"UserId":            {S: aws.String("u1")},
+			"CompletedWorkouts": {N: aws.String(strconv.Itoa(len(m.workoutIDs)))},
+			"CompletedMinutes":  {N: aws.String(strconv.Itoa(m.minutes))},
+		}
+		if len(m.workoutIDs) > 0 {
+			item["CompletedWorkoutIDs"] = &dynamodb.AttributeValue{SS: aws.StringSlice(m.workoutIDs)}
+		}
+		return &dynamodb.GetItemOutput{Item: item}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: m.challenge}, nil
+}
+
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	workoutID := *input.ExpressionAttributeValues[":workoutId"].S
+	counted := false
+	for _, id := range m.workoutIDs {
+		counted = counted || id == workoutID
+	}
+	if !m.joined || counted {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	minutes, _ := strconv.Atoi(*input.ExpressionAttributeValues[":minutes"].N)
+	m.workoutIDs = append(m.workoutIDs, workoutID)
+	m.minutes += minutes
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+	os.Setenv("completions_table_name", "completions")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+		os.Unsetenv("completions_table_name")
+	}
+}
+
+func TestBodyValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		lr      logRequest
+		wantErr bool
+	}{
+		{"valid", logRequest{RideID: " r1 ", Discipline: "cycling", Duration: 1800, CompletedAt: "2024-05-10T12:00:00Z"}, false},
+		{"missing rideId", logRequest{Discipline: "cycling", CompletedAt: "2024-05-10T12:00:00Z"}, true},
+		{"missing discipline", logRequest{RideID: "r1", Discipline: " ", CompletedAt: "2024-05-10T12:00:00Z"}, true},
+		{"negative duration", logRequest{RideID: "r1", Discipline: "cycling", Duration: -1, CompletedAt: "2024-05-10T12:00:00Z"}, true},
+		{"not RFC3339", logRequest{RideID: "r1", Discipline: "cycling", CompletedAt: "2024-05-10"}, true},
+		{"in the future", logRequest{RideID: "r1", Discipline: "cycling", CompletedAt: time.Now().Add(time.Hour).Format(time.RFC3339)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lr := tt.lr
+			_, err := bodyValidation(&lr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("bodyValidation() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if err == nil && lr.RideID != "r1" {
+				t.Errorf("RideID = %q, want it trimmed", lr.RideID)
+			}
+		})
+	}
+}
+
+func TestLogChallengeWorkout(t *testing.T) {
+	body := func(rideID, discipline, completedAt string, duration int) string {
+		return `{"rideId": "` + rideID + `", "discipline": "` + discipline + `", "duration": ` + strconv.Itoa(duration) + `, "completedAt": "` + completedAt + `"}`
+	}
+
+	tests := []struct {
+		name        string
+		joined      bool
+		bodies      []string
+		wantStatus  []int
+		wantCount   int
+		wantMinutes int
+	}{
+		{
+			"counter math",
+			true,
+			[]string{body("r1", "cycling", "2024-05-10T12:00:00Z", 1800), body("r2", "Running", "2024-05-11T12:00:00Z", 2730)},
+			[]int{http.StatusOK, http.StatusOK},
+			2, 75,
+		},
+		{
+			"duplicate ride",
+			true,
+			[]string{body("r1", "cycling", "2024-05-10T12:00:00Z", 1800), body("r1", "cycling", "2024-05-12T12:00:00Z", 1800)},
+			[]int{http.StatusOK, http.StatusConflict},
+			1, 30,
+		},
+		{"before the start date", true, []string{body("r1", "cycling", "2024-04-30T23:59:59Z", 1800)}, []int{http.StatusBadRequest}, 0, 0},
+		{"after the end date", true, []string{body("r1", "cycling", "2024-06-01T00:00:00Z", 1800)}, []int{http.StatusBadRequest}, 0, 0},
+		{"wrong discipline", true, []string{body("r1", "yoga", "2024-05-10T12:00:00Z", 1800)}, []int{http.StatusBadRequest}, 0, 0},
+		{"not joined", false, []string{body("r1", "cycling", "2024-05-10T12:00:00Z", 1800)}, []int{http.StatusForbidden}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{
+				challenge: map[string]*dynamodb.AttributeValue{
+					"Id":           {S: aws.String(challengeID)},
+					"Type":         {S: aws.String(shared.ItemTypeChallenge)},
+					"CreatedBy":    {S: aws.String("u2")},
+					"Public":       {BOOL: aws.Bool(true)},
+					"StartDate":    {S: aws.String("2024-05-01")},
+					"EndDate":      {S: aws.String("2024-05-31")},
+					"GoalType":     {S: aws.String(shared.GoalTypeWorkouts)},
+					"GoalValue":    {N: aws.String("10")},
+					"WorkoutTypes": {SS: aws.StringSlice([]string{"cycling", "running"})},
+				},
+				joined: tt.joined,
+			}
+			defer withMockDB(db)()
+
+			for idx, b := range tt.bodies {
+				request := events.APIGatewayV2HTTPRequest{
+					Headers:        map[string]string{"UserID": "u1"},
+					PathParameters: map[string]string{"challengeId": challengeID},
+					Body:           b,
+				}
+				res, err := shared.WithUserID(logChallengeWorkout)(context.Background(), request)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if res.StatusCode != tt.wantStatus[idx] {
+					t.Fatalf("log %d StatusCode = %d, want %d: %s", idx+1, res.StatusCode, tt.wantStatus[idx], res.Body)
+				}
+			}
+
+			if len(db.workoutIDs) != tt.wantCount || db.minutes != tt.wantMinutes {
+				t.Errorf("counted %d workouts and %d minutes, want %d and %d", len(db.workoutIDs), db.minutes, tt.wantCount, tt.wantMinutes)
+			}
+		})
+	}
+}
@@ -25,6 +25,23 @@ type loginRequest struct {
 type loginResponse struct {
 	UserID    string `json:"user_id"`
 	SessionID string `json:"session_id"`
+	Cookie    string `json:"cookie"`
+}
+
+// sessionCookieName is the name of the Peloton session cookie
+const sessionCookieName = "peloton_session_id"
+
+// getSessionCookie returns the Peloton session cookie from the Set-Cookie headers
+// formatted to be sent back as the Cookie header. Other cookies are ignored
+func getSessionCookie(respHeaders http.Header) string {
+	resp := http.Response{Header: respHeaders}
+	for _, c := range resp.Cookies() {
+		if c.Name == sessionCookieName {
+			return fmt.Sprintf("%s=%s", c.Name, c.Value)
+		}
+	}
+
+	return ""
 }
 
 // getBody attempts to generate the request body and return it
@@ -68,7 +85,7 @@ func login(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.
 	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, nil, bytes.NewBuffer(reqBody))
 	if err != nil {
 		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
+			StatusCode: shared.UpstreamStatus(resCode, err),
 			Body:       err.Error(),
 		}
 
@@ -86,6 +103,7 @@ func login(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.
 			StatusCode: http.StatusInternalServerError,
 		}, fmt.Errorf("Unable to unmarshal response: %s", err)
 	}
+	loginRes.Cookie = getSessionCookie(respHeaders)
 
 	reply, err := json.Marshal(loginRes)
 	if err != nil {
@@ -102,5 +120,5 @@ func login(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.
 }
 
 func main() {
-	lambda.Start(login)
+	lambda.Start(shared.Chain(login, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithJSONBody))
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestGetSessionCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		setCookies []string
+		want       string
+	}{
+		{"session only", []string{"peloton_session_id=abc123; Path=/; HttpOnly; Secure"}, "peloton_session_id=abc123"},
+		{"other cookies ignored", []string{"tracking=xyz; Path=/", "peloton_session_id=abc123; Path=/", "csrf=token"}, "peloton_session_id=abc123"},
+		{"no session", []string{"tracking=xyz; Path=/"}, ""},
+		{"no cookies", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			for _, c := range tt.setCookies {
+				headers.Add("Set-Cookie", c)
+			}
+			if got := getSessionCookie(headers); got != tt.want {
+				t.Errorf("getSessionCookie() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginCookie(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "tracking=xyz; Path=/")
+		w.Header().Add("Set-Cookie", "peloton_session_id=abc123; Path=/; HttpOnly; Secure")
+		w.Write([]byte(`{"user_id": "u1", "session_id": "abc123"}`))
+	}))
+	defer server.Close()
+	os.Setenv("peloton_url", server.URL)
+	defer os.Unsetenv("peloton_url")
+
+	request := events.APIGatewayV2HTTPRequest{Body: `{"username_or_email": "rider@example.com", "password": "hunter2"}`}
+	res, err := login(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want 200: %s", res.StatusCode, res.Body)
+	}
+
+	body := loginResponse{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := loginResponse{UserID: "u1", SessionID: "abc123", Cookie: "peloton_session_id=abc123"}
+	if body != want {
+		t.Errorf("body = %+v, want %+v", body, want)
+	}
+	// The Set-Cookie headers are still passed through
+	if got := res.MultiValueHeaders["Set-Cookie"]; len(got) != 2 {
+		t.Errorf("Set-Cookie = %v, want both cookies passed through", got)
+	}
+}
+
+func TestLoginInvalidBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing password", `{"username_or_email": "rider@example.com"}`},
+		{"blank username", `{"username_or_email": " ", "password": "hunter2"}`},
+		{"invalid json", `{`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := login(context.Background(), events.APIGatewayV2HTTPRequest{Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want 400", res.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/workout/{workoutID}?joins=ride,ride.instructor
+
+// Path Params:
+//   challengeId - ID of the challenge the workout counts towards
+
+// Body:
+//   workoutId - ID of the completed Peloton workout
+
+type completeRequest struct {
+	WorkoutID string `json:"workoutId"`
+}
+
+type completeResponse struct {
+	Status      int    `json:"status"`
+	ChallengeID string `json:"challengeId"`
+	WorkoutID   string `json:"workoutId"`
+	Message     string `json:"message"`
+	// Completed is true if the workout completed the challenge
+	Completed bool `json:"completed"`
+}
+
+// markWorkoutComplete counts a completed workout towards the user's progress in a challenge they joined
+// the workout is resolved with Peloton and counted by its class id with the class's duration,
+// so the same class logged or synced from the user's history isn't counted twice
+func markWorkoutComplete(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	completeReq := completeRequest{}
+	err := json.Unmarshal([]byte(request.Body), &completeReq)
+	completeReq.WorkoutID = strings.TrimSpace(completeReq.WorkoutID)
+	if err != nil || completeReq.WorkoutID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "workoutId is required in request body"), nil
+	}
+	if err := shared.ValidateID("workoutId", completeReq.WorkoutID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	completionsTableName, err := shared.GetCompletionsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	if !challenge.VisibleTo(userID) {
+		// It's reported as not found so the ids of private challenges can't be probed
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	_, joined, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if !joined {
+		return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge first"), nil
+	}
+
+	// Add peloton cookie header
+	headers := map[string]string{}
+	if cookie, ok := shared.GetHeader(request.Headers, "Cookie"); ok {
+		headers["Cookie"] = cookie
+	}
+
+	workout, body, resCode, err := shared.GetWorkout(completeReq.WorkoutID, headers)
+	if err != nil {
+		return shared.UpstreamErrorResponse(resCode, body, err), nil
+	}
+	if workout.UserID != "" && workout.UserID != userID {
+		return shared.ErrorResponse(http.StatusForbidden, "Workout belongs to another user"), nil
+	}
+	if workout.Ride.ID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, fmt.Sprintf("Workout %s isn't a class", completeReq.WorkoutID)), nil
+	}
+
+	completedAt := time.Now().UTC()
+	if workout.StartTime > 0 {
+		completedAt = time.Unix(workout.StartTime, 0).UTC()
+	}
+	// The class must be taken in the same window and be one of the same disciplines logChallengeWorkout counts
+	if err := challenge.CountsWorkout(workout.FitnessDiscipline, completedAt); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+	err = shared.RecordCompletedWorkout(db, participantsTableName, challengeID, userID, shared.LoggedWorkout{
+		WorkoutID:   workout.Ride.ID,
+		Discipline:  workout.FitnessDiscipline,
+		Minutes:     workout.Ride.Duration / 60,
+		CompletedAt: completedAt.Format(time.RFC3339),
+	})
+	if err == shared.ErrNotParticipating {
+		return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge first"), nil
+	}
+	if err == shared.ErrWorkoutAlreadyCounted {
+		return shared.ErrorResponse(http.StatusConflict, err.Error()), nil
+	}
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	participation, _, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	completed, err := shared.CompleteIfGoalMet(db, participantsTableName, completionsTableName, challenge, participation)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	return shared.JSONResponse(http.StatusOK, completeResponse{
+		Status:      http.StatusOK,
+		ChallengeID: challengeID,
+		WorkoutID:   completeReq.WorkoutID,
+		Message:     "Workout counted",
+		Completed:   completed,
+	})
+}
+
+func main() {
+	lambda.Start(shared.Chain(markWorkoutComplete, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithJSONBody, shared.WithUserID))
+}
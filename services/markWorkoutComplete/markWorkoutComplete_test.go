@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	challengeID        = "11111111-1111-1111-1111-111111111111"
+	privateChallengeID = "22222222-2222-2222-2222-222222222222"
+)
+
+// mockDB serves the challenges and keeps the caller's participation in memory
+// UpdateItem applies RecordCompletedWorkout's condition that a class is only counted once
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges  map[string]map[string]*dynamodb.AttributeValue
+	joined      bool
+	workoutIDs  []string
+	updates     int
+	completions int
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName == "participants" {
+		if !m.joined {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		item := map[string]*dynamodb.AttributeValue{
+			"ChallengeId":       {S: aws.String(challengeID)},
+			"UserId":            {S: aws.String("u1")},
+			"CompletedWorkouts": {N: aws.String(fmt.Sprint(len(m.workoutIDs)))},
+		}
+		if len(m.workoutIDs) > 0 {
+			item["CompletedWorkoutIDs"] = &dynamodb.AttributeValue{SS: aws.StringSlice(m.workoutIDs)}
+		}
+		return &dynamodb.GetItemOutput{Item: item}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: m.challenges[*input.Key["Id"].S]}, nil
+}
+
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	workoutID := *input.ExpressionAttributeValues[":workoutId"].S
+	counted := false
+	for _, id := range m.workoutIDs {
+		counted = counted || id == workoutID
+	}
+	if !m.joined || counted {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	m.updates++
+	m.workoutIDs = append(m.workoutIDs, workoutID)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *mockDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.completions++
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func challengeItem(createdBy string, public bool) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":           {S: aws.String(challengeID)},
+		"Type":         {S: aws.String(shared.ItemTypeChallenge)},
+		"CreatedBy":    {S: aws.String(createdBy)},
+		"Public":       {BOOL: aws.Bool(public)},
+		"StartDate":    {S: aws.String("2024-05-01")},
+		"EndDate":      {S: aws.String("2024-05-31")},
+		"GoalType":     {S: aws.String(shared.GoalTypeWorkouts)},
+		"GoalValue":    {N: aws.String("10")},
+		"WorkoutTypes": {SS: aws.StringSlice([]string{"cycling"})},
+	}
+}
+
+// pelotonWorkouts serves /api/workout/{id} for the workouts keyed on id
+func pelotonWorkouts(workouts map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := workouts[strings.TrimPrefix(r.URL.Path, "/api/workout/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+}
+
+func workoutJSON(rideID, discipline string, takenAt string) string {
+	start, _ := time.Parse(time.RFC3339, takenAt)
+	return fmt.Sprintf(`{"id": "w-%s", "user_id": "u1", "start_time": %d, "fitness_discipline": "%s", "status": "COMPLETE",
+		"ride": {"id": "%s", "duration": 1800, "instructor": {"id": "i1", "name": "Robin"}}}`, rideID, start.Unix(), discipline, rideID)
+}
+
+// setup points the handler at db and a stub Peloton, the returned func restores the real client and env
+func setup(db dynamodbiface.DynamoDBAPI) func() {
+	server := pelotonWorkouts(map[string]string{
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa": workoutJSON("r1", "cycling", "2024-05-10T12:00:00Z"),
+		"bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb": workoutJSON("r2", "cycling", "2024-06-02T12:00:00Z"),
+		"cccccccccccccccccccccccccccccccc": workoutJSON("r3", "yoga", "2024-05-10T12:00:00Z"),
+	})
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	env := map[string]string{
+		"table_region":            "us-east-1",
+		"table_name":              "challenges",
+		"participants_table_name": "participants",
+		"completions_table_name":  "completions",
+		"peloton_url":             server.URL,
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		server.Close()
+		shared.NewDB = newDB
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func callMarkWorkoutComplete(t *testing.T, challengeID, workoutID string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"UserID": "u1", "cookie": "peloton_session_id=abc"},
+		PathParameters: map[string]string{"challengeId": challengeID},
+		Body:           fmt.Sprintf(`{"workoutId": "%s"}`, workoutID),
+	}
+	res, err := shared.WithUserID(markWorkoutComplete)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestMarkWorkoutCompleteTwice(t *testing.T) {
+	db := &mockDB{
+		challenges: map[string]map[string]*dynamodb.AttributeValue{challengeID: challengeItem("u2", true)},
+		joined:     true,
+	}
+	defer setup(db)()
+
+	if res := callMarkWorkoutComplete(t, challengeID, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"); res.StatusCode != http.StatusOK {
+		t.Fatalf("first submission status = %d, body %s", res.StatusCode, res.Body)
+	}
+	if res := callMarkWorkoutComplete(t, challengeID, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"); res.StatusCode != http.StatusConflict {
+		t.Errorf("second submission status = %d, want %d", res.StatusCode, http.StatusConflict)
+	}
+	if db.updates != 1 || len(db.workoutIDs) != 1 || db.workoutIDs[0] != "r1" {
+		t.Errorf("counted %v in %d updates, want r1 counted once", db.workoutIDs, db.updates)
+	}
+}
+
+func TestMarkWorkoutCompleteRejected(t *testing.T) {
+	private := challengeItem("u2", false)
+	private["Id"] = &dynamodb.AttributeValue{S: aws.String(privateChallengeID)}
+
+	tests := []struct {
+		name        string
+		challengeID string
+		workoutID   string
+		joined      bool
+		wantStatus  int
+	}{
+		{"invalid challenge id", "not-an-id", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", true, http.StatusBadRequest},
+		{"private challenge", privateChallengeID, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", true, http.StatusNotFound},
+		{"not joined", challengeID, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", false, http.StatusForbidden},
+		{"after the end date", challengeID, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", true, http.StatusBadRequest},
+		{"wrong discipline", challengeID, "cccccccccccccccccccccccccccccccc", true, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{
+				challenges: map[string]map[string]*dynamodb.AttributeValue{challengeID: challengeItem("u2", true), privateChallengeID: private},
+				joined:     tt.joined,
+			}
+			defer setup(db)()
+
+			res := callMarkWorkoutComplete(t, tt.challengeID, tt.workoutID)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if db.updates != 0 {
+				t.Errorf("workout was counted %d times, want 0", db.updates)
+			}
+		})
+	}
+}
@@ -5,22 +5,53 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Doug2D2/pelodata-serverless/services/shared"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/google/uuid"
 )
 
+// recommendation is stored with dynamodbattribute, the dynamodbav tags are the attribute names
 type recommendation struct {
-	ID             string         `json:"id"`
-	CreatedBy      string         `json:"createdBy"`
-	RecommendedFor string         `json:"recommendedFor"`
-	Workout        shared.Workout `json:"workout"`
+	ID             string             `json:"id" dynamodbav:"Id"`
+	CreatedBy      string             `json:"createdBy" dynamodbav:"CreatedBy"`
+	RecommendedFor string             `json:"recommendedFor" dynamodbav:"RecommendedFor"`
+	Workout        shared.WorkoutBlob `json:"workout" dynamodbav:"Workout"`
+	CreatedDate    string             `json:"createdDate" dynamodbav:"CreatedDate"`
+	Warning        string             `json:"warning,omitempty" dynamodbav:"-"`
+}
+
+// bookmarkValidation checks if the user the class is recommended for has already bookmarked it
+// if the reject_bookmarked_recommendations env var is true, an error is returned
+// otherwise a warning is returned
+func bookmarkValidation(r recommendation) (string, int, error) {
+	bookmarked, err := shared.IsBookmarked(r.RecommendedFor, r.Workout.ID)
+	if err != nil {
+		// Bookmarks are best-effort so don't fail the recommendation
+		log.Printf("Unable to check if class is bookmarked: %s", err)
+		return "", -1, nil
+	}
+	if !bookmarked {
+		return "", -1, nil
+	}
+
+	msg := fmt.Sprintf("%s has already bookmarked this class", r.RecommendedFor)
+	if reject, _ := strconv.ParseBool(os.Getenv("reject_bookmarked_recommendations")); reject {
+		return "", http.StatusBadRequest, errors.New(msg)
+	}
+
+	return msg, -1, nil
 }
 
 func bodyValidation(r recommendation) error {
@@ -36,41 +67,68 @@ func bodyValidation(r recommendation) error {
 	return nil
 }
 
-func recommendationValidation(r recommendation, workoutData []byte, tableName string, db *dynamodb.DynamoDB) (int, error) {
+// verifyWorkout replaces the client sent workout with the class from Peloton
+// so a recommendation can't be made for a class that doesn't exist or with made up details
+// the check is skipped if the skip_workout_verification env var is true
+func verifyWorkout(r *recommendation, headers map[string]string) (int, error) {
+	if skip, _ := strconv.ParseBool(os.Getenv("skip_workout_verification")); skip {
+		return -1, nil
+	}
+
+	r.Workout.ID = strings.TrimSpace(r.Workout.ID)
+	if r.Workout.ID == "" {
+		return http.StatusBadRequest, errors.New("workout.id is required in request body")
+	}
+	if err := shared.ValidateRideID(r.Workout.ID); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	details, body, _, resCode, err := shared.GetRideDetails(r.Workout.ID, headers)
+	if err != nil {
+		if resCode == http.StatusNotFound {
+			return http.StatusBadRequest, fmt.Errorf("Class %s doesn't exist", r.Workout.ID)
+		}
+		return shared.UpstreamStatus(resCode, err), errors.New(shared.UpstreamErrorMessage(body, err))
+	}
+	r.Workout = shared.WorkoutBlob(details.Ride.ToWorkout())
+
+	return -1, nil
+}
+
+func recommendationValidation(r recommendation, workoutData []byte, tableName string, db dynamodbiface.DynamoDBAPI) (int, error) {
 	scanInput := &dynamodb.ScanInput{
 		TableName:        aws.String(tableName),
-		FilterExpression: aws.String("CreatedBy = :createdBy and RecommendedFor = :recommendedFor and Workout = :workout"),
+		FilterExpression: aws.String("CreatedBy = :createdBy and RecommendedFor = :recommendedFor and Workout = :workout and " + shared.NotDeletedFilter),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":createdBy":      {S: aws.String(r.CreatedBy)},
 			":recommendedFor": {S: aws.String(r.RecommendedFor)},
 			":workout":        {B: workoutData},
 		},
 	}
-	scanOutput, err := db.Scan(scanInput)
+	items, err := shared.ScanAll(db, scanInput)
 	if err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("Unable to get existing recommendations: %s", err.Error())
 	}
 
-	// If the Scan call returns any items, then that recommendation already exists
-	if len(scanOutput.Items) > 0 {
+	// If the scan returns any items, then that recommendation already exists
+	if len(items) > 0 {
 		return http.StatusBadRequest, errors.New("That recommendation already exists")
 	}
 
 	return -1, nil
 }
 
-func putItem(r recommendation, workoutData []byte, tableName string, db *dynamodb.DynamoDB) error {
-	itemToPut := map[string]*dynamodb.AttributeValue{
-		"Id":             {S: aws.String(r.ID)},
-		"CreatedBy":      {S: aws.String(r.CreatedBy)},
-		"RecommendedFor": {S: aws.String(r.RecommendedFor)},
-		"Workout":        {B: workoutData},
+func putItem(r recommendation, tableName string, db dynamodbiface.DynamoDBAPI) error {
+	itemToPut, err := dynamodbattribute.MarshalMap(r)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal recommendation: %s", err)
 	}
+	itemToPut["Type"] = &dynamodb.AttributeValue{S: aws.String(shared.ItemTypeRecommendation)}
 	putInput := &dynamodb.PutItemInput{
 		TableName: aws.String(tableName),
 		Item:      itemToPut,
 	}
-	_, err := db.PutItem(putInput)
+	_, err = db.PutItem(putInput)
 	if err != nil {
 		return fmt.Errorf("Unable to save recommendation: %s", err.Error())
 	}
@@ -80,21 +138,13 @@ func putItem(r recommendation, workoutData []byte, tableName string, db *dynamod
 
 func recommendClass(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	// Get UserID header
-	userID, ok := request.Headers["UserID"]
+	userID, ok := shared.GetHeader(request.Headers, "UserID")
 	userID = strings.TrimSpace(userID)
 	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
 	}
 
-	tableRegion, tableName, err := shared.GetDBInfo()
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableRecommendations)
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -105,79 +155,58 @@ func recommendClass(ctx context.Context, request events.APIGatewayV2HTTPRequest)
 	r := recommendation{}
 	err = json.Unmarshal([]byte(request.Body), &r)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Invalid request body"
-		}`, http.StatusBadRequest)
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(http.StatusBadRequest, "Invalid request body"), nil
 	}
 
 	r.ID = uuid.New().String()
 	r.CreatedBy = userID
-	workoutData, err := json.Marshal(r.Workout)
-	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal classes: %s", err)
-	}
+	r.CreatedDate = time.Now().Format(time.RFC3339)
 
 	err = bodyValidation(r)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusBadRequest, err.Error())
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	// Add peloton cookie header
+	headers := map[string]string{}
+	if cookie, ok := shared.GetHeader(request.Headers, "Cookie"); ok {
+		headers["Cookie"] = cookie
+	}
+	if returnCode, err := verifyWorkout(&r, headers); err != nil {
+		return shared.ErrorResponse(returnCode, err.Error()), nil
+	}
+
+	r.Workout = shared.WorkoutBlob(shared.Workout(r.Workout).WithoutPopularity())
 
+	// The workout is marshaled after verification so duplicates are found using the class from Peloton
+	workoutData, err := json.Marshal(r.Workout)
+	if err != nil {
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal classes: %s", err)
 	}
 
 	db := shared.GetDB(tableRegion)
 
 	if returnCode, err := recommendationValidation(r, workoutData, tableName, db); err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, returnCode, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: returnCode,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(returnCode, err.Error()), nil
 	}
 
-	err = putItem(r, workoutData, tableName, db)
+	warning, returnCode, err := bookmarkValidation(r)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, http.StatusInternalServerError, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(returnCode, err.Error()), nil
 	}
 
-	reply, err := json.Marshal(r)
+	err = putItem(r, tableName, db)
 	if err != nil {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-		}, fmt.Errorf("Unable to marshal response: %s", err)
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(reply),
-	}, nil
+	r.Warning = warning
+
+	return shared.CreatedResponse("recommendations", r.ID, r)
 }
 
 func main() {
-	lambda.Start(recommendClass)
+	lambda.Start(shared.Chain(recommendClass, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithMaxBodySize, shared.WithJSONBody))
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB records puts and serves the ids in bookmarks from GetItem
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	puts      []*dynamodb.PutItemInput
+	bookmarks map[string]bool
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	id := *input.Key["Id"].S
+	if *input.TableName != "bookmarks" || !m.bookmarks[id] {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"Id": {S: aws.String(id)}}}, nil
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (m *mockDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, input)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+const rideID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+// pelotonRides serves the details of rideID, any other ride isn't found
+// the Cookie header of each request is appended to cookies
+func pelotonRides(cookies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*cookies = append(*cookies, r.Header.Get("Cookie"))
+		if r.URL.Path != "/api/ride/"+rideID+"/details" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Ride not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ride": {"id": "` + rideID + `", "title": "30 min Pop Ride", "duration": 1800,
+			"fitness_discipline": "cycling", "total_workouts": 5000, "instructor": {"id": "i1", "name": "Robin"}}}`))
+	}))
+}
+
+// withMocks points the handler at db and sets env on top of the table config
+// the returned func restores the real client and env
+func withMocks(db dynamodbiface.DynamoDBAPI, env map[string]string) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	env["table_region"] = "us-east-1"
+	env["table_name"] = "pelodata"
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		shared.NewDB = newDB
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestRecommendClassVerifiesWorkout(t *testing.T) {
+	tests := []struct {
+		name       string
+		workoutID  string
+		wantStatus int
+	}{
+		{"class from Peloton", rideID, http.StatusCreated},
+		{"class doesn't exist", strings.Repeat("b", 32), http.StatusBadRequest},
+		{"invalid class id", "ride1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies := []string{}
+			server := pelotonRides(&cookies)
+			defer server.Close()
+
+			db := &mockDB{}
+			defer withMocks(db, map[string]string{"peloton_url": server.URL})()
+			// Ride details are cached between tests
+			shared.InvalidateRideDetails(tt.workoutID, map[string]string{"Cookie": "peloton_session_id=abc"})
+
+			request := events.APIGatewayV2HTTPRequest{
+				// Headers are read regardless of their case
+				Headers: map[string]string{"userid": "user1", "cookie": "peloton_session_id=abc"},
+				Body:    `{"recommendedFor": "user2", "workout": {"id": "` + tt.workoutID + `", "title": "Made up title", "difficulty_estimate": 10}}`,
+			}
+			res, err := recommendClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if len(db.puts) != 0 {
+					t.Error("the recommendation was saved")
+				}
+				return
+			}
+
+			if len(cookies) != 1 || cookies[0] != "peloton_session_id=abc" {
+				t.Errorf("Peloton was sent cookies %v, want the user's cookie", cookies)
+			}
+			if len(db.puts) != 1 {
+				t.Fatalf("%d items written, want 1", len(db.puts))
+			}
+			stored := shared.Workout{}
+			if err := json.Unmarshal(db.puts[0].Item["Workout"].B, &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Title != "30 min Pop Ride" || stored.Difficulty != 0 || stored.InstructorName != "Robin" {
+				t.Errorf("stored workout = %+v, want the class from Peloton", stored)
+			}
+			if stored.TotalWorkouts != 0 {
+				t.Errorf("stored TotalWorkouts = %d, want 0", stored.TotalWorkouts)
+			}
+		})
+	}
+}
+
+func TestRecommendClassBookmarked(t *testing.T) {
+	tests := []struct {
+		name        string
+		bookmarked  bool
+		env         map[string]string
+		wantStatus  int
+		wantWarning bool
+	}{
+		{"not bookmarked", false, map[string]string{"bookmarks_table_name": "bookmarks"}, http.StatusCreated, false},
+		{"bookmarked", true, map[string]string{"bookmarks_table_name": "bookmarks"}, http.StatusCreated, true},
+		{"bookmarked and rejected", true, map[string]string{"bookmarks_table_name": "bookmarks", "reject_bookmarked_recommendations": "true"}, http.StatusBadRequest, false},
+		{"bookmarks not recorded", true, map[string]string{}, http.StatusCreated, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies := []string{}
+			server := pelotonRides(&cookies)
+			defer server.Close()
+
+			db := &mockDB{bookmarks: map[string]bool{"user2#" + rideID: tt.bookmarked}}
+			tt.env["peloton_url"] = server.URL
+			defer withMocks(db, tt.env)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1"},
+				Body:    `{"recommendedFor": "user2", "workout": {"id": "` + rideID + `"}}`,
+			}
+			res, err := recommendClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if len(db.puts) != 0 {
+					t.Error("the recommendation was saved")
+				}
+				return
+			}
+
+			body := recommendation{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if (body.Warning != "") != tt.wantWarning {
+				t.Errorf("warning = %q, want a warning %t", body.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRecommendationRoundTrip(t *testing.T) {
+	r := recommendation{
+		ID:             "r1",
+		CreatedBy:      "user1",
+		RecommendedFor: "user2",
+		Workout:        shared.WorkoutBlob{ID: "w1", Title: "20 min Ride", Duration: 1200, InstructorID: "i1"},
+		CreatedDate:    "2024-05-01T00:00:00Z",
+		Warning:        "already bookmarked",
+	}
+
+	item, err := dynamodbattribute.MarshalMap(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item["Workout"] == nil || item["Workout"].B == nil {
+		t.Errorf("Workout isn't stored as a binary blob: %v", item["Workout"])
+	}
+	if _, ok := item["Warning"]; ok {
+		t.Error("Warning is stored")
+	}
+
+	got := recommendation{}
+	if err := dynamodbattribute.UnmarshalMap(item, &got); err != nil {
+		t.Fatal(err)
+	}
+	r.Warning = ""
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("round trip = %+v, want %+v", got, r)
+	}
+}
+
+func TestRecommendClassSkipVerification(t *testing.T) {
+	tests := []struct {
+		name      string
+		skip      string
+		wantTitle string
+		wantCalls int
+	}{
+		{"verified", "false", "30 min Pop Ride", 1},
+		{"verification skipped", "true", "Made up title", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies := []string{}
+			server := pelotonRides(&cookies)
+			defer server.Close()
+
+			db := &mockDB{}
+			defer withMocks(db, map[string]string{"peloton_url": server.URL, "skip_workout_verification": tt.skip})()
+			headers := map[string]string{"Cookie": "peloton_session_id=skip"}
+			// Ride details are cached between tests
+			shared.InvalidateRideDetails(rideID, headers)
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "user1", "Cookie": headers["Cookie"]},
+				Body:    `{"recommendedFor": "user2", "workout": {"id": "` + rideID + `", "title": "Made up title"}}`,
+			}
+			res, err := recommendClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusCreated {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, http.StatusCreated, res.Body)
+			}
+			if len(cookies) != tt.wantCalls {
+				t.Errorf("Peloton was called %d times, want %d", len(cookies), tt.wantCalls)
+			}
+			stored := shared.Workout{}
+			if err := json.Unmarshal(db.puts[0].Item["Workout"].B, &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Title != tt.wantTitle {
+				t.Errorf("stored title = %q, want %q", stored.Title, tt.wantTitle)
+			}
+		})
+	}
+}
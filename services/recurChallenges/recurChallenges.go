@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+)
+
+// Scheduled by an EventBridge cron rule, ex) cron(0 5 * * ? *)
+// Creates the next instance of each recurring challenge that has ended
+
+const dateFormat = shared.DateFormat
+
+// addMonths adds n months to t, clamping the day to the last day of the resulting month
+// so Jan 31 becomes Feb 28 rather than Mar 3
+func addMonths(t time.Time, n int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, t.Location())
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// isLastDayOfMonth returns true if t is the last day of its month
+func isLastDayOfMonth(t time.Time) bool {
+	return t.AddDate(0, 0, 1).Day() == 1
+}
+
+// nextPeriod shifts the start and end date forward by one recurrence period
+// monthly challenges ending on the last day of a month end on the last day of the next month
+func nextPeriod(recurrence string, start, end time.Time) (time.Time, time.Time) {
+	if recurrence == shared.RecurrenceWeekly {
+		return start.AddDate(0, 0, 7), end.AddDate(0, 0, 7)
+	}
+
+	nextEnd := addMonths(end, 1)
+	if isLastDayOfMonth(end) {
+		nextEnd = addMonths(time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, end.Location()), 2).AddDate(0, 0, -1)
+	}
+
+	return addMonths(start, 1), nextEnd
+}
+
+// periodLabel is appended to the series name so each instance has a unique name
+func periodLabel(recurrence string, start time.Time) string {
+	if recurrence == shared.RecurrenceWeekly {
+		return fmt.Sprintf("Week of %s", start.Format(dateFormat))
+	}
+
+	return start.Format("January 2006")
+}
+
+// nextInstanceID is derived from the series and start date so a rerun can't create duplicates
+func nextInstanceID(parentID string, start time.Time) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("%s#%s", parentID, start.Format(dateFormat)))).String()
+}
+
+// getEndedChallenges returns the recurring challenges that ended before today and haven't recurred
+func getEndedChallenges(db dynamodbiface.DynamoDBAPI, tableName string, today time.Time) ([]map[string]*dynamodb.AttributeValue, error) {
+	scanInput := &dynamodb.ScanInput{
+		TableName:        aws.String(tableName),
+		FilterExpression: aws.String("Recurrence in (:weekly, :monthly) and EndDate < :today and attribute_not_exists(NextChallengeId) and " + shared.NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":weekly":  {S: aws.String(shared.RecurrenceWeekly)},
+			":monthly": {S: aws.String(shared.RecurrenceMonthly)},
+			":today":   {S: aws.String(today.Format(dateFormat))},
+		},
+	}
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	err := db.ScanPages(scanInput, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get recurring challenges: %s", err)
+	}
+
+	return items, nil
+}
+
+// recur writes the next instance of the challenge and links it from the ended challenge
+func recur(db dynamodbiface.DynamoDBAPI, tableName string, item map[string]*dynamodb.AttributeValue, today time.Time) error {
+	c, err := shared.FormatChallenge(item)
+	if err != nil {
+		return err
+	}
+
+	start, err := time.Parse(dateFormat, c.StartDate)
+	if err != nil {
+		return fmt.Errorf("Challenge %s has an invalid StartDate: %s", c.ID, err)
+	}
+	end, err := time.Parse(dateFormat, c.EndDate)
+	if err != nil {
+		return fmt.Errorf("Challenge %s has an invalid EndDate: %s", c.ID, err)
+	}
+
+	// Skip periods missed while the job wasn't running, only the current period is created
+	for end.Before(today) {
+		start, end = nextPeriod(c.Recurrence, start, end)
+	}
+
+	parentID := c.ParentChallengeID
+	if parentID == "" {
+		parentID = c.ID
+	}
+	seriesName := c.Name
+	if item["SeriesName"] != nil && item["SeriesName"].S != nil {
+		seriesName = *item["SeriesName"].S
+	}
+	name := fmt.Sprintf("%s %s", seriesName, periodLabel(c.Recurrence, start))
+	nextID := nextInstanceID(parentID, start)
+	now := time.Now().Format(time.RFC3339)
+
+	// Copy the ended challenge so every attribute carries over to the next instance
+	nextItem := map[string]*dynamodb.AttributeValue{}
+	for k, v := range item {
+		nextItem[k] = v
+	}
+	delete(nextItem, "NextChallengeId")
+	// Participants join each instance separately
+	delete(nextItem, "ParticipantCount")
+	// Invited users respond to each instance separately
+	delete(nextItem, "AcceptedUsers")
+	delete(nextItem, "DeclinedUsers")
+	nextItem["Id"] = &dynamodb.AttributeValue{S: aws.String(nextID)}
+	nextItem["Type"] = &dynamodb.AttributeValue{S: aws.String(shared.ItemTypeChallenge)}
+	nextItem["Name"] = &dynamodb.AttributeValue{S: aws.String(name)}
+	nextItem["NameKey"] = &dynamodb.AttributeValue{S: aws.String(shared.NameKey(name))}
+	nextItem["SeriesName"] = &dynamodb.AttributeValue{S: aws.String(seriesName)}
+	nextItem["StartDate"] = &dynamodb.AttributeValue{S: aws.String(start.Format(dateFormat))}
+	nextItem["EndDate"] = &dynamodb.AttributeValue{S: aws.String(end.Format(dateFormat))}
+	nextItem["ParentChallengeId"] = &dynamodb.AttributeValue{S: aws.String(parentID)}
+	nextItem["CreatedDate"] = &dynamodb.AttributeValue{S: aws.String(now)}
+	nextItem["UpdatedDate"] = &dynamodb.AttributeValue{S: aws.String(now)}
+
+	putInput := &dynamodb.PutItemInput{
+		TableName:           aws.String(tableName),
+		Item:                nextItem,
+		ConditionExpression: aws.String("attribute_not_exists(Id)"),
+	}
+	_, err = db.PutItem(putInput)
+	if err != nil {
+		// A previous run already created this instance
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("Unable to save next instance of challenge %s: %s", c.ID, err)
+		}
+	}
+
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(c.ID)},
+		},
+		UpdateExpression: aws.String("SET NextChallengeId = :nextId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":nextId": {S: aws.String(nextID)},
+		},
+	}
+	_, err = db.UpdateItem(updateInput)
+	if err != nil {
+		return fmt.Errorf("Unable to link challenge %s to %s: %s", c.ID, nextID, err)
+	}
+
+	log.Printf("Created challenge %s (%s) from %s", nextID, name, c.ID)
+	return nil
+}
+
+// recurChallenges creates the next instance of every recurring challenge that has ended
+func recurChallenges(ctx context.Context, event events.CloudWatchEvent) error {
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return err
+	}
+
+	db := shared.GetDB(tableRegion)
+	today := shared.Today()
+
+	items, err := getEndedChallenges(db, tableName, today)
+	if err != nil {
+		return err
+	}
+
+	// Keep going if one challenge fails so the rest of the series aren't held up
+	failed := []string{}
+	for _, item := range items {
+		if err := recur(db, tableName, item, today); err != nil {
+			log.Println(err)
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Unable to recur %d of %d challenges: %s", len(failed), len(items), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(recurChallenges)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+func mustParseDate(t *testing.T, date string) time.Time {
+	d, err := time.Parse(dateFormat, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		date string
+		n    int
+		want string
+	}{
+		{"2024-05-10", 1, "2024-06-10"},
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2023-01-31", 1, "2023-02-28"},
+		{"2024-03-31", 1, "2024-04-30"},
+		{"2024-12-15", 1, "2025-01-15"},
+		{"2024-11-30", 2, "2025-01-30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			if got := addMonths(mustParseDate(t, tt.date), tt.n).Format(dateFormat); got != tt.want {
+				t.Errorf("addMonths(%s, %d) = %s, want %s", tt.date, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		recurrence string
+		start      string
+		end        string
+		wantStart  string
+		wantEnd    string
+	}{
+		{"weekly", shared.RecurrenceWeekly, "2024-05-06", "2024-05-12", "2024-05-13", "2024-05-19"},
+		{"monthly mid month", shared.RecurrenceMonthly, "2024-05-10", "2024-06-09", "2024-06-10", "2024-07-09"},
+		{"monthly whole month", shared.RecurrenceMonthly, "2024-01-01", "2024-01-31", "2024-02-01", "2024-02-29"},
+		{"monthly short month", shared.RecurrenceMonthly, "2024-02-01", "2024-02-29", "2024-03-01", "2024-03-31"},
+		{"monthly across the year", shared.RecurrenceMonthly, "2024-12-01", "2024-12-31", "2025-01-01", "2025-01-31"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := nextPeriod(tt.recurrence, mustParseDate(t, tt.start), mustParseDate(t, tt.end))
+			if start.Format(dateFormat) != tt.wantStart || end.Format(dateFormat) != tt.wantEnd {
+				t.Errorf("nextPeriod() = %s to %s, want %s to %s", start.Format(dateFormat), end.Format(dateFormat), tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestPeriodLabel(t *testing.T) {
+	start := time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC)
+
+	if got := periodLabel(shared.RecurrenceWeekly, start); got != "Week of 2024-05-06" {
+		t.Errorf("weekly label = %s", got)
+	}
+	if got := periodLabel(shared.RecurrenceMonthly, start); got != "May 2024" {
+		t.Errorf("monthly label = %s", got)
+	}
+}
+
+func TestNextInstanceID(t *testing.T) {
+	start := time.Date(2024, time.May, 6, 0, 0, 0, 0, time.UTC)
+
+	id := nextInstanceID("parent", start)
+	if id != nextInstanceID("parent", start) {
+		t.Error("nextInstanceID isn't deterministic")
+	}
+	if id == nextInstanceID("parent", start.AddDate(0, 0, 7)) {
+		t.Error("nextInstanceID is the same for different periods")
+	}
+	if id == nextInstanceID("other", start) {
+		t.Error("nextInstanceID is the same for different series")
+	}
+}
+
+// mockDB is an in memory table that applies the conditions and filters used by recurChallenges
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+	puts  int
+}
+
+func (m *mockDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	today := *input.ExpressionAttributeValues[":today"].S
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, i := range m.items {
+		recurring := i["Recurrence"] != nil && *i["Recurrence"].S != shared.RecurrenceNone
+		if recurring && *i["EndDate"].S < today && i["NextChallengeId"] == nil && !shared.IsDeleted(i) {
+			items = append(items, i)
+		}
+	}
+	fn(&dynamodb.ScanOutput{Items: items}, true)
+
+	return nil
+}
+
+func (m *mockDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	id := *input.Item["Id"].S
+	if _, ok := m.items[id]; ok {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	m.puts++
+	m.items[id] = input.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.items[*input.Key["Id"].S]["NextChallengeId"] = input.ExpressionAttributeValues[":nextId"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func copyItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	c := map[string]*dynamodb.AttributeValue{}
+	for k, v := range item {
+		c[k] = v
+	}
+
+	return c
+}
+
+// withEnv sets the env vars, the returned func unsets them
+func withEnv(env map[string]string) func() {
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}
+}
+
+func TestRecurChallengesIdempotent(t *testing.T) {
+	today := shared.Today()
+	ended := map[string]*dynamodb.AttributeValue{
+		"Id":         {S: aws.String("c1")},
+		"Type":       {S: aws.String(shared.ItemTypeChallenge)},
+		"Name":       {S: aws.String("Ride Week")},
+		"Recurrence": {S: aws.String(shared.RecurrenceWeekly)},
+		"StartDate":  {S: aws.String(today.AddDate(0, 0, -7).Format(dateFormat))},
+		"EndDate":    {S: aws.String(today.AddDate(0, 0, -1).Format(dateFormat))},
+	}
+	start, _ := nextPeriod(shared.RecurrenceWeekly, today.AddDate(0, 0, -7), today.AddDate(0, 0, -1))
+	nextID := nextInstanceID("c1", start)
+
+	tests := []struct {
+		name string
+		// existing returns the items in the table before the runs
+		existing func() map[string]map[string]*dynamodb.AttributeValue
+		wantPuts int
+	}{
+		{"first run", func() map[string]map[string]*dynamodb.AttributeValue {
+			return map[string]map[string]*dynamodb.AttributeValue{"c1": copyItem(ended)}
+		}, 1},
+		{"previous run created the instance but didn't link it", func() map[string]map[string]*dynamodb.AttributeValue {
+			return map[string]map[string]*dynamodb.AttributeValue{"c1": copyItem(ended), nextID: {"Id": {S: aws.String(nextID)}}}
+		}, 0},
+	}
+
+	defer withEnv(map[string]string{"table_region": "us-east-1", "table_name": "pelodata"})()
+	newDB := shared.NewDB
+	defer func() { shared.NewDB = newDB }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{items: tt.existing()}
+			shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+				return db
+			}
+
+			for run := 1; run <= 2; run++ {
+				if err := recurChallenges(context.Background(), events.CloudWatchEvent{}); err != nil {
+					t.Fatalf("run %d: %s", run, err)
+				}
+			}
+
+			if len(db.items) != 2 {
+				t.Errorf("%d items in the table, want the ended challenge and its next instance", len(db.items))
+			}
+			if db.puts != tt.wantPuts {
+				t.Errorf("%d instances created, want %d", db.puts, tt.wantPuts)
+			}
+			if link := db.items["c1"]["NextChallengeId"]; link == nil || *link.S != nextID {
+				t.Errorf("NextChallengeId = %v, want %s", link, nextID)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/auth/check_session
+
+type checkSessionResponse struct {
+	IsAuthed bool `json:"is_authed"`
+	User     struct {
+		ID string `json:"id"`
+	} `json:"user"`
+}
+
+type refreshSessionResponse struct {
+	UserID string `json:"user_id"`
+}
+
+// refreshSession checks the user's Peloton session and returns the refreshed session cookie
+// if the session is no longer valid, a 401 is returned so the client knows to login again
+func refreshSession(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	method := "GET"
+	url := "/auth/check_session"
+	headers := map[string]string{}
+
+	// Add peloton cookie header
+	cookie, ok := request.Headers["Cookie"]
+	if !ok || strings.TrimSpace(cookie) == "" {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "Cookie header is required"
+		}`, http.StatusBadRequest)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       errBody,
+		}, nil
+	}
+	headers["Cookie"] = cookie
+
+	sessionExpiredBody := fmt.Sprintf(`{
+		"status": %d,
+		"message": "Session has expired, login is required"
+	}`, http.StatusUnauthorized)
+
+	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, nil)
+	if err != nil {
+		if resCode == http.StatusUnauthorized || resCode == http.StatusForbidden {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusUnauthorized,
+				Body:       sessionExpiredBody,
+			}, nil
+		}
+
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	checkSessionRes := &checkSessionResponse{}
+	err = json.Unmarshal(body, checkSessionRes)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to unmarshal response: %s", err)
+	}
+
+	if !checkSessionRes.IsAuthed {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusUnauthorized,
+			Body:       sessionExpiredBody,
+		}, nil
+	}
+
+	reply, err := json.Marshal(refreshSessionResponse{UserID: checkSessionRes.User.ID})
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	// The refreshed Set-Cookie headers are passed through to the client
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(refreshSession, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRefreshSession(t *testing.T) {
+	tests := []struct {
+		name          string
+		cookie        string
+		upstream      int
+		upstreamBody  string
+		wantStatus    int
+		wantSetCookie string
+	}{
+		{"refreshed", "peloton_session_id=old", http.StatusOK, `{"is_authed": true, "user": {"id": "u1"}}`,
+			http.StatusOK, "peloton_session_id=new; Path=/; HttpOnly"},
+		{"not authed", "peloton_session_id=old", http.StatusOK, `{"is_authed": false}`, http.StatusUnauthorized, ""},
+		{"unauthorized", "peloton_session_id=old", http.StatusUnauthorized, `{"message": "Login required"}`, http.StatusUnauthorized, ""},
+		{"forbidden", "peloton_session_id=old", http.StatusForbidden, `{}`, http.StatusUnauthorized, ""},
+		{"missing cookie", " ", http.StatusOK, `{}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCookie string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotCookie = r.Header.Get("Cookie")
+				if tt.upstream == http.StatusOK {
+					w.Header().Add("Set-Cookie", "peloton_session_id=new; Path=/; HttpOnly")
+				}
+				w.WriteHeader(tt.upstream)
+				w.Write([]byte(tt.upstreamBody))
+			}))
+			defer server.Close()
+			os.Setenv("peloton_url", server.URL)
+			defer os.Unsetenv("peloton_url")
+
+			request := events.APIGatewayV2HTTPRequest{Headers: map[string]string{"Cookie": tt.cookie}}
+			res, err := refreshSession(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if !json.Valid([]byte(res.Body)) {
+				t.Errorf("body %q isn't JSON", res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if gotCookie != tt.cookie {
+				t.Errorf("Peloton got Cookie %q, want %q", gotCookie, tt.cookie)
+			}
+			if got := res.MultiValueHeaders["Set-Cookie"]; len(got) != 1 || got[0] != tt.wantSetCookie {
+				t.Errorf("Set-Cookie = %v, want %s", got, tt.wantSetCookie)
+			}
+			body := refreshSessionResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil || body.UserID != "u1" {
+				t.Errorf("body = %s, want user_id u1", res.Body)
+			}
+		})
+	}
+}
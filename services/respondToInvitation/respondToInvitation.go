@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Path Params:
+//   challengeId - ID of the challenge the user was invited to
+
+// Body:
+//   action - accept or decline
+
+// Values of the action in the request body
+const (
+	actionAccept  = "accept"
+	actionDecline = "decline"
+)
+
+type invitationRequest struct {
+	Action string `json:"action"`
+}
+
+type invitationResponse struct {
+	Status           int    `json:"status"`
+	ChallengeID      string `json:"challengeId"`
+	InvitationStatus string `json:"invitationStatus"`
+	// Participation is only set when the invitation is accepted
+	Participation *shared.Participation `json:"participation,omitempty"`
+}
+
+// setInvitationStatus moves the user into AcceptedUsers or DeclinedUsers and out of the other
+// both are string sets, so setting the same status again doesn't change the challenge
+func setInvitationStatus(db dynamodbiface.DynamoDBAPI, tableName, challengeID, userID string, accepted bool) error {
+	addTo, removeFrom := "DeclinedUsers", "AcceptedUsers"
+	if accepted {
+		addTo, removeFrom = removeFrom, addTo
+	}
+
+	updateItemInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+		UpdateExpression:    aws.String(fmt.Sprintf("ADD %s :user DELETE %s :user", addTo, removeFrom)),
+		ConditionExpression: aws.String("attribute_exists(Id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":user": {SS: aws.StringSlice([]string{userID})},
+		},
+	}
+	_, err := db.UpdateItem(updateItemInput)
+	if err != nil {
+		return fmt.Errorf("Unable to update invitation: %s", err)
+	}
+
+	return nil
+}
+
+// respondToInvitation accepts or declines the user's invitation to a private challenge
+// accepting joins the challenge, declining leaves it if it was accepted and hides it from the user's list of challenges
+// responding is idempotent, repeating a response returns 200 without changing anything
+func respondToInvitation(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	invitationReq := invitationRequest{}
+	err := json.Unmarshal([]byte(request.Body), &invitationReq)
+	action := strings.ToLower(strings.TrimSpace(invitationReq.Action))
+	if err != nil || (action != actionAccept && action != actionDecline) {
+		return shared.ErrorResponse(http.StatusBadRequest, "action must be accept or decline"), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	if !challenge.VisibleTo(userID) {
+		// Reported as not found so the ids of private challenges can't be probed
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+	if !challenge.IsInvited(userID) {
+		return shared.ErrorResponse(http.StatusForbidden, "Must be invited to the challenge to respond to an invitation"), nil
+	}
+
+	res := invitationResponse{
+		Status:      http.StatusOK,
+		ChallengeID: challengeID,
+	}
+
+	if action == actionDecline {
+		// A user that accepted and then declines no longer participates
+		if _, err := shared.LeaveChallenge(db, participantsTableName, tableName, challengeID, userID); err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		if err := setInvitationStatus(db, tableName, challengeID, userID, false); err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		res.InvitationStatus = shared.InvitationDeclined
+
+		return shared.JSONResponse(http.StatusOK, res)
+	}
+
+	if challenge.Status == shared.StatusCompleted {
+		return shared.ErrorResponse(http.StatusBadRequest, "Unable to join a challenge that has ended"), nil
+	}
+	participation, _, err := shared.JoinChallenge(db, participantsTableName, tableName, challengeID, userID)
+	if err == shared.ErrChallengeNotFound {
+		// The challenge was deleted after it was read
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if err := setInvitationStatus(db, tableName, challengeID, userID, true); err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	res.InvitationStatus = shared.InvitationAccepted
+	res.Participation = &participation
+
+	return shared.JSONResponse(http.StatusOK, res)
+}
+
+func main() {
+	lambda.Start(shared.Chain(respondToInvitation, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithJSONBody, shared.WithUserID))
+}
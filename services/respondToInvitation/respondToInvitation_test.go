@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	privateID = "11111111-1111-4111-8111-111111111111"
+	publicID  = "22222222-2222-4222-8222-222222222222"
+	missingID = "33333333-3333-4333-8333-333333333333"
+)
+
+// mockDB keeps the challenges and participation records in memory
+// TransactWriteItems applies the join and leave transactions, UpdateItem applies setInvitationStatus's ADD and DELETE
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	challenges   map[string]map[string]*dynamodb.AttributeValue
+	participants map[string]bool
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	id := *input.Key["Id"].S
+	if *input.TableName == "participants" {
+		if !m.participants[id] {
+			return &dynamodb.GetItemOutput{}, nil
+		}
+		return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"Id": {S: aws.String(id)}}}, nil
+	}
+
+	return &dynamodb.GetItemOutput{Item: m.challenges[id]}, nil
+}
+
+func (m *mockDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	record := input.TransactItems[0]
+	reasons := []*dynamodb.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}}
+	if record.Put != nil {
+		id := *record.Put.Item["Id"].S
+		if m.participants[id] {
+			reasons[0].Code = aws.String("ConditionalCheckFailed")
+			return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+		}
+		m.participants[id] = true
+	} else {
+		id := *record.Delete.Key["Id"].S
+		if !m.participants[id] {
+			reasons[0].Code = aws.String("ConditionalCheckFailed")
+			return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+		}
+		delete(m.participants, id)
+	}
+
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	// UpdateExpression is "ADD <set> :user DELETE <set> :user"
+	fields := strings.Fields(*input.UpdateExpression)
+	item := m.challenges[*input.Key["Id"].S]
+	user := *input.ExpressionAttributeValues[":user"].SS[0]
+
+	kept := []*string{}
+	if item[fields[4]] != nil {
+		for _, u := range item[fields[4]].SS {
+			if *u != user {
+				kept = append(kept, u)
+			}
+		}
+	}
+	item[fields[4]] = &dynamodb.AttributeValue{SS: kept}
+	if item[fields[1]] == nil {
+		item[fields[1]] = &dynamodb.AttributeValue{}
+	}
+	for _, u := range item[fields[1]].SS {
+		if *u == user {
+			return &dynamodb.UpdateItemOutput{}, nil
+		}
+	}
+	item[fields[1]].SS = append(item[fields[1]].SS, aws.String(user))
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+	os.Setenv("participants_table_name", "participants")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+		os.Unsetenv("participants_table_name")
+	}
+}
+
+func invitationsDB() *mockDB {
+	challenge := func(id string, public bool) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{
+			"Id":           {S: aws.String(id)},
+			"Type":         {S: aws.String(shared.ItemTypeChallenge)},
+			"CreatedBy":    {S: aws.String("owner")},
+			"Public":       {BOOL: aws.Bool(public)},
+			"InvitedUsers": {SS: aws.StringSlice([]string{"invitee"})},
+		}
+	}
+
+	return &mockDB{
+		challenges: map[string]map[string]*dynamodb.AttributeValue{
+			privateID: challenge(privateID, false),
+			publicID:  challenge(publicID, true),
+		},
+		participants: map[string]bool{},
+	}
+}
+
+func respond(t *testing.T, userID, challengeID, body string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"UserID": userID},
+		PathParameters: map[string]string{"challengeId": challengeID},
+		Body:           body,
+	}
+	res, err := shared.WithUserID(respondToInvitation)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestRespondToInvitation(t *testing.T) {
+	db := invitationsDB()
+	defer withMockDB(db)()
+
+	// Each step is applied to the state left by the previous ones
+	steps := []struct {
+		name       string
+		action     string
+		wantStatus string
+		wantJoined bool
+	}{
+		{"accept", "accept", shared.InvitationAccepted, true},
+		{"repeated accept", "accept", shared.InvitationAccepted, true},
+		{"decline after accepting", "decline", shared.InvitationDeclined, false},
+		{"repeated decline", "DECLINE", shared.InvitationDeclined, false},
+		{"accept after declining", "accept", shared.InvitationAccepted, true},
+	}
+
+	for _, step := range steps {
+		res := respond(t, "invitee", privateID, `{"action": "`+step.action+`"}`)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: StatusCode = %d: %s", step.name, res.StatusCode, res.Body)
+		}
+		body := invitationResponse{}
+		if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.InvitationStatus != step.wantStatus {
+			t.Errorf("%s: InvitationStatus = %q, want %q", step.name, body.InvitationStatus, step.wantStatus)
+		}
+		if (body.Participation != nil) != step.wantJoined {
+			t.Errorf("%s: Participation = %+v, want returned %t", step.name, body.Participation, step.wantJoined)
+		}
+
+		if joined := db.participants[shared.ParticipationID(privateID, "invitee")]; joined != step.wantJoined {
+			t.Errorf("%s: joined = %t, want %t", step.name, joined, step.wantJoined)
+		}
+		c, err := shared.FormatChallenge(db.challenges[privateID])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.InvitationStatus("invitee"); got != step.wantStatus {
+			t.Errorf("%s: stored InvitationStatus = %q, want %q", step.name, got, step.wantStatus)
+		}
+		if len(c.AcceptedUsers)+len(c.DeclinedUsers) != 1 {
+			t.Errorf("%s: AcceptedUsers = %v, DeclinedUsers = %v, want the invitee in one", step.name, c.AcceptedUsers, c.DeclinedUsers)
+		}
+	}
+}
+
+func TestRespondToInvitationErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		challengeID string
+		body        string
+		wantStatus  int
+	}{
+		{"stranger on a private challenge", "stranger", privateID, `{"action": "accept"}`, http.StatusNotFound},
+		{"not invited to a public challenge", "stranger", publicID, `{"action": "accept"}`, http.StatusForbidden},
+		{"owner", "owner", privateID, `{"action": "decline"}`, http.StatusForbidden},
+		{"unknown challenge", "invitee", missingID, `{"action": "accept"}`, http.StatusNotFound},
+		{"invalid action", "invitee", privateID, `{"action": "maybe"}`, http.StatusBadRequest},
+		{"missing action", "invitee", privateID, `{}`, http.StatusBadRequest},
+		{"invalid id", "invitee", "c1", `{"action": "accept"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := invitationsDB()
+			defer withMockDB(db)()
+
+			res := respond(t, tt.userID, tt.challengeID, tt.body)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if len(db.participants) != 0 {
+				t.Errorf("participation records: %v", db.participants)
+			}
+		})
+	}
+}
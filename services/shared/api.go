@@ -1,24 +1,92 @@
 package shared
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const basePelotonURL = "https://api.onepeloton.com"
 
+// pelotonURL returns the base URL of the Peloton API
+// the peloton_url env var overrides it, e.g. to point at a stub server
+func pelotonURL() string {
+	if url := strings.TrimSpace(os.Getenv("peloton_url")); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+
+	return basePelotonURL
+}
+
+// pelotonTimeout is how long to wait for a response from Peloton
+const pelotonTimeout = 15 * time.Second
+
+// Kinds of errors returned by PelotonRequest, use errors.Is to check for them
+var (
+	// ErrUpstreamClient is returned when Peloton responds with a 4xx status
+	ErrUpstreamClient = errors.New("Peloton rejected the request")
+	// ErrUpstreamServer is returned when Peloton responds with a 5xx status
+	ErrUpstreamServer = errors.New("Peloton failed to handle the request")
+	// ErrTimeout is returned when Peloton doesn't respond in time
+	ErrTimeout = errors.New("Peloton request timed out")
+)
+
+// UpstreamError is returned by PelotonRequest when Peloton responds with an error status
+// it unwraps to ErrUpstreamClient or ErrUpstreamServer
+type UpstreamError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("Error communicating with Peloton: %s", e.Status)
+}
+
+// Unwrap returns the kind of upstream error
+func (e *UpstreamError) Unwrap() error {
+	if e.StatusCode >= http.StatusInternalServerError {
+		return ErrUpstreamServer
+	}
+
+	return ErrUpstreamClient
+}
+
+// UpstreamStatus returns the status code to respond with for an error from PelotonRequest
+// Peloton's 4xx statuses are passed through, 5xx become a 502 and timeouts a 504
+func UpstreamStatus(resCode int, err error) int {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrUpstreamServer):
+		return http.StatusBadGateway
+	case errors.Is(err, ErrUpstreamClient):
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) {
+			return upstreamErr.StatusCode
+		}
+	}
+
+	return resCode
+}
+
 // PelotonRequest calls the Peloton API
 func PelotonRequest(method, url string, headers map[string]string, body io.Reader) ([]byte, http.Header, int, error) {
 	if !strings.HasPrefix(url, "/") {
 		url = fmt.Sprintf("/%s", url)
 	}
 
-	fullURL := fmt.Sprintf("%s%s", basePelotonURL, url)
+	fullURL := fmt.Sprintf("%s%s", pelotonURL(), url)
 
-	client := &http.Client{}
+	client := &http.Client{
+		Timeout: pelotonTimeout,
+	}
 	req, err := http.NewRequest(method, fullURL, body)
 	if err != nil {
 		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to generate http request: %s", err.Error())
@@ -32,7 +100,11 @@ func PelotonRequest(method, url string, headers map[string]string, body io.Reade
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to get categories from Peloton: %s", err.Error())
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, nil, http.StatusGatewayTimeout, fmt.Errorf("%w: %s", ErrTimeout, err.Error())
+		}
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to communicate with Peloton: %s", err.Error())
 	}
 	defer resp.Body.Close()
 
@@ -42,7 +114,14 @@ func PelotonRequest(method, url string, headers map[string]string, body io.Reade
 	}
 
 	if resp.StatusCode > 399 {
-		return resBody, resp.Header, resp.StatusCode, fmt.Errorf("Error communicating with Peloton: %s", resp.Status)
+		EmitMetrics(map[string]string{
+			"status": strconv.Itoa(resp.StatusCode),
+		}, Metric{Name: "PelotonUpstreamError", Unit: UnitCount, Value: 1})
+
+		return resBody, resp.Header, resp.StatusCode, &UpstreamError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
 	}
 
 	return resBody, resp.Header, http.StatusOK, nil
@@ -0,0 +1,89 @@
+package shared
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUpstreamErrorKinds(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusBadRequest, ErrUpstreamClient},
+		{http.StatusUnauthorized, ErrUpstreamClient},
+		{http.StatusNotFound, ErrUpstreamClient},
+		{http.StatusInternalServerError, ErrUpstreamServer},
+		{http.StatusBadGateway, ErrUpstreamServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			err := error(&UpstreamError{StatusCode: tt.statusCode, Status: http.StatusText(tt.statusCode)})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("errors.Is(%s, %s) = false", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		resCode int
+		err     error
+		want    int
+	}{
+		{"client error", 0, &UpstreamError{StatusCode: http.StatusForbidden}, http.StatusForbidden},
+		{"server error", http.StatusInternalServerError, &UpstreamError{StatusCode: http.StatusInternalServerError}, http.StatusBadGateway},
+		{"timeout", 0, ErrTimeout, http.StatusGatewayTimeout},
+		{"other error", http.StatusInternalServerError, errors.New("other"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UpstreamStatus(tt.resCode, tt.err); got != tt.want {
+				t.Errorf("UpstreamStatus() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPelotonRequestErrors(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusOK, nil},
+		{http.StatusNotFound, ErrUpstreamClient},
+		{http.StatusTooManyRequests, ErrUpstreamClient},
+		{http.StatusServiceUnavailable, ErrUpstreamServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer server.Close()
+			defer setEnv(t, "peloton_url", server.URL)()
+
+			_, _, resCode, err := PelotonRequest(http.MethodGet, "/api/me", nil, nil)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("PelotonRequest() error = %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("PelotonRequest() error = %v, want %s", err, tt.want)
+			}
+			upstreamErr := &UpstreamError{}
+			if !errors.As(err, &upstreamErr) || upstreamErr.StatusCode != tt.statusCode || resCode != tt.statusCode {
+				t.Errorf("status = %d (UpstreamError %+v), want %d", resCode, upstreamErr, tt.statusCode)
+			}
+		})
+	}
+}
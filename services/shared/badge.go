@@ -0,0 +1,45 @@
+package shared
+
+// Names of the badge tiers, from lowest to highest
+const (
+	BadgeBronze   = "bronze"
+	BadgeSilver   = "silver"
+	BadgeGold     = "gold"
+	BadgePlatinum = "platinum"
+)
+
+// goalSizeSteps are the goal values of each GoalType that earn an extra badge point, ex) 20 and 40 workouts
+var goalSizeSteps = map[string][]int{
+	GoalTypeWorkouts: {20, 40},
+	GoalTypeMinutes:  {600, 1200},
+}
+
+// ComputeBadgeTier returns the badge tier earned by completing the challenge
+// a point is scored for each difficulty tier above beginner and for each goal size step the goal reaches
+// 0 points is bronze, 1 or 2 is silver, 3 is gold and 4 is platinum
+// the tier is stamped on the completion record so changing the formula doesn't change earned badges
+func ComputeBadgeTier(c Challenge) string {
+	points := 0
+	for i, t := range DifficultyTiers {
+		if t.Contains(c.Difficulty) {
+			points += i
+			break
+		}
+	}
+	for _, step := range goalSizeSteps[c.GoalType] {
+		if c.GoalValue >= step {
+			points++
+		}
+	}
+
+	switch {
+	case points >= 4:
+		return BadgePlatinum
+	case points == 3:
+		return BadgeGold
+	case points >= 1:
+		return BadgeSilver
+	default:
+		return BadgeBronze
+	}
+}
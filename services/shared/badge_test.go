@@ -0,0 +1,36 @@
+package shared
+
+import "testing"
+
+func TestComputeBadgeTier(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty float32
+		goalType   string
+		goalValue  int
+		want       string
+	}{
+		{"unknown difficulty small goal", 0, GoalTypeWorkouts, 10, BadgeBronze},
+		{"beginner upper bound", 3, GoalTypeWorkouts, 19, BadgeBronze},
+		{"beginner first workouts step", 3, GoalTypeWorkouts, 20, BadgeSilver},
+		{"intermediate lower bound", 3.1, GoalTypeWorkouts, 10, BadgeSilver},
+		{"intermediate first workouts step", 6, GoalTypeWorkouts, 20, BadgeSilver},
+		{"advanced first workouts step", 6.1, GoalTypeWorkouts, 20, BadgeGold},
+		{"intermediate second workouts step", 5, GoalTypeWorkouts, 40, BadgeGold},
+		{"advanced second workouts step", 8, GoalTypeWorkouts, 40, BadgePlatinum},
+		{"advanced just under minutes step", 8, GoalTypeMinutes, 599, BadgeSilver},
+		{"advanced first minutes step", 8, GoalTypeMinutes, 600, BadgeGold},
+		{"advanced second minutes step", 8, GoalTypeMinutes, 1200, BadgePlatinum},
+		{"workouts steps don't apply to minutes", 0, GoalTypeMinutes, 40, BadgeBronze},
+		{"unknown goal type", 8, "laps", 1000, BadgeSilver},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Challenge{Difficulty: tt.difficulty, GoalType: tt.goalType, GoalValue: tt.goalValue}
+			if got := ComputeBadgeTier(c); got != tt.want {
+				t.Errorf("ComputeBadgeTier() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// JSONAttributeBytes returns the JSON stored in a binary (B) attribute, or in a string (S) attribute written by
+// older versions. nil is returned if the attribute is missing or is neither type
+func JSONAttributeBytes(av *dynamodb.AttributeValue) []byte {
+	if av == nil {
+		return nil
+	}
+	if av.B != nil {
+		return av.B
+	}
+	if av.S != nil {
+		return []byte(*av.S)
+	}
+
+	return nil
+}
+
+// marshalJSONBlob stores v in av as a JSON blob
+func marshalJSONBlob(v interface{}, av *dynamodb.AttributeValue) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal blob: %s", err)
+	}
+	av.B = data
+
+	return nil
+}
+
+// unmarshalJSONBlob reads the JSON blob in av into v, a missing blob leaves v unchanged
+func unmarshalJSONBlob(av *dynamodb.AttributeValue, v interface{}) error {
+	data := JSONAttributeBytes(av)
+	if data == nil {
+		return nil
+	}
+	err := json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("Unable to unmarshal blob: %s", err)
+	}
+
+	return nil
+}
+
+// WorkoutBlob is a Workout that dynamodbattribute stores as a JSON blob
+type WorkoutBlob Workout
+
+// MarshalDynamoDBAttributeValue implements dynamodbattribute.Marshaler
+func (w WorkoutBlob) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return marshalJSONBlob(Workout(w), av)
+}
+
+// UnmarshalDynamoDBAttributeValue implements dynamodbattribute.Unmarshaler
+func (w *WorkoutBlob) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return unmarshalJSONBlob(av, (*Workout)(w))
+}
+
+// WorkoutsBlob is a program's workouts by week that dynamodbattribute stores as a JSON blob
+type WorkoutsBlob [][]Workout
+
+// MarshalDynamoDBAttributeValue implements dynamodbattribute.Marshaler
+func (w WorkoutsBlob) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return marshalJSONBlob([][]Workout(w), av)
+}
+
+// UnmarshalDynamoDBAttributeValue implements dynamodbattribute.Unmarshaler
+func (w *WorkoutsBlob) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return unmarshalJSONBlob(av, (*[][]Workout)(w))
+}
+
+// SubGoalsBlob is a challenge's sub-goals that dynamodbattribute stores as a JSON blob
+type SubGoalsBlob []SubGoal
+
+// MarshalDynamoDBAttributeValue implements dynamodbattribute.Marshaler
+func (s SubGoalsBlob) MarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return marshalJSONBlob([]SubGoal(s), av)
+}
+
+// UnmarshalDynamoDBAttributeValue implements dynamodbattribute.Unmarshaler
+func (s *SubGoalsBlob) UnmarshalDynamoDBAttributeValue(av *dynamodb.AttributeValue) error {
+	return unmarshalJSONBlob(av, (*[]SubGoal)(s))
+}
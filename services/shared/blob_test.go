@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+func TestJSONAttributeBytes(t *testing.T) {
+	tests := []struct {
+		name string
+		av   *dynamodb.AttributeValue
+		want []byte
+	}{
+		{"missing", nil, nil},
+		{"binary", &dynamodb.AttributeValue{B: []byte(`[]`)}, []byte(`[]`)},
+		{"legacy string", &dynamodb.AttributeValue{S: aws.String(`[]`)}, []byte(`[]`)},
+		{"other type", &dynamodb.AttributeValue{N: aws.String("1")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JSONAttributeBytes(tt.av); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSONAttributeBytes() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	type item struct {
+		Workout  WorkoutBlob  `dynamodbav:"Workout"`
+		Workouts WorkoutsBlob `dynamodbav:"Workouts"`
+		SubGoals SubGoalsBlob `dynamodbav:"SubGoals"`
+	}
+	in := item{
+		Workout:  WorkoutBlob{ID: "w1", Title: "20 min Ride", Duration: 1200},
+		Workouts: WorkoutsBlob{{{ID: "w1"}}, {{ID: "w2"}, {ID: "w3"}}},
+		SubGoals: SubGoalsBlob{{WorkoutType: "cycling", Count: 4}},
+	}
+
+	av, err := dynamodbattribute.MarshalMap(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, attr := range []string{"Workout", "Workouts", "SubGoals"} {
+		if av[attr] == nil || av[attr].B == nil {
+			t.Errorf("%s isn't stored as a binary blob: %v", attr, av[attr])
+		}
+	}
+
+	out := item{}
+	if err := dynamodbattribute.UnmarshalMap(av, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// getBookmarksDB returns the bookmarks table name and a DynamoDB instance
+// false is returned if the bookmarks_table_name or table_region env vars aren't set
+func getBookmarksDB() (string, dynamodbiface.DynamoDBAPI, bool) {
+	tableName, exists := os.LookupEnv("bookmarks_table_name")
+	if !exists || tableName == "" {
+		return "", nil, false
+	}
+	region, exists := os.LookupEnv("table_region")
+	if !exists {
+		return "", nil, false
+	}
+
+	return tableName, GetDB(region), true
+}
+
+// bookmarkID returns the Id of a user's bookmark of a ride
+func bookmarkID(userID, rideID string) string {
+	return fmt.Sprintf("%s#%s", userID, rideID)
+}
+
+// SaveBookmark records that the user bookmarked the ride
+// this is best-effort, failures are logged but not returned
+func SaveBookmark(userID, rideID string) {
+	tableName, db, ok := getBookmarksDB()
+	if !ok || userID == "" {
+		return
+	}
+
+	putInput := &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			"Id":           {S: aws.String(bookmarkID(userID, rideID))},
+			"UserID":       {S: aws.String(userID)},
+			"RideID":       {S: aws.String(rideID)},
+			"BookmarkedAt": {S: aws.String(time.Now().Format(time.RFC3339))},
+		},
+	}
+	_, err := db.PutItem(putInput)
+	if err != nil {
+		log.Printf("Unable to save bookmark of %s for %s: %s", rideID, userID, err)
+	}
+}
+
+// DeleteBookmark removes the record of the user bookmarking the ride
+// this is best-effort, failures are logged but not returned
+func DeleteBookmark(userID, rideID string) {
+	tableName, db, ok := getBookmarksDB()
+	if !ok || userID == "" {
+		return
+	}
+
+	deleteInput := &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(bookmarkID(userID, rideID))},
+		},
+	}
+	_, err := db.DeleteItem(deleteInput)
+	if err != nil {
+		log.Printf("Unable to delete bookmark of %s for %s: %s", rideID, userID, err)
+	}
+}
+
+// IsBookmarked returns whether the user has bookmarked the ride
+// false is returned if bookmarks aren't being recorded
+func IsBookmarked(userID, rideID string) (bool, error) {
+	tableName, db, ok := getBookmarksDB()
+	if !ok {
+		return false, nil
+	}
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(bookmarkID(userID, rideID))},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return false, fmt.Errorf("Unable to get bookmark: %s", err)
+	}
+
+	return len(getItemOutput.Item) > 0, nil
+}
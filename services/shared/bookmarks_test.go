@@ -0,0 +1,93 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// bookmarksDB stores bookmarks by Id, every call fails if err is set
+func bookmarksDB(err error) (*mockDB, map[string]map[string]*dynamodb.AttributeValue) {
+	items := map[string]map[string]*dynamodb.AttributeValue{}
+	db := &mockDB{}
+	db.putItem = func(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+		if err != nil {
+			return nil, err
+		}
+		items[*input.Item["Id"].S] = input.Item
+		return &dynamodb.PutItemOutput{}, nil
+	}
+	db.deleteItem = func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+		if err != nil {
+			return nil, err
+		}
+		delete(items, *input.Key["Id"].S)
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+	db.getItem = func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		if err != nil {
+			return nil, err
+		}
+		return &dynamodb.GetItemOutput{Item: items[*input.Key["Id"].S]}, nil
+	}
+
+	return db, items
+}
+
+func TestBookmarkSync(t *testing.T) {
+	db, items := bookmarksDB(nil)
+	defer useMockDB(t, db)()
+	defer setEnv(t, "bookmarks_table_name", "bookmarks")()
+
+	SaveBookmark("u1", "r1")
+	item := items["u1#r1"]
+	if item == nil || *item["UserID"].S != "u1" || *item["RideID"].S != "r1" || item["BookmarkedAt"] == nil {
+		t.Fatalf("saved bookmark = %v", item)
+	}
+	if bookmarked, err := IsBookmarked("u1", "r1"); err != nil || !bookmarked {
+		t.Errorf("IsBookmarked() = %t, %v after saving, want true", bookmarked, err)
+	}
+	if bookmarked, _ := IsBookmarked("u2", "r1"); bookmarked {
+		t.Error("IsBookmarked() = true for another user")
+	}
+
+	DeleteBookmark("u1", "r1")
+	if bookmarked, err := IsBookmarked("u1", "r1"); err != nil || bookmarked {
+		t.Errorf("IsBookmarked() = %t, %v after deleting, want false", bookmarked, err)
+	}
+}
+
+func TestBookmarkSyncSkipped(t *testing.T) {
+	tests := []struct {
+		name      string
+		tableName string
+		userID    string
+		dbErr     error
+		wantErr   bool
+	}{
+		{"not configured", "", "u1", nil, false},
+		{"no user", "bookmarks", "", nil, false},
+		{"db failure", "bookmarks", "u1", errors.New("throttled"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, items := bookmarksDB(tt.dbErr)
+			defer useMockDB(t, db)()
+			if tt.tableName != "" {
+				defer setEnv(t, "bookmarks_table_name", tt.tableName)()
+			}
+
+			// Failures are logged rather than returned
+			SaveBookmark(tt.userID, "r1")
+			DeleteBookmark(tt.userID, "r1")
+			if len(items) != 0 {
+				t.Errorf("bookmarks = %v, want none saved", items)
+			}
+			if _, err := IsBookmarked(tt.userID, "r1"); (err != nil) != tt.wantErr {
+				t.Errorf("IsBookmarked() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/browse_categories?library_type=on_demand
+
+// categoriesCacheTTL is how long category slugs are cached between warm invocations
+const categoriesCacheTTL = time.Hour
+
+var (
+	categorySlugs          []string
+	categorySlugsExpiresAt time.Time
+	categorySlugsMu        sync.Mutex
+)
+
+// GetCategorySlugs returns the slugs of the Peloton browse categories, ex) cycling, yoga
+// the slugs are cached for warm invocations
+func GetCategorySlugs() ([]string, error) {
+	categorySlugsMu.Lock()
+	defer categorySlugsMu.Unlock()
+
+	if categorySlugs != nil && time.Now().Before(categorySlugsExpiresAt) {
+		return categorySlugs, nil
+	}
+
+	body, _, _, err := PelotonRequest("GET", "/api/browse_categories?library_type=on_demand", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	categoriesRes := struct {
+		BrowseCategories []struct {
+			Slug string `json:"slug"`
+		} `json:"browse_categories"`
+	}{}
+	err = json.Unmarshal(body, &categoriesRes)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to unmarshal categories: %s", err)
+	}
+
+	slugs := []string{}
+	for _, c := range categoriesRes.BrowseCategories {
+		slugs = append(slugs, c.Slug)
+	}
+	categorySlugs = slugs
+	categorySlugsExpiresAt = time.Now().Add(categoriesCacheTTL)
+
+	return categorySlugs, nil
+}
@@ -0,0 +1,332 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DateFormat is the layout of a challenge's StartDate and EndDate
+const DateFormat = "2006-01-02"
+
+// Types of goals a challenge can have
+const (
+	// GoalTypeWorkouts is a goal of completing GoalValue workouts
+	GoalTypeWorkouts = "workouts"
+	// GoalTypeMinutes is a goal of working out for GoalValue minutes
+	GoalTypeMinutes = "minutes"
+)
+
+// How often a challenge is re-created
+const (
+	RecurrenceNone    = "none"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+)
+
+// SubGoal is a goal for one workout type within a challenge, ex) at least 4 of 12 workouts are strength
+// Count is in the unit of the challenge's GoalType, the sum of all sub-goals is at most the challenge's GoalValue
+// progress towards each sub-goal is evaluated separately from the main goal
+type SubGoal struct {
+	WorkoutType string `json:"workoutType"`
+	Count       int    `json:"count"`
+}
+
+// VisibilityPublic is stored in the Visibility attribute of public challenges only
+// so the public GSI keyed on Visibility is sparse
+const VisibilityPublic = "public"
+
+// Statuses of a challenge, computed from its dates
+const (
+	StatusUpcoming  = "upcoming"
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+)
+
+// Challenge is a custom challenge created by a user
+type Challenge struct {
+	ID              string   `json:"id"`
+	CreatedBy       string   `json:"createdBy"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	Public          bool     `json:"public"`
+	EquipmentNeeded []string `json:"equipmentNeeded"`
+	Difficulty      float32  `json:"difficulty"`
+	StartDate       string   `json:"startDate"`
+	EndDate         string   `json:"endDate"`
+	// NumWorkoutGoal is kept for backward compatibility, it equals GoalValue when GoalType is workouts
+	NumWorkoutGoal int    `json:"numWorkoutGoal"`
+	GoalType       string `json:"goalType"`
+	GoalValue      int    `json:"goalValue"`
+	// SubGoals are stored as a JSON blob
+	SubGoals     []SubGoal `json:"subGoals"`
+	WorkoutTypes []string  `json:"workoutTypes"`
+	Tags         []string  `json:"tags"`
+	// InvitedUsers are the Peloton user ids that can see the challenge when it isn't public
+	InvitedUsers []string `json:"invitedUsers"`
+	// AcceptedUsers and DeclinedUsers are the invited users that responded to their invitation
+	AcceptedUsers []string `json:"acceptedUsers"`
+	DeclinedUsers []string `json:"declinedUsers"`
+	CreatedDate   string   `json:"createdDate"`
+	UpdatedDate   string   `json:"updatedDate"`
+	// SourceProgramID is the program the challenge was built from, if any
+	SourceProgramID string `json:"sourceProgramId,omitempty"`
+	Recurrence      string `json:"recurrence"`
+	// ParentChallengeID is the first challenge of a recurring series, if any
+	ParentChallengeID string `json:"parentChallengeId,omitempty"`
+	// Status, DaysRemaining and DurationDays are computed from StartDate and EndDate, they aren't stored
+	// DaysRemaining and DurationDays are omitted if the dates are missing or invalid
+	Status        string `json:"status"`
+	DaysRemaining *int   `json:"daysRemaining,omitempty"`
+	DurationDays  *int   `json:"durationDays,omitempty"`
+	// ParticipantCount is maintained by JoinChallenge and LeaveChallenge, it's 0 for challenges created before it existed
+	ParticipantCount int `json:"participantCount"`
+	// IsOwner is computed from the caller's UserID, it isn't stored
+	IsOwner bool `json:"isOwner"`
+	// Progress is the caller's progress, it's only set when requested
+	Progress *ParticipantProgress `json:"progress,omitempty"`
+}
+
+// Today returns the current date in UTC at midnight
+func Today() time.Time {
+	today, _ := time.Parse(DateFormat, time.Now().UTC().Format(DateFormat))
+	return today
+}
+
+// ChallengeStatus returns whether the challenge is upcoming, active or completed on the given day
+// a challenge starting today is active and a challenge with missing or invalid dates is upcoming
+func ChallengeStatus(startDate, endDate string, today time.Time) string {
+	start, err := time.Parse(DateFormat, startDate)
+	if err != nil || start.After(today) {
+		return StatusUpcoming
+	}
+	end, err := time.Parse(DateFormat, endDate)
+	if err != nil {
+		return StatusUpcoming
+	}
+	if end.Before(today) {
+		return StatusCompleted
+	}
+
+	return StatusActive
+}
+
+// daysBetween returns the number of whole days from a to b
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// SetComputedFields sets the fields computed from StartDate and EndDate as of the given day
+// DurationDays includes both the start and end date. DaysRemaining counts down to EndDate for an active
+// challenge, so it's 0 on the last day, to StartDate for an upcoming challenge and is 0 once completed
+func (c *Challenge) SetComputedFields(today time.Time) {
+	c.Status = ChallengeStatus(c.StartDate, c.EndDate, today)
+	c.DaysRemaining = nil
+	c.DurationDays = nil
+
+	start, err := time.Parse(DateFormat, c.StartDate)
+	if err != nil {
+		return
+	}
+	end, err := time.Parse(DateFormat, c.EndDate)
+	if err != nil || end.Before(start) {
+		return
+	}
+
+	duration := daysBetween(start, end) + 1
+	remaining := 0
+	switch c.Status {
+	case StatusUpcoming:
+		remaining = daysBetween(today, start)
+	case StatusActive:
+		remaining = daysBetween(today, end)
+	}
+	c.DurationDays = &duration
+	c.DaysRemaining = &remaining
+}
+
+// CountsWorkout returns an error if a class of the discipline completed at completedAt doesn't count towards the challenge
+// it must be completed between StartDate and EndDate (UTC), both inclusive, and its discipline must be one of
+// the challenge's WorkoutTypes, ignoring case. A challenge without WorkoutTypes counts every discipline
+func (c Challenge) CountsWorkout(discipline string, completedAt time.Time) error {
+	start, err := time.Parse(DateFormat, c.StartDate)
+	if err != nil {
+		return fmt.Errorf("The challenge has an invalid startDate %s", c.StartDate)
+	}
+	end, err := time.Parse(DateFormat, c.EndDate)
+	if err != nil {
+		return fmt.Errorf("The challenge has an invalid endDate %s", c.EndDate)
+	}
+	completedAt = completedAt.UTC()
+	if completedAt.Before(start) || !completedAt.Before(end.AddDate(0, 0, 1)) {
+		return fmt.Errorf("The workout must be completed between the challenge's startDate %s and endDate %s", c.StartDate, c.EndDate)
+	}
+
+	if len(c.WorkoutTypes) == 0 {
+		return nil
+	}
+	for _, wt := range c.WorkoutTypes {
+		if strings.EqualFold(wt, discipline) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("The workout's discipline must be one of the challenge's workout types: %s", strings.Join(c.WorkoutTypes, ", "))
+}
+
+// NameKey normalizes a name for uniqueness checks
+// it's trimmed, internal whitespace is collapsed and it's lowercased
+func NameKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// VisibleTo returns true if the challenge is public, created by the user or the user is invited
+func (c Challenge) VisibleTo(userID string) bool {
+	return c.Public || c.CreatedBy == userID || c.IsInvited(userID)
+}
+
+// Statuses of a user's invitation to a challenge
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// containsUser returns true if userID is in users
+func containsUser(users []string, userID string) bool {
+	for _, u := range users {
+		if u == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsInvited returns true if the user is one of the challenge's InvitedUsers
+func (c Challenge) IsInvited(userID string) bool {
+	return containsUser(c.InvitedUsers, userID)
+}
+
+// InvitationStatus returns the status of the user's invitation to the challenge
+// an empty string is returned if the user isn't invited
+func (c Challenge) InvitationStatus(userID string) string {
+	switch {
+	case !c.IsInvited(userID):
+		return ""
+	case containsUser(c.DeclinedUsers, userID):
+		return InvitationDeclined
+	case containsUser(c.AcceptedUsers, userID):
+		return InvitationAccepted
+	}
+
+	return InvitationPending
+}
+
+// challengeItem is read with dynamodbattribute, the dynamodbav tags are the attribute names
+type challengeItem struct {
+	ID              string   `dynamodbav:"Id"`
+	CreatedBy       string   `dynamodbav:"CreatedBy"`
+	Name            string   `dynamodbav:"Name"`
+	Description     string   `dynamodbav:"Description"`
+	Public          bool     `dynamodbav:"Public"`
+	EquipmentNeeded []string `dynamodbav:"EquipmentNeeded"`
+	Difficulty      float32  `dynamodbav:"Difficulty"`
+	StartDate       string   `dynamodbav:"StartDate"`
+	EndDate         string   `dynamodbav:"EndDate"`
+	// NumWorkoutGoal isn't stored for minutes goals
+	NumWorkoutGoal int    `dynamodbav:"NumWorkoutGoal"`
+	GoalType       string `dynamodbav:"GoalType"`
+	// GoalValue is nil for challenges created before goal types existed
+	GoalValue         *int         `dynamodbav:"GoalValue"`
+	SubGoals          SubGoalsBlob `dynamodbav:"SubGoals"`
+	WorkoutTypes      []string     `dynamodbav:"WorkoutTypes"`
+	Tags              []string     `dynamodbav:"Tags"`
+	InvitedUsers      []string     `dynamodbav:"InvitedUsers"`
+	AcceptedUsers     []string     `dynamodbav:"AcceptedUsers"`
+	DeclinedUsers     []string     `dynamodbav:"DeclinedUsers"`
+	CreatedDate       string       `dynamodbav:"CreatedDate"`
+	UpdatedDate       string       `dynamodbav:"UpdatedDate"`
+	SourceProgramID   string       `dynamodbav:"SourceProgramId"`
+	Recurrence        string       `dynamodbav:"Recurrence"`
+	ParentChallengeID string       `dynamodbav:"ParentChallengeId"`
+	ParticipantCount  int          `dynamodbav:"ParticipantCount"`
+}
+
+// FormatChallenge converts a DynamoDB item to a Challenge
+// items written by older versions or edited by hand may be missing attributes, so every attribute is optional
+// and missing lists are returned as empty so they serialize as []
+func FormatChallenge(item map[string]*dynamodb.AttributeValue) (Challenge, error) {
+	ci := challengeItem{}
+	err := dynamodbattribute.UnmarshalMap(item, &ci)
+	if err != nil {
+		return Challenge{}, fmt.Errorf("Unable to unmarshal challenge: %s", err)
+	}
+
+	challenge := Challenge{
+		ID:                ci.ID,
+		CreatedBy:         ci.CreatedBy,
+		Name:              ci.Name,
+		Description:       ci.Description,
+		Public:            ci.Public,
+		EquipmentNeeded:   append([]string{}, ci.EquipmentNeeded...),
+		Difficulty:        ci.Difficulty,
+		StartDate:         ci.StartDate,
+		EndDate:           ci.EndDate,
+		NumWorkoutGoal:    ci.NumWorkoutGoal,
+		GoalType:          ci.GoalType,
+		GoalValue:         ci.NumWorkoutGoal,
+		SubGoals:          append([]SubGoal{}, ci.SubGoals...),
+		WorkoutTypes:      append([]string{}, ci.WorkoutTypes...),
+		Tags:              append([]string{}, ci.Tags...),
+		InvitedUsers:      append([]string{}, ci.InvitedUsers...),
+		AcceptedUsers:     append([]string{}, ci.AcceptedUsers...),
+		DeclinedUsers:     append([]string{}, ci.DeclinedUsers...),
+		CreatedDate:       ci.CreatedDate,
+		UpdatedDate:       ci.UpdatedDate,
+		SourceProgramID:   ci.SourceProgramID,
+		Recurrence:        ci.Recurrence,
+		ParentChallengeID: ci.ParentChallengeID,
+		ParticipantCount:  ci.ParticipantCount,
+	}
+	// Challenges created before goal types existed are workout goals
+	if challenge.GoalType == "" {
+		challenge.GoalType = GoalTypeWorkouts
+	}
+	if ci.GoalValue != nil {
+		challenge.GoalValue = *ci.GoalValue
+	}
+	if challenge.Recurrence == "" {
+		challenge.Recurrence = RecurrenceNone
+	}
+	challenge.SetComputedFields(Today())
+
+	return challenge, nil
+}
+
+// BatchGetChallenges returns the challenges with the given ids, keyed on id
+// ids that don't exist, were soft deleted or aren't challenges are left out of the map
+func BatchGetChallenges(db dynamodbiface.DynamoDBAPI, tableName string, ids []string) (map[string]Challenge, error) {
+	items, err := BatchGetItems(db, tableName, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get challenges: %s", err)
+	}
+
+	challenges := map[string]Challenge{}
+	for _, item := range items {
+		if !IsItemType(item, ItemTypeChallenge) || IsDeleted(item) {
+			continue
+		}
+		c, err := FormatChallenge(item)
+		if err != nil {
+			return nil, err
+		}
+		challenges[c.ID] = c
+	}
+
+	return challenges, nil
+}
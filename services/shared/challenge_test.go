@@ -0,0 +1,361 @@
+package shared
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCountsWorkout(t *testing.T) {
+	challenge := Challenge{StartDate: "2024-05-01", EndDate: "2024-05-31", WorkoutTypes: []string{"cycling"}}
+
+	tests := []struct {
+		name        string
+		challenge   Challenge
+		discipline  string
+		completedAt string
+		wantErr     bool
+	}{
+		{"first day", challenge, "cycling", "2024-05-01T00:00:00Z", false},
+		{"last day", challenge, "Cycling", "2024-05-31T23:59:59Z", false},
+		{"converted to UTC", challenge, "cycling", "2024-05-31T20:00:00-05:00", true},
+		{"before start", challenge, "cycling", "2024-04-30T23:59:59Z", true},
+		{"after end", challenge, "cycling", "2024-06-01T00:00:00Z", true},
+		{"wrong discipline", challenge, "yoga", "2024-05-10T12:00:00Z", true},
+		{"no workout types", Challenge{StartDate: "2024-05-01", EndDate: "2024-05-31"}, "yoga", "2024-05-10T12:00:00Z", false},
+		{"invalid challenge dates", Challenge{StartDate: "2024-05-01"}, "cycling", "2024-05-10T12:00:00Z", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completedAt, err := time.Parse(time.RFC3339, tt.completedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.challenge.CountsWorkout(tt.discipline, completedAt); (err != nil) != tt.wantErr {
+				t.Errorf("CountsWorkout() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNameKey(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Summer Miles", "summer miles"},
+		{"  SUMMER   miles ", "summer miles"},
+		{"summer\tmiles", "summer miles"},
+	}
+
+	for _, tt := range tests {
+		if got := NameKey(tt.name); got != tt.want {
+			t.Errorf("NameKey(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatChallengeDefaults(t *testing.T) {
+	tests := []struct {
+		name          string
+		item          map[string]*dynamodb.AttributeValue
+		wantGoalType  string
+		wantGoalValue int
+	}{
+		{"empty item", map[string]*dynamodb.AttributeValue{}, GoalTypeWorkouts, 0},
+		{
+			"legacy workout goal",
+			map[string]*dynamodb.AttributeValue{"NumWorkoutGoal": {N: aws.String("12")}},
+			GoalTypeWorkouts, 12,
+		},
+		{
+			"goal value overrides legacy goal",
+			map[string]*dynamodb.AttributeValue{
+				"NumWorkoutGoal": {N: aws.String("12")},
+				"GoalType":       {S: aws.String(GoalTypeWorkouts)},
+				"GoalValue":      {N: aws.String("15")},
+			},
+			GoalTypeWorkouts, 15,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := FormatChallenge(tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.GoalType != tt.wantGoalType || c.GoalValue != tt.wantGoalValue {
+				t.Errorf("goal = %d %s, want %d %s", c.GoalValue, c.GoalType, tt.wantGoalValue, tt.wantGoalType)
+			}
+			if c.Recurrence != RecurrenceNone {
+				t.Errorf("Recurrence = %q, want %q", c.Recurrence, RecurrenceNone)
+			}
+		})
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	c := Challenge{CreatedBy: "owner", InvitedUsers: []string{"invitee"}}
+
+	tests := []struct {
+		userID string
+		want   bool
+	}{
+		{"owner", true},
+		{"invitee", true},
+		{"stranger", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			if got := c.VisibleTo(tt.userID); got != tt.want {
+				t.Errorf("VisibleTo() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+
+	c.Public = true
+	if !c.VisibleTo("stranger") {
+		t.Error("public challenge isn't visible to a stranger")
+	}
+}
+
+func mustParseDate(t *testing.T, date string) time.Time {
+	t.Helper()
+	d, err := time.Parse(DateFormat, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestChallengeStatus(t *testing.T) {
+	today := mustParseDate(t, "2024-05-10")
+
+	tests := []struct {
+		name      string
+		startDate string
+		endDate   string
+		want      string
+	}{
+		{"starts tomorrow", "2024-05-11", "2024-05-20", StatusUpcoming},
+		{"starts today", "2024-05-10", "2024-05-20", StatusActive},
+		{"ends today", "2024-05-01", "2024-05-10", StatusActive},
+		{"ended yesterday", "2024-05-01", "2024-05-09", StatusCompleted},
+		{"invalid start date", "05/01/2024", "2024-05-20", StatusUpcoming},
+		{"invalid end date", "2024-05-01", "", StatusUpcoming},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChallengeStatus(tt.startDate, tt.endDate, today); got != tt.want {
+				t.Errorf("ChallengeStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func fullChallengeItem() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":                {S: aws.String("c1")},
+		"Type":              {S: aws.String(ItemTypeChallenge)},
+		"CreatedBy":         {S: aws.String("owner")},
+		"Name":              {S: aws.String("Summer Miles")},
+		"Description":       {S: aws.String("Ride all summer")},
+		"Public":            {BOOL: aws.Bool(true)},
+		"EquipmentNeeded":   {SS: aws.StringSlice([]string{"bike"})},
+		"Difficulty":        {N: aws.String("5.5")},
+		"StartDate":         {S: aws.String("2024-06-01")},
+		"EndDate":           {S: aws.String("2024-08-31")},
+		"GoalType":          {S: aws.String(GoalTypeMinutes)},
+		"GoalValue":         {N: aws.String("600")},
+		"SubGoals":          {B: []byte(`[{"workoutType":"cycling","count":300}]`)},
+		"WorkoutTypes":      {SS: aws.StringSlice([]string{"cycling", "strength"})},
+		"Tags":              {SS: aws.StringSlice([]string{"summer"})},
+		"InvitedUsers":      {SS: aws.StringSlice([]string{"friend"})},
+		"AcceptedUsers":     {SS: aws.StringSlice([]string{"friend"})},
+		"DeclinedUsers":     {SS: aws.StringSlice([]string{"other"})},
+		"CreatedDate":       {S: aws.String("2024-05-01T00:00:00Z")},
+		"UpdatedDate":       {S: aws.String("2024-05-02T00:00:00Z")},
+		"SourceProgramId":   {S: aws.String("p1")},
+		"Recurrence":        {S: aws.String(RecurrenceMonthly)},
+		"ParentChallengeId": {S: aws.String("c0")},
+		"ParticipantCount":  {N: aws.String("3")},
+	}
+}
+
+func TestFormatChallenge(t *testing.T) {
+	c, err := FormatChallenge(fullChallengeItem())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.ID != "c1" || c.Name != "Summer Miles" || !c.Public || c.Difficulty != 5.5 {
+		t.Errorf("unexpected challenge %+v", c)
+	}
+	if c.GoalType != GoalTypeMinutes || c.GoalValue != 600 {
+		t.Errorf("goal = %d %s, want 600 minutes", c.GoalValue, c.GoalType)
+	}
+	if !reflect.DeepEqual(c.SubGoals, []SubGoal{{WorkoutType: "cycling", Count: 300}}) {
+		t.Errorf("SubGoals = %+v", c.SubGoals)
+	}
+	if !reflect.DeepEqual(c.WorkoutTypes, []string{"cycling", "strength"}) {
+		t.Errorf("WorkoutTypes = %v", c.WorkoutTypes)
+	}
+	if c.Recurrence != RecurrenceMonthly || c.ParentChallengeID != "c0" || c.ParticipantCount != 3 {
+		t.Errorf("unexpected recurrence fields %+v", c)
+	}
+}
+
+func TestFormatChallengeMissingAttributes(t *testing.T) {
+	for attr := range fullChallengeItem() {
+		t.Run(attr, func(t *testing.T) {
+			item := fullChallengeItem()
+			delete(item, attr)
+
+			c, err := FormatChallenge(item)
+			if err != nil {
+				t.Fatalf("FormatChallenge() error = %s", err)
+			}
+			if c.EquipmentNeeded == nil || c.SubGoals == nil || c.WorkoutTypes == nil || c.Tags == nil ||
+				c.InvitedUsers == nil || c.AcceptedUsers == nil || c.DeclinedUsers == nil {
+				t.Errorf("missing lists should be empty, got %+v", c)
+			}
+		})
+	}
+}
+
+func TestFormatChallengeEmptyItem(t *testing.T) {
+	c, err := FormatChallenge(map[string]*dynamodb.AttributeValue{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Missing lists are returned as [] so clients don't need null checks
+	for _, field := range []string{"equipmentNeeded", "subGoals", "workoutTypes", "tags", "invitedUsers", "acceptedUsers", "declinedUsers"} {
+		if !strings.Contains(string(body), `"`+field+`":[]`) {
+			t.Errorf("%s isn't an empty list in %s", field, body)
+		}
+	}
+}
+
+func TestSetComputedFields(t *testing.T) {
+	today := mustParseDate(t, "2024-05-10")
+
+	tests := []struct {
+		name          string
+		startDate     string
+		endDate       string
+		wantStatus    string
+		wantDuration  *int
+		wantRemaining *int
+	}{
+		{"upcoming", "2024-05-15", "2024-05-20", StatusUpcoming, aws.Int(6), aws.Int(5)},
+		{"active", "2024-05-10", "2024-05-12", StatusActive, aws.Int(3), aws.Int(2)},
+		{"last day", "2024-05-01", "2024-05-10", StatusActive, aws.Int(10), aws.Int(0)},
+		{"completed", "2024-05-01", "2024-05-09", StatusCompleted, aws.Int(9), aws.Int(0)},
+		{"end before start", "2024-05-20", "2024-05-15", StatusUpcoming, nil, nil},
+		{"day before start", "2024-05-11", "2024-05-12", StatusUpcoming, aws.Int(2), aws.Int(1)},
+		{"malformed start", "05/01/2024", "2024-05-12", StatusUpcoming, nil, nil},
+		{"malformed end", "2024-05-01", "soon", StatusUpcoming, nil, nil},
+		{"missing dates", "", "", StatusUpcoming, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Challenge{StartDate: tt.startDate, EndDate: tt.endDate}
+			c.SetComputedFields(today)
+
+			if c.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", c.Status, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(c.DurationDays, tt.wantDuration) {
+				t.Errorf("DurationDays = %v, want %v", aws.IntValue(c.DurationDays), aws.IntValue(tt.wantDuration))
+			}
+			if !reflect.DeepEqual(c.DaysRemaining, tt.wantRemaining) {
+				t.Errorf("DaysRemaining = %v, want %v", aws.IntValue(c.DaysRemaining), aws.IntValue(tt.wantRemaining))
+			}
+		})
+	}
+}
+
+func TestInvitations(t *testing.T) {
+	c := Challenge{
+		CreatedBy:     "owner",
+		InvitedUsers:  []string{"pending", "accepted", "declined"},
+		AcceptedUsers: []string{"accepted"},
+		DeclinedUsers: []string{"declined"},
+	}
+
+	tests := []struct {
+		userID      string
+		wantVisible bool
+		wantStatus  string
+	}{
+		{"owner", true, ""},
+		{"pending", true, InvitationPending},
+		{"accepted", true, InvitationAccepted},
+		{"declined", true, InvitationDeclined},
+		{"stranger", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.userID, func(t *testing.T) {
+			if got := c.VisibleTo(tt.userID); got != tt.wantVisible {
+				t.Errorf("VisibleTo() = %t, want %t", got, tt.wantVisible)
+			}
+			if got := c.InvitationStatus(tt.userID); got != tt.wantStatus {
+				t.Errorf("InvitationStatus() = %q, want %q", got, tt.wantStatus)
+			}
+		})
+	}
+
+	c.Public = true
+	if !c.VisibleTo("stranger") {
+		t.Error("public challenge isn't visible to a stranger")
+	}
+}
+
+func TestFormatChallengeSubGoals(t *testing.T) {
+	yoga := []SubGoal{{WorkoutType: "yoga", Count: 2}}
+
+	tests := []struct {
+		name string
+		av   *dynamodb.AttributeValue
+		want []SubGoal
+	}{
+		{"binary", &dynamodb.AttributeValue{B: []byte(`[{"workoutType":"yoga","count":2}]`)}, yoga},
+		// Challenges written before SubGoals was a binary blob stored it as a string
+		{"legacy string", &dynamodb.AttributeValue{S: aws.String(`[{"workoutType":"yoga","count":2}]`)}, yoga},
+		{"missing", nil, []SubGoal{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("c1")}}
+			if tt.av != nil {
+				item["SubGoals"] = tt.av
+			}
+
+			c, err := FormatChallenge(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.SubGoals, tt.want) {
+				t.Errorf("SubGoals = %#v, want %#v", c.SubGoals, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Completion records that a user reached the goal of a challenge
+// it is keyed on the same Id as the user's participation so a challenge can only be completed once
+type Completion struct {
+	ChallengeID string `json:"challengeId"`
+	UserID      string `json:"userId"`
+	CompletedAt string `json:"completedAt"`
+	// FinalCount is the user's progress when they completed the challenge, in the unit of its GoalType
+	FinalCount int `json:"finalCount"`
+	// BadgeTier is stamped when the challenge is completed, see ComputeBadgeTier
+	// it's empty for completions recorded before badges existed
+	BadgeTier string `json:"badgeTier"`
+}
+
+// GetCompletionsTableName returns the table of completed challenges
+// from the completions_table_name env var
+func GetCompletionsTableName() (string, error) {
+	tableName, exists := os.LookupEnv("completions_table_name")
+	if !exists || tableName == "" {
+		return "", fmt.Errorf("completions_table_name %w", ErrNotConfigured)
+	}
+
+	return tableName, nil
+}
+
+// FormatCompletion converts a DynamoDB item to a Completion
+func FormatCompletion(item map[string]*dynamodb.AttributeValue) (Completion, error) {
+	c := Completion{}
+	var err error
+
+	if item["ChallengeId"] != nil && item["ChallengeId"].S != nil {
+		c.ChallengeID = *item["ChallengeId"].S
+	}
+	if item["UserId"] != nil && item["UserId"].S != nil {
+		c.UserID = *item["UserId"].S
+	}
+	if item["CompletedAt"] != nil && item["CompletedAt"].S != nil {
+		c.CompletedAt = *item["CompletedAt"].S
+	}
+	if item["BadgeTier"] != nil && item["BadgeTier"].S != nil {
+		c.BadgeTier = *item["BadgeTier"].S
+	}
+	if item["FinalCount"] != nil && item["FinalCount"].N != nil {
+		c.FinalCount, err = strconv.Atoi(*item["FinalCount"].N)
+		if err != nil {
+			return Completion{}, fmt.Errorf("Unable to convert FinalCount to int: %s", err)
+		}
+	}
+
+	return c, nil
+}
+
+// CompleteIfGoalMet records the user's completion of the challenge if their progress has reached its goal
+// the participation is flagged as Completed and the completion record is written in one transaction,
+// conditional on the participation not already being completed, so racing updates only complete it once
+// true is returned only for the update that completed the challenge
+func CompleteIfGoalMet(db dynamodbiface.DynamoDBAPI, participantsTableName, completionsTableName string, c Challenge, p Participation) (bool, error) {
+	if p.IsCompleted || c.GoalValue <= 0 || p.Completed(c) < c.GoalValue {
+		return false, nil
+	}
+
+	id := ParticipationID(c.ID, p.UserID)
+	now := time.Now().Format(time.RFC3339)
+	finalCount := strconv.Itoa(p.Completed(c))
+	transactInput := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(participantsTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"Id": {S: aws.String(id)},
+					},
+					UpdateExpression: aws.String("SET Completed = :true, CompletedAt = :now"),
+					// The participation must still exist so a user that left isn't completed
+					ConditionExpression: aws.String("attribute_exists(Id) and (attribute_not_exists(Completed) or Completed = :false)"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":true":  {BOOL: aws.Bool(true)},
+						":false": {BOOL: aws.Bool(false)},
+						":now":   {S: aws.String(now)},
+					},
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(completionsTableName),
+					Item: map[string]*dynamodb.AttributeValue{
+						"Id":          {S: aws.String(id)},
+						"ChallengeId": {S: aws.String(c.ID)},
+						"UserId":      {S: aws.String(p.UserID)},
+						"CompletedAt": {S: aws.String(now)},
+						"FinalCount":  {N: aws.String(finalCount)},
+						"BadgeTier":   {S: aws.String(ComputeBadgeTier(c))},
+					},
+					ConditionExpression: aws.String("attribute_not_exists(Id)"),
+				},
+			},
+		},
+	}
+	_, err := db.TransactWriteItems(transactInput)
+	if err == nil {
+		return true, nil
+	}
+	codes := cancellationCodes(err)
+	if conditionFailed(codes, 0) || conditionFailed(codes, 1) {
+		// Another update already completed the challenge
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Unable to record challenge completion: %s", err)
+}
+
+// GetUserCompletions returns every challenge the user has completed
+// the GSI named by the completions_user_index_name env var, partitioned on UserId, is queried if it's set
+// otherwise the completions table is scanned
+func GetUserCompletions(db dynamodbiface.DynamoDBAPI, tableName, userID string) ([]Completion, error) {
+	values := map[string]*dynamodb.AttributeValue{
+		":userId": {S: aws.String(userID)},
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	if indexName := os.Getenv("completions_user_index_name"); indexName != "" {
+		queryInput := &dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(indexName),
+			KeyConditionExpression:    aws.String("UserId = :userId"),
+			ExpressionAttributeValues: values,
+		}
+		for {
+			queryOutput, err := db.Query(queryInput)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to get completed challenges: %s", err)
+			}
+			items = append(items, queryOutput.Items...)
+			if len(queryOutput.LastEvaluatedKey) == 0 {
+				break
+			}
+			queryInput.ExclusiveStartKey = queryOutput.LastEvaluatedKey
+		}
+	} else {
+		var err error
+		items, err = ScanAll(db, &dynamodb.ScanInput{
+			TableName:                 aws.String(tableName),
+			FilterExpression:          aws.String("UserId = :userId"),
+			ExpressionAttributeValues: values,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Unable to get completed challenges: %s", err)
+		}
+	}
+
+	completions := []Completion{}
+	for _, i := range items {
+		c, err := FormatCompletion(i)
+		if err != nil {
+			return nil, err
+		}
+		completions = append(completions, c)
+	}
+
+	return completions, nil
+}
@@ -0,0 +1,105 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// countItems counts the items matching scanInput, following LastEvaluatedKey until the table is exhausted
+func countItems(db dynamodbiface.DynamoDBAPI, scanInput *dynamodb.ScanInput) (int64, error) {
+	var count int64
+	scanInput.Select = aws.String(dynamodb.SelectCount)
+
+	for {
+		scanOutput, err := db.Scan(scanInput)
+		if err != nil {
+			return 0, err
+		}
+		count += aws.Int64Value(scanOutput.Count)
+
+		if len(scanOutput.LastEvaluatedKey) == 0 {
+			break
+		}
+		scanInput.ExclusiveStartKey = scanOutput.LastEvaluatedKey
+	}
+
+	return count, nil
+}
+
+// itemTypeForKind is the Type attribute of the items of each kind, the kinds can share a table
+// items written before the Type attribute existed are counted for every kind, like IsItemType
+var itemTypeForKind = map[string]string{
+	TableChallenges:      ItemTypeChallenge,
+	TablePrograms:        ItemTypeProgram,
+	TableRecommendations: ItemTypeRecommendation,
+}
+
+type countResponse struct {
+	Public int64 `json:"public"`
+	Mine   int64 `json:"mine"`
+}
+
+// CountByOwnership returns a handler that counts the items of a kind visible to the user
+// broken down into public items created by others and items created by the user
+// the UserID header is required by WithUserID
+func CountByOwnership(kind string) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		return countByOwnership(ctx, kind)
+	}
+}
+
+func countByOwnership(ctx context.Context, kind string) (events.APIGatewayProxyResponse, error) {
+	userID := UserIDFromContext(ctx)
+
+	tableRegion, tableName, err := GetTableFor(kind)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := GetDB(tableRegion)
+
+	publicCount, err := countItems(db, &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#P": aws.String("Public"),
+			"#T": aws.String("Type"),
+		},
+		FilterExpression: aws.String(ItemTypeFilter + " and #P = :public and CreatedBy <> :createdBy and " + NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type":      {S: aws.String(itemTypeForKind[kind])},
+			":public":    {BOOL: aws.Bool(true)},
+			":createdBy": {S: aws.String(userID)},
+		},
+	})
+	if err != nil {
+		return ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to count public items: %s", err)), nil
+	}
+
+	mineCount, err := countItems(db, &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#T": aws.String("Type"),
+		},
+		FilterExpression: aws.String(ItemTypeFilter + " and CreatedBy = :createdBy and " + NotDeletedFilter),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":type":      {S: aws.String(itemTypeForKind[kind])},
+			":createdBy": {S: aws.String(userID)},
+		},
+	})
+	if err != nil {
+		return ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to count user's items: %s", err)), nil
+	}
+
+	return JSONResponse(http.StatusOK, countResponse{
+		Public: publicCount,
+		Mine:   mineCount,
+	})
+}
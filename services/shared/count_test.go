@@ -0,0 +1,80 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCountByOwnership(t *testing.T) {
+	// Counts of each page of the scan, the public items are split across pages
+	pageCounts := map[bool][]int64{
+		true:  {3, 0, 2},
+		false: {4},
+	}
+
+	tests := []struct {
+		kind     string
+		wantType string
+	}{
+		{TableChallenges, ItemTypeChallenge},
+		{TablePrograms, ItemTypeProgram},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			scans := []*dynamodb.ScanInput{}
+			calls := map[bool]int{}
+			db := &mockDB{}
+			db.scan = func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+				scans = append(scans, input)
+				public := strings.Contains(*input.FilterExpression, "#P = :public")
+				counts := pageCounts[public]
+				output := &dynamodb.ScanOutput{Count: aws.Int64(counts[calls[public]])}
+				calls[public]++
+				if calls[public] < len(counts) {
+					output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("next")}}
+				}
+				return output, nil
+			}
+			defer useMockDB(t, db)()
+
+			request := events.APIGatewayV2HTTPRequest{Headers: map[string]string{"UserID": "u1"}}
+			res, err := WithUserID(CountByOwnership(tt.kind))(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("StatusCode = %d, want 200: %s", res.StatusCode, res.Body)
+			}
+			body := countResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Public != 5 || body.Mine != 4 {
+				t.Errorf("counts = %+v, want 5 public and 4 mine", body)
+			}
+
+			if len(scans) != 4 {
+				t.Errorf("%d scans, want 4", len(scans))
+			}
+			for _, s := range scans {
+				if aws.StringValue(s.Select) != dynamodb.SelectCount {
+					t.Errorf("Select = %s, want %s", aws.StringValue(s.Select), dynamodb.SelectCount)
+				}
+				if !strings.Contains(*s.FilterExpression, ItemTypeFilter) || aws.StringValue(s.ExpressionAttributeValues[":type"].S) != tt.wantType {
+					t.Errorf("filter %q doesn't match type %s", *s.FilterExpression, tt.wantType)
+				}
+				if aws.StringValue(s.ExpressionAttributeValues[":createdBy"].S) != "u1" {
+					t.Errorf("counted for %s, want u1", aws.StringValue(s.ExpressionAttributeValues[":createdBy"].S))
+				}
+			}
+		})
+	}
+}
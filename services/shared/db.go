@@ -4,32 +4,217 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
+// ErrNotConfigured is returned when a required env var isn't set
+var ErrNotConfigured = errors.New("env var doesn't exist")
+
 // GetDBInfo returns the db region and table name from the env vars
 func GetDBInfo() (string, string, error) {
 	region, exists := os.LookupEnv("table_region")
 	if !exists {
-		errors.New("table_region env var doesn't exist")
+		return "", "", fmt.Errorf("table_region %w", ErrNotConfigured)
 	}
 	name, exists := os.LookupEnv("table_name")
 	if !exists {
-		errors.New("table_name env var doesn't exist")
+		return "", "", fmt.Errorf("table_name %w", ErrNotConfigured)
 	}
 
 	return region, name, nil
 }
 
+// Kinds of items that can be stored in their own table
+const (
+	TableChallenges      = "challenges"
+	TablePrograms        = "programs"
+	TableRecommendations = "recommendations"
+)
+
+// Types of items, stored in the Type attribute so items sharing a table can be told apart
+const (
+	ItemTypeChallenge      = "challenge"
+	ItemTypeProgram        = "program"
+	ItemTypeRecommendation = "recommendation"
+)
+
+// GetTableFor returns the db region and the table name for a kind of item
+// the <kind>_table env var, ex) challenges_table, is used if it is set, otherwise the shared table_name is used
+func GetTableFor(kind string) (string, string, error) {
+	if name := strings.TrimSpace(os.Getenv(kind + "_table")); name != "" {
+		region, exists := os.LookupEnv("table_region")
+		if !exists {
+			return "", "", fmt.Errorf("table_region %w", ErrNotConfigured)
+		}
+		return region, name, nil
+	}
+
+	return GetDBInfo()
+}
+
 // GetDB returns a DynamoDB instance
-func GetDB(region string) *dynamodb.DynamoDB {
+func GetDB(region string) dynamodbiface.DynamoDBAPI {
+	return NewDB(region)
+}
+
+// NewDB creates the DynamoDB client returned by GetDB, tests replace it with a mock
+// the dynamodb_endpoint env var overrides the regional endpoint, e.g. http://localhost:8000 for DynamoDB Local
+var NewDB = func(region string) dynamodbiface.DynamoDBAPI {
 	sess := session.Must(session.NewSession())
 	config := &aws.Config{
 		Endpoint: aws.String(fmt.Sprintf("dynamodb.%s.amazonaws.com", region)),
 		Region:   aws.String(region),
 	}
+
+	if endpoint := strings.TrimSpace(os.Getenv("dynamodb_endpoint")); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		// DynamoDB Local is usually served over plain http and accepts any credentials
+		config.DisableSSL = aws.Bool(strings.HasPrefix(endpoint, "http://"))
+		if _, exists := os.LookupEnv("AWS_ACCESS_KEY_ID"); !exists {
+			config.Credentials = credentials.NewStaticCredentials("local", "local", "")
+		}
+	}
+
 	return dynamodb.New(sess, config)
 }
+
+// defaultCreatedByIndexName is the GSI partitioned on CreatedBy
+const defaultCreatedByIndexName = "CreatedByIndex"
+
+// GetCreatedByIndexName returns the name of the GSI partitioned on CreatedBy
+// from the created_by_index_name env var, or CreatedByIndex if it isn't set
+func GetCreatedByIndexName() string {
+	if name := strings.TrimSpace(os.Getenv("created_by_index_name")); name != "" {
+		return name
+	}
+
+	return defaultCreatedByIndexName
+}
+
+// IsItemType returns false if the item's Type attribute is set to a type other than itemType
+// items written before the Type attribute existed match every type
+func IsItemType(item map[string]*dynamodb.AttributeValue, itemType string) bool {
+	if item["Type"] == nil || item["Type"].S == nil {
+		return true
+	}
+
+	return *item["Type"].S == itemType
+}
+
+// ItemTypeFilter is the FilterExpression condition that matches items like IsItemType
+// Type is a reserved word, so #T must be bound to Type and :type to the item type
+const ItemTypeFilter = "(attribute_not_exists(#T) or #T = :type)"
+
+// maxBatchGetKeys is the max number of keys DynamoDB accepts in one BatchGetItem call
+const maxBatchGetKeys = 100
+
+// BatchGetItems returns the items with the given Ids, in no particular order
+// ids that don't exist are left out, duplicate ids are only read once
+func BatchGetItems(db dynamodbiface.DynamoDBAPI, tableName string, ids []string) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	// DynamoDB rejects a batch with duplicate keys
+	unique := []string{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	for start := 0; start < len(unique); start += maxBatchGetKeys {
+		end := start + maxBatchGetKeys
+		if end > len(unique) {
+			end = len(unique)
+		}
+		keys := []map[string]*dynamodb.AttributeValue{}
+		for _, id := range unique[start:end] {
+			keys = append(keys, map[string]*dynamodb.AttributeValue{
+				"Id": {S: aws.String(id)},
+			})
+		}
+
+		requestItems := map[string]*dynamodb.KeysAndAttributes{
+			tableName: {Keys: keys},
+		}
+		// Keys DynamoDB couldn't read this time are returned as UnprocessedKeys and retried
+		for len(requestItems) > 0 {
+			batchOutput, err := db.BatchGetItem(&dynamodb.BatchGetItemInput{
+				RequestItems: requestItems,
+			})
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, batchOutput.Responses[tableName]...)
+			requestItems = batchOutput.UnprocessedKeys
+		}
+	}
+
+	return items, nil
+}
+
+// NotDeletedFilter is the FilterExpression condition that excludes soft deleted items, see DeleteByID
+const NotDeletedFilter = "attribute_not_exists(DeletedAt)"
+
+// IsDeleted returns true if the item was soft deleted
+// soft deleted items are kept until their TTL expires so they can be restored
+func IsDeleted(item map[string]*dynamodb.AttributeValue) bool {
+	return item["DeletedAt"] != nil
+}
+
+// ScanAll scans the whole table, following LastEvaluatedKey until it's exhausted, and returns every matching item
+// input.ExclusiveStartKey is updated as the scan progresses
+func ScanAll(db dynamodbiface.DynamoDBAPI, input *dynamodb.ScanInput) ([]map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	for {
+		scanOutput, err := db.Scan(input)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, scanOutput.Items...)
+
+		if len(scanOutput.LastEvaluatedKey) == 0 {
+			return items, nil
+		}
+		input.ExclusiveStartKey = scanOutput.LastEvaluatedKey
+	}
+}
+
+// ScanPage scans for a page of at most limit matching items, starting at input.ExclusiveStartKey
+// the filter is applied after items are read, so the scan continues until the page is full or the table is exhausted
+// the returned key is the ExclusiveStartKey of the next page, or nil if there are no more items
+// the table must be keyed on Id, since a page that ends mid-scan continues after its last item
+func ScanPage(db dynamodbiface.DynamoDBAPI, input *dynamodb.ScanInput, limit int) ([]map[string]*dynamodb.AttributeValue, map[string]*dynamodb.AttributeValue, error) {
+	items := []map[string]*dynamodb.AttributeValue{}
+
+	for {
+		scanOutput, err := db.Scan(input)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for idx, i := range scanOutput.Items {
+			items = append(items, i)
+			if len(items) < limit {
+				continue
+			}
+			if idx == len(scanOutput.Items)-1 && len(scanOutput.LastEvaluatedKey) == 0 {
+				return items, nil, nil
+			}
+			return items, map[string]*dynamodb.AttributeValue{"Id": i["Id"]}, nil
+		}
+
+		if len(scanOutput.LastEvaluatedKey) == 0 {
+			return items, nil, nil
+		}
+		input.ExclusiveStartKey = scanOutput.LastEvaluatedKey
+	}
+}
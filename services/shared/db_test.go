@@ -0,0 +1,238 @@
+package shared
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestScanAll(t *testing.T) {
+	tests := []struct {
+		name          string
+		pages         [][]map[string]*dynamodb.AttributeValue
+		wantIDs       []string
+		wantStartKeys []string
+	}{
+		{"one page", [][]map[string]*dynamodb.AttributeValue{idItems("a", "b")}, []string{"a", "b"}, []string{""}},
+		{
+			"three pages",
+			[][]map[string]*dynamodb.AttributeValue{idItems("a", "b"), idItems("c"), idItems("d", "e")},
+			[]string{"a", "b", "c", "d", "e"},
+			[]string{"", "page1", "page2"},
+		},
+		{
+			"empty page in the middle",
+			[][]map[string]*dynamodb.AttributeValue{idItems("a"), idItems(), idItems("b")},
+			[]string{"a", "b"},
+			[]string{"", "page1", "page2"},
+		},
+		{"empty table", [][]map[string]*dynamodb.AttributeValue{idItems()}, []string{}, []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startKeys := []string{}
+			db := &mockDB{scan: scanPages(&startKeys, tt.pages...)}
+
+			items, err := ScanAll(db, &dynamodb.ScanInput{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := itemIDs(items); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("ScanAll() = %v, want %v", got, tt.wantIDs)
+			}
+			if !reflect.DeepEqual(startKeys, tt.wantStartKeys) {
+				t.Errorf("ExclusiveStartKeys = %v, want %v", startKeys, tt.wantStartKeys)
+			}
+		})
+	}
+}
+
+func TestScanAllError(t *testing.T) {
+	scanErr := errors.New("throttled")
+	calls := 0
+	db := &mockDB{scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		calls++
+		if calls == 2 {
+			return nil, scanErr
+		}
+		return &dynamodb.ScanOutput{Items: idItems("a"), LastEvaluatedKey: idItems("a")[0]}, nil
+	}}
+
+	items, err := ScanAll(db, &dynamodb.ScanInput{})
+	if !errors.Is(err, scanErr) || items != nil {
+		t.Errorf("ScanAll() = %v, %v, want nil and the scan error", items, err)
+	}
+}
+
+func TestScanPage(t *testing.T) {
+	tests := []struct {
+		name          string
+		pages         [][]map[string]*dynamodb.AttributeValue
+		limit         int
+		wantIDs       []string
+		wantLastKey   string
+		wantStartKeys []string
+	}{
+		{"limit in the middle of a page", [][]map[string]*dynamodb.AttributeValue{idItems("a", "b", "c")}, 2, []string{"a", "b"}, "b", []string{""}},
+		{"limit at the end of the table", [][]map[string]*dynamodb.AttributeValue{idItems("a", "b")}, 2, []string{"a", "b"}, "", []string{""}},
+		// The next page might be empty, but there's no way to know without reading it
+		{"limit at the end of a page", [][]map[string]*dynamodb.AttributeValue{idItems("a", "b"), idItems("c")}, 2, []string{"a", "b"}, "b", []string{""}},
+		{
+			"under the limit across pages",
+			[][]map[string]*dynamodb.AttributeValue{idItems("a"), idItems(), idItems("b")},
+			5,
+			[]string{"a", "b"},
+			"",
+			[]string{"", "page1", "page2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startKeys := []string{}
+			db := &mockDB{scan: scanPages(&startKeys, tt.pages...)}
+
+			items, lastKey, err := ScanPage(db, &dynamodb.ScanInput{}, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := itemIDs(items); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("ScanPage() = %v, want %v", got, tt.wantIDs)
+			}
+			gotLastKey := ""
+			if lastKey != nil {
+				gotLastKey = aws.StringValue(lastKey["Id"].S)
+			}
+			if gotLastKey != tt.wantLastKey {
+				t.Errorf("lastKey = %q, want %q", gotLastKey, tt.wantLastKey)
+			}
+			if !reflect.DeepEqual(startKeys, tt.wantStartKeys) {
+				t.Errorf("ExclusiveStartKeys = %v, want %v", startKeys, tt.wantStartKeys)
+			}
+		})
+	}
+}
+
+func TestGetDBEndpoint(t *testing.T) {
+	tests := []struct {
+		name      string
+		override  string
+		want      string
+		wantNoSSL bool
+	}{
+		{"regional endpoint", "", "https://dynamodb.us-east-1.amazonaws.com", false},
+		{"local endpoint", "http://localhost:8000", "http://localhost:8000", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(t, "dynamodb_endpoint", tt.override)()
+
+			client := GetDB("us-east-1").(*dynamodb.DynamoDB)
+			if client.Endpoint != tt.want {
+				t.Errorf("Endpoint = %s, want %s", client.Endpoint, tt.want)
+			}
+			if got := aws.BoolValue(client.Config.DisableSSL); got != tt.wantNoSSL {
+				t.Errorf("DisableSSL = %t, want %t", got, tt.wantNoSSL)
+			}
+		})
+	}
+}
+
+func TestGetTableFor(t *testing.T) {
+	defer setEnv(t, "table_region", "us-east-1")()
+	defer setEnv(t, "table_name", "pelodata")()
+
+	tests := []struct {
+		name      string
+		kind      string
+		kindTable string
+		want      string
+	}{
+		{"single table", TablePrograms, "", "pelodata"},
+		{"blank table per type", TablePrograms, " ", "pelodata"},
+		{"programs table", TablePrograms, "pelodata-programs", "pelodata-programs"},
+		{"challenges table", TableChallenges, "pelodata-challenges", "pelodata-challenges"},
+		{"recommendations table", TableRecommendations, "pelodata-recommendations", "pelodata-recommendations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(t, tt.kind+"_table", tt.kindTable)()
+
+			region, table, err := GetTableFor(tt.kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region != "us-east-1" || table != tt.want {
+				t.Errorf("GetTableFor() = %s, %s, want us-east-1, %s", region, table, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDBInfoNotConfigured(t *testing.T) {
+	defer setEnv(t, "table_region", "")()
+	defer setEnv(t, "programs_table", "pelodata-programs")()
+	os.Unsetenv("table_region")
+
+	// A table per type still needs the shared region
+	if _, _, err := GetTableFor(TablePrograms); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("GetTableFor() error = %v, want ErrNotConfigured", err)
+	}
+
+	defer setEnv(t, "table_region", "us-east-1")()
+	defer setEnv(t, "table_name", "")()
+	os.Unsetenv("table_name")
+
+	if _, _, err := GetDBInfo(); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("GetDBInfo() error = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestIsItemType(t *testing.T) {
+	tests := []struct {
+		name string
+		item map[string]*dynamodb.AttributeValue
+		want bool
+	}{
+		{"matching type", map[string]*dynamodb.AttributeValue{"Type": {S: aws.String(ItemTypeProgram)}}, true},
+		{"other type", map[string]*dynamodb.AttributeValue{"Type": {S: aws.String(ItemTypeChallenge)}}, false},
+		{"written before types", map[string]*dynamodb.AttributeValue{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsItemType(tt.item, ItemTypeProgram); got != tt.want {
+				t.Errorf("IsItemType() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDeleted(t *testing.T) {
+	tests := []struct {
+		name string
+		item map[string]*dynamodb.AttributeValue
+		want bool
+	}{
+		{"without DeletedAt", map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("c1")}}, false},
+		{"with DeletedAt", map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("c1")}, "DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")}}, true},
+		// The item is hidden until DynamoDB's TTL removes it, even after the TTL has passed
+		{"ttl passed", map[string]*dynamodb.AttributeValue{"DeletedAt": {S: aws.String("2024-04-10T00:00:00Z")}, softDeleteTTLAttribute: {N: aws.String("1715299200")}}, true},
+		{"empty item", map[string]*dynamodb.AttributeValue{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDeleted(tt.item); got != tt.want {
+				t.Errorf("IsDeleted() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,125 +4,260 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
+// SoftDeleteGraceDays is how long a soft deleted item can be restored before its TTL removes it
+const SoftDeleteGraceDays = 30
+
+// softDeleteTTLAttribute is the attribute DynamoDB's TTL must be enabled on, in epoch seconds
+const softDeleteTTLAttribute = "ExpiresAt"
+
 var validPathParams = []string{"challengeId", "programId", "recommendationId"}
 
+// deleteDataType returns the type of item and its id from the path params
+// ex) recommendationId returns recommendation
+func deleteDataType(request events.APIGatewayV2HTTPRequest) (string, string) {
+	for _, p := range validPathParams {
+		if val, ok := request.PathParameters[p]; ok {
+			return strings.TrimSuffix(p, "Id"), strings.TrimSpace(val)
+		}
+	}
+
+	return "", ""
+}
+
+// getOwnedItem gets the item of the path param's type and checks the user owns it
+// the status to respond with is returned if the item can't be used
+func getOwnedItem(db dynamodbiface.DynamoDBAPI, tableName, dataType, id, userID, action string) (map[string]*dynamodb.AttributeValue, int, error) {
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(id)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("Unable to get %s: %s", dataType, err)
+	}
+
+	createdBy, ok := getItemOutput.Item["CreatedBy"]
+	if !ok || createdBy == nil || createdBy.S == nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("The %s doesn't exist", dataType)
+	}
+	// Challenges, programs and recommendations may share a table, so the id must be for this kind of item
+	if !IsItemType(getItemOutput.Item, dataType) {
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is not a %s", id, dataType)
+	}
+	if *createdBy.S != userID {
+		return nil, http.StatusUnauthorized, fmt.Errorf("Must be the owner of the %s to %s it", dataType, action)
+	}
+
+	return getItemOutput.Item, -1, nil
+}
+
 // DeleteByID deletes an item from a Dynamo table by Id
+// items are soft deleted by default, they're hidden by setting DeletedAt and removed by DynamoDB's TTL
+// after SoftDeleteGraceDays unless they're restored with RestoreByID
+// the hard query param set to true deletes the item immediately, including an item that was soft deleted
 func DeleteByID(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
 	// Get UserID header
 	userID, ok := request.Headers["UserID"]
 	userID = strings.TrimSpace(userID)
 	if !ok || userID == "" {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "UserID header is required"
-		}`, http.StatusBadRequest)
+		return ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	dataType, id := deleteDataType(request)
+	if dataType == "" || id == "" {
+		return ErrorResponse(http.StatusBadRequest, fmt.Sprintf("One of the path parameters %s is required", strings.Join(validPathParams, ", "))), nil
+	}
+
+	hard := false
+	if hardStr, ok := request.QueryStringParameters["hard"]; ok {
+		var err error
+		hard, err = strconv.ParseBool(hardStr)
+		if err != nil {
+			return ErrorResponse(http.StatusBadRequest, "hard must be true or false"), nil
+		}
 	}
 
-	tableRegion, tableName, err := GetDBInfo()
+	// The table kinds are the plural of the data type, ex) challenge is stored in challenges
+	tableRegion, tableName, err := GetTableFor(dataType + "s")
 	if err != nil {
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusInternalServerError,
 		}, err
 	}
 
-	dataType := ""
-	id := ""
-	for _, p := range validPathParams {
-		if val, ok := request.PathParameters[p]; ok {
-			dataType = strings.Replace(p, "Id", "", 1)
-			id = strings.TrimSpace(val)
-			break
+	db := GetDB(tableRegion)
+
+	item, returnCode, err := getOwnedItem(db, tableName, dataType, id, userID, "delete")
+	if err != nil {
+		return ErrorResponse(returnCode, err.Error()), nil
+	}
+
+	if hard {
+		deleteItemInput := &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"Id": {S: aws.String(id)},
+			},
+		}
+		_, err = db.DeleteItem(deleteItemInput)
+		if err != nil {
+			return ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to delete %s: %s", dataType, err)), nil
 		}
+
+		return JSONResponse(http.StatusOK, errorBody{
+			Status:  http.StatusOK,
+			Message: fmt.Sprintf("The %s was deleted", dataType),
+		})
 	}
 
-	sess := session.Must(session.NewSession())
-	config := &aws.Config{
-		Endpoint: aws.String(fmt.Sprintf("dynamodb.%s.amazonaws.com", tableRegion)),
-		Region:   aws.String(tableRegion),
+	if IsDeleted(item) {
+		return ErrorResponse(http.StatusBadRequest, fmt.Sprintf("The %s doesn't exist", dataType)), nil
 	}
-	db := dynamodb.New(sess, config)
 
-	getItemInput := &dynamodb.GetItemInput{
+	now := time.Now()
+	expiresAt := now.AddDate(0, 0, SoftDeleteGraceDays)
+	updateItemInput := &dynamodb.UpdateItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"Id": {S: aws.String(id)},
 		},
+		UpdateExpression: aws.String(fmt.Sprintf("SET DeletedAt = :deletedAt, %s = :expiresAt", softDeleteTTLAttribute)),
+		// Another request may have deleted it since it was read
+		ConditionExpression: aws.String("attribute_exists(Id) and attribute_not_exists(DeletedAt)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deletedAt": {S: aws.String(now.Format(time.RFC3339))},
+			":expiresAt": {N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))},
+		},
 	}
-	getItemOutput, err := db.GetItem(getItemInput)
+	_, err = db.UpdateItem(updateItemInput)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to get %s: %s"
-		}`, http.StatusInternalServerError, dataType, err.Error())
+		return ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to delete %s: %s", dataType, err)), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
+	return JSONResponse(http.StatusOK, errorBody{
+		Status:  http.StatusOK,
+		Message: fmt.Sprintf("The %s was deleted, it can be restored until %s", dataType, expiresAt.UTC().Format(time.RFC3339)),
+	})
+}
+
+// nameTaken returns true if another item of the data type that isn't deleted has the item's name
+// names are unique among public items and among each user's items, as they are when items are created,
+// but deleted items don't hold their name, so one may have been reused while the item was deleted
+// challenges are matched on NameKey and programs, which don't store it, on their exact Name
+func nameTaken(db dynamodbiface.DynamoDBAPI, tableName, dataType, id string, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	if item["Name"] == nil || item["Name"].S == nil {
+		return false, nil
 	}
+	name := *item["Name"].S
 
-	createdBy, ok := getItemOutput.Item["CreatedBy"]
-	if !ok || createdBy == nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": The %s doesn't exist
-		}`, http.StatusBadRequest, dataType)
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#N": aws.String("Name"),
+			"#T": aws.String("Type"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":name": {S: aws.String(name)},
+			":type": {S: aws.String(dataType)},
+			":id":   {S: aws.String(id)},
+		},
+	}
+	nameFilter := "#N = :name"
+	if dataType == ItemTypeChallenge {
+		nameFilter = "(NameKey = :nameKey or #N = :name)"
+		scanInput.ExpressionAttributeValues[":nameKey"] = &dynamodb.AttributeValue{S: aws.String(NameKey(name))}
+	}
+	if item["Public"] != nil && aws.BoolValue(item["Public"].BOOL) {
+		scanInput.ExpressionAttributeNames["#P"] = aws.String("Public")
+		scanInput.ExpressionAttributeValues[":public"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+		nameFilter += " and #P = :public"
+	} else {
+		scanInput.ExpressionAttributeValues[":createdBy"] = item["CreatedBy"]
+		nameFilter += " and CreatedBy = :createdBy"
+	}
+	scanInput.FilterExpression = aws.String(nameFilter + " and " + ItemTypeFilter + " and Id <> :id and " + NotDeletedFilter)
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusBadRequest,
-			Body:       errBody,
-		}, nil
+	items, err := ScanAll(db, scanInput)
+	if err != nil {
+		return false, fmt.Errorf("Unable to get existing %ss: %s", dataType, err)
+	}
+
+	return len(items) > 0, nil
+}
+
+// RestoreByID restores an item soft deleted by DeleteByID, if its grace period hasn't ended
+func RestoreByID(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// Get UserID header
+	userID, ok := request.Headers["UserID"]
+	userID = strings.TrimSpace(userID)
+	if !ok || userID == "" {
+		return ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
 	}
-	if *createdBy.S != userID {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": Must be the owner of the %s to delete it
-		}`, http.StatusUnauthorized, dataType)
 
+	dataType, id := deleteDataType(request)
+	if dataType == "" || id == "" {
+		return ErrorResponse(http.StatusBadRequest, fmt.Sprintf("One of the path parameters %s is required", strings.Join(validPathParams, ", "))), nil
+	}
+
+	// The table kinds are the plural of the data type, ex) challenge is stored in challenges
+	tableRegion, tableName, err := GetTableFor(dataType + "s")
+	if err != nil {
 		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusUnauthorized,
-			Body:       errBody,
-		}, nil
+			StatusCode: http.StatusInternalServerError,
+		}, err
 	}
 
-	deleteItemInput := &dynamodb.DeleteItemInput{
+	db := GetDB(tableRegion)
+
+	item, returnCode, err := getOwnedItem(db, tableName, dataType, id, userID, "restore")
+	if err != nil {
+		return ErrorResponse(returnCode, err.Error()), nil
+	}
+	if !IsDeleted(item) {
+		return ErrorResponse(http.StatusBadRequest, fmt.Sprintf("The %s isn't deleted", dataType)), nil
+	}
+	// DynamoDB removes expired items within a couple of days, so the expiry is checked rather than relying on the TTL
+	if expiresAt := item[softDeleteTTLAttribute]; expiresAt != nil && expiresAt.N != nil {
+		expiry, err := strconv.ParseInt(*expiresAt.N, 10, 64)
+		if err == nil && time.Now().Unix() >= expiry {
+			return ErrorResponse(http.StatusGone, fmt.Sprintf("The %s can't be restored, it was deleted more than %d days ago", dataType, SoftDeleteGraceDays)), nil
+		}
+	}
+	taken, err := nameTaken(db, tableName, dataType, id, item)
+	if err != nil {
+		return ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if taken {
+		return ErrorResponse(http.StatusConflict, fmt.Sprintf("The %s can't be restored, another %s is named %s", dataType, dataType, *item["Name"].S)), nil
+	}
+
+	updateItemInput := &dynamodb.UpdateItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]*dynamodb.AttributeValue{
 			"Id": {S: aws.String(id)},
 		},
+		UpdateExpression:    aws.String(fmt.Sprintf("REMOVE DeletedAt, %s", softDeleteTTLAttribute)),
+		ConditionExpression: aws.String("attribute_exists(DeletedAt)"),
 	}
-	_, err = db.DeleteItem(deleteItemInput)
+	_, err = db.UpdateItem(updateItemInput)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "Unable to delete %s: %s"
-		}`, http.StatusInternalServerError, dataType, err.Error())
+		return ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to restore %s: %s", dataType, err)), nil
+	}
 
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusInternalServerError,
-			Body:       errBody,
-		}, nil
-	}
-
-	resBody := fmt.Sprintf(`{
-		"status": %d,
-		"message": "%s deleted"
-	}`, http.StatusOK, dataType)
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Body:       string(resBody),
-	}, nil
+	return JSONResponse(http.StatusOK, errorBody{
+		Status:  http.StatusOK,
+		Message: fmt.Sprintf("The %s was restored", dataType),
+	})
 }
@@ -0,0 +1,285 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// softDeleteDB stores one item and applies DeleteByID's and RestoreByID's updates to it
+// otherItems are returned by the name scan
+type softDeleteDB struct {
+	mockDB
+	item       map[string]*dynamodb.AttributeValue
+	otherItems []map[string]*dynamodb.AttributeValue
+	nameScans  []*dynamodb.ScanInput
+}
+
+func newSoftDeleteDB(item map[string]*dynamodb.AttributeValue, otherItems ...map[string]*dynamodb.AttributeValue) *softDeleteDB {
+	db := &softDeleteDB{item: item, otherItems: otherItems}
+	db.getItem = func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+		return &dynamodb.GetItemOutput{Item: db.item}, nil
+	}
+	db.updateItem = func(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+		if strings.HasPrefix(*input.UpdateExpression, "REMOVE") {
+			delete(db.item, "DeletedAt")
+			delete(db.item, softDeleteTTLAttribute)
+		} else {
+			db.item["DeletedAt"] = input.ExpressionAttributeValues[":deletedAt"]
+			db.item[softDeleteTTLAttribute] = input.ExpressionAttributeValues[":expiresAt"]
+		}
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+	db.scan = func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		db.nameScans = append(db.nameScans, input)
+		return &dynamodb.ScanOutput{Items: db.otherItems}, nil
+	}
+
+	return db
+}
+
+func softDeleteRequest(method, challengeID string, params map[string]string) events.APIGatewayV2HTTPRequest {
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:               map[string]string{"UserID": "u1"},
+		PathParameters:        map[string]string{"challengeId": challengeID},
+		QueryStringParameters: params,
+	}
+	request.RequestContext.HTTP.Method = method
+
+	return request
+}
+
+func challengeToDelete() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":        {S: aws.String("c1")},
+		"Type":      {S: aws.String(ItemTypeChallenge)},
+		"Name":      {S: aws.String("May Miles")},
+		"CreatedBy": {S: aws.String("u1")},
+		"Public":    {BOOL: aws.Bool(true)},
+	}
+}
+
+func TestDeleteThenRestore(t *testing.T) {
+	db := newSoftDeleteDB(challengeToDelete())
+	defer useMockDB(t, db)()
+
+	res, err := DeleteByID(context.Background(), softDeleteRequest(http.MethodDelete, "c1", nil))
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("DeleteByID() = %d %s, %v", res.StatusCode, res.Body, err)
+	}
+	if !IsDeleted(db.item) {
+		t.Fatal("item isn't hidden after a soft delete")
+	}
+	expiresAt, _ := strconv.ParseInt(*db.item[softDeleteTTLAttribute].N, 10, 64)
+	wantExpiry := time.Now().AddDate(0, 0, SoftDeleteGraceDays).Unix()
+	if expiresAt < wantExpiry-60 || expiresAt > wantExpiry {
+		t.Errorf("ExpiresAt = %d, want about %d", expiresAt, wantExpiry)
+	}
+
+	res, err = DeleteByID(context.Background(), softDeleteRequest(http.MethodDelete, "c1", nil))
+	if err != nil || res.StatusCode != http.StatusBadRequest {
+		t.Errorf("deleting again = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+
+	res, err = RestoreByID(context.Background(), softDeleteRequest(http.MethodPost, "c1", nil))
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("RestoreByID() = %d %s, %v", res.StatusCode, res.Body, err)
+	}
+	if IsDeleted(db.item) || db.item[softDeleteTTLAttribute] != nil {
+		t.Errorf("restored item still has DeletedAt or ExpiresAt: %v", db.item)
+	}
+}
+
+func TestHardDelete(t *testing.T) {
+	db := newSoftDeleteDB(challengeToDelete())
+	deleted := false
+	db.deleteItem = func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+		deleted = *input.Key["Id"].S == "c1"
+		return &dynamodb.DeleteItemOutput{}, nil
+	}
+	defer useMockDB(t, db)()
+
+	res, err := DeleteByID(context.Background(), softDeleteRequest(http.MethodDelete, "c1", map[string]string{"hard": "true"}))
+	if err != nil || res.StatusCode != http.StatusOK || !deleted {
+		t.Errorf("hard delete = %d %s, deleted %t", res.StatusCode, res.Body, deleted)
+	}
+}
+
+func TestRestoreByID(t *testing.T) {
+	deletedAt := func(expiresAt time.Time) map[string]*dynamodb.AttributeValue {
+		item := challengeToDelete()
+		item["DeletedAt"] = &dynamodb.AttributeValue{S: aws.String(expiresAt.AddDate(0, 0, -SoftDeleteGraceDays).Format(time.RFC3339))}
+		item[softDeleteTTLAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))}
+		return item
+	}
+	sameName := map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("c2")}, "Name": {S: aws.String("may miles")}}
+
+	tests := []struct {
+		name        string
+		item        map[string]*dynamodb.AttributeValue
+		otherItems  []map[string]*dynamodb.AttributeValue
+		wantStatus  int
+		wantDeleted bool
+	}{
+		{"in grace period", deletedAt(time.Now().Add(time.Hour)), nil, http.StatusOK, false},
+		{"not deleted", challengeToDelete(), nil, http.StatusBadRequest, false},
+		{"ttl expired but not yet removed", deletedAt(time.Now().Add(-time.Hour)), nil, http.StatusGone, true},
+		{"name reused while deleted", deletedAt(time.Now().Add(time.Hour)), []map[string]*dynamodb.AttributeValue{sameName}, http.StatusConflict, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newSoftDeleteDB(tt.item, tt.otherItems...)
+			defer useMockDB(t, db)()
+
+			res, err := RestoreByID(context.Background(), softDeleteRequest(http.MethodPost, "c1", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d, body %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if !json.Valid([]byte(res.Body)) {
+				t.Errorf("body %q isn't JSON", res.Body)
+			}
+			if IsDeleted(db.item) != tt.wantDeleted {
+				t.Errorf("IsDeleted() = %t after restoring, want %t", IsDeleted(db.item), tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestRestoreNameScan(t *testing.T) {
+	item := challengeToDelete()
+	item["DeletedAt"] = &dynamodb.AttributeValue{S: aws.String(time.Now().Format(time.RFC3339))}
+	db := newSoftDeleteDB(item)
+	defer useMockDB(t, db)()
+
+	if _, err := RestoreByID(context.Background(), softDeleteRequest(http.MethodPost, "c1", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.nameScans) != 1 {
+		t.Fatalf("name was checked %d times, want 1", len(db.nameScans))
+	}
+	scan := db.nameScans[0]
+	filter := *scan.FilterExpression
+	for _, want := range []string{"NameKey = :nameKey", "#P = :public", "Id <> :id", NotDeletedFilter} {
+		if !strings.Contains(filter, want) {
+			t.Errorf("FilterExpression %q doesn't contain %q", filter, want)
+		}
+	}
+	if got := *scan.ExpressionAttributeValues[":nameKey"].S; got != "may miles" {
+		t.Errorf(":nameKey = %s, want may miles", got)
+	}
+}
+
+func TestDeleteMessages(t *testing.T) {
+	tests := []struct {
+		name        string
+		param       string
+		noun        string
+		createdBy   string
+		deleted     bool
+		missing     bool
+		wantStatus  int
+		wantMessage string
+	}{
+		{"challenge deleted", "challengeId", "challenge", "u1", false, false, http.StatusOK, "The challenge was deleted, it can be restored until "},
+		{"challenge not owned", "challengeId", "challenge", "u2", false, false, http.StatusUnauthorized, "Must be the owner of the challenge to delete it"},
+		{"challenge missing", "challengeId", "challenge", "u1", false, true, http.StatusBadRequest, "The challenge doesn't exist"},
+		{"program deleted", "programId", "program", "u1", false, false, http.StatusOK, "The program was deleted, it can be restored until "},
+		{"program not owned", "programId", "program", "u2", false, false, http.StatusUnauthorized, "Must be the owner of the program to delete it"},
+		{"program already deleted", "programId", "program", "u1", true, false, http.StatusBadRequest, "The program doesn't exist"},
+		{"recommendation deleted", "recommendationId", "recommendation", "u1", false, false, http.StatusOK, "The recommendation was deleted, it can be restored until "},
+		{"recommendation not owned", "recommendationId", "recommendation", "u2", false, false, http.StatusUnauthorized, "Must be the owner of the recommendation to delete it"},
+		{"recommendation missing", "recommendationId", "recommendation", "u1", false, true, http.StatusBadRequest, "The recommendation doesn't exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := map[string]*dynamodb.AttributeValue{}
+			if !tt.missing {
+				item = map[string]*dynamodb.AttributeValue{
+					"Id":        {S: aws.String("i1")},
+					"Type":      {S: aws.String(tt.noun)},
+					"CreatedBy": {S: aws.String(tt.createdBy)},
+				}
+			}
+			if tt.deleted {
+				item["DeletedAt"] = &dynamodb.AttributeValue{S: aws.String("2024-05-10T00:00:00Z")}
+			}
+			defer useMockDB(t, newSoftDeleteDB(item))()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "u1"},
+				PathParameters: map[string]string{tt.param: "i1"},
+			}
+			res, err := DeleteByID(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			body := errorBody{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+			}
+			if body.Status != tt.wantStatus || !strings.HasPrefix(body.Message, tt.wantMessage) {
+				t.Errorf("body = %+v, want %d %q", body, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestDeleteWrongType(t *testing.T) {
+	tests := []struct {
+		name       string
+		param      string
+		itemType   string
+		wantStatus int
+	}{
+		{"program via the challenge route", "challengeId", ItemTypeProgram, http.StatusBadRequest},
+		{"recommendation via the program route", "programId", ItemTypeRecommendation, http.StatusBadRequest},
+		{"challenge via the challenge route", "challengeId", ItemTypeChallenge, http.StatusOK},
+		{"written before types", "challengeId", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := map[string]*dynamodb.AttributeValue{
+				"Id":        {S: aws.String("i1")},
+				"CreatedBy": {S: aws.String("u1")},
+			}
+			if tt.itemType != "" {
+				item["Type"] = &dynamodb.AttributeValue{S: aws.String(tt.itemType)}
+			}
+			db := newSoftDeleteDB(item)
+			defer useMockDB(t, db)()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "u1"},
+				PathParameters: map[string]string{tt.param: "i1"},
+			}
+			res, err := DeleteByID(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			// An item of another type is left as it was
+			if _, deleted := db.item["DeletedAt"]; deleted != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("deleted = %t, want %t", deleted, tt.wantStatus == http.StatusOK)
+			}
+		})
+	}
+}
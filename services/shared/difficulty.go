@@ -0,0 +1,59 @@
+package shared
+
+import "strings"
+
+// DifficultyTier is a named range of difficulties, ex) beginner
+// a difficulty is in the tier if it's greater than Above and at most AtMost
+// a zero AtMost means the tier has no upper bound
+type DifficultyTier struct {
+	Name   string
+	Above  float32
+	AtMost float32
+}
+
+// Names of the difficulty tiers
+const (
+	TierBeginner     = "beginner"
+	TierIntermediate = "intermediate"
+	TierAdvanced     = "advanced"
+)
+
+// DifficultyTiers are the tiers from easiest to hardest
+// beginner is at most 3, intermediate is above 3 and at most 6, advanced is above 6
+// an unknown difficulty of 0 is beginner
+var DifficultyTiers = []DifficultyTier{
+	{Name: TierBeginner, Above: -1, AtMost: 3},
+	{Name: TierIntermediate, Above: 3, AtMost: 6},
+	{Name: TierAdvanced, Above: 6},
+}
+
+// Contains returns true if difficulty is in the tier
+func (t DifficultyTier) Contains(difficulty float32) bool {
+	if difficulty <= t.Above {
+		return false
+	}
+
+	return t.AtMost == 0 || difficulty <= t.AtMost
+}
+
+// GetDifficultyTier returns the tier with the name, ignoring case
+// false is returned if there is no tier with the name
+func GetDifficultyTier(name string) (DifficultyTier, bool) {
+	for _, t := range DifficultyTiers {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+
+	return DifficultyTier{}, false
+}
+
+// DifficultyTierNames returns the names of the tiers from easiest to hardest
+func DifficultyTierNames() []string {
+	names := []string{}
+	for _, t := range DifficultyTiers {
+		names = append(names, t.Name)
+	}
+
+	return names
+}
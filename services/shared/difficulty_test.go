@@ -0,0 +1,46 @@
+package shared
+
+import "testing"
+
+func TestDifficultyTiers(t *testing.T) {
+	tests := []struct {
+		difficulty float32
+		want       string
+	}{
+		{0, TierBeginner},
+		{3, TierBeginner},
+		{3.01, TierIntermediate},
+		{6, TierIntermediate},
+		{6.01, TierAdvanced},
+		{10, TierAdvanced},
+	}
+
+	for _, tt := range tests {
+		matched := []string{}
+		for _, tier := range DifficultyTiers {
+			if tier.Contains(tt.difficulty) {
+				matched = append(matched, tier.Name)
+			}
+		}
+		if len(matched) != 1 || matched[0] != tt.want {
+			t.Errorf("difficulty %v is in tiers %v, want only %s", tt.difficulty, matched, tt.want)
+		}
+	}
+}
+
+func TestGetDifficultyTier(t *testing.T) {
+	tests := []struct {
+		name   string
+		wantOK bool
+	}{
+		{"beginner", true},
+		{"Advanced", true},
+		{"expert", false},
+	}
+
+	for _, tt := range tests {
+		if _, ok := GetDifficultyTier(tt.name); ok != tt.wantOK {
+			t.Errorf("GetDifficultyTier(%q) ok = %t, want %t", tt.name, ok, tt.wantOK)
+		}
+	}
+}
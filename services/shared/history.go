@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Endpoints:
+//   GET https://api.onepeloton.com/api/user/{userID}/workouts?joins=ride,ride.instructor
+//   GET https://api.onepeloton.com/api/workout/{workoutID}?joins=ride,ride.instructor
+
+// historyPageSize is the number of workouts requested per page of history
+const historyPageSize = 100
+
+// maxHistoryPages caps the number of pages of history fetched in a single request
+const maxHistoryPages = 10
+
+// HistoryWorkout is a workout the user has taken
+type HistoryWorkout struct {
+	ID                string `json:"id"`
+	UserID            string `json:"user_id"`
+	CreatedAt         int64  `json:"created_at"`
+	StartTime         int64  `json:"start_time"`
+	EndTime           int64  `json:"end_time"`
+	FitnessDiscipline string `json:"fitness_discipline"`
+	Status            string `json:"status"`
+	Ride              Ride   `json:"ride"`
+}
+
+type workoutHistoryResponse struct {
+	Data      []HistoryWorkout `json:"data"`
+	Page      int              `json:"page"`
+	PageCount int              `json:"page_count"`
+}
+
+// GetWorkoutHistory returns the user's most recent workouts, newest first
+// at most maxHistoryPages pages of history are fetched
+// if Peloton returns an error, the Peloton response body, status code and error are returned
+func GetWorkoutHistory(userID string, headers map[string]string) ([]HistoryWorkout, []byte, int, error) {
+	workouts := []HistoryWorkout{}
+
+	for page := 0; page < maxHistoryPages; page++ {
+		url := fmt.Sprintf("/api/user/%s/workouts?joins=ride,ride.instructor&sort_by=-created&limit=%d&page=%d", userID, historyPageSize, page)
+
+		body, _, resCode, err := PelotonRequest("GET", url, headers, nil)
+		if err != nil {
+			return nil, body, resCode, err
+		}
+
+		historyRes := &workoutHistoryResponse{}
+		err = json.Unmarshal(body, historyRes)
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to unmarshal response: %s", err)
+		}
+
+		for _, w := range historyRes.Data {
+			// The instructor name is only included on the nested instructor object
+			w.Ride.InstructorName = w.Ride.Instructor.Name
+			workouts = append(workouts, w)
+		}
+
+		if page+1 >= historyRes.PageCount {
+			break
+		}
+	}
+
+	return workouts, nil, http.StatusOK, nil
+}
+
+// GetWorkout returns one of the user's workouts with the class that was taken
+// if Peloton returns an error, the Peloton response body, status code and error are returned
+func GetWorkout(workoutID string, headers map[string]string) (*HistoryWorkout, []byte, int, error) {
+	url := fmt.Sprintf("/api/workout/%s?joins=ride,ride.instructor", workoutID)
+
+	body, _, resCode, err := PelotonRequest("GET", url, headers, nil)
+	if err != nil {
+		return nil, body, resCode, err
+	}
+
+	w := &HistoryWorkout{}
+	err = json.Unmarshal(body, w)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to unmarshal response: %s", err)
+	}
+	// The instructor name is only included on the nested instructor object
+	w.Ride.InstructorName = w.Ride.Instructor.Name
+
+	return w, nil, http.StatusOK, nil
+}
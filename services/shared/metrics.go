@@ -0,0 +1,111 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// metricsNamespace is the CloudWatch namespace all metrics are published to
+const metricsNamespace = "PeloData"
+
+// Metric units supported by CloudWatch
+const (
+	UnitCount        = "Count"
+	UnitMilliseconds = "Milliseconds"
+)
+
+// metricsOutput is where EMF logs are written, Lambda sends stdout to CloudWatch Logs
+var metricsOutput io.Writer = os.Stdout
+
+// Metric is a single CloudWatch metric value
+type Metric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+type emfMetricDefinition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string                `json:"Namespace"`
+	Dimensions [][]string            `json:"Dimensions"`
+	Metrics    []emfMetricDefinition `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// formatEMF builds a CloudWatch Embedded Metric Format log line
+func formatEMF(timestamp time.Time, dimensions map[string]string, metrics []Metric) ([]byte, error) {
+	dimensionKeys := []string{}
+	definitions := []emfMetricDefinition{}
+	doc := map[string]interface{}{}
+
+	for k, v := range dimensions {
+		dimensionKeys = append(dimensionKeys, k)
+		doc[k] = v
+	}
+	for _, m := range metrics {
+		definitions = append(definitions, emfMetricDefinition{Name: m.Name, Unit: m.Unit})
+		doc[m.Name] = m.Value
+	}
+
+	doc["_aws"] = emfMetadata{
+		Timestamp: timestamp.UnixNano() / int64(time.Millisecond),
+		CloudWatchMetrics: []emfDirective{
+			{
+				Namespace:  metricsNamespace,
+				Dimensions: [][]string{dimensionKeys},
+				Metrics:    definitions,
+			},
+		},
+	}
+
+	return json.Marshal(doc)
+}
+
+// EmitMetrics writes the metrics to stdout in CloudWatch Embedded Metric Format
+// CloudWatch extracts them from the Lambda logs, so no extra calls are made
+func EmitMetrics(dimensions map[string]string, metrics ...Metric) {
+	emf, err := formatEMF(time.Now(), dimensions, metrics)
+	if err != nil {
+		log.Printf("Unable to format metrics: %s", err)
+		return
+	}
+
+	fmt.Fprintln(metricsOutput, string(emf))
+}
+
+// WithMetrics emits the request count and duration of each request
+// dimensioned by handler and response status
+func WithMetrics(handlerName string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+			start := time.Now()
+			res, err := next(ctx, request)
+
+			EmitMetrics(map[string]string{
+				"handler": handlerName,
+				"status":  strconv.Itoa(res.StatusCode),
+			},
+				Metric{Name: "RequestCount", Unit: UnitCount, Value: 1},
+				Metric{Name: "Duration", Unit: UnitMilliseconds, Value: float64(time.Since(start)) / float64(time.Millisecond)},
+			)
+
+			return res, err
+		}
+	}
+}
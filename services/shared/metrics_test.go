@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormatEMF(t *testing.T) {
+	timestamp := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	emf, err := formatEMF(timestamp, map[string]string{"handler": "getWorkouts"}, []Metric{
+		{Name: "RequestCount", Unit: UnitCount, Value: 1},
+		{Name: "Duration", Unit: UnitMilliseconds, Value: 12.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := struct {
+		AWS          emfMetadata `json:"_aws"`
+		Handler      string      `json:"handler"`
+		RequestCount float64     `json:"RequestCount"`
+		Duration     float64     `json:"Duration"`
+	}{}
+	if err := json.Unmarshal(emf, &doc); err != nil {
+		t.Fatalf("EMF %s isn't JSON: %s", emf, err)
+	}
+
+	if doc.AWS.Timestamp != timestamp.Unix()*1000 {
+		t.Errorf("Timestamp = %d, want milliseconds since the epoch", doc.AWS.Timestamp)
+	}
+	if len(doc.AWS.CloudWatchMetrics) != 1 {
+		t.Fatalf("CloudWatchMetrics = %+v, want one directive", doc.AWS.CloudWatchMetrics)
+	}
+	directive := doc.AWS.CloudWatchMetrics[0]
+	if directive.Namespace != metricsNamespace {
+		t.Errorf("Namespace = %s, want %s", directive.Namespace, metricsNamespace)
+	}
+	if len(directive.Dimensions) != 1 || len(directive.Dimensions[0]) != 1 || directive.Dimensions[0][0] != "handler" {
+		t.Errorf("Dimensions = %v, want [[handler]]", directive.Dimensions)
+	}
+	if len(directive.Metrics) != 2 || directive.Metrics[0].Name != "RequestCount" || directive.Metrics[1].Unit != UnitMilliseconds {
+		t.Errorf("Metrics = %+v", directive.Metrics)
+	}
+	if doc.Handler != "getWorkouts" || doc.RequestCount != 1 || doc.Duration != 12.5 {
+		t.Errorf("values = %+v", doc)
+	}
+}
@@ -0,0 +1,227 @@
+package shared
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler is a Lambda handler for an API Gateway HTTP request
+type Handler func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps a Handler to add cross-cutting behavior
+type Middleware func(Handler) Handler
+
+type contextKey string
+
+const userIDContextKey contextKey = "userID"
+
+// Chain wraps h with each middleware. The first middleware is the outermost
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h
+}
+
+// WithRecovery converts a panic in the handler into a 500 JSON response
+func WithRecovery(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (res events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Recovered from panic handling request %s: %v\n%s", request.RequestContext.RequestID, r, debug.Stack())
+
+				res, err = JSONResponse(http.StatusInternalServerError, struct {
+					Status    int    `json:"status"`
+					Message   string `json:"message"`
+					RequestID string `json:"requestId"`
+				}{
+					Status:    http.StatusInternalServerError,
+					Message:   "internal error",
+					RequestID: request.RequestContext.RequestID,
+				})
+			}
+		}()
+
+		return next(ctx, request)
+	}
+}
+
+// WithLogging logs the method, path, status and duration of each request
+func WithLogging(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		res, err := next(ctx, request)
+
+		log.Printf("requestId=%s method=%s path=%s status=%d duration=%s",
+			request.RequestContext.RequestID, request.RequestContext.HTTP.Method, request.RawPath, res.StatusCode, time.Since(start))
+		if err != nil {
+			log.Printf("requestId=%s error=%s", request.RequestContext.RequestID, err)
+		}
+
+		return res, err
+	}
+}
+
+// WithUserID requires the UserID header and adds it to the context
+// use UserIDFromContext to get the UserID in the handler
+func WithUserID(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		userID, ok := request.Headers["UserID"]
+		userID = strings.TrimSpace(userID)
+		if !ok || userID == "" {
+			return ErrorResponse(http.StatusBadRequest, "UserID header is required"), nil
+		}
+
+		return next(context.WithValue(ctx, userIDContextKey, userID), request)
+	}
+}
+
+// UserIDFromContext returns the UserID added by WithUserID
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDContextKey).(string)
+	return userID
+}
+
+// GetHeader returns the value of the request header, ignoring the case of name
+func GetHeader(headers map[string]string, name string) (string, bool) {
+	if val, ok := headers[name]; ok {
+		return val, true
+	}
+	for key, val := range headers {
+		if strings.EqualFold(key, name) {
+			return val, true
+		}
+	}
+
+	return "", false
+}
+
+// WithRequestID sets the X-Request-Id response header
+// an inbound X-Request-Id is echoed back, otherwise the API Gateway request ID is used
+func WithRequestID(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		requestID, _ := GetHeader(request.Headers, "X-Request-Id")
+		requestID = strings.TrimSpace(requestID)
+		if requestID == "" {
+			requestID = request.RequestContext.RequestID
+		}
+
+		res, err := next(ctx, request)
+		if res.Headers == nil {
+			res.Headers = map[string]string{}
+		}
+		res.Headers["X-Request-Id"] = requestID
+
+		return res, err
+	}
+}
+
+// WithJSONBody rejects requests with a Content-Type other than application/json
+// a missing Content-Type is accepted
+func WithJSONBody(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		contentType, _ := GetHeader(request.Headers, "Content-Type")
+		contentType = strings.TrimSpace(contentType)
+		if contentType != "" {
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || mediaType != "application/json" {
+				return ErrorResponse(http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type must be application/json, got %s", contentType)), nil
+			}
+		}
+
+		return next(ctx, request)
+	}
+}
+
+// WithMaxBodySize rejects request bodies larger than MaxBodyBytes with a 413
+// the decoded size is used for base64 encoded bodies
+func WithMaxBodySize(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		size := len(request.Body)
+		if request.IsBase64Encoded {
+			size = base64.StdEncoding.DecodedLen(size)
+		}
+		if max := MaxBodyBytes(); size > max {
+			return ErrorResponse(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body is %d bytes, it must not be larger than %d bytes", size, max)), nil
+		}
+
+		return next(ctx, request)
+	}
+}
+
+// CORS headers sent with preflight responses and added to every other response
+// Access-Control-Allow-Origin is set by corsHeaders from the cors_allowed_origin env var
+const (
+	corsAllowMethods  = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, UserID, X-Request-Id, If-None-Match, If-Modified-Since"
+	corsExposeHeaders = "ETag, Last-Modified, Location, X-Request-Id"
+	corsMaxAge        = "600"
+)
+
+// corsHeaders returns the CORS headers of a response
+// env cors_allowed_origin restricts the allowed origin, otherwise any origin is allowed
+func corsHeaders() map[string]string {
+	origin := strings.TrimSpace(os.Getenv("cors_allowed_origin"))
+	if origin == "" {
+		origin = "*"
+	}
+
+	return map[string]string{
+		"Access-Control-Allow-Origin":   origin,
+		"Access-Control-Allow-Methods":  corsAllowMethods,
+		"Access-Control-Allow-Headers":  corsAllowHeaders,
+		"Access-Control-Expose-Headers": corsExposeHeaders,
+		"Access-Control-Max-Age":        corsMaxAge,
+	}
+}
+
+// WithCORS answers OPTIONS preflight requests with a 204 and no body without calling the handler
+// and adds the CORS headers to every other response
+// it must come before middleware that validates headers or bodies, since preflight requests have neither
+func WithCORS(next Handler) Handler {
+	return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		if strings.EqualFold(request.RequestContext.HTTP.Method, http.MethodOptions) {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusNoContent,
+				Headers:    corsHeaders(),
+			}, nil
+		}
+
+		res, err := next(ctx, request)
+		if res.Headers == nil {
+			res.Headers = map[string]string{}
+		}
+		for key, val := range corsHeaders() {
+			res.Headers[key] = val
+		}
+
+		return res, err
+	}
+}
+
+// RequireMethod returns a middleware that rejects requests whose method isn't method with a 405 and an Allow header
+// it must come after WithCORS so preflight OPTIONS requests are still answered
+func RequireMethod(method string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+			if !strings.EqualFold(request.RequestContext.HTTP.Method, method) {
+				res := ErrorResponse(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s is not allowed, use %s", request.RequestContext.HTTP.Method, method))
+				res.Headers["Allow"] = fmt.Sprintf("%s, %s", method, http.MethodOptions)
+				return res, nil
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package shared
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func okHandler(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	return JSONResponse(http.StatusOK, map[string]string{"userId": UserIDFromContext(ctx)})
+}
+
+func requestWithMethod(method string) events.APIGatewayV2HTTPRequest {
+	request := events.APIGatewayV2HTTPRequest{Headers: map[string]string{}}
+	request.RequestContext.HTTP.Method = method
+	request.RequestContext.RequestID = "gateway-id"
+
+	return request
+}
+
+func TestChainOrder(t *testing.T) {
+	order := []string{}
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+				order = append(order, name)
+				return next(ctx, request)
+			}
+		}
+	}
+
+	h := Chain(okHandler, mark("outer"), mark("inner"))
+	h(context.Background(), requestWithMethod(http.MethodGet))
+
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Errorf("middleware ran in order %v, want outer then inner", order)
+	}
+}
+
+func TestWithRecovery(t *testing.T) {
+	panicking := func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		panic("boom")
+	}
+
+	res, err := WithRecovery(panicking)(context.Background(), requestWithMethod(http.MethodGet))
+	if err != nil {
+		t.Fatalf("error = %s, want nil", err)
+	}
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %d, want 500", res.StatusCode)
+	}
+	body := struct {
+		Status    int    `json:"status"`
+		RequestID string `json:"requestId"`
+	}{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+	}
+	if body.Status != http.StatusInternalServerError || body.RequestID != "gateway-id" {
+		t.Errorf("unexpected body %+v", body)
+	}
+}
+
+func TestWithLogging(t *testing.T) {
+	failing := func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, errors.New("boom")
+	}
+
+	tests := []struct {
+		name       string
+		handler    Handler
+		wantStatus int
+		wantErr    bool
+	}{
+		{"success", okHandler, http.StatusOK, false},
+		{"error", failing, http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := WithLogging(tt.handler)(context.Background(), requestWithMethod(http.MethodGet))
+			if res.StatusCode != tt.wantStatus || (err != nil) != tt.wantErr {
+				t.Errorf("WithLogging() = %d, %v, want the handler's %d and error %t", res.StatusCode, err, tt.wantStatus, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithUserID(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		wantUserID string
+	}{
+		{"missing", map[string]string{}, http.StatusBadRequest, ""},
+		{"blank", map[string]string{"UserID": "  "}, http.StatusBadRequest, ""},
+		{"trimmed", map[string]string{"UserID": " user1 "}, http.StatusOK, "user1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := requestWithMethod(http.MethodGet)
+			request.Headers = tt.headers
+
+			res, _ := WithUserID(okHandler)(context.Background(), request)
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(res.Body, tt.wantUserID) {
+				t.Errorf("body %s doesn't have user id %s", res.Body, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestGetHeader(t *testing.T) {
+	headers := map[string]string{"x-timezone": "America/New_York"}
+
+	if val, ok := GetHeader(headers, "X-Timezone"); !ok || val != "America/New_York" {
+		t.Errorf("GetHeader() = %q, %t, want America/New_York, true", val, ok)
+	}
+	if _, ok := GetHeader(headers, "X-Request-Id"); ok {
+		t.Error("GetHeader() found a missing header")
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"echoes inbound id", map[string]string{"x-request-id": "client-id"}, "client-id"},
+		{"falls back to the gateway id", map[string]string{}, "gateway-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := requestWithMethod(http.MethodGet)
+			request.Headers = tt.headers
+
+			res, _ := WithRequestID(okHandler)(context.Background(), request)
+			if got := res.Headers["X-Request-Id"]; got != tt.want {
+				t.Errorf("X-Request-Id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithJSONBody(t *testing.T) {
+	tests := []struct {
+		contentType string
+		wantStatus  int
+	}{
+		{"", http.StatusOK},
+		{"application/json", http.StatusOK},
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"text/xml", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			request := requestWithMethod(http.MethodPost)
+			if tt.contentType != "" {
+				request.Headers["content-type"] = tt.contentType
+			}
+
+			res, _ := WithJSONBody(okHandler)(context.Background(), request)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	defer setEnv(t, "max_body_bytes", "10")()
+
+	tests := []struct {
+		name       string
+		body       string
+		base64     bool
+		wantStatus int
+	}{
+		{"at the limit", strings.Repeat("a", 10), false, http.StatusOK},
+		{"over the limit", strings.Repeat("a", 11), false, http.StatusRequestEntityTooLarge},
+		{"base64 decoded size is used", strings.Repeat("a", 12), true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := requestWithMethod(http.MethodPost)
+			request.Body = tt.body
+			request.IsBase64Encoded = tt.base64
+
+			res, _ := WithMaxBodySize(okHandler)(context.Background(), request)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	called := false
+	h := WithCORS(func(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+		called = true
+		return okHandler(ctx, request)
+	})
+
+	res, _ := h(context.Background(), requestWithMethod(http.MethodOptions))
+	if called {
+		t.Error("handler was called for a preflight request")
+	}
+	if res.StatusCode != http.StatusNoContent || res.Body != "" {
+		t.Errorf("preflight response = %d %q, want 204 with no body", res.StatusCode, res.Body)
+	}
+	if res.Headers["Access-Control-Allow-Origin"] != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", res.Headers["Access-Control-Allow-Origin"])
+	}
+
+	res, _ = h(context.Background(), requestWithMethod(http.MethodGet))
+	if !called || res.StatusCode != http.StatusOK {
+		t.Errorf("GET response = %d, want the handler's 200", res.StatusCode)
+	}
+	if res.Headers["Access-Control-Allow-Methods"] == "" {
+		t.Error("CORS headers weren't added to the handler's response")
+	}
+}
+
+func TestRequireMethod(t *testing.T) {
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		{http.MethodPost, http.StatusOK},
+		{"post", http.StatusOK},
+		{http.MethodGet, http.StatusMethodNotAllowed},
+		{http.MethodDelete, http.StatusMethodNotAllowed},
+		// A misconfigured route may not send the method at all
+		{"", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			res, _ := RequireMethod(http.MethodPost)(okHandler)(context.Background(), requestWithMethod(tt.method))
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusMethodNotAllowed {
+				return
+			}
+			if got := res.Headers["Allow"]; got != "POST, OPTIONS" {
+				t.Errorf("Allow = %q, want POST, OPTIONS", got)
+			}
+			if body := decodeErrorBody(t, res); body.Status != http.StatusMethodNotAllowed {
+				t.Errorf("body status = %d, want 405", body.Status)
+			}
+		})
+	}
+}
+
+func TestRequireMethodAfterCORS(t *testing.T) {
+	h := Chain(okHandler, WithCORS, RequireMethod(http.MethodGet))
+
+	tests := []struct {
+		method     string
+		wantStatus int
+	}{
+		// Preflight requests are answered by WithCORS before the method is checked
+		{http.MethodOptions, http.StatusNoContent},
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			res, _ := h(context.Background(), requestWithMethod(tt.method))
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if res.Headers["Access-Control-Allow-Origin"] == "" {
+				t.Error("Access-Control-Allow-Origin isn't set")
+			}
+		})
+	}
+}
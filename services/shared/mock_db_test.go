@@ -0,0 +1,132 @@
+package shared
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDB is a DynamoDB client that calls the func set for each method
+// calling a method without a func panics on the nil embedded interface
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	scan               func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	query              func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	getItem            func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	putItem            func(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	updateItem         func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	deleteItem         func(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
+	batchGetItem       func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	transactWriteItems func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return m.scan(input)
+}
+
+func (m *mockDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	return m.query(input)
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return m.getItem(input)
+}
+
+func (m *mockDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return m.putItem(input)
+}
+
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	return m.updateItem(input)
+}
+
+func (m *mockDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	return m.deleteItem(input)
+}
+
+func (m *mockDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+	return m.batchGetItem(input)
+}
+
+func (m *mockDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	return m.transactWriteItems(input)
+}
+
+// scanPages returns a scan func serving one page per call, each page but the last has a LastEvaluatedKey
+// the ExclusiveStartKey of each call is appended to startKeys, "" for the first page
+func scanPages(startKeys *[]string, pages ...[]map[string]*dynamodb.AttributeValue) func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	call := 0
+	return func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+		startKey := ""
+		if input.ExclusiveStartKey != nil {
+			startKey = aws.StringValue(input.ExclusiveStartKey["Id"].S)
+		}
+		*startKeys = append(*startKeys, startKey)
+
+		output := &dynamodb.ScanOutput{Items: pages[call]}
+		call++
+		if call < len(pages) {
+			output.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("page" + strconv.Itoa(call))}}
+		}
+
+		return output, nil
+	}
+}
+
+// idItems returns an item with only an Id for each id
+func idItems(ids ...string) []map[string]*dynamodb.AttributeValue {
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, id := range ids {
+		items = append(items, map[string]*dynamodb.AttributeValue{"Id": {S: aws.String(id)}})
+	}
+
+	return items
+}
+
+// itemIDs returns the Id of each item
+func itemIDs(items []map[string]*dynamodb.AttributeValue) []string {
+	ids := []string{}
+	for _, i := range items {
+		ids = append(ids, aws.StringValue(i["Id"].S))
+	}
+
+	return ids
+}
+
+// useMockDB makes GetDB return db and configures a single table named pelodata
+// the returned func restores the real client and env
+func useMockDB(t *testing.T, db dynamodbiface.DynamoDBAPI) func() {
+	newDB := NewDB
+	NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	restoreRegion := setEnv(t, "table_region", "us-east-1")
+	restoreName := setEnv(t, "table_name", "pelodata")
+
+	return func() {
+		NewDB = newDB
+		restoreRegion()
+		restoreName()
+	}
+}
+
+// setEnv sets an env var for a test, the returned func restores its previous value
+func setEnv(t *testing.T, key, value string) func() {
+	t.Helper()
+	prev, existed := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if existed {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
@@ -0,0 +1,615 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Participation is a user's participation in a challenge
+// it is keyed on a derived Id of challengeId#userId so a user can only join a challenge once
+type Participation struct {
+	ChallengeID         string   `json:"challengeId"`
+	UserID              string   `json:"userId"`
+	JoinedDate          string   `json:"joinedDate"`
+	CompletedWorkouts   int      `json:"completedWorkouts"`
+	CompletedMinutes    int      `json:"completedMinutes"`
+	CompletedWorkoutIDs []string `json:"completedWorkoutIds"`
+	// LoggedWorkouts are the counted workouts in the order they were recorded
+	// workouts counted before they were logged are only in CompletedWorkoutIDs
+	LoggedWorkouts []LoggedWorkout `json:"loggedWorkouts"`
+	// IsCompleted is set once the user reaches the challenge's goal, see CompleteIfGoalMet
+	IsCompleted bool   `json:"isCompleted"`
+	CompletedAt string `json:"completedAt,omitempty"`
+}
+
+// LoggedWorkout is a workout counted towards a challenge, stored in the participation record's LoggedWorkouts list
+type LoggedWorkout struct {
+	WorkoutID string `json:"workoutId" dynamodbav:"WorkoutId"`
+	// Discipline is used to evaluate sub-goals, it may be empty if the client didn't send it
+	Discipline  string `json:"discipline" dynamodbav:"Discipline"`
+	Minutes     int    `json:"minutes" dynamodbav:"Minutes"`
+	CompletedAt string `json:"completedAt" dynamodbav:"CompletedAt"`
+}
+
+// ParticipantProgress is a summary of a user's progress in a challenge
+type ParticipantProgress struct {
+	Joined            bool `json:"joined"`
+	CompletedWorkouts int  `json:"completedWorkouts"`
+	// PercentComplete is progress towards the challenge's goal in the unit of its GoalType, capped at 100
+	PercentComplete float64 `json:"percentComplete"`
+}
+
+// ErrWorkoutAlreadyCounted is returned when a workout was already counted towards a challenge
+var ErrWorkoutAlreadyCounted = errors.New("workout has already been counted for this challenge")
+
+// ErrNotParticipating is returned when counting a workout towards a challenge the user hasn't joined
+var ErrNotParticipating = errors.New("must join the challenge first")
+
+// GetParticipantsTableName returns the table of users' participation in challenges
+// from the participants_table_name env var
+func GetParticipantsTableName() (string, error) {
+	tableName, exists := os.LookupEnv("participants_table_name")
+	if !exists || tableName == "" {
+		return "", fmt.Errorf("participants_table_name %w", ErrNotConfigured)
+	}
+
+	return tableName, nil
+}
+
+// ParticipationID returns the Id of a user's participation record for a challenge
+func ParticipationID(challengeID, userID string) string {
+	return fmt.Sprintf("%s#%s", challengeID, userID)
+}
+
+// FormatParticipation converts a DynamoDB item to a Participation
+func FormatParticipation(item map[string]*dynamodb.AttributeValue) (Participation, error) {
+	p := Participation{
+		CompletedWorkoutIDs: []string{},
+		LoggedWorkouts:      []LoggedWorkout{},
+	}
+	var err error
+
+	if item["ChallengeId"] != nil && item["ChallengeId"].S != nil {
+		p.ChallengeID = *item["ChallengeId"].S
+	}
+	if item["UserId"] != nil && item["UserId"].S != nil {
+		p.UserID = *item["UserId"].S
+	}
+	if item["JoinedDate"] != nil && item["JoinedDate"].S != nil {
+		p.JoinedDate = *item["JoinedDate"].S
+	}
+	if item["CompletedWorkouts"] != nil && item["CompletedWorkouts"].N != nil {
+		p.CompletedWorkouts, err = strconv.Atoi(*item["CompletedWorkouts"].N)
+		if err != nil {
+			return Participation{}, fmt.Errorf("Unable to convert CompletedWorkouts to int: %s", err)
+		}
+	}
+	if item["CompletedMinutes"] != nil && item["CompletedMinutes"].N != nil {
+		p.CompletedMinutes, err = strconv.Atoi(*item["CompletedMinutes"].N)
+		if err != nil {
+			return Participation{}, fmt.Errorf("Unable to convert CompletedMinutes to int: %s", err)
+		}
+	}
+	if item["CompletedWorkoutIDs"] != nil && item["CompletedWorkoutIDs"].SS != nil {
+		for _, id := range item["CompletedWorkoutIDs"].SS {
+			p.CompletedWorkoutIDs = append(p.CompletedWorkoutIDs, *id)
+		}
+	}
+	if item["Completed"] != nil && item["Completed"].BOOL != nil {
+		p.IsCompleted = *item["Completed"].BOOL
+	}
+	if item["CompletedAt"] != nil && item["CompletedAt"].S != nil {
+		p.CompletedAt = *item["CompletedAt"].S
+	}
+	if item["LoggedWorkouts"] != nil && item["LoggedWorkouts"].L != nil {
+		err = dynamodbattribute.Unmarshal(item["LoggedWorkouts"], &p.LoggedWorkouts)
+		if err != nil {
+			return Participation{}, fmt.Errorf("Unable to unmarshal LoggedWorkouts: %s", err)
+		}
+	}
+
+	return p, nil
+}
+
+// GetParticipation returns the user's participation in a challenge
+// false is returned if the user hasn't joined the challenge
+func GetParticipation(db dynamodbiface.DynamoDBAPI, tableName, challengeID, userID string) (Participation, bool, error) {
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(ParticipationID(challengeID, userID))},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return Participation{}, false, fmt.Errorf("Unable to get participation: %s", err)
+	}
+	if len(getItemOutput.Item) == 0 {
+		return Participation{}, false, nil
+	}
+
+	p, err := FormatParticipation(getItemOutput.Item)
+	return p, err == nil, err
+}
+
+// ErrChallengeNotFound is returned when joining a challenge that doesn't exist
+var ErrChallengeNotFound = errors.New("challenge doesn't exist")
+
+// cancellationCodes returns the reason each item of a cancelled transaction failed
+// nil is returned if err isn't a cancelled transaction
+func cancellationCodes(err error) []string {
+	tce, ok := err.(*dynamodb.TransactionCanceledException)
+	if !ok {
+		return nil
+	}
+	codes := []string{}
+	for _, r := range tce.CancellationReasons {
+		codes = append(codes, aws.StringValue(r.Code))
+	}
+
+	return codes
+}
+
+// conditionFailed returns true if the item at idx of a cancelled transaction failed its condition
+func conditionFailed(codes []string, idx int) bool {
+	return idx < len(codes) && codes[idx] == "ConditionalCheckFailed"
+}
+
+// JoinChallenge creates the user's participation record for a challenge and increments the challenge's ParticipantCount
+// both writes are made in one transaction so the count can't drift from the participation records
+// joining is idempotent, if the user already joined the existing record is returned with created set to false
+// and the count isn't incremented again
+func JoinChallenge(db dynamodbiface.DynamoDBAPI, tableName, challengesTableName, challengeID, userID string) (Participation, bool, error) {
+	p := Participation{
+		ChallengeID:         challengeID,
+		UserID:              userID,
+		JoinedDate:          time.Now().Format(time.RFC3339),
+		CompletedWorkoutIDs: []string{},
+		LoggedWorkouts:      []LoggedWorkout{},
+	}
+
+	transactInput := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(tableName),
+					Item: map[string]*dynamodb.AttributeValue{
+						"Id":                {S: aws.String(ParticipationID(challengeID, userID))},
+						"ChallengeId":       {S: aws.String(challengeID)},
+						"UserId":            {S: aws.String(userID)},
+						"JoinedDate":        {S: aws.String(p.JoinedDate)},
+						"CompletedWorkouts": {N: aws.String("0")},
+						"CompletedMinutes":  {N: aws.String("0")},
+					},
+					// A retried join must not reset the user's progress or be counted twice
+					ConditionExpression: aws.String("attribute_not_exists(Id)"),
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(challengesTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"Id": {S: aws.String(challengeID)},
+					},
+					UpdateExpression:    aws.String("ADD ParticipantCount :one"),
+					ConditionExpression: aws.String("attribute_exists(Id)"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":one": {N: aws.String("1")},
+					},
+				},
+			},
+		},
+	}
+	_, err := db.TransactWriteItems(transactInput)
+	if err == nil {
+		return p, true, nil
+	}
+	codes := cancellationCodes(err)
+	if conditionFailed(codes, 1) {
+		return Participation{}, false, ErrChallengeNotFound
+	}
+	if !conditionFailed(codes, 0) {
+		return Participation{}, false, fmt.Errorf("Unable to join challenge: %s", err)
+	}
+
+	existing, _, err := GetParticipation(db, tableName, challengeID, userID)
+	if err != nil {
+		return Participation{}, false, err
+	}
+
+	return existing, false, nil
+}
+
+// LeaveChallenge deletes the user's participation record for a challenge and decrements the challenge's ParticipantCount
+// both writes are made in one transaction, false is returned if the user hadn't joined the challenge
+// so a retried leave isn't counted twice
+func LeaveChallenge(db dynamodbiface.DynamoDBAPI, tableName, challengesTableName, challengeID, userID string) (bool, error) {
+	transactInput := &dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(tableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"Id": {S: aws.String(ParticipationID(challengeID, userID))},
+					},
+					ConditionExpression: aws.String("attribute_exists(Id)"),
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(challengesTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"Id": {S: aws.String(challengeID)},
+					},
+					UpdateExpression: aws.String("ADD ParticipantCount :minusOne"),
+					// Participants that joined before the count existed aren't in it
+					ConditionExpression: aws.String("attribute_exists(Id) and ParticipantCount > :zero"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":minusOne": {N: aws.String("-1")},
+						":zero":     {N: aws.String("0")},
+					},
+				},
+			},
+		},
+	}
+	_, err := db.TransactWriteItems(transactInput)
+	if err == nil {
+		return true, nil
+	}
+	codes := cancellationCodes(err)
+	if conditionFailed(codes, 0) {
+		return false, nil
+	}
+	if !conditionFailed(codes, 1) {
+		return false, fmt.Errorf("Unable to leave challenge: %s", err)
+	}
+
+	// The count is already 0 or the challenge was deleted, so only remove the participation record
+	_, err = db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(ParticipationID(challengeID, userID))},
+		},
+		ConditionExpression: aws.String("attribute_exists(Id)"),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, fmt.Errorf("Unable to leave challenge: %s", err)
+	}
+
+	return true, nil
+}
+
+// RecordCompletedWorkout counts a completed workout towards the user's progress in a challenge
+// the workout is appended to LoggedWorkouts and the counters are incremented in the same update
+// the workout is only counted once, ErrWorkoutAlreadyCounted is returned if it was already counted
+// the user must have joined the challenge, ErrNotParticipating is returned otherwise
+func RecordCompletedWorkout(db dynamodbiface.DynamoDBAPI, tableName, challengeID, userID string, w LoggedWorkout) error {
+	logged, err := dynamodbattribute.MarshalMap(w)
+	if err != nil {
+		return fmt.Errorf("Unable to marshal logged workout: %s", err)
+	}
+
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(ParticipationID(challengeID, userID))},
+		},
+		// ADD and the condition are applied atomically, so a workout submitted twice at once is only counted once
+		UpdateExpression: aws.String("SET UpdatedDate = :now, " +
+			"LoggedWorkouts = list_append(if_not_exists(LoggedWorkouts, :empty), :logged) " +
+			"ADD CompletedWorkoutIDs :workoutIds, CompletedWorkouts :one, CompletedMinutes :minutes"),
+		// The participation must exist so a workout can't be counted for a user that hasn't joined
+		ConditionExpression: aws.String("attribute_exists(Id) and (attribute_not_exists(CompletedWorkoutIDs) or not contains(CompletedWorkoutIDs, :workoutId))"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now":        {S: aws.String(time.Now().Format(time.RFC3339))},
+			":empty":      {L: []*dynamodb.AttributeValue{}},
+			":logged":     {L: []*dynamodb.AttributeValue{{M: logged}}},
+			":workoutIds": {SS: aws.StringSlice([]string{w.WorkoutID})},
+			":workoutId":  {S: aws.String(w.WorkoutID)},
+			":one":        {N: aws.String("1")},
+			":minutes":    {N: aws.String(strconv.Itoa(w.Minutes))},
+		},
+	}
+	_, err = db.UpdateItem(updateInput)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			// The condition doesn't say which part failed, so check whether the user has joined
+			_, joined, err := GetParticipation(db, tableName, challengeID, userID)
+			if err != nil {
+				return err
+			}
+			if !joined {
+				return ErrNotParticipating
+			}
+			return ErrWorkoutAlreadyCounted
+		}
+		return fmt.Errorf("Unable to record completed workout: %s", err)
+	}
+
+	return nil
+}
+
+// GetUserParticipations returns every challenge the user has joined
+// the GSI named by the participants_user_index_name env var, partitioned on UserId, is queried if it's set
+// otherwise the participants table is scanned
+func GetUserParticipations(db dynamodbiface.DynamoDBAPI, tableName, userID string) ([]Participation, error) {
+	participations := []Participation{}
+	values := map[string]*dynamodb.AttributeValue{
+		":userId": {S: aws.String(userID)},
+	}
+
+	var startKey map[string]*dynamodb.AttributeValue
+	indexName := os.Getenv("participants_user_index_name")
+	for {
+		var items []map[string]*dynamodb.AttributeValue
+		var lastKey map[string]*dynamodb.AttributeValue
+		if indexName != "" {
+			queryOutput, err := db.Query(&dynamodb.QueryInput{
+				TableName:                 aws.String(tableName),
+				IndexName:                 aws.String(indexName),
+				KeyConditionExpression:    aws.String("UserId = :userId"),
+				ExpressionAttributeValues: values,
+				ExclusiveStartKey:         startKey,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Unable to get participation: %s", err)
+			}
+			items, lastKey = queryOutput.Items, queryOutput.LastEvaluatedKey
+		} else {
+			scanOutput, err := db.Scan(&dynamodb.ScanInput{
+				TableName:                 aws.String(tableName),
+				FilterExpression:          aws.String("UserId = :userId"),
+				ExpressionAttributeValues: values,
+				ExclusiveStartKey:         startKey,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Unable to get participation: %s", err)
+			}
+			items, lastKey = scanOutput.Items, scanOutput.LastEvaluatedKey
+		}
+
+		for _, i := range items {
+			p, err := FormatParticipation(i)
+			if err != nil {
+				return nil, err
+			}
+			participations = append(participations, p)
+		}
+
+		if len(lastKey) == 0 {
+			break
+		}
+		startKey = lastKey
+	}
+
+	return participations, nil
+}
+
+// Progress returns the participant's progress towards the challenge's goal
+func (p Participation) Progress(c Challenge) ParticipantProgress {
+	progress := ParticipantProgress{
+		Joined:            true,
+		CompletedWorkouts: p.CompletedWorkouts,
+	}
+
+	completed := p.Completed(c)
+	if c.GoalValue > 0 {
+		progress.PercentComplete = math.Min(100, math.Round(float64(completed)/float64(c.GoalValue)*1000)/10)
+	}
+
+	return progress
+}
+
+// BatchGetParticipations returns the user's participation in each of the challenges, keyed on challenge id
+// challenges the user hasn't joined are left out of the map
+func BatchGetParticipations(db dynamodbiface.DynamoDBAPI, tableName string, challengeIDs []string, userID string) (map[string]Participation, error) {
+	ids := []string{}
+	for _, cid := range challengeIDs {
+		ids = append(ids, ParticipationID(cid, userID))
+	}
+	items, err := BatchGetItems(db, tableName, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get participation: %s", err)
+	}
+
+	participations := map[string]Participation{}
+	for _, item := range items {
+		p, err := FormatParticipation(item)
+		if err != nil {
+			return nil, err
+		}
+		participations[p.ChallengeID] = p
+	}
+
+	return participations, nil
+}
+
+// SubGoalProgress is the participant's progress towards one of the challenge's sub-goals
+type SubGoalProgress struct {
+	SubGoal
+	// Completed is in the unit of the challenge's GoalType
+	Completed int  `json:"completed"`
+	Met       bool `json:"met"`
+}
+
+// ProgressSummary is the participant's full progress in a challenge
+type ProgressSummary struct {
+	ChallengeID string `json:"challengeId"`
+	GoalType    string `json:"goalType"`
+	Goal        int    `json:"goal"`
+	// Completed is in the unit of GoalType
+	Completed         int     `json:"completed"`
+	CompletedWorkouts int     `json:"completedWorkouts"`
+	CompletedMinutes  int     `json:"completedMinutes"`
+	PercentComplete   float64 `json:"percentComplete"`
+	GoalMet           bool    `json:"goalMet"`
+	// DaysRemaining is nil if the challenge's dates are invalid
+	DaysRemaining  *int              `json:"daysRemaining"`
+	SubGoals       []SubGoalProgress `json:"subGoals"`
+	SubGoalsMet    bool              `json:"subGoalsMet"`
+	LoggedWorkouts []LoggedWorkout   `json:"loggedWorkouts"`
+	// IsCompleted is whether the completion of the challenge was recorded
+	IsCompleted bool   `json:"isCompleted"`
+	CompletedAt string `json:"completedAt,omitempty"`
+}
+
+// Completed returns the participant's progress in the unit of the challenge's GoalType
+func (p Participation) Completed(c Challenge) int {
+	if c.GoalType == GoalTypeMinutes {
+		return p.CompletedMinutes
+	}
+
+	return p.CompletedWorkouts
+}
+
+// SubGoalProgress returns the participant's progress towards each of the challenge's sub-goals
+// sub-goals are evaluated from LoggedWorkouts, workouts logged without a discipline don't count towards any sub-goal
+func (p Participation) SubGoalProgress(c Challenge) []SubGoalProgress {
+	progress := []SubGoalProgress{}
+	for _, sg := range c.SubGoals {
+		completed := 0
+		for _, w := range p.LoggedWorkouts {
+			if !strings.EqualFold(w.Discipline, sg.WorkoutType) {
+				continue
+			}
+			if c.GoalType == GoalTypeMinutes {
+				completed += w.Minutes
+			} else {
+				completed++
+			}
+		}
+		progress = append(progress, SubGoalProgress{
+			SubGoal:   sg,
+			Completed: completed,
+			Met:       completed >= sg.Count,
+		})
+	}
+
+	return progress
+}
+
+// Summary returns the participant's full progress in the challenge
+// the challenge's computed fields must already be set, ex) by FormatChallenge
+func (p Participation) Summary(c Challenge) ProgressSummary {
+	summary := ProgressSummary{
+		ChallengeID:       c.ID,
+		GoalType:          c.GoalType,
+		Goal:              c.GoalValue,
+		Completed:         p.Completed(c),
+		CompletedWorkouts: p.CompletedWorkouts,
+		CompletedMinutes:  p.CompletedMinutes,
+		PercentComplete:   p.Progress(c).PercentComplete,
+		DaysRemaining:     c.DaysRemaining,
+		SubGoals:          p.SubGoalProgress(c),
+		SubGoalsMet:       true,
+		LoggedWorkouts:    p.LoggedWorkouts,
+		IsCompleted:       p.IsCompleted,
+		CompletedAt:       p.CompletedAt,
+	}
+	summary.GoalMet = summary.Completed >= summary.Goal
+	for _, sg := range summary.SubGoals {
+		if !sg.Met {
+			summary.SubGoalsMet = false
+			break
+		}
+	}
+	if summary.LoggedWorkouts == nil {
+		summary.LoggedWorkouts = []LoggedWorkout{}
+	}
+
+	return summary
+}
+
+// ListParticipants returns a page of at most limit participants of a challenge, starting after the participation
+// with the Id startAfter. The Id of the last participation is returned if there may be more participants
+// the GSI named by the participants_challenge_index_name env var, partitioned on ChallengeId, is queried if it's set
+// otherwise the participants table is scanned
+func ListParticipants(db dynamodbiface.DynamoDBAPI, tableName, challengeID string, limit int, startAfter string) ([]Participation, string, error) {
+	participants := []Participation{}
+	values := map[string]*dynamodb.AttributeValue{
+		":challengeId": {S: aws.String(challengeID)},
+	}
+
+	indexName := os.Getenv("participants_challenge_index_name")
+	var startKey map[string]*dynamodb.AttributeValue
+	if startAfter != "" {
+		startKey = map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(startAfter)},
+		}
+		// The start key of a GSI query also has the index's key
+		if indexName != "" {
+			startKey["ChallengeId"] = &dynamodb.AttributeValue{S: aws.String(challengeID)}
+		}
+	}
+
+	if indexName == "" {
+		items, lastKey, err := ScanPage(db, &dynamodb.ScanInput{
+			TableName:                 aws.String(tableName),
+			FilterExpression:          aws.String("ChallengeId = :challengeId"),
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int64(int64(limit)),
+			ExclusiveStartKey:         startKey,
+		}, limit)
+		if err != nil {
+			return nil, "", fmt.Errorf("Unable to get participants: %s", err)
+		}
+		for _, i := range items {
+			p, err := FormatParticipation(i)
+			if err != nil {
+				return nil, "", err
+			}
+			participants = append(participants, p)
+		}
+		if len(lastKey) == 0 {
+			return participants, "", nil
+		}
+
+		return participants, aws.StringValue(lastKey["Id"].S), nil
+	}
+
+	// Limit is the number of items still needed for the page, so the query never reads past the page
+	for {
+		queryOutput, err := db.Query(&dynamodb.QueryInput{
+			TableName:                 aws.String(tableName),
+			IndexName:                 aws.String(indexName),
+			KeyConditionExpression:    aws.String("ChallengeId = :challengeId"),
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int64(int64(limit - len(participants))),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("Unable to get participants: %s", err)
+		}
+
+		for _, i := range queryOutput.Items {
+			p, err := FormatParticipation(i)
+			if err != nil {
+				return nil, "", err
+			}
+			participants = append(participants, p)
+		}
+
+		if len(queryOutput.LastEvaluatedKey) == 0 {
+			return participants, "", nil
+		}
+		if len(participants) == limit {
+			return participants, aws.StringValue(queryOutput.LastEvaluatedKey["Id"].S), nil
+		}
+		startKey = queryOutput.LastEvaluatedKey
+	}
+}
@@ -0,0 +1,401 @@
+package shared
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// participationStore keeps a challenge's ParticipantCount and the participation records in memory
+// its transactWriteItems applies the conditions of JoinChallenge and LeaveChallenge like DynamoDB
+type participationStore struct {
+	// count is nil until the challenge's ParticipantCount is first updated
+	count        *int
+	participants map[string]bool
+}
+
+func (s *participationStore) db() *mockDB {
+	return &mockDB{
+		transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			record, update := input.TransactItems[0], input.TransactItems[1].Update
+			reasons := []*dynamodb.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}}
+			cancelled := false
+			if record.Put != nil && s.participants[*record.Put.Item["Id"].S] {
+				reasons[0].Code = aws.String("ConditionalCheckFailed")
+				cancelled = true
+			}
+			if record.Delete != nil && !s.participants[*record.Delete.Key["Id"].S] {
+				reasons[0].Code = aws.String("ConditionalCheckFailed")
+				cancelled = true
+			}
+			if record.Delete != nil && (s.count == nil || *s.count <= 0) {
+				reasons[1].Code = aws.String("ConditionalCheckFailed")
+				cancelled = true
+			}
+			if cancelled {
+				return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+			}
+
+			if s.count == nil {
+				s.count = aws.Int(0)
+			}
+			increment := update.ExpressionAttributeValues[":one"]
+			if record.Delete != nil {
+				increment = update.ExpressionAttributeValues[":minusOne"]
+				delete(s.participants, *record.Delete.Key["Id"].S)
+			} else {
+				s.participants[*record.Put.Item["Id"].S] = true
+			}
+			delta, _ := strconv.Atoi(*increment.N)
+			*s.count += delta
+
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+		getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			if !s.participants[*input.Key["Id"].S] {
+				return &dynamodb.GetItemOutput{}, nil
+			}
+			return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{"Id": input.Key["Id"]}}, nil
+		},
+		deleteItem: func(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+			delete(s.participants, *input.Key["Id"].S)
+			return &dynamodb.DeleteItemOutput{}, nil
+		},
+	}
+}
+
+func TestParticipantCount(t *testing.T) {
+	// legacy joined before ParticipantCount existed, so it isn't in the count
+	store := &participationStore{participants: map[string]bool{ParticipationID("c1", "legacy"): true}}
+	db := store.db()
+
+	steps := []struct {
+		name      string
+		join      bool
+		userID    string
+		wantOK    bool
+		wantCount int
+	}{
+		{"join", true, "u1", true, 1},
+		{"retried join", true, "u1", false, 1},
+		{"second user joins", true, "u2", true, 2},
+		{"leave", false, "u1", true, 1},
+		{"retried leave", false, "u1", false, 1},
+		{"last counted user leaves", false, "u2", true, 0},
+		{"uncounted user leaves", false, "legacy", true, 0},
+	}
+
+	for _, step := range steps {
+		var ok bool
+		var err error
+		if step.join {
+			_, ok, err = JoinChallenge(db, "participants", "challenges", "c1", step.userID)
+		} else {
+			ok, err = LeaveChallenge(db, "participants", "challenges", "c1", step.userID)
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", step.name, err)
+		}
+		if ok != step.wantOK {
+			t.Errorf("%s: changed = %t, want %t", step.name, ok, step.wantOK)
+		}
+		if aws.IntValue(store.count) != step.wantCount {
+			t.Errorf("%s: ParticipantCount = %d, want %d", step.name, aws.IntValue(store.count), step.wantCount)
+		}
+	}
+	if len(store.participants) != 0 {
+		t.Errorf("participation records left: %v", store.participants)
+	}
+
+	// The count is returned on the challenge, defaulting to 0 when it isn't tracked
+	for _, count := range []*int{store.count, nil, aws.Int(5)} {
+		item := map[string]*dynamodb.AttributeValue{"Id": {S: aws.String("c1")}}
+		if count != nil {
+			item["ParticipantCount"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(*count))}
+		}
+		c, err := FormatChallenge(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.ParticipantCount != aws.IntValue(count) {
+			t.Errorf("ParticipantCount = %d, want %d", c.ParticipantCount, aws.IntValue(count))
+		}
+	}
+}
+
+func TestParticipationProgress(t *testing.T) {
+	p := Participation{CompletedWorkouts: 3, CompletedMinutes: 90}
+
+	tests := []struct {
+		name          string
+		challenge     Challenge
+		wantCompleted int
+		wantPercent   float64
+	}{
+		{"workouts goal", Challenge{GoalType: GoalTypeWorkouts, GoalValue: 12}, 3, 25},
+		{"minutes goal", Challenge{GoalType: GoalTypeMinutes, GoalValue: 270}, 90, 33.3},
+		{"capped at 100", Challenge{GoalType: GoalTypeWorkouts, GoalValue: 2}, 3, 100},
+		{"no goal", Challenge{GoalType: GoalTypeWorkouts}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Completed(tt.challenge); got != tt.wantCompleted {
+				t.Errorf("Completed() = %d, want %d", got, tt.wantCompleted)
+			}
+			if got := p.Progress(tt.challenge).PercentComplete; got != tt.wantPercent {
+				t.Errorf("PercentComplete = %v, want %v", got, tt.wantPercent)
+			}
+		})
+	}
+}
+
+func TestParticipationSummary(t *testing.T) {
+	c := Challenge{
+		ID:        "c1",
+		GoalType:  GoalTypeMinutes,
+		GoalValue: 60,
+		SubGoals: []SubGoal{
+			{WorkoutType: "cycling", Count: 30},
+			{WorkoutType: "strength", Count: 20},
+		},
+	}
+	p := Participation{
+		CompletedWorkouts: 3,
+		CompletedMinutes:  65,
+		LoggedWorkouts: []LoggedWorkout{
+			{WorkoutID: "r1", Discipline: "cycling", Minutes: 45},
+			{WorkoutID: "r2", Discipline: "Strength", Minutes: 10},
+			{WorkoutID: "r3", Minutes: 10},
+		},
+	}
+
+	summary := p.Summary(c)
+	if summary.Completed != 65 || !summary.GoalMet {
+		t.Errorf("Completed = %d, GoalMet = %t, want 65 and true", summary.Completed, summary.GoalMet)
+	}
+	want := []SubGoalProgress{
+		{SubGoal: c.SubGoals[0], Completed: 45, Met: true},
+		{SubGoal: c.SubGoals[1], Completed: 10, Met: false},
+	}
+	if !reflect.DeepEqual(summary.SubGoals, want) {
+		t.Errorf("SubGoals = %+v, want %+v", summary.SubGoals, want)
+	}
+	if summary.SubGoalsMet {
+		t.Error("SubGoalsMet is true with an unmet sub-goal")
+	}
+}
+
+func TestFormatParticipation(t *testing.T) {
+	logged, err := dynamodbattribute.Marshal([]LoggedWorkout{{WorkoutID: "r1", Discipline: "cycling", Minutes: 20, CompletedAt: "2024-05-10T12:00:00Z"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		item map[string]*dynamodb.AttributeValue
+		want Participation
+	}{
+		{
+			"empty item",
+			map[string]*dynamodb.AttributeValue{},
+			Participation{CompletedWorkoutIDs: []string{}, LoggedWorkouts: []LoggedWorkout{}},
+		},
+		{
+			"full item",
+			map[string]*dynamodb.AttributeValue{
+				"ChallengeId":         {S: aws.String("c1")},
+				"UserId":              {S: aws.String("u1")},
+				"JoinedDate":          {S: aws.String("2024-05-01T00:00:00Z")},
+				"CompletedWorkouts":   {N: aws.String("1")},
+				"CompletedMinutes":    {N: aws.String("20")},
+				"CompletedWorkoutIDs": {SS: aws.StringSlice([]string{"r1"})},
+				"LoggedWorkouts":      logged,
+				"Completed":           {BOOL: aws.Bool(true)},
+				"CompletedAt":         {S: aws.String("2024-05-10T12:00:00Z")},
+			},
+			Participation{
+				ChallengeID:         "c1",
+				UserID:              "u1",
+				JoinedDate:          "2024-05-01T00:00:00Z",
+				CompletedWorkouts:   1,
+				CompletedMinutes:    20,
+				CompletedWorkoutIDs: []string{"r1"},
+				LoggedWorkouts:      []LoggedWorkout{{WorkoutID: "r1", Discipline: "cycling", Minutes: 20, CompletedAt: "2024-05-10T12:00:00Z"}},
+				IsCompleted:         true,
+				CompletedAt:         "2024-05-10T12:00:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatParticipation(tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FormatParticipation() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// completionStore keeps the participation records and completions in memory
+// its transactWriteItems applies CompleteIfGoalMet's conditions atomically, like DynamoDB
+type completionStore struct {
+	mu          sync.Mutex
+	completed   map[string]bool
+	completions map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (s *completionStore) db() *mockDB {
+	return &mockDB{
+		transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			update, put := input.TransactItems[0].Update, input.TransactItems[1].Put
+			reasons := []*dynamodb.CancellationReason{{Code: aws.String("None")}, {Code: aws.String("None")}}
+			cancelled := false
+			if completed, ok := s.completed[*update.Key["Id"].S]; !ok || completed {
+				reasons[0].Code = aws.String("ConditionalCheckFailed")
+				cancelled = true
+			}
+			if _, ok := s.completions[*put.Item["Id"].S]; ok {
+				reasons[1].Code = aws.String("ConditionalCheckFailed")
+				cancelled = true
+			}
+			if cancelled {
+				return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+			}
+
+			s.completed[*update.Key["Id"].S] = true
+			s.completions[*put.Item["Id"].S] = put.Item
+
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+}
+
+func TestCompleteIfGoalMet(t *testing.T) {
+	c := Challenge{ID: "c1", GoalType: GoalTypeWorkouts, GoalValue: 3}
+
+	tests := []struct {
+		name          string
+		p             Participation
+		stored        map[string]bool
+		wantCompleted bool
+		wantRecords   int
+	}{
+		{"goal not met", Participation{UserID: "u1", CompletedWorkouts: 2}, map[string]bool{ParticipationID("c1", "u1"): false}, false, 0},
+		{"goal met", Participation{UserID: "u1", CompletedWorkouts: 3}, map[string]bool{ParticipationID("c1", "u1"): false}, true, 1},
+		{"goal exceeded", Participation{UserID: "u1", CompletedWorkouts: 5}, map[string]bool{ParticipationID("c1", "u1"): false}, true, 1},
+		{"already completed", Participation{UserID: "u1", CompletedWorkouts: 4, IsCompleted: true}, map[string]bool{ParticipationID("c1", "u1"): true}, false, 0},
+		// Another update completed it after p was read
+		{"completed since read", Participation{UserID: "u1", CompletedWorkouts: 4}, map[string]bool{ParticipationID("c1", "u1"): true}, false, 0},
+		{"left the challenge", Participation{UserID: "u1", CompletedWorkouts: 3}, map[string]bool{}, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &completionStore{completed: tt.stored, completions: map[string]map[string]*dynamodb.AttributeValue{}}
+
+			completed, err := CompleteIfGoalMet(store.db(), "participants", "completions", c, tt.p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if completed != tt.wantCompleted {
+				t.Errorf("CompleteIfGoalMet() = %t, want %t", completed, tt.wantCompleted)
+			}
+			if len(store.completions) != tt.wantRecords {
+				t.Fatalf("%d completion records, want %d", len(store.completions), tt.wantRecords)
+			}
+			if tt.wantRecords == 0 {
+				return
+			}
+
+			record, err := FormatCompletion(store.completions[ParticipationID("c1", "u1")])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if record.ChallengeID != "c1" || record.UserID != "u1" || record.FinalCount != tt.p.CompletedWorkouts || record.CompletedAt == "" {
+				t.Errorf("completion = %+v", record)
+			}
+			// The tier is stamped so the badge doesn't change if ComputeBadgeTier does
+			if record.BadgeTier != ComputeBadgeTier(c) {
+				t.Errorf("BadgeTier = %q, want %q", record.BadgeTier, ComputeBadgeTier(c))
+			}
+		})
+	}
+}
+
+func TestCompleteIfGoalMetRace(t *testing.T) {
+	c := Challenge{ID: "c1", GoalType: GoalTypeWorkouts, GoalValue: 3}
+	store := &completionStore{
+		completed:   map[string]bool{ParticipationID("c1", "u1"): false},
+		completions: map[string]map[string]*dynamodb.AttributeValue{},
+	}
+	db := store.db()
+
+	// Every update read the participation before any of them completed it
+	const updates = 10
+	results := make(chan bool, updates)
+	errs := make(chan error, updates)
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			completed, err := CompleteIfGoalMet(db, "participants", "completions", c, Participation{UserID: "u1", CompletedWorkouts: 3 + i})
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- completed
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	completedCount := 0
+	for completed := range results {
+		if completed {
+			completedCount++
+		}
+	}
+	if completedCount != 1 {
+		t.Errorf("%d updates completed the challenge, want 1", completedCount)
+	}
+	if len(store.completions) != 1 {
+		t.Errorf("%d completion records, want 1", len(store.completions))
+	}
+}
+
+func TestFormatCompletion(t *testing.T) {
+	c, err := FormatCompletion(map[string]*dynamodb.AttributeValue{
+		"ChallengeId": {S: aws.String("c1")},
+		"UserId":      {S: aws.String("u1")},
+		"CompletedAt": {S: aws.String("2024-05-10T12:00:00Z")},
+		"FinalCount":  {N: aws.String("12")},
+		"BadgeTier":   {S: aws.String(BadgeGold)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Completion{ChallengeID: "c1", UserID: "u1", CompletedAt: "2024-05-10T12:00:00Z", FinalCount: 12, BadgeTier: BadgeGold}
+	if c != want {
+		t.Errorf("FormatCompletion() = %+v, want %+v", c, want)
+	}
+}
@@ -0,0 +1,112 @@
+package shared
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// Program is a custom program created by a user
+type Program struct {
+	ID              string      `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	Public          bool        `json:"public"`
+	EquipmentNeeded []string    `json:"equipmentNeeded"`
+	NumWeeks        int         `json:"numWeeks"`
+	Workouts        [][]Workout `json:"workouts"`
+	CreatedBy       string      `json:"createdBy"`
+	CreatedDate     string      `json:"createdDate"`
+	UpdatedDate     string      `json:"updatedDate"`
+	// ComputedDifficulty is the average difficulty of the program's workouts, see ComputeDifficulty
+	ComputedDifficulty float32 `json:"computedDifficulty"`
+	// IsOwner is computed from the caller's UserID, it isn't stored
+	IsOwner bool `json:"isOwner"`
+}
+
+// ComputeDifficulty returns the average difficulty of the workouts across all weeks, rounded to 2 decimal places
+// workouts with an unknown difficulty of 0 are ignored, 0 is returned if no workout has a difficulty
+func ComputeDifficulty(workouts [][]Workout) float32 {
+	var total float64
+	count := 0
+	for _, week := range workouts {
+		for _, w := range week {
+			if w.Difficulty <= 0 {
+				continue
+			}
+			total += float64(w.Difficulty)
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+
+	return float32(math.Round(total/float64(count)*100) / 100)
+}
+
+// programItem is read with dynamodbattribute, the dynamodbav tags are the attribute names
+// the Workouts blob is decoded separately so it's only read when needed
+type programItem struct {
+	ID              string   `dynamodbav:"Id"`
+	Name            string   `dynamodbav:"Name"`
+	Description     string   `dynamodbav:"Description"`
+	Public          bool     `dynamodbav:"Public"`
+	EquipmentNeeded []string `dynamodbav:"EquipmentNeeded"`
+	NumWeeks        int      `dynamodbav:"NumWeeks"`
+	CreatedBy       string   `dynamodbav:"CreatedBy"`
+	CreatedDate     string   `dynamodbav:"CreatedDate"`
+	UpdatedDate     string   `dynamodbav:"UpdatedDate"`
+	// ComputedDifficulty is nil for programs written before it was stored
+	ComputedDifficulty *float32 `dynamodbav:"ComputedDifficulty"`
+}
+
+// ProgramSummaryProjection is a ProjectionExpression of every attribute FormatProgram reads except the Workouts blob
+// Name and Public are reserved words, so #N must be bound to Name and #P to Public
+const ProgramSummaryProjection = "Id, #N, Description, #P, EquipmentNeeded, NumWeeks, CreatedBy, CreatedDate, UpdatedDate, ComputedDifficulty"
+
+// FormatProgram converts a DynamoDB item to a Program
+// the Workouts blob is only decoded if includeWorkouts is true, so programs written before ComputedDifficulty
+// was stored only have a difficulty when their workouts are included
+// every attribute is optional, a missing Workouts blob is returned as no workouts
+func FormatProgram(item map[string]*dynamodb.AttributeValue, includeWorkouts bool) (Program, error) {
+	pi := programItem{}
+	err := dynamodbattribute.UnmarshalMap(item, &pi)
+	if err != nil {
+		return Program{}, fmt.Errorf("Unable to unmarshal program: %s", err)
+	}
+
+	program := Program{
+		ID:          pi.ID,
+		Name:        pi.Name,
+		Description: pi.Description,
+		Public:      pi.Public,
+		// EquipmentNeeded isn't stored when empty
+		EquipmentNeeded: []string{},
+		NumWeeks:        pi.NumWeeks,
+		CreatedBy:       pi.CreatedBy,
+		CreatedDate:     pi.CreatedDate,
+		UpdatedDate:     pi.UpdatedDate,
+	}
+	program.EquipmentNeeded = append(program.EquipmentNeeded, pi.EquipmentNeeded...)
+	if pi.ComputedDifficulty != nil {
+		program.ComputedDifficulty = *pi.ComputedDifficulty
+	}
+	if !includeWorkouts {
+		return program, nil
+	}
+
+	workouts := [][]Workout{}
+	err = unmarshalJSONBlob(item["Workouts"], &workouts)
+	if err != nil {
+		return Program{}, err
+	}
+	if pi.ComputedDifficulty == nil {
+		program.ComputedDifficulty = ComputeDifficulty(workouts)
+	}
+	program.Workouts = workouts
+
+	return program, nil
+}
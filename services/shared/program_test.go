@@ -0,0 +1,163 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestComputeDifficulty(t *testing.T) {
+	tests := []struct {
+		name     string
+		workouts [][]Workout
+		want     float32
+	}{
+		{"no workouts", nil, 0},
+		{"only unknown difficulties", [][]Workout{{{Difficulty: 0}}}, 0},
+		{"single week", [][]Workout{{{Difficulty: 4}, {Difficulty: 6}}}, 5},
+		{"zero difficulties are excluded", [][]Workout{{{Difficulty: 4}, {Difficulty: 0}}, {{Difficulty: 8}}}, 6},
+		{"rounded to 2 decimal places", [][]Workout{{{Difficulty: 1}, {Difficulty: 1}, {Difficulty: 2}}}, 1.33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeDifficulty(tt.workouts); got != tt.want {
+				t.Errorf("ComputeDifficulty() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func fullProgramItem() map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"Id":                 {S: aws.String("p1")},
+		"Type":               {S: aws.String(ItemTypeProgram)},
+		"Name":               {S: aws.String("Base Building")},
+		"Description":        {S: aws.String("4 weeks of endurance")},
+		"Public":             {BOOL: aws.Bool(true)},
+		"EquipmentNeeded":    {SS: aws.StringSlice([]string{"bike"})},
+		"NumWeeks":           {N: aws.String("1")},
+		"Workouts":           {B: []byte(`[[{"id":"w1","difficulty_estimate":4},{"id":"w2","difficulty_estimate":6}]]`)},
+		"CreatedBy":          {S: aws.String("owner")},
+		"CreatedDate":        {S: aws.String("2024-05-01T00:00:00Z")},
+		"UpdatedDate":        {S: aws.String("2024-05-02T00:00:00Z")},
+		"ComputedDifficulty": {N: aws.String("5")},
+	}
+}
+
+func TestFormatProgram(t *testing.T) {
+	p, err := FormatProgram(fullProgramItem(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID != "p1" || p.Name != "Base Building" || !p.Public || p.NumWeeks != 1 || p.CreatedBy != "owner" {
+		t.Errorf("unexpected program %+v", p)
+	}
+	if len(p.Workouts) != 1 || len(p.Workouts[0]) != 2 || p.Workouts[0][1].ID != "w2" {
+		t.Errorf("Workouts = %+v", p.Workouts)
+	}
+	if p.ComputedDifficulty != 5 {
+		t.Errorf("ComputedDifficulty = %v, want 5", p.ComputedDifficulty)
+	}
+}
+
+func TestFormatProgramMissingAttributes(t *testing.T) {
+	for attr := range fullProgramItem() {
+		t.Run(attr, func(t *testing.T) {
+			item := fullProgramItem()
+			delete(item, attr)
+
+			p, err := FormatProgram(item, true)
+			if err != nil {
+				t.Fatalf("FormatProgram() error = %s", err)
+			}
+			if p.EquipmentNeeded == nil || p.Workouts == nil {
+				t.Errorf("missing lists should be empty, got %+v", p)
+			}
+		})
+	}
+}
+
+func TestFormatProgramWorkouts(t *testing.T) {
+	legacy := fullProgramItem()
+	legacy["Workouts"] = &dynamodb.AttributeValue{S: aws.String(`[[{"id":"w1","difficulty_estimate":4}]]`)}
+	delete(legacy, "ComputedDifficulty")
+
+	undecodable := fullProgramItem()
+	undecodable["Workouts"] = &dynamodb.AttributeValue{B: []byte("not json")}
+
+	undecodableLegacy := fullProgramItem()
+	undecodableLegacy["Workouts"] = &dynamodb.AttributeValue{B: []byte("not json")}
+	delete(undecodableLegacy, "ComputedDifficulty")
+
+	tests := []struct {
+		name            string
+		item            map[string]*dynamodb.AttributeValue
+		includeWorkouts bool
+		wantWorkouts    [][]Workout
+		wantDifficulty  float32
+		wantErr         bool
+	}{
+		{"legacy string blob", legacy, true, [][]Workout{{{ID: "w1", Difficulty: 4}}}, 4, false},
+		{"legacy blob isn't decoded when not requested", legacy, false, nil, 0, false},
+		{"blob isn't decoded when not requested", undecodable, false, nil, 5, false},
+		{"legacy blob isn't decoded for the difficulty", undecodableLegacy, false, nil, 0, false},
+		{"invalid blob is an error when requested", undecodable, true, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := FormatProgram(tt.item, tt.includeWorkouts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatProgram() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(p.Workouts, tt.wantWorkouts) {
+				t.Errorf("Workouts = %+v, want %+v", p.Workouts, tt.wantWorkouts)
+			}
+			if p.ComputedDifficulty != tt.wantDifficulty {
+				t.Errorf("ComputedDifficulty = %v, want %v", p.ComputedDifficulty, tt.wantDifficulty)
+			}
+		})
+	}
+}
+
+func TestFormatProgramComputedDifficulty(t *testing.T) {
+	tests := []struct {
+		name            string
+		stored          bool
+		includeWorkouts bool
+		want            float32
+	}{
+		{"stored", true, false, 7},
+		{"stored with workouts", true, true, 7},
+		{"legacy program", false, false, 0},
+		{"legacy program with workouts", false, true, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := fullProgramItem()
+			// The stored value differs from the workouts' average of 5 so it's clear which one is returned
+			// the 0 difficulty workout is excluded from the average
+			item["ComputedDifficulty"] = &dynamodb.AttributeValue{N: aws.String("7")}
+			item["Workouts"] = &dynamodb.AttributeValue{B: []byte(`[[{"id":"w1","difficulty_estimate":4},{"id":"w2","difficulty_estimate":0}],[{"id":"w3","difficulty_estimate":6}]]`)}
+			if !tt.stored {
+				delete(item, "ComputedDifficulty")
+			}
+
+			p, err := FormatProgram(item, tt.includeWorkouts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ComputedDifficulty != tt.want {
+				t.Errorf("ComputedDifficulty = %v, want %v", p.ComputedDifficulty, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package shared
+
+import (
+	"errors"
+	"strings"
+)
+
+// Recommendation types used to filter recommendations
+const (
+	// RecTypeForMe is recommendations made for the user
+	RecTypeForMe = "forme"
+	// RecTypeByMe is recommendations made by the user
+	RecTypeByMe = "byme"
+	// RecTypeAll is recommendations made for or by the user
+	RecTypeAll = "all"
+)
+
+// What a recommendation recommends
+// recommendations with a ProgramId attribute are program recommendations, otherwise they're workout recommendations
+const (
+	RecommendationWorkout = "workout"
+	RecommendationProgram = "program"
+)
+
+// ParseRecType normalizes and validates a recommendation type
+// an empty recType defaults to RecTypeForMe
+func ParseRecType(recType string) (string, error) {
+	recType = strings.ToLower(strings.TrimSpace(recType))
+
+	switch recType {
+	case "":
+		return RecTypeForMe, nil
+	case RecTypeForMe, RecTypeByMe, RecTypeAll:
+		return recType, nil
+	default:
+		return "", errors.New("type must be forMe, byMe, or all")
+	}
+}
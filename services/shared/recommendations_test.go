@@ -0,0 +1,26 @@
+package shared
+
+import "testing"
+
+func TestParseRecType(t *testing.T) {
+	tests := []struct {
+		recType string
+		want    string
+		wantErr bool
+	}{
+		{"", RecTypeForMe, false},
+		{"forMe", RecTypeForMe, false},
+		{" BYME ", RecTypeByMe, false},
+		{"All", RecTypeAll, false},
+		{"everyone", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.recType, func(t *testing.T) {
+			got, err := ParseRecType(tt.recType)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseRecType() = %q, %v, want %q, wantErr %t", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
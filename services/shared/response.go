@@ -0,0 +1,144 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// errorBody is also used for success responses that only have a message
+type errorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// JSONResponse marshals body and returns it as a JSON response
+func JSONResponse(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+	reply, err := json.Marshal(body)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(reply),
+	}, nil
+}
+
+// ConditionalResponse returns body as a 200 JSON response with ETag and Last-Modified headers
+// or a 304 with no body if the request's If-None-Match or If-Modified-Since shows the client already has it
+// If-Modified-Since is ignored when If-None-Match is sent, a zero lastModified omits Last-Modified
+func ConditionalResponse(request events.APIGatewayV2HTTPRequest, body interface{}, lastModified time.Time) (events.APIGatewayProxyResponse, error) {
+	res, err := JSONResponse(http.StatusOK, body)
+	if err != nil {
+		return res, err
+	}
+
+	// The ETag covers the whole body, so removed items and changed counts also change it
+	hash := sha256.Sum256([]byte(res.Body))
+	etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16]))
+	res.Headers["ETag"] = etag
+	lastModified = lastModified.UTC().Truncate(time.Second)
+	if !lastModified.IsZero() {
+		res.Headers["Last-Modified"] = lastModified.Format(http.TimeFormat)
+	}
+
+	notModified := false
+	if ifNoneMatch, _ := GetHeader(request.Headers, "If-None-Match"); ifNoneMatch != "" {
+		for _, t := range strings.Split(ifNoneMatch, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == etag || t == "*" {
+				notModified = true
+				break
+			}
+		}
+	} else if ifModifiedSince, _ := GetHeader(request.Headers, "If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ifModifiedSince)
+		notModified = err == nil && !lastModified.After(since)
+	}
+	if notModified {
+		res.StatusCode = http.StatusNotModified
+		res.Body = ""
+		delete(res.Headers, "Content-Type")
+	}
+
+	return res, nil
+}
+
+// ResourceLocation returns the path of a resource, ex) /challenges/{id}
+// the path is prefixed with the api_base_path env var if it is set
+func ResourceLocation(resource, id string) string {
+	basePath := strings.TrimRight(os.Getenv("api_base_path"), "/")
+	return fmt.Sprintf("%s/%s/%s", basePath, resource, id)
+}
+
+// CreatedResponse marshals body and returns it as a 201 JSON response
+// with a Location header pointing at the created resource
+func CreatedResponse(resource, id string, body interface{}) (events.APIGatewayProxyResponse, error) {
+	res, err := JSONResponse(http.StatusCreated, body)
+	if err != nil {
+		return res, err
+	}
+	res.Headers["Location"] = ResourceLocation(resource, id)
+
+	return res, nil
+}
+
+// ErrorResponse returns a JSON response in the form of {"status": statusCode, "message": message}
+func ErrorResponse(statusCode int, message string) events.APIGatewayProxyResponse {
+	// errorBody can always be marshaled
+	res, _ := JSONResponse(statusCode, errorBody{
+		Status:  statusCode,
+		Message: message,
+	})
+
+	return res
+}
+
+// UpstreamErrorMessage returns Peloton's error message when the Peloton response body contains one
+// otherwise the error's message is returned
+func UpstreamErrorMessage(body []byte, err error) string {
+	pelotonErr := struct {
+		Message string `json:"message"`
+	}{}
+	if body != nil && json.Unmarshal(body, &pelotonErr) == nil && pelotonErr.Message != "" {
+		return pelotonErr.Message
+	}
+
+	return err.Error()
+}
+
+// UpstreamErrorResponse converts an error from PelotonRequest into a JSON error response
+// the status is mapped with UpstreamStatus
+func UpstreamErrorResponse(resCode int, body []byte, err error) events.APIGatewayProxyResponse {
+	return ErrorResponse(UpstreamStatus(resCode, err), UpstreamErrorMessage(body, err))
+}
+
+// ValidationErrorResponse returns a 400 JSON response listing every validation error
+// message joins the errors for clients that only read a single message
+func ValidationErrorResponse(errs []FieldError) events.APIGatewayProxyResponse {
+	// the body can always be marshaled
+	res, _ := JSONResponse(http.StatusBadRequest, struct {
+		Status  int          `json:"status"`
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}{
+		Status:  http.StatusBadRequest,
+		Message: FieldErrorsMessage(errs),
+		Errors:  errs,
+	})
+
+	return res
+}
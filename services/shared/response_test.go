@@ -0,0 +1,199 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func decodeErrorBody(t *testing.T, res events.APIGatewayProxyResponse) errorBody {
+	t.Helper()
+	body := errorBody{}
+	if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+		t.Fatalf("body %q isn't JSON: %s", res.Body, err)
+	}
+
+	return body
+}
+
+func TestCreatedResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		want     string
+	}{
+		{"no base path", "", "/challenges/c1"},
+		{"base path", "/prod/", "/prod/challenges/c1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer setEnv(t, "api_base_path", tt.basePath)()
+
+			res, err := CreatedResponse("challenges", "c1", map[string]string{"id": "c1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusCreated {
+				t.Errorf("StatusCode = %d, want 201", res.StatusCode)
+			}
+			if res.Headers["Location"] != tt.want {
+				t.Errorf("Location = %q, want %q", res.Headers["Location"], tt.want)
+			}
+			if res.Body != `{"id":"c1"}` {
+				t.Errorf("Body = %s", res.Body)
+			}
+		})
+	}
+}
+
+func TestUpstreamErrorResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		resCode     int
+		body        []byte
+		err         error
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			"client error is passed through with Peloton's message",
+			http.StatusNotFound, []byte(`{"message":"Ride not found"}`),
+			&UpstreamError{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+			http.StatusNotFound, "Ride not found",
+		},
+		{
+			"server error is a bad gateway",
+			http.StatusServiceUnavailable, nil,
+			&UpstreamError{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+			http.StatusBadGateway, "Error communicating with Peloton: 503 Service Unavailable",
+		},
+		{
+			"timeout is a gateway timeout",
+			http.StatusInternalServerError, nil, ErrTimeout,
+			http.StatusGatewayTimeout, ErrTimeout.Error(),
+		},
+		{
+			"other errors keep their status",
+			http.StatusInternalServerError, nil, errors.New("Unable to unmarshal response"),
+			http.StatusInternalServerError, "Unable to unmarshal response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := UpstreamErrorResponse(tt.resCode, tt.body, tt.err)
+			body := decodeErrorBody(t, res)
+			if res.StatusCode != tt.wantStatus || body.Message != tt.wantMessage {
+				t.Errorf("response = %d %q, want %d %q", res.StatusCode, body.Message, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestConditionalResponse(t *testing.T) {
+	lastModified := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	first, err := ConditionalResponse(events.APIGatewayV2HTTPRequest{}, []string{"a"}, lastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := first.Headers["ETag"]
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		body       interface{}
+		wantStatus int
+	}{
+		{"no conditional headers", map[string]string{}, []string{"a"}, http.StatusOK},
+		{"matching etag", map[string]string{"if-none-match": etag}, []string{"a"}, http.StatusNotModified},
+		{"weak matching etag", map[string]string{"If-None-Match": "W/" + etag}, []string{"a"}, http.StatusNotModified},
+		{"body changed", map[string]string{"If-None-Match": etag}, []string{"a", "b"}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, []string{"a"}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": lastModified.Add(-time.Hour).Format(http.TimeFormat)}, []string{"a"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ConditionalResponse(events.APIGatewayV2HTTPRequest{Headers: tt.headers}, tt.body, lastModified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified && res.Body != "" {
+				t.Errorf("304 has body %q", res.Body)
+			}
+		})
+	}
+}
+
+func TestErrorResponseEscapesMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"quotes and backslash", `workoutType "bad\type" is invalid`},
+		{"newline", "Unable to get challenge:\nthrottled"},
+		{"html", "<script>alert(1)</script>"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ErrorResponse(http.StatusBadRequest, tt.message)
+			body := decodeErrorBody(t, res)
+			if body.Message != tt.message || body.Status != http.StatusBadRequest {
+				t.Errorf("body = %+v, want the original message", body)
+			}
+			if res.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Content-Type = %q", res.Headers["Content-Type"])
+			}
+		})
+	}
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		errs        []FieldError
+		wantMessage string
+	}{
+		{"one error", []FieldError{{Field: "name", Message: "name is required"}}, "name is required"},
+		{
+			"every error",
+			[]FieldError{{Field: "name", Message: "name is required"}, {Field: "startDate", Message: "startDate is invalid"}},
+			"name is required; startDate is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ValidationErrorResponse(tt.errs)
+
+			body := struct {
+				Status  int          `json:"status"`
+				Message string       `json:"message"`
+				Errors  []FieldError `json:"errors"`
+			}{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusBadRequest || body.Status != http.StatusBadRequest {
+				t.Errorf("status = %d, body status %d, want 400", res.StatusCode, body.Status)
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("Message = %q, want %q", body.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(body.Errors, tt.errs) {
+				t.Errorf("Errors = %+v, want %+v", body.Errors, tt.errs)
+			}
+		})
+	}
+}
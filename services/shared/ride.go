@@ -0,0 +1,211 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+
+// rideIDPattern matches Peloton ride ids, which are 32 hex characters
+// the length is lenient in case Peloton changes the format
+var rideIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16,64}$`)
+
+// ValidateRideID returns an error if rideID can't be a Peloton ride id
+func ValidateRideID(rideID string) error {
+	if !rideIDPattern.MatchString(rideID) {
+		return fmt.Errorf("ride_id %s is invalid, ride ids are hexadecimal strings", rideID)
+	}
+
+	return nil
+}
+
+// RideInstructor is the instructor info embedded in a ride
+type RideInstructor struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ImageURL string `json:"image_url"`
+	Bio      string `json:"bio"`
+}
+
+// Ride is a superset of Workout returned by the ride details endpoint
+type Ride struct {
+	Workout
+	Length             int            `json:"length"`
+	HasClosedCaptions  bool           `json:"has_closed_captions"`
+	Captions           []string       `json:"captions"`
+	HasPedalingMetrics bool           `json:"has_pedaling_metrics"`
+	IsExplicit         bool           `json:"is_explicit"`
+	HasFavorite        bool           `json:"has_favorite"`
+	Instructor         RideInstructor `json:"instructor"`
+}
+
+// ToWorkout returns the ride as the Workout stored in recommendations and programs
+// the instructor's id and name are taken from the nested instructor when they aren't set on the ride
+func (r Ride) ToWorkout() Workout {
+	w := r.Workout
+	if w.InstructorID == "" {
+		w.InstructorID = r.Instructor.ID
+	}
+	if w.InstructorName == "" {
+		w.InstructorName = r.Instructor.Name
+	}
+
+	return w
+}
+
+// RideAverages are the averages across all users that have taken a ride
+type RideAverages struct {
+	AverageTotalWork     float64 `json:"average_total_work"`
+	AverageDistance      float64 `json:"average_distance"`
+	AverageCalories      float64 `json:"average_calories"`
+	AverageAvgPower      float64 `json:"average_avg_power"`
+	AverageAvgSpeed      float64 `json:"average_avg_speed"`
+	AverageAvgCadence    float64 `json:"average_avg_cadence"`
+	AverageAvgResistance float64 `json:"average_avg_resistance"`
+}
+
+// PlaylistArtist is an artist on a ride's playlist
+type PlaylistArtist struct {
+	ID   string `json:"artist_id"`
+	Name string `json:"artist_name"`
+}
+
+// PlaylistSong is a song on a ride's playlist
+type PlaylistSong struct {
+	Title   string           `json:"title"`
+	Artists []PlaylistArtist `json:"artists"`
+	Album   struct {
+		Name string `json:"name"`
+	} `json:"album"`
+	StartTimeOffset int `json:"start_time_offset"`
+}
+
+// Playlist is the music played during a ride
+type Playlist struct {
+	Songs      []PlaylistSong   `json:"songs"`
+	TopArtists []PlaylistArtist `json:"top_artists"`
+}
+
+// RideSegment is a section of a ride, ex) warmup, main, cooldown
+type RideSegment struct {
+	ID              string  `json:"id"`
+	Name            string  `json:"name"`
+	Length          int     `json:"length"`
+	StartTimeOffset int     `json:"start_time_offset"`
+	IntensityInMets float64 `json:"intensity_in_mets"`
+	IconSlug        string  `json:"icon_slug"`
+	MetricsType     string  `json:"metrics_type"`
+}
+
+// RideSegments are the segments that make up a ride
+type RideSegments struct {
+	SegmentList []RideSegment `json:"segment_list"`
+}
+
+// TargetMetric is a single target, ex) power_zone between lower and upper
+type TargetMetric struct {
+	Name  string  `json:"name"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// TargetMetricOffset is the targets for a period of a ride
+type TargetMetricOffset struct {
+	Offsets struct {
+		Start int `json:"start"`
+		End   int `json:"end"`
+	} `json:"offsets"`
+	SegmentType string         `json:"segment_type"`
+	Metrics     []TargetMetric `json:"metrics"`
+}
+
+// TargetMetricsData are the target metrics for structured rides
+type TargetMetricsData struct {
+	TargetMetrics []TargetMetricOffset `json:"target_metrics"`
+}
+
+// RideDetails is the response from the ride details endpoint
+type RideDetails struct {
+	Ride     Ride         `json:"ride"`
+	Averages RideAverages `json:"averages"`
+	// Playlist is nil for rides without music, ex) meditation
+	Playlist *Playlist `json:"playlist"`
+	// Segments and TargetMetricsData are nil for unstructured rides
+	Segments          *RideSegments      `json:"segments"`
+	TargetMetricsData *TargetMetricsData `json:"target_metrics_data"`
+}
+
+// rideDetailsCacheTTL is how long ride details are cached between warm invocations
+const rideDetailsCacheTTL = 5 * time.Minute
+
+type rideDetailsCacheEntry struct {
+	details   *RideDetails
+	expiresAt time.Time
+}
+
+var (
+	rideDetailsCache   = map[string]rideDetailsCacheEntry{}
+	rideDetailsCacheMu sync.Mutex
+)
+
+// rideDetailsCacheKey returns the cache key for a ride
+// some fields depend on the user, so the cookie is part of the cache key
+func rideDetailsCacheKey(rideID string, headers map[string]string) string {
+	return fmt.Sprintf("%s|%s", rideID, headers["Cookie"])
+}
+
+// GetRideDetails gets the details of a single ride from Peloton
+// successful responses are cached for warm invocations
+// if Peloton returns an error, the Peloton response body, status code and error are returned
+func GetRideDetails(rideID string, headers map[string]string) (*RideDetails, []byte, http.Header, int, error) {
+	rideDetailsCacheMu.Lock()
+	entry, ok := rideDetailsCache[rideDetailsCacheKey(rideID, headers)]
+	rideDetailsCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.details, nil, nil, http.StatusOK, nil
+	}
+
+	return FetchRideDetails(rideID, headers)
+}
+
+// FetchRideDetails always gets the details of a single ride from Peloton and refreshes the cache
+// if Peloton returns an error, the Peloton response body, status code and error are returned
+func FetchRideDetails(rideID string, headers map[string]string) (*RideDetails, []byte, http.Header, int, error) {
+	url := fmt.Sprintf("/api/ride/%s/details", rideID)
+
+	body, respHeaders, resCode, err := PelotonRequest("GET", url, headers, nil)
+	if err != nil {
+		return nil, body, respHeaders, resCode, err
+	}
+
+	details := &RideDetails{}
+	err = json.Unmarshal(body, details)
+	if err != nil {
+		return nil, nil, nil, http.StatusInternalServerError, fmt.Errorf("Unable to unmarshal response: %s", err)
+	}
+
+	// The instructor name is only included on the nested instructor object
+	details.Ride.InstructorName = details.Ride.Instructor.Name
+
+	rideDetailsCacheMu.Lock()
+	rideDetailsCache[rideDetailsCacheKey(rideID, headers)] = rideDetailsCacheEntry{
+		details:   details,
+		expiresAt: time.Now().Add(rideDetailsCacheTTL),
+	}
+	rideDetailsCacheMu.Unlock()
+
+	return details, body, respHeaders, http.StatusOK, nil
+}
+
+// InvalidateRideDetails removes a ride from the cache, ex) after it is bookmarked
+func InvalidateRideDetails(rideID string, headers map[string]string) {
+	rideDetailsCacheMu.Lock()
+	delete(rideDetailsCache, rideDetailsCacheKey(rideID, headers))
+	rideDetailsCacheMu.Unlock()
+}
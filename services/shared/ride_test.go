@@ -0,0 +1,148 @@
+package shared
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const rideDetailsFixture = `{
+	"ride": {
+		"id": "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c",
+		"title": "45 min Power Zone Endurance Ride",
+		"description": "Build your aerobic base.",
+		"difficulty_estimate": 6.42,
+		"duration": 2700,
+		"image_url": "https://example.com/ride.png",
+		"instructor_id": "",
+		"original_air_time": 1715342400,
+		"fitness_discipline": "cycling",
+		"total_workouts": 18234,
+		"favorite_count": 512,
+		"length": 2760,
+		"has_closed_captions": true,
+		"captions": ["en-US"],
+		"instructor": {"id": "i1", "name": "Matt Wilpers"}
+	},
+	"averages": {"average_avg_power": 151.2, "average_calories": 480},
+	"playlist": {
+		"songs": [{"title": "Song", "artists": [{"artist_id": "a1", "artist_name": "Artist"}], "album": {"name": "Album"}, "start_time_offset": 60}],
+		"top_artists": [{"artist_id": "a1", "artist_name": "Artist"}]
+	},
+	"segments": {"segment_list": [{"id": "s1", "name": "Warm Up", "length": 300, "start_time_offset": 0, "icon_slug": "warmup"}]},
+	"target_metrics_data": {
+		"target_metrics": [{"offsets": {"start": 60, "end": 300}, "segment_type": "warmup", "metrics": [{"name": "power_zone", "lower": 2, "upper": 2}]}]
+	}
+}`
+
+func TestUnmarshalRideDetails(t *testing.T) {
+	details := RideDetails{}
+	if err := json.Unmarshal([]byte(rideDetailsFixture), &details); err != nil {
+		t.Fatal(err)
+	}
+
+	ride := details.Ride
+	if ride.ID != "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c" || ride.Duration != 2700 || ride.Length != 2760 || ride.Difficulty != 6.42 {
+		t.Errorf("unexpected ride %+v", ride)
+	}
+	if ride.TotalWorkouts != 18234 || ride.FavoriteCount != 512 {
+		t.Errorf("popularity = %d workouts, %d favorites, want 18234 and 512", ride.TotalWorkouts, ride.FavoriteCount)
+	}
+	if details.Averages.AverageAvgPower != 151.2 {
+		t.Errorf("AverageAvgPower = %v", details.Averages.AverageAvgPower)
+	}
+	if details.Playlist == nil || len(details.Playlist.Songs) != 1 || details.Playlist.Songs[0].Artists[0].Name != "Artist" {
+		t.Errorf("Playlist = %+v", details.Playlist)
+	}
+	if details.Segments == nil || details.Segments.SegmentList[0].Name != "Warm Up" {
+		t.Errorf("Segments = %+v", details.Segments)
+	}
+	if details.TargetMetricsData == nil || details.TargetMetricsData.TargetMetrics[0].Metrics[0].Name != "power_zone" {
+		t.Errorf("TargetMetricsData = %+v", details.TargetMetricsData)
+	}
+
+	w := ride.ToWorkout()
+	if w.InstructorID != "i1" || w.InstructorName != "Matt Wilpers" || w.Title != ride.Title {
+		t.Errorf("ToWorkout() = %+v", w)
+	}
+}
+
+func TestUnmarshalUnstructuredRide(t *testing.T) {
+	details := RideDetails{}
+	if err := json.Unmarshal([]byte(`{"ride": {"id": "abc", "fitness_discipline": "meditation"}}`), &details); err != nil {
+		t.Fatal(err)
+	}
+
+	if details.Playlist != nil || details.Segments != nil || details.TargetMetricsData != nil {
+		t.Errorf("rides without music or structure should leave them nil, got %+v", details)
+	}
+	if details.Ride.TotalWorkouts != 0 || details.Ride.FavoriteCount != 0 {
+		t.Errorf("missing popularity should be 0, got %+v", details.Ride.Workout)
+	}
+}
+
+func TestValidateRideID(t *testing.T) {
+	tests := []struct {
+		rideID  string
+		wantErr bool
+	}{
+		{"4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c", false},
+		{"4F3B2A1C9D8E7F6A", false},
+		{"", true},
+		{"abc", true},
+		{"4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8z", true},
+		{"../../api/me", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rideID, func(t *testing.T) {
+			if err := ValidateRideID(tt.rideID); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRideID() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRideToWorkout(t *testing.T) {
+	details := RideDetails{}
+	if err := json.Unmarshal([]byte(rideDetailsFixture), &details); err != nil {
+		t.Fatal(err)
+	}
+	// The fixture's instructor_id is empty, so it's resolved from the nested instructor
+	want := Workout{
+		ID:                "4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c",
+		Title:             "45 min Power Zone Endurance Ride",
+		Description:       "Build your aerobic base.",
+		Difficulty:        6.42,
+		Duration:          2700,
+		ImageURL:          "https://example.com/ride.png",
+		InstructorID:      "i1",
+		InstructorName:    "Matt Wilpers",
+		OriginalAirTime:   1715342400,
+		FitnessDiscipline: "cycling",
+		TotalWorkouts:     18234,
+		FavoriteCount:     512,
+	}
+	if got := details.Ride.ToWorkout(); got != want {
+		t.Errorf("ToWorkout() = %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		name     string
+		workout  Workout
+		wantID   string
+		wantName string
+	}{
+		{"from the nested instructor", Workout{}, "i1", "Matt Wilpers"},
+		{"set on the ride", Workout{InstructorID: "i2", InstructorName: "Denis Morton"}, "i2", "Denis Morton"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Ride{Workout: tt.workout, Instructor: RideInstructor{ID: "i1", Name: "Matt Wilpers"}}
+			w := r.ToWorkout()
+			if w.InstructorID != tt.wantID || w.InstructorName != tt.wantName {
+				t.Errorf("instructor = %s %q, want %s %q", w.InstructorID, w.InstructorName, tt.wantID, tt.wantName)
+			}
+		})
+	}
+}
@@ -1,13 +1,27 @@
 package shared
 
 type Workout struct {
-	ID              string  `json:"id"`
-	Title           string  `json:"title"`
-	Description     string  `json:"description"`
-	Difficulty      float32 `json:"difficulty_estimate"`
-	Duration        int     `json:"duration"`
-	ImageURL        string  `json:"image_url"`
-	InstructorID    string  `json:"instructor_id"`
-	InstructorName  string  `json:"instructor_name"`
-	OriginalAirTime int64   `json:"original_air_time"`
+	ID                string  `json:"id"`
+	Title             string  `json:"title"`
+	Description       string  `json:"description"`
+	Difficulty        float32 `json:"difficulty_estimate"`
+	Duration          int     `json:"duration"`
+	ImageURL          string  `json:"image_url"`
+	InstructorID      string  `json:"instructor_id"`
+	InstructorName    string  `json:"instructor_name"`
+	OriginalAirTime   int64   `json:"original_air_time"`
+	FitnessDiscipline string  `json:"fitness_discipline"`
+	// TotalWorkouts and FavoriteCount are how popular the class is, they're 0 when Peloton doesn't send them
+	// they're omitted when 0 so stored classes are unchanged, see WithoutPopularity
+	TotalWorkouts int `json:"total_workouts,omitempty"`
+	FavoriteCount int `json:"favorite_count,omitempty"`
+}
+
+// WithoutPopularity returns the workout without TotalWorkouts and FavoriteCount
+// popularity changes over time, so it isn't stored with programs and recommendations
+// and the same class is still found as a duplicate
+func (w Workout) WithoutPopularity() Workout {
+	w.TotalWorkouts, w.FavoriteCount = 0, 0
+
+	return w
 }
@@ -0,0 +1,44 @@
+package shared
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWorkoutPopularity(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantTotal     int
+		wantFavorites int
+	}{
+		{"sent by Peloton", `{"id": "r1", "total_workouts": 18234, "favorite_count": 512}`, 18234, 512},
+		{"not sent", `{"id": "r1"}`, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Workout{}
+			if err := json.Unmarshal([]byte(tt.body), &w); err != nil {
+				t.Fatal(err)
+			}
+			if w.TotalWorkouts != tt.wantTotal || w.FavoriteCount != tt.wantFavorites {
+				t.Errorf("popularity = %d workouts, %d favorites, want %d and %d", w.TotalWorkouts, w.FavoriteCount, tt.wantTotal, tt.wantFavorites)
+			}
+
+			stored := w.WithoutPopularity()
+			if stored.TotalWorkouts != 0 || stored.FavoriteCount != 0 || stored.ID != w.ID {
+				t.Errorf("WithoutPopularity() = %+v", stored)
+			}
+			// Stored classes must marshal like they did before popularity existed, so duplicates are still found
+			data, err := json.Marshal(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), "total_workouts") || strings.Contains(string(data), "favorite_count") {
+				t.Errorf("stored workout %s has popularity", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/user/{userID}
+
+// usernameCacheTTL is how long usernames are cached between warm invocations
+const usernameCacheTTL = 30 * time.Minute
+
+type usernameCacheEntry struct {
+	username  string
+	expiresAt time.Time
+}
+
+var (
+	usernameCache   = map[string]usernameCacheEntry{}
+	usernameCacheMu sync.Mutex
+)
+
+// GetUsername returns the Peloton username of a user
+// successful lookups are cached for warm invocations
+// if Peloton returns an error, the status code and error are returned
+func GetUsername(userID string, headers map[string]string) (string, int, error) {
+	usernameCacheMu.Lock()
+	entry, ok := usernameCache[userID]
+	usernameCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.username, http.StatusOK, nil
+	}
+
+	body, _, resCode, err := PelotonRequest("GET", fmt.Sprintf("/api/user/%s", userID), headers, nil)
+	if err != nil {
+		return "", resCode, err
+	}
+
+	user := struct {
+		Username string `json:"username"`
+	}{}
+	err = json.Unmarshal(body, &user)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Unable to unmarshal response: %s", err)
+	}
+
+	usernameCacheMu.Lock()
+	usernameCache[userID] = usernameCacheEntry{
+		username:  user.Username,
+		expiresAt: time.Now().Add(usernameCacheTTL),
+	}
+	usernameCacheMu.Unlock()
+
+	return user.Username, http.StatusOK, nil
+}
@@ -0,0 +1,135 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Limits on the user provided text of challenges and programs
+const (
+	MaxNameLength        = 100
+	MaxDescriptionLength = 2000
+	MaxEquipmentLength   = 50
+	MaxEquipmentItems    = 20
+)
+
+// Default limits on the size of request bodies and the JSON blobs stored from them
+// DynamoDB items can't be larger than 400KB, so a blob must leave room for the rest of the item
+const (
+	DefaultMaxBodyBytes = 256 * 1024
+	DefaultMaxBlobBytes = 350 * 1024
+)
+
+// envLimit returns the positive integer in the env variable name, or def if it's missing or invalid
+func envLimit(name string, def int) int {
+	limit, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name)))
+	if err != nil || limit < 1 {
+		return def
+	}
+
+	return limit
+}
+
+// MaxBodyBytes returns the largest request body accepted by create endpoints
+// env max_body_bytes overrides DefaultMaxBodyBytes
+func MaxBodyBytes() int {
+	return envLimit("max_body_bytes", DefaultMaxBodyBytes)
+}
+
+// MaxBlobBytes returns the largest JSON blob that is stored in an item
+// env max_blob_bytes overrides DefaultMaxBlobBytes
+func MaxBlobBytes() int {
+	return envLimit("max_blob_bytes", DefaultMaxBlobBytes)
+}
+
+// BlobSizeError returns an error if the marshaled blob for field is larger than MaxBlobBytes
+func BlobSizeError(field string, data []byte) error {
+	if max := MaxBlobBytes(); len(data) > max {
+		return fmt.Errorf("%s is %d bytes, it must not be larger than %d bytes", field, len(data), max)
+	}
+
+	return nil
+}
+
+// FieldError is a validation error for one field of a request body
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrorsMessage joins the messages of errs into one message
+func FieldErrorsMessage(errs []FieldError) string {
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// SanitizeText removes control characters other than newlines and tabs
+// and trims surrounding whitespace
+func SanitizeText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, s)
+
+	return strings.TrimSpace(s)
+}
+
+// SanitizeEquipment sanitizes each equipment entry and drops empty ones
+func SanitizeEquipment(equipment []string) []string {
+	sanitized := []string{}
+	for _, e := range equipment {
+		e = SanitizeText(e)
+		if e == "" {
+			continue
+		}
+		sanitized = append(sanitized, e)
+	}
+
+	return sanitized
+}
+
+// TextLimitErrors returns an error for each text field over its limit
+// an empty slice means all fields are within their limits
+func TextLimitErrors(name, description string, equipment []string) []FieldError {
+	errs := []FieldError{}
+
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		errs = append(errs, FieldError{"name", fmt.Sprintf("name must not be longer than %d characters", MaxNameLength)})
+	}
+	if utf8.RuneCountInString(description) > MaxDescriptionLength {
+		errs = append(errs, FieldError{"description", fmt.Sprintf("description must not be longer than %d characters", MaxDescriptionLength)})
+	}
+	if len(equipment) > MaxEquipmentItems {
+		errs = append(errs, FieldError{"equipmentNeeded", fmt.Sprintf("equipmentNeeded must not have more than %d items", MaxEquipmentItems)})
+	}
+	for _, e := range equipment {
+		if utf8.RuneCountInString(e) > MaxEquipmentLength {
+			errs = append(errs, FieldError{"equipmentNeeded", fmt.Sprintf("equipmentNeeded items must not be longer than %d characters", MaxEquipmentLength)})
+			break
+		}
+	}
+
+	return errs
+}
+
+// ValidateID returns an error if id isn't a UUID, the format of every challenge, program and recommendation id
+// param is the name of the path param, used in the error message
+func ValidateID(param, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("%s %s is invalid, ids are UUIDs", param, id)
+	}
+
+	return nil
+}
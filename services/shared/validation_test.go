@@ -0,0 +1,90 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextLimitErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		cName       string
+		description string
+		equipment   []string
+		wantFields  []string
+	}{
+		{"at the limits", strings.Repeat("n", MaxNameLength), strings.Repeat("d", MaxDescriptionLength), []string{strings.Repeat("e", MaxEquipmentLength)}, nil},
+		{"name over", strings.Repeat("n", MaxNameLength+1), "", nil, []string{"name"}},
+		{"multibyte name at the limit", strings.Repeat("é", MaxNameLength), "", nil, nil},
+		{"description over", "", strings.Repeat("d", MaxDescriptionLength+1), nil, []string{"description"}},
+		{"equipment item over", "", "", []string{strings.Repeat("e", MaxEquipmentLength+1)}, []string{"equipmentNeeded"}},
+		{"too many equipment items", "", "", make([]string, MaxEquipmentItems+1), []string{"equipmentNeeded"}},
+		{"every field over", strings.Repeat("n", MaxNameLength+1), strings.Repeat("d", MaxDescriptionLength+1), nil, []string{"name", "description"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := TextLimitErrors(tt.cName, tt.description, tt.equipment)
+			fields := []string{}
+			for _, e := range errs {
+				fields = append(fields, e.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("fields with errors = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestSanitizeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"  Summer Miles  ", "Summer Miles"},
+		{"Summer\x00 Miles\x1b", "Summer Miles"},
+		{"line one\nline\ttwo", "line one\nline\ttwo"},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeText(tt.in); got != tt.want {
+			t.Errorf("SanitizeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	equipment := SanitizeEquipment([]string{" bike ", "", "\x00", "mat"})
+	if strings.Join(equipment, ",") != "bike,mat" {
+		t.Errorf("SanitizeEquipment() = %v, want [bike mat]", equipment)
+	}
+}
+
+func TestValidateID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{"8f14e45f-ceea-467f-a0e6-1b2c3d4e5f60", false},
+		{"", true},
+		{"not-a-uuid", true},
+		{"8f14e45fceea467fa0e61b2c3d4e5f6", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if err := ValidateID("challengeId", tt.id); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateID() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlobSizeError(t *testing.T) {
+	defer setEnv(t, "max_blob_bytes", "4")()
+
+	if err := BlobSizeError("workouts", []byte("1234")); err != nil {
+		t.Errorf("blob at the limit: %s", err)
+	}
+	if err := BlobSizeError("workouts", []byte("12345")); err == nil {
+		t.Error("blob over the limit wasn't rejected")
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Endpoint:
+//   GET https://api.onepeloton.com/api/user/{userID}/workouts?joins=ride,ride.instructor
+
+// Path Params:
+//   challengeId - ID of the challenge to sync progress for
+
+// Headers:
+//   UserID - Peloton user id whose workout history is synced
+
+// workoutStatusComplete is the status of a finished workout in the user's history
+const workoutStatusComplete = "COMPLETE"
+
+type syncResponse struct {
+	shared.Participation
+	// SyncedWorkouts is the number of classes from the history newly counted by this sync
+	SyncedWorkouts int `json:"syncedWorkouts"`
+}
+
+// matchingWorkouts returns the completed classes in the history that count towards the challenge
+// a class counts if it was taken between the challenge's StartDate and EndDate (UTC) and its discipline
+// is one of the challenge's WorkoutTypes. A challenge without WorkoutTypes counts every discipline
+// classes taken more than once are only returned once
+func matchingWorkouts(challenge shared.Challenge, history []shared.HistoryWorkout) []shared.HistoryWorkout {
+	matched := []shared.HistoryWorkout{}
+
+	seen := map[string]bool{}
+	for _, w := range history {
+		if w.Status != "" && w.Status != workoutStatusComplete {
+			continue
+		}
+		classID := w.Ride.ID
+		if classID == "" || seen[classID] {
+			continue
+		}
+		if challenge.CountsWorkout(w.FitnessDiscipline, time.Unix(w.StartTime, 0)) != nil {
+			continue
+		}
+
+		seen[classID] = true
+		matched = append(matched, w)
+	}
+
+	return matched
+}
+
+// syncChallengeProgress counts classes the user has already taken towards their progress in a challenge they joined
+// classes are counted by class id, so syncing again or marking the same class complete doesn't double count
+func syncChallengeProgress(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+	headers := map[string]string{}
+
+	challengeID, _ := request.PathParameters["challengeId"]
+	challengeID = strings.TrimSpace(challengeID)
+	if challengeID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter challengeId is required"), nil
+	}
+	if err := shared.ValidateID("challengeId", challengeID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TableChallenges)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	participantsTableName, err := shared.GetParticipantsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	completionsTableName, err := shared.GetCompletionsTableName()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(challengeID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get challenge: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeChallenge) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	challenge, err := shared.FormatChallenge(getItemOutput.Item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	// It's reported as not found so the ids of private challenges can't be probed
+	if !challenge.VisibleTo(userID) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find challenge %s", challengeID)), nil
+	}
+
+	_, joined, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if !joined {
+		return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge to sync progress for it"), nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := shared.GetHeader(request.Headers, "Cookie"); ok {
+		headers["Cookie"] = cookie
+	}
+
+	history, body, resCode, err := shared.GetWorkoutHistory(userID, headers)
+	if err != nil {
+		return shared.UpstreamErrorResponse(resCode, body, err), nil
+	}
+
+	synced := 0
+	for _, w := range matchingWorkouts(challenge, history) {
+		err = shared.RecordCompletedWorkout(db, participantsTableName, challengeID, userID, shared.LoggedWorkout{
+			WorkoutID:   w.Ride.ID,
+			Discipline:  w.FitnessDiscipline,
+			Minutes:     w.Ride.Duration / 60,
+			CompletedAt: time.Unix(w.StartTime, 0).UTC().Format(time.RFC3339),
+		})
+		if err == shared.ErrWorkoutAlreadyCounted {
+			continue
+		}
+		if err == shared.ErrNotParticipating {
+			// The user left the challenge while it was syncing
+			return shared.ErrorResponse(http.StatusForbidden, "Must join the challenge to sync progress for it"), nil
+		}
+		if err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+		synced++
+	}
+
+	participation, _, err := shared.GetParticipation(db, participantsTableName, challengeID, userID)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+
+	completed, err := shared.CompleteIfGoalMet(db, participantsTableName, completionsTableName, challenge, participation)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+	}
+	if completed {
+		participation, _, err = shared.GetParticipation(db, participantsTableName, challengeID, userID)
+		if err != nil {
+			return shared.ErrorResponse(http.StatusInternalServerError, err.Error()), nil
+		}
+	}
+
+	return shared.JSONResponse(http.StatusOK, syncResponse{
+		Participation:  participation,
+		SyncedWorkouts: synced,
+	})
+}
+
+func main() {
+	lambda.Start(shared.Chain(syncChallengeProgress, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithLogging, shared.WithUserID))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	item map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.item}, nil
+}
+
+func historyWorkout(rideID, discipline, status string, takenAt string) shared.HistoryWorkout {
+	start, _ := time.Parse(time.RFC3339, takenAt)
+	w := shared.HistoryWorkout{
+		ID:                "w-" + rideID,
+		StartTime:         start.Unix(),
+		FitnessDiscipline: discipline,
+		Status:            status,
+	}
+	w.Ride.ID = rideID
+
+	return w
+}
+
+func TestMatchingWorkouts(t *testing.T) {
+	challenge := shared.Challenge{StartDate: "2024-05-01", EndDate: "2024-05-31", WorkoutTypes: []string{"cycling", "strength"}}
+
+	tests := []struct {
+		name      string
+		challenge shared.Challenge
+		history   []shared.HistoryWorkout
+		want      []string
+	}{
+		{
+			"in window",
+			challenge,
+			[]shared.HistoryWorkout{
+				historyWorkout("r1", "cycling", "COMPLETE", "2024-05-01T00:00:00Z"),
+				historyWorkout("r2", "strength", "COMPLETE", "2024-05-31T23:59:59Z"),
+			},
+			[]string{"r1", "r2"},
+		},
+		{
+			"out of window",
+			challenge,
+			[]shared.HistoryWorkout{
+				historyWorkout("r1", "cycling", "COMPLETE", "2024-04-30T23:59:59Z"),
+				historyWorkout("r2", "cycling", "COMPLETE", "2024-06-01T00:00:00Z"),
+			},
+			[]string{},
+		},
+		{
+			"discipline ignores case",
+			challenge,
+			[]shared.HistoryWorkout{
+				historyWorkout("r1", "Cycling", "COMPLETE", "2024-05-10T12:00:00Z"),
+				historyWorkout("r2", "yoga", "COMPLETE", "2024-05-10T13:00:00Z"),
+			},
+			[]string{"r1"},
+		},
+		{
+			"no workout types counts everything",
+			shared.Challenge{StartDate: "2024-05-01", EndDate: "2024-05-31"},
+			[]shared.HistoryWorkout{historyWorkout("r1", "yoga", "COMPLETE", "2024-05-10T12:00:00Z")},
+			[]string{"r1"},
+		},
+		{
+			"incomplete, missing class and repeats",
+			challenge,
+			[]shared.HistoryWorkout{
+				historyWorkout("r1", "cycling", "IN_PROGRESS", "2024-05-10T12:00:00Z"),
+				historyWorkout("", "cycling", "COMPLETE", "2024-05-10T12:00:00Z"),
+				historyWorkout("r2", "cycling", "", "2024-05-11T12:00:00Z"),
+				historyWorkout("r2", "cycling", "COMPLETE", "2024-05-12T12:00:00Z"),
+			},
+			[]string{"r2"},
+		},
+		{
+			"invalid dates",
+			shared.Challenge{StartDate: "soon", EndDate: "2024-05-31"},
+			[]shared.HistoryWorkout{historyWorkout("r1", "cycling", "COMPLETE", "2024-05-10T12:00:00Z")},
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, w := range matchingWorkouts(tt.challenge, tt.history) {
+				got = append(got, w.Ride.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchingWorkouts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const challengeID = "6f1c2a8e-3b4d-4e5f-9a0b-1c2d3e4f5a6b"
+
+func TestSyncChallengeProgressNotFound(t *testing.T) {
+	challenge := func(attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+		item := map[string]*dynamodb.AttributeValue{
+			"Id":        {S: aws.String(challengeID)},
+			"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+			"CreatedBy": {S: aws.String("user2")},
+			"Name":      {S: aws.String("Ride Week")},
+			"Public":    {BOOL: aws.Bool(false)},
+			"StartDate": {S: aws.String("2024-05-01")},
+			"EndDate":   {S: aws.String("2024-05-31")},
+		}
+		for k, v := range attrs {
+			item[k] = v
+		}
+		return item
+	}
+
+	tests := []struct {
+		name        string
+		challengeID string
+		item        map[string]*dynamodb.AttributeValue
+		wantStatus  int
+	}{
+		{"invalid id", "challenge1", nil, http.StatusBadRequest},
+		{"unknown id", challengeID, nil, http.StatusNotFound},
+		{"private challenge", challengeID, challenge(nil), http.StatusNotFound},
+		{"program id", challengeID, challenge(map[string]*dynamodb.AttributeValue{
+			"Type":   {S: aws.String(shared.ItemTypeProgram)},
+			"Public": {BOOL: aws.Bool(true)},
+		}), http.StatusNotFound},
+	}
+
+	env := map[string]string{
+		"table_region":            "us-east-1",
+		"table_name":              "challenges",
+		"participants_table_name": "participants",
+		"completions_table_name":  "completions",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	newDB := shared.NewDB
+	defer func() {
+		shared.NewDB = newDB
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+				return &mockDB{item: tt.item}
+			}
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers:        map[string]string{"UserID": "user1"},
+				PathParameters: map[string]string{"challengeId": tt.challengeID},
+			}
+			res, err := shared.WithUserID(syncChallengeProgress)(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+		})
+	}
+}
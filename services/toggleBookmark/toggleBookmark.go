@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Endpoints:
+//   GET https://api.onepeloton.com/api/ride/{rideID}/details
+//   POST https://api.onepeloton.com/api/favorites/create
+//   POST https://api.onepeloton.com/api/favorites/delete
+
+type toggleBookmarkRequest struct {
+	RideID string `json:"ride_id"`
+}
+
+type toggleBookmarkResponse struct {
+	RideID     string `json:"ride_id"`
+	Bookmarked bool   `json:"bookmarked"`
+}
+
+// getBody attempts to generate the request body and return it
+// if an error occurs, the error code and message are returned
+func getBody(request events.APIGatewayV2HTTPRequest) (string, []byte, int, error) {
+	toggleReq := toggleBookmarkRequest{}
+
+	err := json.Unmarshal([]byte(request.Body), &toggleReq)
+	toggleReq.RideID = strings.TrimSpace(toggleReq.RideID)
+	if err != nil || toggleReq.RideID == "" {
+		return "", nil, http.StatusBadRequest, errors.New("ride_id is required in request body")
+	}
+	if err := shared.ValidateRideID(toggleReq.RideID); err != nil {
+		return "", nil, http.StatusBadRequest, err
+	}
+
+	toggleBytes, err := json.Marshal(toggleReq)
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, fmt.Errorf("Unable to marshal request: %s", err)
+	}
+
+	return toggleReq.RideID, toggleBytes, -1, nil
+}
+
+// isRaceError returns true if the Peloton error means the ride is already in the requested state
+// ex) the ride was bookmarked or unbookmarked by another client since it was checked
+func isRaceError(resCode int, body []byte) bool {
+	if resCode == http.StatusNotFound {
+		return true
+	}
+	if resCode != http.StatusBadRequest && resCode != http.StatusConflict {
+		return false
+	}
+
+	bodyStr := strings.ToLower(string(body))
+	return strings.Contains(bodyStr, "already") || strings.Contains(bodyStr, "not found") || strings.Contains(bodyStr, "does not exist")
+}
+
+// toggleBookmark bookmarks the class if it isn't bookmarked, otherwise it unbookmarks it
+func toggleBookmark(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	method := "POST"
+	headers := map[string]string{}
+
+	rideID, reqBody, resCode, err := getBody(request)
+	if err != nil {
+		errBody := fmt.Sprintf(`{
+			"status": %d,
+			"message": "%s"
+		}`, resCode, err.Error())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: resCode,
+			Body:       errBody,
+		}, nil
+	}
+
+	// Add peloton cookie header
+	if cookie, ok := request.Headers["Cookie"]; ok {
+		headers["Cookie"] = cookie
+	}
+
+	// Check the current state, skipping the cache since it may be stale
+	details, body, _, resCode, err := shared.FetchRideDetails(rideID, headers)
+	if err != nil {
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+
+	url := "/api/favorites/create"
+	if details.Ride.HasFavorite {
+		url = "/api/favorites/delete"
+	}
+
+	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, bytes.NewBuffer(reqBody))
+	if err != nil && !isRaceError(resCode, body) {
+		res := events.APIGatewayProxyResponse{
+			StatusCode: shared.UpstreamStatus(resCode, err),
+			Body:       err.Error(),
+		}
+
+		if body != nil {
+			res.Body = string(body)
+		}
+
+		return res, nil
+	}
+	shared.InvalidateRideDetails(rideID, headers)
+
+	reply, err := json.Marshal(toggleBookmarkResponse{
+		RideID:     rideID,
+		Bookmarked: !details.Ride.HasFavorite,
+	})
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, fmt.Errorf("Unable to marshal response: %s", err)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode:        http.StatusOK,
+		MultiValueHeaders: respHeaders,
+		Body:              string(reply),
+	}, nil
+}
+
+func main() {
+	lambda.Start(shared.Chain(toggleBookmark, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithJSONBody))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const rideID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func TestToggleBookmark(t *testing.T) {
+	tests := []struct {
+		name           string
+		hasFavorite    bool
+		upstream       int
+		upstreamBody   string
+		wantPath       string
+		wantStatus     int
+		wantBookmarked bool
+	}{
+		{"bookmark", false, http.StatusOK, `{}`, "/api/favorites/create", http.StatusOK, true},
+		{"unbookmark", true, http.StatusOK, `{}`, "/api/favorites/delete", http.StatusOK, false},
+		{"bookmarked since it was checked", false, http.StatusBadRequest, `{"message": "Ride is already a favorite"}`, "/api/favorites/create", http.StatusOK, true},
+		{"unbookmarked since it was checked", true, http.StatusNotFound, `{"message": "Favorite not found"}`, "/api/favorites/delete", http.StatusOK, false},
+		{"upstream failure", false, http.StatusInternalServerError, `{"message": "Something went wrong"}`, "/api/favorites/create", http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/ride/"+rideID+"/details" {
+					fmt.Fprintf(w, `{"ride": {"id": "%s", "has_favorite": %t}}`, rideID, tt.hasFavorite)
+					return
+				}
+				gotPath = r.URL.Path
+				w.WriteHeader(tt.upstream)
+				fmt.Fprint(w, tt.upstreamBody)
+			}))
+			defer server.Close()
+			os.Setenv("peloton_url", server.URL)
+			defer os.Unsetenv("peloton_url")
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"Cookie": "peloton_session_id=abc"},
+				Body:    `{"ride_id": "` + rideID + `"}`,
+			}
+			res, err := toggleBookmark(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("called %s, want %s", gotPath, tt.wantPath)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			body := toggleBookmarkResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body != (toggleBookmarkResponse{RideID: rideID, Bookmarked: tt.wantBookmarked}) {
+				t.Errorf("body = %+v, want bookmarked %t", body, tt.wantBookmarked)
+			}
+		})
+	}
+}
+
+func TestToggleBookmarkInvalidBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing ride_id", `{}`},
+		{"invalid ride_id", `{"ride_id": "not-a-ride"}`},
+		{"invalid json", `{`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := toggleBookmark(context.Background(), events.APIGatewayV2HTTPRequest{Body: tt.body})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("StatusCode = %d, want 400", res.StatusCode)
+			}
+		})
+	}
+}
@@ -17,27 +17,52 @@ import (
 // Endpoint:
 //   POST https://api.onepeloton.com/api/favorites/delete
 
+// Headers:
+//   UserID - Optional. If set, the bookmark is removed from the bookmarks table
+
 type unbookmarkRequest struct {
 	RideID string `json:"ride_id"`
 }
 
+type unbookmarkResponse struct {
+	Status  int    `json:"status"`
+	RideID  string `json:"ride_id"`
+	Message string `json:"message"`
+}
+
 // getBody attempts to generate the request body and return it
 // if an error occurs, the error code and message are returned
-func getBody(url string, request events.APIGatewayV2HTTPRequest) ([]byte, int, error) {
+func getBody(url string, request events.APIGatewayV2HTTPRequest) (string, []byte, int, error) {
 	unbookmarkReq := unbookmarkRequest{}
 
 	err := json.Unmarshal([]byte(request.Body), &unbookmarkReq)
 	unbookmarkReq.RideID = strings.TrimSpace(unbookmarkReq.RideID)
 	if err != nil || unbookmarkReq.RideID == "" {
-		return nil, http.StatusBadRequest, errors.New("ride_id is required in request body")
+		return "", nil, http.StatusBadRequest, errors.New("ride_id is required in request body")
+	}
+	if err := shared.ValidateRideID(unbookmarkReq.RideID); err != nil {
+		return "", nil, http.StatusBadRequest, err
 	}
 
 	unbookmarkBytes, err := json.Marshal(unbookmarkReq)
 	if err != nil {
-		return nil, http.StatusInternalServerError, fmt.Errorf("Unable to marshal request: %s", err)
+		return "", nil, http.StatusInternalServerError, fmt.Errorf("Unable to marshal request: %s", err)
+	}
+
+	return unbookmarkReq.RideID, unbookmarkBytes, -1, nil
+}
+
+// isNotBookmarked returns true if the Peloton error is because the ride isn't bookmarked
+func isNotBookmarked(resCode int, body []byte) bool {
+	if resCode == http.StatusNotFound {
+		return true
+	}
+	if resCode != http.StatusBadRequest {
+		return false
 	}
 
-	return unbookmarkBytes, -1, nil
+	bodyStr := strings.ToLower(string(body))
+	return strings.Contains(bodyStr, "not found") || strings.Contains(bodyStr, "does not exist") || strings.Contains(bodyStr, "not a favorite")
 }
 
 // unbookmarkClass unbookmarks the class that is passed in
@@ -46,17 +71,9 @@ func unbookmarkClass(ctx context.Context, request events.APIGatewayV2HTTPRequest
 	url := "/api/favorites/delete"
 	headers := map[string]string{}
 
-	reqBody, resCode, err := getBody(url, request)
+	rideID, reqBody, resCode, err := getBody(url, request)
 	if err != nil {
-		errBody := fmt.Sprintf(`{
-			"status": %d,
-			"message": "%s"
-		}`, resCode, err.Error())
-
-		return events.APIGatewayProxyResponse{
-			StatusCode: resCode,
-			Body:       errBody,
-		}, nil
+		return shared.ErrorResponse(resCode, err.Error()), nil
 	}
 
 	// Add peloton cookie header
@@ -64,26 +81,24 @@ func unbookmarkClass(ctx context.Context, request events.APIGatewayV2HTTPRequest
 		headers["Cookie"] = cookie
 	}
 
-	body, respHeaders, resCode, err := shared.PelotonRequest(method, url, headers, bytes.NewBuffer(reqBody))
+	message := "Class unbookmarked"
+	body, _, resCode, err := shared.PelotonRequest(method, url, headers, bytes.NewBuffer(reqBody))
 	if err != nil {
-		res := events.APIGatewayProxyResponse{
-			StatusCode: resCode,
-			Body:       err.Error(),
+		if !isNotBookmarked(resCode, body) {
+			return shared.UpstreamErrorResponse(resCode, body, err), nil
 		}
-
-		if body != nil {
-			res.Body = string(body)
-		}
-
-		return res, nil
+		// Unbookmarking is idempotent
+		message = "Class was not bookmarked"
 	}
+	shared.DeleteBookmark(strings.TrimSpace(request.Headers["UserID"]), rideID)
 
-	return events.APIGatewayProxyResponse{
-		StatusCode:        resCode,
-		MultiValueHeaders: respHeaders,
-	}, nil
+	return shared.JSONResponse(http.StatusOK, unbookmarkResponse{
+		Status:  http.StatusOK,
+		RideID:  rideID,
+		Message: message,
+	})
 }
 
 func main() {
-	lambda.Start(unbookmarkClass)
+	lambda.Start(shared.Chain(unbookmarkClass, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPost), shared.WithRecovery, shared.WithJSONBody))
 }
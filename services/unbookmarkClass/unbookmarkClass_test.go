@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const rideID = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func TestUnbookmarkClass(t *testing.T) {
+	tests := []struct {
+		name         string
+		upstream     int
+		upstreamBody string
+		wantStatus   int
+		wantMessage  string
+	}{
+		{"unbookmarked", http.StatusOK, `{}`, http.StatusOK, "Class unbookmarked"},
+		{"not bookmarked", http.StatusBadRequest, `{"message": "Ride is not a favorite"}`, http.StatusOK, "Class was not bookmarked"},
+		{"favorite not found", http.StatusNotFound, `{}`, http.StatusOK, "Class was not bookmarked"},
+		{"upstream failure", http.StatusInternalServerError, `Internal Server Error`, http.StatusBadGateway, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstream)
+				fmt.Fprint(w, tt.upstreamBody)
+			}))
+			defer server.Close()
+			os.Setenv("peloton_url", server.URL)
+			defer os.Unsetenv("peloton_url")
+
+			request := events.APIGatewayV2HTTPRequest{Body: `{"ride_id": "` + rideID + `"}`}
+			res, err := unbookmarkClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			// Upstream text errors are still returned as JSON
+			if res.Headers["Content-Type"] != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", res.Headers["Content-Type"])
+			}
+			if !json.Valid([]byte(res.Body)) {
+				t.Fatalf("body %q isn't JSON", res.Body)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+
+			body := unbookmarkResponse{}
+			if err := json.Unmarshal([]byte(res.Body), &body); err != nil {
+				t.Fatal(err)
+			}
+			want := unbookmarkResponse{Status: http.StatusOK, RideID: rideID, Message: tt.wantMessage}
+			if body != want {
+				t.Errorf("body = %+v, want %+v", body, want)
+			}
+		})
+	}
+}
+
+func TestUnbookmarkClassRideIDFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		rideID     string
+		wantStatus int
+		wantCalls  int
+	}{
+		{"plausible", " 4f3b2a1c9d8e7f6a5b4c3d2e1f0a9b8c ", http.StatusOK, 1},
+		{"empty", " ", http.StatusBadRequest, 0},
+		{"too short", "abc", http.StatusBadRequest, 0},
+		{"not hex", "not-a-ride-id-not-a-ride-id-1234", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+			os.Setenv("peloton_url", server.URL)
+			defer os.Unsetenv("peloton_url")
+
+			request := events.APIGatewayV2HTTPRequest{Body: `{"ride_id": "` + tt.rideID + `"}`}
+			res, err := unbookmarkClass(context.Background(), request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			// Malformed ids are rejected before Peloton is called
+			if calls != tt.wantCalls {
+				t.Errorf("Peloton called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// bookmarksDB records the ids of the bookmarks deleted
+type bookmarksDB struct {
+	dynamodbiface.DynamoDBAPI
+	deleted []string
+}
+
+func (m *bookmarksDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	m.deleted = append(m.deleted, *input.Key["Id"].S)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestUnbookmarkClassSync(t *testing.T) {
+	tests := []struct {
+		name        string
+		upstream    int
+		wantDeleted int
+	}{
+		{"unbookmarked", http.StatusOK, 1},
+		{"not bookmarked", http.StatusNotFound, 1},
+		{"upstream failure", http.StatusInternalServerError, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.upstream)
+			}))
+			defer server.Close()
+
+			db := &bookmarksDB{}
+			newDB := shared.NewDB
+			shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+				return db
+			}
+			env := map[string]string{"peloton_url": server.URL, "table_region": "us-east-1", "bookmarks_table_name": "bookmarks"}
+			for k, v := range env {
+				os.Setenv(k, v)
+			}
+			defer func() {
+				shared.NewDB = newDB
+				for k := range env {
+					os.Unsetenv(k)
+				}
+			}()
+
+			request := events.APIGatewayV2HTTPRequest{
+				Headers: map[string]string{"UserID": "u1"},
+				Body:    `{"ride_id": "` + rideID + `"}`,
+			}
+			if _, err := unbookmarkClass(context.Background(), request); err != nil {
+				t.Fatal(err)
+			}
+			if len(db.deleted) != tt.wantDeleted {
+				t.Fatalf("%d bookmarks deleted, want %d", len(db.deleted), tt.wantDeleted)
+			}
+			if tt.wantDeleted > 0 && db.deleted[0] != "u1#"+rideID {
+				t.Errorf("deleted bookmark %s, want u1#%s", db.deleted[0], rideID)
+			}
+		})
+	}
+}
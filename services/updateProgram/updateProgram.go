@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// programPatch contains the fields to update on a program
+// a nil field is left unchanged
+type programPatch struct {
+	Name            *string             `json:"name"`
+	Description     *string             `json:"description"`
+	Public          *bool               `json:"public"`
+	EquipmentNeeded *[]string           `json:"equipmentNeeded"`
+	NumWeeks        *int                `json:"numWeeks"`
+	Workouts        *[][]shared.Workout `json:"workouts"`
+}
+
+func bodyValidation(patch *programPatch) error {
+	name, description, equipment := "", "", []string{}
+	if patch.Name != nil {
+		*patch.Name = shared.SanitizeText(*patch.Name)
+		if *patch.Name == "" {
+			return errors.New("name must not be empty")
+		}
+		name = *patch.Name
+	}
+	if patch.Description != nil {
+		*patch.Description = shared.SanitizeText(*patch.Description)
+		description = *patch.Description
+	}
+	if patch.EquipmentNeeded != nil {
+		*patch.EquipmentNeeded = shared.SanitizeEquipment(*patch.EquipmentNeeded)
+		equipment = *patch.EquipmentNeeded
+	}
+	if errs := shared.TextLimitErrors(name, description, equipment); len(errs) > 0 {
+		return errors.New(shared.FieldErrorsMessage(errs))
+	}
+	if patch.NumWeeks != nil && *patch.NumWeeks < 1 {
+		return errors.New("numWeeks must be a number greater than 0")
+	}
+	if patch.Workouts != nil && len(*patch.Workouts) < 1 {
+		return errors.New("workouts must not be empty")
+	}
+
+	return nil
+}
+
+// nameValidation verifies the updated name is still unique
+// public program names must be unique for all public programs, otherwise for the user's programs
+func nameValidation(current shared.Program, patch programPatch, tableName string, db dynamodbiface.DynamoDBAPI) (int, error) {
+	if patch.Name == nil && patch.Public == nil {
+		return -1, nil
+	}
+
+	name := current.Name
+	if patch.Name != nil {
+		name = *patch.Name
+	}
+	public := current.Public
+	if patch.Public != nil {
+		public = *patch.Public
+	}
+
+	scanInput := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+		ExpressionAttributeNames: map[string]*string{
+			"#N": aws.String("Name"),
+		},
+	}
+	if public {
+		scanInput.ExpressionAttributeNames["#P"] = aws.String("Public")
+		scanInput.FilterExpression = aws.String("#N = :name and #P = :public and Id <> :id and " + shared.NotDeletedFilter)
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":name":   {S: aws.String(name)},
+			":public": {BOOL: aws.Bool(true)},
+			":id":     {S: aws.String(current.ID)},
+		}
+	} else {
+		scanInput.FilterExpression = aws.String("#N = :name and CreatedBy = :createdBy and Id <> :id and " + shared.NotDeletedFilter)
+		scanInput.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":name":      {S: aws.String(name)},
+			":createdBy": {S: aws.String(current.CreatedBy)},
+			":id":        {S: aws.String(current.ID)},
+		}
+	}
+	items, err := shared.ScanAll(db, scanInput)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("Unable to get existing programs: %s", err.Error())
+	}
+
+	// If the scan returns any items, then that name can't be used
+	if len(items) > 0 {
+		return http.StatusBadRequest, fmt.Errorf("A program with the name %s already exists", name)
+	}
+
+	return -1, nil
+}
+
+// buildUpdate builds an UpdateExpression that only sets the fields present in the patch
+func buildUpdate(patch programPatch) (string, map[string]*string, map[string]*dynamodb.AttributeValue, error) {
+	sets := []string{"UpdatedDate = :updatedDate"}
+	removes := []string{}
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{
+		":updatedDate": {S: aws.String(time.Now().Format(time.RFC3339))},
+	}
+
+	if patch.Name != nil {
+		sets = append(sets, "#N = :name")
+		names["#N"] = aws.String("Name")
+		values[":name"] = &dynamodb.AttributeValue{S: aws.String(*patch.Name)}
+	}
+	if patch.Description != nil {
+		sets = append(sets, "Description = :description")
+		values[":description"] = &dynamodb.AttributeValue{S: aws.String(*patch.Description)}
+	}
+	if patch.Public != nil {
+		sets = append(sets, "#P = :public")
+		names["#P"] = aws.String("Public")
+		values[":public"] = &dynamodb.AttributeValue{BOOL: aws.Bool(*patch.Public)}
+	}
+	if patch.EquipmentNeeded != nil {
+		// DynamoDB doesn't allow empty string sets
+		if len(*patch.EquipmentNeeded) > 0 {
+			sets = append(sets, "EquipmentNeeded = :equipmentNeeded")
+			values[":equipmentNeeded"] = &dynamodb.AttributeValue{SS: aws.StringSlice(*patch.EquipmentNeeded)}
+		} else {
+			removes = append(removes, "EquipmentNeeded")
+		}
+	}
+	if patch.NumWeeks != nil {
+		sets = append(sets, "NumWeeks = :numWeeks")
+		values[":numWeeks"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(*patch.NumWeeks))}
+	}
+	if patch.Workouts != nil {
+		for _, week := range *patch.Workouts {
+			for idx := range week {
+				week[idx] = week[idx].WithoutPopularity()
+			}
+		}
+		workoutsData, err := json.Marshal(*patch.Workouts)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("Unable to marshal classes: %s", err)
+		}
+		sets = append(sets, "Workouts = :workouts", "ComputedDifficulty = :computedDifficulty")
+		values[":workouts"] = &dynamodb.AttributeValue{B: workoutsData}
+		values[":computedDifficulty"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(float64(shared.ComputeDifficulty(*patch.Workouts)), 'f', -1, 32))}
+	}
+
+	updateExpression := fmt.Sprintf("SET %s", strings.Join(sets, ", "))
+	if len(removes) > 0 {
+		updateExpression = fmt.Sprintf("%s REMOVE %s", updateExpression, strings.Join(removes, ", "))
+	}
+	if len(names) == 0 {
+		names = nil
+	}
+
+	return updateExpression, names, values, nil
+}
+
+// updateProgram updates only the fields of a program present in the request body
+func updateProgram(ctx context.Context, request events.APIGatewayV2HTTPRequest) (events.APIGatewayProxyResponse, error) {
+	// UserID header is required by shared.WithUserID
+	userID := shared.UserIDFromContext(ctx)
+
+	programID, _ := request.PathParameters["programId"]
+	programID = strings.TrimSpace(programID)
+	if programID == "" {
+		return shared.ErrorResponse(http.StatusBadRequest, "Path parameter programId is required: /updateProgram/{programId}"), nil
+	}
+	if err := shared.ValidateID("programId", programID); err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	tableRegion, tableName, err := shared.GetTableFor(shared.TablePrograms)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	// Parse request body
+	patch := programPatch{}
+	err = json.Unmarshal([]byte(request.Body), &patch)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, "Invalid request body"), nil
+	}
+
+	err = bodyValidation(&patch)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+	if patch.Workouts != nil {
+		// Workouts is stored as one blob, so it must fit in a DynamoDB item
+		workoutsData, err := json.Marshal(*patch.Workouts)
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: http.StatusInternalServerError,
+			}, fmt.Errorf("Unable to marshal classes: %s", err)
+		}
+		if err := shared.BlobSizeError("workouts", workoutsData); err != nil {
+			return shared.ErrorResponse(http.StatusRequestEntityTooLarge, err.Error()), nil
+		}
+	}
+
+	db := shared.GetDB(tableRegion)
+
+	getItemInput := &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(programID)},
+		},
+	}
+	getItemOutput, err := db.GetItem(getItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to get program: %s", err)), nil
+	}
+	if len(getItemOutput.Item) == 0 || shared.IsDeleted(getItemOutput.Item) || !shared.IsItemType(getItemOutput.Item, shared.ItemTypeProgram) {
+		return shared.ErrorResponse(http.StatusNotFound, fmt.Sprintf("Unable to find program %s", programID)), nil
+	}
+
+	current, err := shared.FormatProgram(getItemOutput.Item, false)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	if current.CreatedBy != userID {
+		return shared.ErrorResponse(http.StatusUnauthorized, "Must be the owner of the program to update it"), nil
+	}
+
+	if returnCode, err := nameValidation(current, patch, tableName, db); err != nil {
+		return shared.ErrorResponse(returnCode, err.Error()), nil
+	}
+
+	updateExpression, names, values, err := buildUpdate(patch)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+	values[":userID"] = &dynamodb.AttributeValue{S: aws.String(userID)}
+
+	updateItemInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"Id": {S: aws.String(programID)},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("CreatedBy = :userID"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	}
+	updateItemOutput, err := db.UpdateItem(updateItemInput)
+	if err != nil {
+		return shared.ErrorResponse(http.StatusInternalServerError, fmt.Sprintf("Unable to update program: %s", err)), nil
+	}
+
+	program, err := shared.FormatProgram(updateItemOutput.Attributes, true)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+		}, err
+	}
+
+	return shared.JSONResponse(http.StatusOK, program)
+}
+
+func main() {
+	lambda.Start(shared.Chain(updateProgram, shared.WithRequestID, shared.WithCORS, shared.RequireMethod(http.MethodPatch), shared.WithMetrics("updateProgram"), shared.WithRecovery, shared.WithLogging, shared.WithMaxBodySize, shared.WithJSONBody, shared.WithUserID))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doug2D2/pelodata-serverless/services/shared"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type mockDB struct {
+	dynamodbiface.DynamoDBAPI
+	items   map[string]map[string]*dynamodb.AttributeValue
+	updated bool
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (m *mockDB) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (m *mockDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[*input.Key["Id"].S]}, nil
+}
+
+// UpdateItem applies the name and dates set by buildUpdate to the stored item
+func (m *mockDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.updated = true
+	m.updates = append(m.updates, input)
+	item := m.items[*input.Key["Id"].S]
+	if v, ok := input.ExpressionAttributeValues[":name"]; ok {
+		item["Name"] = v
+	}
+	item["UpdatedDate"] = input.ExpressionAttributeValues[":updatedDate"]
+
+	return &dynamodb.UpdateItemOutput{Attributes: item}, nil
+}
+
+func TestBuildUpdate(t *testing.T) {
+	name := "Power Zone Builder"
+	public := false
+	equipment := []string{}
+	numWeeks := 4
+	workouts := [][]shared.Workout{{{ID: "ride1", Title: "20 min Ride", Difficulty: 7}}}
+
+	tests := []struct {
+		name        string
+		patch       programPatch
+		wantSets    []string
+		wantMissing []string
+		wantRemove  string
+	}{
+		{
+			name:        "name only leaves workouts untouched",
+			patch:       programPatch{Name: &name},
+			wantSets:    []string{"UpdatedDate = :updatedDate", "#N = :name"},
+			wantMissing: []string{"Workouts", "ComputedDifficulty", "Description", "NumWeeks"},
+		},
+		{
+			name:        "public and weeks",
+			patch:       programPatch{Public: &public, NumWeeks: &numWeeks},
+			wantSets:    []string{"#P = :public", "NumWeeks = :numWeeks"},
+			wantMissing: []string{"#N", "Workouts"},
+		},
+		{
+			name:     "workouts recompute the difficulty",
+			patch:    programPatch{Workouts: &workouts},
+			wantSets: []string{"Workouts = :workouts", "ComputedDifficulty = :computedDifficulty"},
+		},
+		{
+			name:        "empty equipment is removed",
+			patch:       programPatch{EquipmentNeeded: &equipment},
+			wantMissing: []string{":equipmentNeeded"},
+			wantRemove:  "REMOVE EquipmentNeeded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, _, values, err := buildUpdate(tt.patch)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, set := range tt.wantSets {
+				if !strings.Contains(expr, set) {
+					t.Errorf("expression %q doesn't set %s", expr, set)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(expr, missing) {
+					t.Errorf("expression %q shouldn't change %s", expr, missing)
+				}
+			}
+			if tt.wantRemove != "" && !strings.Contains(expr, tt.wantRemove) {
+				t.Errorf("expression %q doesn't have %s", expr, tt.wantRemove)
+			}
+			for k := range values {
+				if !strings.Contains(expr, k) {
+					t.Errorf("value %s isn't used by %q", k, expr)
+				}
+			}
+		})
+	}
+}
+
+const programID = "6f1c2a8e-3b4d-4e5f-9a0b-1c2d3e4f5a6b"
+
+// withMockDB points the handler at db, the returned func restores the real client and env
+func withMockDB(db dynamodbiface.DynamoDBAPI) func() {
+	newDB := shared.NewDB
+	shared.NewDB = func(region string) dynamodbiface.DynamoDBAPI {
+		return db
+	}
+	os.Setenv("table_region", "us-east-1")
+	os.Setenv("table_name", "pelodata")
+
+	return func() {
+		shared.NewDB = newDB
+		os.Unsetenv("table_region")
+		os.Unsetenv("table_name")
+	}
+}
+
+// callUpdateProgram calls the handler as user1 with the patch in body
+func callUpdateProgram(t *testing.T, id, body string) events.APIGatewayProxyResponse {
+	t.Helper()
+	request := events.APIGatewayV2HTTPRequest{
+		Headers:        map[string]string{"UserID": "user1"},
+		PathParameters: map[string]string{"programId": id},
+		Body:           body,
+	}
+	res, err := shared.WithUserID(updateProgram)(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res
+}
+
+func TestUpdateProgramNotFound(t *testing.T) {
+	tests := []struct {
+		name       string
+		programID  string
+		item       map[string]*dynamodb.AttributeValue
+		wantStatus int
+	}{
+		{"invalid id", "program1", nil, http.StatusBadRequest},
+		{"unknown id", programID, nil, http.StatusNotFound},
+		{"challenge id", programID, map[string]*dynamodb.AttributeValue{
+			"Id":        {S: aws.String(programID)},
+			"Type":      {S: aws.String(shared.ItemTypeChallenge)},
+			"CreatedBy": {S: aws.String("user1")},
+			"Name":      {S: aws.String("Ride Week")},
+		}, http.StatusNotFound},
+		{"deleted program", programID, map[string]*dynamodb.AttributeValue{
+			"Id":        {S: aws.String(programID)},
+			"Type":      {S: aws.String(shared.ItemTypeProgram)},
+			"CreatedBy": {S: aws.String("user1")},
+			"Name":      {S: aws.String("Power Zone Builder")},
+			"DeletedAt": {S: aws.String("2024-05-10T00:00:00Z")},
+		}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{items: map[string]map[string]*dynamodb.AttributeValue{programID: tt.item}}
+			defer withMockDB(db)()
+
+			res := callUpdateProgram(t, tt.programID, `{"name": "Renamed"}`)
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d: %s", res.StatusCode, tt.wantStatus, res.Body)
+			}
+			if db.updated {
+				t.Error("the item was updated")
+			}
+		})
+	}
+}
+
+func TestUpdateProgramAuditDates(t *testing.T) {
+	const created = "2024-01-01T00:00:00Z"
+	db := &mockDB{items: map[string]map[string]*dynamodb.AttributeValue{programID: {
+		"Id":          {S: aws.String(programID)},
+		"Type":        {S: aws.String(shared.ItemTypeProgram)},
+		"CreatedBy":   {S: aws.String("user1")},
+		"Name":        {S: aws.String("Power Zone Builder")},
+		"CreatedDate": {S: aws.String(created)},
+		"UpdatedDate": {S: aws.String(created)},
+	}}}
+	defer withMockDB(db)()
+
+	res := callUpdateProgram(t, programID, `{"name": "Renamed"}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("StatusCode = %d, want 200: %s", res.StatusCode, res.Body)
+	}
+	if strings.Contains(*db.updates[0].UpdateExpression, "CreatedDate") {
+		t.Errorf("UpdateExpression %q changes CreatedDate", *db.updates[0].UpdateExpression)
+	}
+
+	program := shared.Program{}
+	if err := json.Unmarshal([]byte(res.Body), &program); err != nil {
+		t.Fatal(err)
+	}
+	if program.CreatedDate != created {
+		t.Errorf("createdDate = %s, want %s", program.CreatedDate, created)
+	}
+	updated, err := time.Parse(time.RFC3339, program.UpdatedDate)
+	if err != nil || !updated.After(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("updatedDate = %s, want the time of the edit", program.UpdatedDate)
+	}
+}